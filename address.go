@@ -0,0 +1,696 @@
+package epgc
+
+import (
+	"database/sql"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// Address fields used for grouping
+const (
+	AddressRegion     = "region"
+	AddressDistrict   = "district"
+	AddressSettlement = "settlement"
+)
+
+// Address - structured address, every part keeps its normalized type like "г. Волгоград", "ул. Ленина", "д. 5"
+type Address struct {
+	ID         int64   `sql:"id" json:"id"`
+	CompanyID  int64   `sql:"company_id, null" json:"company_id"`
+	SirenID    int64   `sql:"siren_id, null" json:"siren_id"`
+	PostalCode string  `sql:"postal_code, null" json:"postal_code"`
+	Region     string  `sql:"region, null" json:"region"`
+	District   string  `sql:"district, null" json:"district"`
+	Settlement string  `sql:"settlement, null" json:"settlement"`
+	Street     string  `sql:"street, null" json:"street"`
+	House      string  `sql:"house, null" json:"house"`
+	Building   string  `sql:"building, null" json:"building"`
+	Other      string  `sql:"other, null" json:"other"`
+	Source     string  `sql:"source, null" json:"source"`
+	Precision  string  `sql:"precision, null" json:"precision"`
+	Latitude   float64 `sql:"latitude, null" json:"latitude"`
+	Longitude  float64 `sql:"longitude, null" json:"longitude"`
+	CreatedAt  string  `sql:"created_at" json:"created_at"`
+	UpdatedAt  string  `sql:"updated_at" json:"updated_at"`
+}
+
+// AddressGroup - companies or sirens with same district, settlement or region
+type AddressGroup struct {
+	Name  string       `json:"name"`
+	Items []SelectItem `json:"items"`
+}
+
+const (
+	addressRegion = iota + 1
+	addressDistrict
+	addressSettlement
+	addressStreet
+	addressHouse
+	addressBuilding
+	addressFlat
+)
+
+type addressWord struct {
+	kind      int
+	canonical string
+}
+
+// addressWords - abbreviations and full names of address parts in lower case without dot
+var addressWords = map[string]addressWord{
+	"обл":           {addressRegion, "обл."},
+	"область":       {addressRegion, "обл."},
+	"край":          {addressRegion, "край"},
+	"респ":          {addressRegion, "Респ."},
+	"республика":    {addressRegion, "Респ."},
+	"ао":            {addressRegion, "АО"},
+	"р-н":           {addressDistrict, "р-н"},
+	"район":         {addressDistrict, "р-н"},
+	"м.р-н":         {addressDistrict, "р-н"},
+	"го":            {addressDistrict, "г.о."},
+	"г.о":           {addressDistrict, "г.о."},
+	"г":             {addressSettlement, "г."},
+	"гор":           {addressSettlement, "г."},
+	"город":         {addressSettlement, "г."},
+	"с":             {addressSettlement, "с."},
+	"село":          {addressSettlement, "с."},
+	"п":             {addressSettlement, "п."},
+	"пос":           {addressSettlement, "п."},
+	"поселок":       {addressSettlement, "п."},
+	"посёлок":       {addressSettlement, "п."},
+	"рп":            {addressSettlement, "р.п."},
+	"р.п":           {addressSettlement, "р.п."},
+	"пгт":           {addressSettlement, "пгт"},
+	"п.г.т":         {addressSettlement, "пгт"},
+	"х":             {addressSettlement, "х."},
+	"хутор":         {addressSettlement, "х."},
+	"ст-ца":         {addressSettlement, "ст-ца"},
+	"станица":       {addressSettlement, "ст-ца"},
+	"дер":           {addressSettlement, "д."},
+	"деревня":       {addressSettlement, "д."},
+	"ул":            {addressStreet, "ул."},
+	"улица":         {addressStreet, "ул."},
+	"пр":            {addressStreet, "пр-кт"},
+	"пр-т":          {addressStreet, "пр-кт"},
+	"пр-кт":         {addressStreet, "пр-кт"},
+	"просп":         {addressStreet, "пр-кт"},
+	"проспект":      {addressStreet, "пр-кт"},
+	"пер":           {addressStreet, "пер."},
+	"переулок":      {addressStreet, "пер."},
+	"б-р":           {addressStreet, "б-р"},
+	"бульвар":       {addressStreet, "б-р"},
+	"ш":             {addressStreet, "ш."},
+	"шоссе":         {addressStreet, "ш."},
+	"пл":            {addressStreet, "пл."},
+	"площадь":       {addressStreet, "пл."},
+	"наб":           {addressStreet, "наб."},
+	"набережная":    {addressStreet, "наб."},
+	"пр-д":          {addressStreet, "пр-д"},
+	"проезд":        {addressStreet, "пр-д"},
+	"туп":           {addressStreet, "туп."},
+	"тупик":         {addressStreet, "туп."},
+	"мкр":           {addressStreet, "мкр"},
+	"микрорайон":    {addressStreet, "мкр"},
+	"кв-л":          {addressStreet, "кв-л"},
+	"квартал":       {addressStreet, "кв-л"},
+	"дом":           {addressHouse, "д."},
+	"влд":           {addressHouse, "влд."},
+	"владение":      {addressHouse, "влд."},
+	"к":             {addressBuilding, "корп."},
+	"корп":          {addressBuilding, "корп."},
+	"корпус":        {addressBuilding, "корп."},
+	"стр":           {addressBuilding, "стр."},
+	"строение":      {addressBuilding, "стр."},
+	"лит":           {addressBuilding, "лит."},
+	"литер":         {addressBuilding, "лит."},
+	"литера":        {addressBuilding, "лит."},
+	"кв":            {addressFlat, "кв."},
+	"квартира":      {addressFlat, "кв."},
+	"оф":            {addressFlat, "оф."},
+	"офис":          {addressFlat, "оф."},
+	"помещение":     {addressFlat, "пом."},
+	"пом":           {addressFlat, "пом."},
+	"каб":           {addressFlat, "каб."},
+	"кабинет":       {addressFlat, "каб."},
+	"муниципальный": {addressDistrict, ""},
+}
+
+var (
+	// addressDotRe - word glued to next one by dot, like "ул.Ленина"
+	addressDotRe = regexp.MustCompile(`([А-Яа-яЁё])\.([А-Яа-яЁё0-9])`)
+	// addressGluedRe - type glued to number, like "д5", "корп2", "к.2"
+	addressGluedRe = regexp.MustCompile(`^(д|дом|к|корп|стр|лит|кв|оф)\.?(\d.*)$`)
+	// addressHouseRe - house glued to building, like "5к2"
+	addressHouseRe  = regexp.MustCompile(`^(\d+[а-яё]?)(к|корп|стр)\.?(\d.*)$`)
+	addressNumberRe = regexp.MustCompile(`^\d+[а-яА-ЯёЁ]?([/-]\d+[а-яА-ЯёЁ]?)?$`)
+	addressPostalRe = regexp.MustCompile(`^\d{6}$`)
+)
+
+// addressComposites - abbreviations with dots inside which are not split into words
+var addressComposites = strings.NewReplacer("р.п.", "рп ", "Р.п.", "рп ", "п.г.т.", "пгт ", "г.о.", "го ", "м.р-н", "р-н")
+
+func addressKey(word string) string {
+	return strings.TrimSuffix(strings.ToLower(word), ".")
+}
+
+// addressTokens - words of part of address, glued types are split from names and numbers
+func addressTokens(part string) []string {
+	part = addressComposites.Replace(part)
+	part = addressDotRe.ReplaceAllString(part, "$1. $2")
+	var tokens []string
+	for _, word := range strings.Fields(part) {
+		if m := addressGluedRe.FindStringSubmatch(strings.ToLower(word)); m != nil {
+			tokens = append(tokens, m[1])
+			word = word[len(word)-len(m[2]):]
+		}
+		if m := addressHouseRe.FindStringSubmatch(strings.ToLower(word)); m != nil {
+			tokens = append(tokens, m[1], m[2], m[3])
+			continue
+		}
+		tokens = append(tokens, word)
+	}
+	return tokens
+}
+
+func (a *Address) set(kind int, value string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+	var field *string
+	switch kind {
+	case addressRegion:
+		field = &a.Region
+	case addressDistrict:
+		field = &a.District
+	case addressSettlement:
+		field = &a.Settlement
+	case addressStreet:
+		field = &a.Street
+	case addressHouse:
+		field = &a.House
+	case addressBuilding:
+		if a.Building != "" {
+			a.Building += ", " + value
+			return
+		}
+		field = &a.Building
+	default:
+		field = &a.Other
+	}
+	if *field != "" {
+		a.Other = strings.TrimPrefix(a.Other+", "+value, ", ")
+		return
+	}
+	*field = value
+}
+
+// parsePart - parse one comma separated part of address
+func (a *Address) parsePart(part string) {
+	tokens := addressTokens(part)
+	if len(tokens) == 0 {
+		return
+	}
+	if len(tokens) == 1 && addressPostalRe.MatchString(tokens[0]) {
+		a.PostalCode = tokens[0]
+		return
+	}
+	// name followed by type, like "Волгоградская обл." or "Ленина ул."
+	if last, ok := addressWords[addressKey(tokens[len(tokens)-1])]; ok && len(tokens) > 1 {
+		if _, first := addressWords[addressKey(tokens[0])]; !first {
+			var names []string
+			for _, token := range tokens[:len(tokens)-1] {
+				if word, ok := addressWords[addressKey(token)]; !ok || word.canonical != "" {
+					names = append(names, token)
+				}
+			}
+			name := strings.Join(names, " ")
+			if last.kind == addressRegion || last.kind == addressDistrict {
+				a.set(last.kind, name+" "+last.canonical)
+			} else {
+				a.set(last.kind, last.canonical+" "+name)
+			}
+			return
+		}
+	}
+	var (
+		kind  int
+		label string
+		words []string
+	)
+	flush := func() {
+		if len(words) == 0 {
+			return
+		}
+		value := strings.Join(words, " ")
+		switch {
+		case kind != 0 && label != "":
+			a.set(kind, label+" "+value)
+		case kind != 0:
+			a.set(kind, value)
+		case addressNumberRe.MatchString(value) && a.House == "":
+			a.set(addressHouse, "д. "+value)
+		case addressNumberRe.MatchString(value):
+			a.set(addressBuilding, "корп. "+value)
+		case a.Settlement == "" && a.Street == "":
+			a.set(addressSettlement, value)
+		case a.Street == "":
+			a.set(addressStreet, value)
+		default:
+			a.set(0, value)
+		}
+		words = nil
+	}
+	for _, token := range tokens {
+		key := addressKey(token)
+		word, ok := addressWords[key]
+		// "д." is house after street and village before it
+		if key == "д" {
+			ok = true
+			word = addressWord{addressHouse, "д."}
+			if a.Street == "" && kind != addressStreet && a.Settlement == "" {
+				word = addressWord{addressSettlement, "д."}
+			}
+		}
+		switch {
+		case ok && word.canonical == "":
+			continue
+		case ok:
+			flush()
+			kind, label = word.kind, word.canonical
+		case (kind == addressStreet || kind == addressSettlement || kind == 0) && len(words) > 0 && addressNumberRe.MatchString(token):
+			// house number after street name without type, like "ул. Ленина 5"
+			flush()
+			kind, label = addressHouse, "д."
+			words = append(words, token)
+		case (kind == addressHouse || kind == addressBuilding || kind == addressFlat) && len(words) > 0:
+			flush()
+			kind, label = 0, ""
+			words = append(words, token)
+		default:
+			words = append(words, token)
+		}
+	}
+	flush()
+}
+
+// ParseAddress - parse address in common russian notation like
+// "400001, Волгоградская обл., г. Волгоград, ул. Ленина, д. 5, корп. 2"
+func ParseAddress(text string) Address {
+	var a Address
+	for _, part := range strings.Split(text, ",") {
+		a.parsePart(strings.TrimSpace(part))
+	}
+	return a
+}
+
+// NormalizeAddress - address with normalized abbreviations and order of parts
+func NormalizeAddress(text string) string {
+	return ParseAddress(text).String()
+}
+
+// String - address in normalized notation
+func (a Address) String() string {
+	var parts []string
+	for _, part := range []string{a.PostalCode, a.Region, a.District, a.Settlement, a.Street, a.House, a.Building, a.Other} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// IsEmpty - address without parts and coordinates
+func (a Address) IsEmpty() bool {
+	return a.String() == "" && a.Latitude == 0 && a.Longitude == 0
+}
+
+func f2n(val float64) sql.NullFloat64 {
+	var f2n sql.NullFloat64
+	if val == 0 {
+		f2n.Valid = false
+	} else {
+		f2n.Valid = true
+		f2n.Float64 = val
+	}
+	return f2n
+}
+
+func n2f(val sql.NullFloat64) float64 {
+	return val.Float64
+}
+
+func scanAddress(row *sql.Row) (Address, error) {
+	var (
+		sID         sql.NullInt64
+		sCompanyID  sql.NullInt64
+		sSirenID    sql.NullInt64
+		sPostalCode sql.NullString
+		sRegion     sql.NullString
+		sDistrict   sql.NullString
+		sSettlement sql.NullString
+		sStreet     sql.NullString
+		sHouse      sql.NullString
+		sBuilding   sql.NullString
+		sOther      sql.NullString
+		sSource     sql.NullString
+		sPrecision  sql.NullString
+		sLatitude   sql.NullFloat64
+		sLongitude  sql.NullFloat64
+		address     Address
+	)
+	err := row.Scan(&sID, &sCompanyID, &sSirenID, &sPostalCode, &sRegion, &sDistrict, &sSettlement, &sStreet, &sHouse, &sBuilding, &sOther, &sSource, &sPrecision, &sLatitude, &sLongitude)
+	if err != nil {
+		return address, err
+	}
+	address.ID = n2i(sID)
+	address.CompanyID = n2i(sCompanyID)
+	address.SirenID = n2i(sSirenID)
+	address.PostalCode = n2s(sPostalCode)
+	address.Region = n2s(sRegion)
+	address.District = n2s(sDistrict)
+	address.Settlement = n2s(sSettlement)
+	address.Street = n2s(sStreet)
+	address.House = n2s(sHouse)
+	address.Building = n2s(sBuilding)
+	address.Other = n2s(sOther)
+	address.Source = n2s(sSource)
+	address.Precision = n2s(sPrecision)
+	address.Latitude = n2f(sLatitude)
+	address.Longitude = n2f(sLongitude)
+	return address, nil
+}
+
+// getAddress - address of company or siren, owner is company_id or siren_id
+func (e *Edb) getAddress(owner string, id int64) (Address, error) {
+	if id == 0 {
+		return Address{}, nil
+	}
+	row := e.db.QueryRow(`
+		SELECT
+			id,
+			company_id,
+			siren_id,
+			postal_code,
+			region,
+			district,
+			settlement,
+			street,
+			house,
+			building,
+			other,
+			source,
+			precision,
+			latitude,
+			longitude
+		FROM
+			addresses
+		WHERE
+			`+owner+` = $1
+		ORDER BY
+			id ASC
+		LIMIT 1
+	`, id)
+	address, err := scanAddress(row)
+	if err == sql.ErrNoRows {
+		return Address{}, nil
+	}
+	if err != nil {
+		log.Println("getAddress scanAddress ", err)
+	}
+	return address, err
+}
+
+// GetCompanyAddress - get structured address of company
+func (e *Edb) GetCompanyAddress(id int64) (Address, error) {
+	return e.getAddress("company_id", id)
+}
+
+// GetSirenAddress - get structured address of siren
+func (e *Edb) GetSirenAddress(id int64) (Address, error) {
+	return e.getAddress("siren_id", id)
+}
+
+// saveAddress - replace address of company or siren, address is parsed from text when it has no parts
+// or text differs from saved one
+func (e *Edb) saveAddress(owner string, id int64, address Address, text string) error {
+	if id == 0 {
+		return nil
+	}
+	old, err := e.getAddress(owner, id)
+	if err != nil {
+		return err
+	}
+	// parts of loaded entity are the same as saved ones, they are parsed again when text of address is edited
+	if address.String() == "" || text != old.Source && address.String() == old.String() {
+		parsed := ParseAddress(text)
+		parsed.Latitude, parsed.Longitude = address.Latitude, address.Longitude
+		address = parsed
+	}
+	// coordinates found before are kept with their precision while address is the same
+	if address.Latitude == 0 && address.Longitude == 0 && old.String() == address.String() {
+		address.Latitude, address.Longitude, address.Precision = old.Latitude, old.Longitude, old.Precision
+	}
+	_, err = e.db.Exec(`
+		DELETE FROM
+			addresses
+		WHERE
+			`+owner+` = $1
+	`, id)
+	if err != nil {
+		log.Println("saveAddress e.db.Exec ", err)
+		return err
+	}
+	if address.IsEmpty() {
+		return nil
+	}
+	_, err = e.db.Exec(`
+		INSERT INTO
+			addresses (
+				`+owner+`,
+				postal_code,
+				region,
+				district,
+				settlement,
+				street,
+				house,
+				building,
+				other,
+				source,
+				precision,
+				latitude,
+				longitude,
+				created_at
+			) VALUES (
+				$1,
+				$2,
+				$3,
+				$4,
+				$5,
+				$6,
+				$7,
+				$8,
+				$9,
+				$10,
+				$11,
+				$12,
+				$13,
+				now()
+			)
+	`,
+		id,
+		s2n(address.PostalCode),
+		s2n(address.Region),
+		s2n(address.District),
+		s2n(address.Settlement),
+		s2n(address.Street),
+		s2n(address.House),
+		s2n(address.Building),
+		s2n(address.Other),
+		s2n(text),
+		s2n(address.Precision),
+		f2n(address.Latitude),
+		f2n(address.Longitude))
+	if err != nil {
+		log.Println("saveAddress e.db.Exec ", err)
+	}
+	return err
+}
+
+// deleteAddress - delete address of company or siren
+func (e *Edb) deleteAddress(owner string, id int64) error {
+	_, err := e.db.Exec(`
+		DELETE FROM
+			addresses
+		WHERE
+			`+owner+` = $1
+	`, id)
+	if err != nil {
+		log.Println("deleteAddress e.db.Exec ", owner, id, err)
+	}
+	return err
+}
+
+func addressGroupColumn(field string) string {
+	switch field {
+	case AddressRegion, AddressSettlement:
+		return field
+	default:
+		return AddressDistrict
+	}
+}
+
+func scanAddressGroups(rows *sql.Rows) ([]AddressGroup, error) {
+	var groups []AddressGroup
+	for rows.Next() {
+		var (
+			sGroup sql.NullString
+			sID    sql.NullInt64
+			sName  sql.NullString
+		)
+		err := rows.Scan(&sGroup, &sID, &sName)
+		if err != nil {
+			log.Println("scanAddressGroups rows.Scan ", err)
+			return groups, err
+		}
+		if len(groups) == 0 || groups[len(groups)-1].Name != n2s(sGroup) {
+			groups = append(groups, AddressGroup{Name: n2s(sGroup)})
+		}
+		last := &groups[len(groups)-1]
+		last.Items = append(last.Items, SelectItem{ID: n2i(sID), Name: n2s(sName)})
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Println("scanAddressGroups rows.Err ", err)
+	}
+	return groups, err
+}
+
+// GetCompanyAddressGroups - get companies grouped by district (AddressDistrict), settlement or region,
+// companies without this part of address are in last group with empty name
+func (e *Edb) GetCompanyAddressGroups(field string) ([]AddressGroup, error) {
+	column := addressGroupColumn(field)
+	rows, err := e.db.Query(`
+		SELECT
+			COALESCE(a.` + column + `, '') AS group_name,
+			c.id,
+			c.name
+		FROM
+			companies AS c
+		LEFT JOIN
+			addresses AS a ON a.company_id = c.id
+		ORDER BY
+			a.` + column + ` IS NULL,
+			group_name ASC,
+			c.name ASC
+	`)
+	if err != nil {
+		log.Println("GetCompanyAddressGroups e.db.Query ", err)
+		return []AddressGroup{}, err
+	}
+	return scanAddressGroups(rows)
+}
+
+// GetSirenAddressGroups - get sirens grouped by district (AddressDistrict), settlement or region,
+// name of siren is its address
+func (e *Edb) GetSirenAddressGroups(field string) ([]AddressGroup, error) {
+	column := addressGroupColumn(field)
+	rows, err := e.db.Query(`
+		SELECT
+			COALESCE(a.` + column + `, '') AS group_name,
+			s.id,
+			s.address
+		FROM
+			sirens AS s
+		LEFT JOIN
+			addresses AS a ON a.siren_id = s.id
+		ORDER BY
+			a.` + column + ` IS NULL,
+			group_name ASC,
+			s.num_id ASC
+	`)
+	if err != nil {
+		log.Println("GetSirenAddressGroups e.db.Query ", err)
+		return []AddressGroup{}, err
+	}
+	return scanAddressGroups(rows)
+}
+
+// ParseAllAddresses - fill structured addresses of companies and sirens which have only text address
+func (e *Edb) ParseAllAddresses() error {
+	for _, owner := range []struct {
+		table  string
+		column string
+	}{{"companies", "company_id"}, {"sirens", "siren_id"}} {
+		rows, err := e.db.Query(`
+			SELECT
+				t.id,
+				t.address
+			FROM
+				` + owner.table + ` AS t
+			WHERE
+				COALESCE(t.address, '') <> '' AND NOT EXISTS (SELECT 1 FROM addresses AS a WHERE a.` + owner.column + ` = t.id)
+		`)
+		if err != nil {
+			log.Println("ParseAllAddresses e.db.Query ", err)
+			return err
+		}
+		texts := make(map[int64]string)
+		for rows.Next() {
+			var (
+				id   int64
+				text string
+			)
+			err = rows.Scan(&id, &text)
+			if err != nil {
+				rows.Close()
+				log.Println("ParseAllAddresses rows.Scan ", err)
+				return err
+			}
+			texts[id] = text
+		}
+		rows.Close()
+		for id, text := range texts {
+			err = e.saveAddress(owner.column, id, Address{}, text)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Edb) addressCreateTable() error {
+	str := `
+		CREATE TABLE IF NOT EXISTS
+			addresses (
+				id bigserial primary key,
+				company_id bigint,
+				siren_id bigint,
+				postal_code text,
+				region text,
+				district text,
+				settlement text,
+				street text,
+				house text,
+				building text,
+				other text,
+				source text,
+				precision text,
+				latitude double precision,
+				longitude double precision,
+				created_at TIMESTAMP without time zone,
+				updated_at TIMESTAMP without time zone
+			);
+		ALTER TABLE addresses ADD COLUMN IF NOT EXISTS source text;
+		ALTER TABLE addresses ADD COLUMN IF NOT EXISTS precision text;
+		CREATE INDEX IF NOT EXISTS addresses_company_id_idx ON addresses (company_id);
+		CREATE INDEX IF NOT EXISTS addresses_siren_id_idx ON addresses (siren_id);
+		CREATE INDEX IF NOT EXISTS addresses_district_idx ON addresses (district);
+	`
+	_, err := e.db.Exec(str)
+	if err != nil {
+		log.Println("addressCreateTable e.db.Exec ", err)
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package epgc
+
+import "testing"
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		text string
+		want Address
+	}{
+		{
+			"400001, Волгоградская обл., г. Волгоград, ул. Ленина, д. 5, корп. 2",
+			Address{PostalCode: "400001", Region: "Волгоградская обл.", Settlement: "г. Волгоград", Street: "ул. Ленина", House: "д. 5", Building: "корп. 2"},
+		},
+		{
+			"г.Волжский, ул.Мира д.12 к.1",
+			Address{Settlement: "г. Волжский", Street: "ул. Мира", House: "д. 12", Building: "корп. 1"},
+		},
+		{
+			"Волгоградская область, Городищенский муниципальный район, р.п. Городище, пл. Павших Борцов, 1а",
+			Address{Region: "Волгоградская обл.", District: "Городищенский р-н", Settlement: "р.п. Городище", Street: "пл. Павших Борцов", House: "д. 1а"},
+		},
+		{
+			"Волгоград, Ленина 5",
+			Address{Settlement: "Волгоград", Street: "Ленина", House: "д. 5"},
+		},
+		{
+			"Камышинский район, д. Ивановка, Садовая улица, дом 3, строение 2, кв. 10",
+			Address{District: "Камышинский р-н", Settlement: "д. Ивановка", Street: "ул. Садовая", House: "д. 3", Building: "стр. 2", Other: "кв. 10"},
+		},
+		{
+			"Респ. Татарстан, город Казань, проспект Победы, д5к2",
+			Address{Region: "Респ. Татарстан", Settlement: "г. Казань", Street: "пр-кт Победы", House: "д. 5", Building: "корп. 2"},
+		},
+		{
+			"",
+			Address{},
+		},
+	}
+	for _, tc := range tests {
+		got := ParseAddress(tc.text)
+		if got != tc.want {
+			t.Errorf("ParseAddress(%q) =\n%+v, want\n%+v", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestNormalizeAddress(t *testing.T) {
+	got := NormalizeAddress("г Волгоград , улица Мира , дом 7 , корпус 1")
+	want := "г. Волгоград, ул. Мира, д. 7, корп. 1"
+	if got != want {
+		t.Errorf("NormalizeAddress = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,483 @@
+package epgc
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Restore modes
+const (
+	// RestoreMerge - keep existing rows, rows of backup matching existing ones by natural key are not inserted
+	RestoreMerge = "merge"
+	// RestoreReplace - delete all rows before restore
+	RestoreReplace = "replace"
+)
+
+// BackupVersion - version of backup format written by Backup, Restore reads this and older versions
+const BackupVersion = 1
+
+const backupFormat = "epgc-backup"
+
+// backupTable - table in backup, refs are columns with id of row in other or same table,
+// key is natural key used to find existing row in merge mode
+type backupTable struct {
+	name string
+	refs map[string]string
+	key  []string
+}
+
+// backupTables - tables in order of restore, referenced tables go first
+var backupTables = []backupTable{
+	{name: "scopes", key: []string{"name"}},
+	{name: "posts", key: []string{"name", "go"}},
+	{name: "ranks", key: []string{"name"}},
+	{name: "kinds", key: []string{"name"}},
+	{name: "sirentypes", key: []string{"name", "radius"}},
+	{name: "educations", key: []string{"start_date", "end_date"}},
+	{
+		name: "companies",
+		refs: map[string]string{"scope_id": "scopes", "parent_id": "companies"},
+		key:  []string{"name", "scope_id"},
+	},
+	{
+		name: "departments",
+		refs: map[string]string{"company_id": "companies", "parent_id": "departments"},
+		key:  []string{"name", "company_id", "parent_id"},
+	},
+	{
+		name: "contacts",
+		refs: map[string]string{
+			"company_id":    "companies",
+			"department_id": "departments",
+			"post_id":       "posts",
+			"post_go_id":    "posts",
+			"rank_id":       "ranks",
+		},
+		key: []string{"name", "birthday"},
+	},
+	{
+		name: "emails",
+		refs: map[string]string{"company_id": "companies", "contact_id": "contacts"},
+		key:  []string{"company_id", "contact_id", "email"},
+	},
+	{
+		name: "phones",
+		refs: map[string]string{"company_id": "companies", "contact_id": "contacts"},
+		key:  []string{"company_id", "contact_id", "phone", "fax"},
+	},
+	{
+		name: "practices",
+		refs: map[string]string{"company_id": "companies", "kind_id": "kinds"},
+		key:  []string{"company_id", "kind_id", "date_of_practice"},
+	},
+	{
+		name: "practice_participants",
+		refs: map[string]string{"practice_id": "practices", "company_id": "companies", "contact_id": "contacts"},
+		key:  []string{"practice_id", "company_id", "contact_id"},
+	},
+	{
+		name: "practice_documents",
+		refs: map[string]string{"practice_id": "practices"},
+		key:  []string{"practice_id", "name", "size"},
+	},
+	{
+		name: "sirens",
+		refs: map[string]string{"type_id": "sirentypes", "contact_id": "contacts", "company_id": "companies"},
+		key:  []string{"num_id", "num_pass", "type_id"},
+	},
+	{
+		name: "addresses",
+		refs: map[string]string{"company_id": "companies", "siren_id": "sirens"},
+		key:  []string{"company_id", "siren_id"},
+	},
+	{name: "notification_lists", key: []string{"name"}},
+	{
+		name: "notification_members",
+		refs: map[string]string{"list_id": "notification_lists", "contact_id": "contacts", "company_id": "companies"},
+		key:  []string{"list_id", "contact_id", "company_id"},
+	},
+	{
+		name: "call_tree_nodes",
+		refs: map[string]string{
+			"list_id":    "notification_lists",
+			"parent_id":  "call_tree_nodes",
+			"contact_id": "contacts",
+			"company_id": "companies",
+		},
+		key: []string{"list_id", "parent_id", "contact_id", "company_id"},
+	},
+	{
+		name: "dispatches",
+		refs: map[string]string{"list_id": "notification_lists"},
+		key:  []string{"created_at", "subject"},
+	},
+	{
+		name: "dispatch_deliveries",
+		refs: map[string]string{"dispatch_id": "dispatches", "contact_id": "contacts", "company_id": "companies"},
+		key:  []string{"ack_token"},
+	},
+	{name: "incident_types", key: []string{"name"}},
+	{
+		name: "incidents",
+		refs: map[string]string{"type_id": "incident_types"},
+		key:  []string{"received_at", "type_id", "description"},
+	},
+	{
+		name: "incident_participants",
+		refs: map[string]string{"incident_id": "incidents", "company_id": "companies", "contact_id": "contacts"},
+		key:  []string{"incident_id", "company_id", "contact_id"},
+	},
+	{
+		name: "incident_actions",
+		refs: map[string]string{"incident_id": "incidents", "contact_id": "contacts"},
+		key:  []string{"incident_id", "action_at", "description"},
+	},
+	{name: "shift_templates", key: []string{"name"}},
+	{
+		name: "shifts",
+		refs: map[string]string{"template_id": "shift_templates", "contact_id": "contacts"},
+		key:  []string{"contact_id", "starts_at"},
+	},
+	{
+		name: "shift_handovers",
+		refs: map[string]string{"shift_id": "shifts"},
+		key:  []string{"shift_id"},
+	},
+	{
+		name: "shift_handover_incidents",
+		refs: map[string]string{"handover_id": "shift_handovers", "incident_id": "incidents"},
+		key:  []string{"handover_id", "incident_id"},
+	},
+}
+
+// backupFile - backup document, every row is json object with columns of table
+type backupFile struct {
+	Format    string                       `json:"format"`
+	Version   int                          `json:"version"`
+	CreatedAt string                       `json:"created_at"`
+	Tables    map[string][]json.RawMessage `json:"tables"`
+}
+
+// RestoreReport - result of restore, number of rows by table
+type RestoreReport struct {
+	Mode     string         `json:"mode"`
+	Version  int            `json:"version"`
+	Inserted map[string]int `json:"inserted"`
+	Matched  map[string]int `json:"matched"`
+	Skipped  []string       `json:"skipped"`
+}
+
+// Backup - write all rows of all tables as versioned json, rows are read in one snapshot
+func (e *Edb) Backup(w io.Writer) error {
+	backup := backupFile{
+		Format:    backupFormat,
+		Version:   BackupVersion,
+		CreatedAt: time.Now().Format(time.RFC3339),
+		Tables:    make(map[string][]json.RawMessage),
+	}
+	tx, err := e.db.Begin()
+	if err != nil {
+		log.Println("Backup e.db.Begin ", err)
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	_, err = tx.Exec(`SET TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY`)
+	if err != nil {
+		log.Println("Backup tx.Exec ", err)
+		return err
+	}
+	for _, table := range backupTables {
+		rows, err := backupRows(tx, table.name)
+		if err != nil {
+			log.Println("Backup backupRows ", table.name, err)
+			return err
+		}
+		backup.Tables[table.name] = rows
+	}
+	err = json.NewEncoder(w).Encode(backup)
+	if err != nil {
+		log.Println("Backup Encode ", err)
+	}
+	return err
+}
+
+func backupRows(tx *instrumentedTx, table string) ([]json.RawMessage, error) {
+	rows, err := tx.Query(`SELECT row_to_json(t) FROM ` + table + ` AS t ORDER BY t.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []json.RawMessage{}
+	for rows.Next() {
+		var row []byte
+		err = rows.Scan(&row)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, json.RawMessage(row))
+	}
+	return list, rows.Err()
+}
+
+// restorer - restore of rows in one transaction, ids maps id in backup to id in database for every table
+type restorer struct {
+	tx      *instrumentedTx
+	merge   bool
+	ids     map[string]map[int64]int64
+	report  *RestoreReport
+	columns map[string]bool
+	links   []backupLink
+}
+
+// backupLink - reference to row of same table restored later, it is set after all rows of table are inserted
+type backupLink struct {
+	column string
+	id     int64
+	old    int64
+}
+
+// backupID - id from json value, false for null and zero
+func backupID(val interface{}) (int64, bool) {
+	num, ok := val.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	id, err := num.Int64()
+	return id, err == nil && id != 0
+}
+
+// tableColumns - columns of table in current schema, columns of backup missing in database are not restored
+func tableColumns(tx *instrumentedTx, table string) (map[string]bool, error) {
+	rows, err := tx.Query(`
+		SELECT
+			column_name
+		FROM
+			information_schema.columns
+		WHERE
+			table_schema = current_schema() AND table_name = $1
+	`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var column string
+		err = rows.Scan(&column)
+		if err != nil {
+			return nil, err
+		}
+		columns[column] = true
+	}
+	return columns, rows.Err()
+}
+
+// find - id of existing row with same natural key
+func (rs *restorer) find(table backupTable, row map[string]interface{}) (int64, bool, error) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+	for _, column := range table.key {
+		if !rs.columns[column] {
+			continue
+		}
+		args = append(args, row[column])
+		conds = append(conds, column+" IS NOT DISTINCT FROM $"+strconv.Itoa(len(args)))
+	}
+	if len(conds) == 0 {
+		return 0, false, nil
+	}
+	var id int64
+	err := rs.tx.QueryRow(`SELECT id FROM `+table.name+` WHERE `+strings.Join(conds, " AND ")+` ORDER BY id LIMIT 1`, args...).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	return id, err == nil, err
+}
+
+func (rs *restorer) insert(table backupTable, row map[string]interface{}) (int64, error) {
+	var (
+		columns []string
+		params  []string
+		args    []interface{}
+	)
+	for column := range row {
+		if column != "id" && rs.columns[column] {
+			columns = append(columns, column)
+		}
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		args = append(args, row[column])
+		params = append(params, "$"+strconv.Itoa(len(args)))
+	}
+	query := `INSERT INTO ` + table.name + ` DEFAULT VALUES RETURNING id`
+	if len(columns) > 0 {
+		query = `INSERT INTO ` + table.name + ` (` + strings.Join(columns, ", ") + `) VALUES (` + strings.Join(params, ", ") + `) RETURNING id`
+	}
+	var id int64
+	err := rs.tx.QueryRow(query, args...).Scan(&id)
+	return id, err
+}
+
+// restoreRow - replace ids of referenced rows by new ids and insert row or match it with existing one
+func (rs *restorer) restoreRow(table backupTable, raw json.RawMessage) error {
+	var row map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	err := dec.Decode(&row)
+	if err != nil {
+		return err
+	}
+	var links []backupLink
+	for column, ref := range table.refs {
+		old, ok := backupID(row[column])
+		row[column] = nil
+		if !ok {
+			continue
+		}
+		if id, found := rs.ids[ref][old]; found {
+			row[column] = id
+		} else if ref == table.name {
+			links = append(links, backupLink{column: column, old: old})
+		}
+	}
+	var (
+		id    int64
+		found bool
+	)
+	if rs.merge {
+		id, found, err = rs.find(table, row)
+		if err != nil {
+			return err
+		}
+	}
+	if found {
+		rs.report.Matched[table.name]++
+	} else {
+		id, err = rs.insert(table, row)
+		if err != nil {
+			return err
+		}
+		rs.report.Inserted[table.name]++
+		for _, link := range links {
+			link.id = id
+			rs.links = append(rs.links, link)
+		}
+	}
+	if old, ok := backupID(row["id"]); ok {
+		rs.ids[table.name][old] = id
+	}
+	return nil
+}
+
+// restoreLinks - set references to rows of same table after all its rows are inserted
+func (rs *restorer) restoreLinks(table backupTable) error {
+	for _, link := range rs.links {
+		id, found := rs.ids[table.name][link.old]
+		if !found || !rs.columns[link.column] {
+			continue
+		}
+		_, err := rs.tx.Exec(`UPDATE `+table.name+` SET `+link.column+` = $1 WHERE id = $2`, id, link.id)
+		if err != nil {
+			return err
+		}
+	}
+	rs.links = nil
+	return nil
+}
+
+// Restore - restore rows written by Backup in one transaction, ids are assigned by database and references are remapped.
+// In RestoreReplace mode all rows are deleted first, in RestoreMerge mode existing rows are kept.
+func (e *Edb) Restore(r io.Reader, mode string) (RestoreReport, error) {
+	report := RestoreReport{Mode: mode, Inserted: make(map[string]int), Matched: make(map[string]int)}
+	if mode != RestoreMerge && mode != RestoreReplace {
+		return report, fmt.Errorf("unknown restore mode %q", mode)
+	}
+	var backup backupFile
+	err := json.NewDecoder(r).Decode(&backup)
+	if err != nil {
+		log.Println("Restore Decode ", err)
+		return report, err
+	}
+	if backup.Format != backupFormat {
+		return report, fmt.Errorf("not a backup: format %q", backup.Format)
+	}
+	if backup.Version < 1 || backup.Version > BackupVersion {
+		return report, fmt.Errorf("unsupported backup version %d", backup.Version)
+	}
+	report.Version = backup.Version
+	known := make(map[string]bool)
+	var names []string
+	for _, table := range backupTables {
+		known[table.name] = true
+		names = append(names, table.name)
+	}
+	for name := range backup.Tables {
+		if !known[name] {
+			report.Skipped = append(report.Skipped, name)
+		}
+	}
+	sort.Strings(report.Skipped)
+	tx, err := e.db.Begin()
+	if err != nil {
+		log.Println("Restore e.db.Begin ", err)
+		return report, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if mode == RestoreReplace {
+		_, err = tx.Exec(`TRUNCATE ` + strings.Join(names, ", ") + ` RESTART IDENTITY`)
+		if err != nil {
+			log.Println("Restore tx.Exec truncate ", err)
+			return report, err
+		}
+		// truncate does not fire row triggers, subscribers have to reload everything
+		_, err = tx.Exec(`SELECT pg_notify($1, $2)`, changeChannel, `{"operation":"`+ChangeResync+`"}`)
+		if err != nil {
+			log.Println("Restore tx.Exec pg_notify ", err)
+			return report, err
+		}
+	}
+	rs := restorer{tx: tx, merge: mode == RestoreMerge, ids: make(map[string]map[int64]int64), report: &report}
+	for _, table := range backupTables {
+		rs.ids[table.name] = make(map[int64]int64)
+		rs.columns, err = tableColumns(tx, table.name)
+		if err != nil {
+			log.Println("Restore tableColumns ", table.name, err)
+			return report, err
+		}
+		for i, raw := range backup.Tables[table.name] {
+			err = rs.restoreRow(table, raw)
+			if err != nil {
+				err = fmt.Errorf("%s row %d: %v", table.name, i+1, err)
+				log.Println("Restore restoreRow ", err)
+				return report, err
+			}
+		}
+		err = rs.restoreLinks(table)
+		if err != nil {
+			log.Println("Restore restoreLinks ", table.name, err)
+			return report, err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Println("Restore tx.Commit ", err)
+		return report, err
+	}
+	e.InvalidateCache()
+	return report, nil
+}
@@ -0,0 +1,144 @@
+package epgc_test
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/serbe/epgc"
+	"github.com/serbe/epgc/epgctest"
+)
+
+func TestRestoreRejectsBadInput(t *testing.T) {
+	e := new(epgc.Edb)
+	for _, tc := range []struct {
+		name, mode, input string
+	}{
+		{"mode", "append", `{"format":"epgc-backup","version":1}`},
+		{"json", epgc.RestoreMerge, `{"format":`},
+		{"format", epgc.RestoreMerge, `{"format":"other","version":1}`},
+		{"version", epgc.RestoreMerge, `{"format":"epgc-backup","version":99}`},
+	} {
+		_, err := e.Restore(strings.NewReader(tc.input), tc.mode)
+		if err == nil {
+			t.Errorf("%s: Restore without error", tc.name)
+		}
+	}
+}
+
+func contactNames(t *testing.T, e *epgc.Edb) []string {
+	t.Helper()
+	list, err := e.GetContactList()
+	check(t, err)
+	var names []string
+	for _, contact := range list {
+		names = append(names, contact.Name+"|"+contact.CompanyName+"|"+contact.PostName+"|"+strings.Join(contact.Phones, ","))
+	}
+	return names
+}
+
+func TestSeedSameData(t *testing.T) {
+	opt := epgc.SeedOptions{Seed: 5, Companies: 3, ContactsPerCompany: 2, PracticesPerCompany: 2, SirensPerCompany: epgc.SeedNone}
+	first := epgctest.Open(t)
+	report, err := first.Seed(opt)
+	check(t, err)
+	if report.Companies != 3 || report.Contacts != 6 || report.Practices != 6 || report.Sirens != 0 {
+		t.Errorf("Seed report = %+v", report)
+	}
+	second := epgctest.Open(t)
+	_, err = second.Seed(opt)
+	check(t, err)
+	if !equalStrings(contactNames(t, second), contactNames(t, first)) {
+		t.Errorf("contacts of same seed = %v and %v", contactNames(t, second), contactNames(t, first))
+	}
+	if !equalStrings(practiceKeys(t, second), practiceKeys(t, first)) {
+		t.Errorf("practices of same seed = %v and %v", practiceKeys(t, second), practiceKeys(t, first))
+	}
+}
+
+func practiceKeys(t *testing.T, e *epgc.Edb) []string {
+	t.Helper()
+	list, err := e.GetPracticeList()
+	check(t, err)
+	var keys []string
+	for _, practice := range list {
+		keys = append(keys, practice.Company.Name+"|"+practice.DateOfPractice+"|"+practice.Kind.Name+"|"+practice.Topic)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestBackupRestore(t *testing.T) {
+	src := epgctest.Open(t)
+	_, err := src.Seed(epgc.SeedOptions{Seed: 7, Companies: 4, ContactsPerCompany: 3, PracticesPerCompany: 2, SirensPerCompany: 1})
+	check(t, err)
+	list, err := src.GetContactList()
+	check(t, err)
+	recipient := list[len(list)-1]
+	_, err = src.CreateDispatch(epgc.DispatchRequest{ContactIDs: []int64{recipient.ID}, Body: "Сбор", Channels: []string{epgc.DeliverySMS}})
+	check(t, err)
+	var buf bytes.Buffer
+	check(t, src.Backup(&buf))
+
+	dst := epgctest.Open(t)
+	f := epgctest.NewFixtures(t, dst)
+	f.Company("ООО Старая")
+	report, err := dst.Restore(bytes.NewReader(buf.Bytes()), epgc.RestoreReplace)
+	check(t, err)
+	if report.Inserted["companies"] != 4 || report.Inserted["contacts"] != 12 || report.Inserted["sirens"] != 4 {
+		t.Errorf("Restore replace report = %+v", report)
+	}
+	if !equalStrings(contactNames(t, dst), contactNames(t, src)) {
+		t.Errorf("contacts after restore = %v, want %v", contactNames(t, dst), contactNames(t, src))
+	}
+	companies, err := dst.GetCompanySelect()
+	check(t, err)
+	if len(companies) != 4 {
+		t.Errorf("companies after replace = %+v", companies)
+	}
+	dispatches, err := dst.GetDispatches()
+	check(t, err)
+	if len(dispatches) != 1 {
+		t.Fatalf("dispatches after replace = %+v", dispatches)
+	}
+	dispatch, err := dst.GetDispatchReport(dispatches[0].ID)
+	check(t, err)
+	if len(dispatch.Deliveries) == 0 {
+		t.Errorf("deliveries after replace = %+v", dispatch)
+	}
+	for _, delivery := range dispatch.Deliveries {
+		contact, err := dst.GetContact(delivery.ContactID)
+		check(t, err)
+		if contact.Name != recipient.Name {
+			t.Errorf("delivery to %s after replace points to %s", recipient.Name, contact.Name)
+		}
+	}
+
+	// second merge of same backup matches every row
+	report, err = dst.Restore(bytes.NewReader(buf.Bytes()), epgc.RestoreMerge)
+	check(t, err)
+	for table, n := range report.Inserted {
+		if n != 0 {
+			t.Errorf("Restore merge inserted %d rows into %s", n, table)
+		}
+	}
+	if report.Matched["contacts"] != 12 {
+		t.Errorf("Restore merge report = %+v", report)
+	}
+
+	other := epgctest.Open(t)
+	kept := epgctest.NewFixtures(t, other).Company("ООО Своя")
+	_, err = other.Restore(bytes.NewReader(buf.Bytes()), epgc.RestoreMerge)
+	check(t, err)
+	company, err := other.GetCompany(kept.ID)
+	check(t, err)
+	if company.Name != "ООО Своя" {
+		t.Errorf("existing company after merge = %+v", company)
+	}
+	companies, err = other.GetCompanySelect()
+	check(t, err)
+	if len(companies) != 5 {
+		t.Errorf("companies after merge = %+v", companies)
+	}
+}
@@ -0,0 +1,241 @@
+package epgc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GroupByDepartment - group reminders by company and department
+const GroupByDepartment = "department"
+
+// Reminder - upcoming birthday of contact
+type Reminder struct {
+	Contact     Contact `json:"contact"`
+	Date        string  `json:"date"`
+	Days        int     `json:"days"`
+	Age         int     `json:"age"`
+	Anniversary bool    `json:"anniversary"`
+}
+
+// ReminderGroup - reminders of one company or department
+type ReminderGroup struct {
+	Name      string     `json:"name"`
+	Reminders []Reminder `json:"reminders"`
+}
+
+// Notifier - receiver of digests, like mail or messenger
+type Notifier interface {
+	Notify(subject, body string) error
+}
+
+// NotifierFunc - function as Notifier
+type NotifierFunc func(subject, body string) error
+
+// Notify - call f
+func (f NotifierFunc) Notify(subject, body string) error {
+	return f(subject, body)
+}
+
+// WriterNotifier - write digests as text to writer, like log file or stdout
+type WriterNotifier struct {
+	W io.Writer
+}
+
+// Notify - write subject and body
+func (n WriterNotifier) Notify(subject, body string) error {
+	_, err := fmt.Fprintf(n.W, "%s\n\n%s\n", subject, body)
+	return err
+}
+
+// SMTPNotifier - send digests by mail, Auth may be nil for local relay
+type SMTPNotifier struct {
+	Addr string
+	Auth smtp.Auth
+	From string
+	To   []string
+}
+
+// smtpMessage - plain text mail in utf-8
+func smtpMessage(from string, to []string, subject, body string) []byte {
+	var msg strings.Builder
+	msg.WriteString("From: " + from + "\r\n")
+	msg.WriteString("To: " + strings.Join(to, ", ") + "\r\n")
+	msg.WriteString("Subject: " + mime.BEncoding.Encode("utf-8", subject) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.Replace(body, "\n", "\r\n", -1))
+	return []byte(msg.String())
+}
+
+// Notify - send plain text mail
+func (n SMTPNotifier) Notify(subject, body string) error {
+	err := smtp.SendMail(n.Addr, n.Auth, n.From, n.To, smtpMessage(n.From, n.To, subject, body))
+	if err != nil {
+		log.Println("SMTPNotifier smtp.SendMail ", err)
+	}
+	return err
+}
+
+// isAnniversary - round age: every ten years and every five years from fifty
+func isAnniversary(age int) bool {
+	return age > 0 && (age%10 == 0 || age >= 50 && age%5 == 0)
+}
+
+// birthdayIn - birthday in year, 29 february is celebrated 28 february in not leap year
+func birthdayIn(birthday time.Time, year int) time.Time {
+	month, day := birthday.Month(), birthday.Day()
+	if month == time.February && day == 29 && time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay() == 365 {
+		day = 28
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// upcomingBirthdays - reminders of contacts with birthday from date to date + days, sorted by date and name
+func upcomingBirthdays(contacts []Contact, from time.Time, days int) []Reminder {
+	var reminders []Reminder
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	for _, contact := range contacts {
+		birthday, err := time.Parse("02.01.2006", contact.Birthday)
+		if err != nil {
+			continue
+		}
+		// next birthday may be in next year when period crosses new year
+		next := birthdayIn(birthday, from.Year())
+		if next.Before(from) {
+			next = birthdayIn(birthday, from.Year()+1)
+		}
+		left := int(next.Sub(from).Hours() / 24)
+		if left > days {
+			continue
+		}
+		age := next.Year() - birthday.Year()
+		reminders = append(reminders, Reminder{
+			Contact:     contact,
+			Date:        next.Format("02.01.2006"),
+			Days:        left,
+			Age:         age,
+			Anniversary: isAnniversary(age),
+		})
+	}
+	sort.SliceStable(reminders, func(i, j int) bool {
+		if reminders[i].Days != reminders[j].Days {
+			return reminders[i].Days < reminders[j].Days
+		}
+		return reminders[i].Contact.Name < reminders[j].Contact.Name
+	})
+	return reminders
+}
+
+// GetUpcomingBirthdays - birthdays of contacts within days from today
+func (e *Edb) GetUpcomingBirthdays(days int) ([]Reminder, error) {
+	contacts, err := e.getContactsFull(`c.birthday IS NOT NULL`)
+	if err != nil {
+		log.Println("GetUpcomingBirthdays getContactsFull ", err)
+		return nil, err
+	}
+	return upcomingBirthdays(contacts, time.Now(), days), nil
+}
+
+// GetUpcomingAnniversaries - round number birthdays of contacts within days from today
+func (e *Edb) GetUpcomingAnniversaries(days int) ([]Reminder, error) {
+	var anniversaries []Reminder
+	reminders, err := e.GetUpcomingBirthdays(days)
+	if err != nil {
+		return anniversaries, err
+	}
+	for _, reminder := range reminders {
+		if reminder.Anniversary {
+			anniversaries = append(anniversaries, reminder)
+		}
+	}
+	return anniversaries, nil
+}
+
+// GroupReminders - group reminders by GroupByCompany or GroupByDepartment, groups are sorted by name
+func GroupReminders(reminders []Reminder, groupBy string) []ReminderGroup {
+	var groups []ReminderGroup
+	index := make(map[string]int)
+	for _, reminder := range reminders {
+		name := reminder.Contact.Company.Name
+		if groupBy == GroupByDepartment {
+			name = joinNotEmpty([]string{name, reminder.Contact.Department.Name})
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(groups)
+			index[name] = i
+			groups = append(groups, ReminderGroup{Name: name})
+		}
+		groups[i].Reminders = append(groups[i].Reminders, reminder)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
+
+// BirthdayDigest - text digest of birthdays within days grouped by company or department
+func BirthdayDigest(reminders []Reminder, groupBy string) string {
+	var b strings.Builder
+	for _, group := range GroupReminders(reminders, groupBy) {
+		if group.Name == "" {
+			b.WriteString("Без организации\n")
+		} else {
+			b.WriteString(group.Name + "\n")
+		}
+		for _, reminder := range group.Reminders {
+			line := "  " + setStrMonth(reminder.Date) + " — " + reminder.Contact.Name
+			if reminder.Contact.Post.Name != "" {
+				line += ", " + reminder.Contact.Post.Name
+			}
+			line += fmt.Sprintf(" (%d)", reminder.Age)
+			if reminder.Anniversary {
+				line += " — юбилей"
+			}
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// SendBirthdayDigest - send digest of birthdays within days to notifier, nothing is sent without birthdays
+func (e *Edb) SendBirthdayDigest(n Notifier, days int, groupBy string) error {
+	reminders, err := e.GetUpcomingBirthdays(days)
+	if err != nil || len(reminders) == 0 {
+		return err
+	}
+	subject := "Дни рождения на " + setStrMonth(time.Now().Format("02.01.2006"))
+	err = n.Notify(subject, BirthdayDigest(reminders, groupBy))
+	if err != nil {
+		log.Println("SendBirthdayDigest Notify ", err)
+	}
+	return err
+}
+
+// RunBirthdayDigest - send digest every day at hour until ctx is done
+func (e *Edb) RunBirthdayDigest(ctx context.Context, n Notifier, days int, groupBy string, hour int) {
+	for {
+		now := time.Now()
+		next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			_ = e.SendBirthdayDigest(n, days, groupBy)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package epgc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUpcomingBirthdays(t *testing.T) {
+	contacts := []Contact{
+		{Name: "Зайцев", Birthday: "02.01.1970"},
+		{Name: "Иванов", Birthday: "29.02.1980"},
+		{Name: "Петров", Birthday: "31.12.1975"},
+		{Name: "Сидоров", Birthday: "01.03.1990"},
+		{Name: "Без даты"},
+	}
+	type reminder struct {
+		name string
+		date string
+		days int
+		age  int
+	}
+	tests := []struct {
+		name string
+		from time.Time
+		days int
+		want []reminder
+	}{
+		{
+			"december to january",
+			time.Date(2023, time.December, 30, 15, 0, 0, 0, time.UTC),
+			5,
+			[]reminder{{"Петров", "31.12.2023", 1, 48}, {"Зайцев", "02.01.2024", 3, 54}},
+		},
+		{
+			"new year day",
+			time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+			1,
+			[]reminder{{"Зайцев", "02.01.2024", 1, 54}},
+		},
+		{
+			"29 february in leap year",
+			time.Date(2024, time.February, 27, 0, 0, 0, 0, time.UTC),
+			3,
+			[]reminder{{"Иванов", "29.02.2024", 2, 44}, {"Сидоров", "01.03.2024", 3, 34}},
+		},
+		{
+			"29 february in not leap year",
+			time.Date(2023, time.February, 27, 0, 0, 0, 0, time.UTC),
+			2,
+			[]reminder{{"Иванов", "28.02.2023", 1, 43}, {"Сидоров", "01.03.2023", 2, 33}},
+		},
+		{
+			"29 february passed in not leap year",
+			time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC),
+			0,
+			[]reminder{{"Сидоров", "01.03.2023", 0, 33}},
+		},
+		{
+			"29 february next leap year",
+			time.Date(2023, time.March, 2, 0, 0, 0, 0, time.UTC),
+			364,
+			[]reminder{
+				{"Петров", "31.12.2023", 304, 48},
+				{"Зайцев", "02.01.2024", 306, 54},
+				{"Иванов", "29.02.2024", 364, 44},
+			},
+		},
+	}
+	for _, tt := range tests {
+		got := upcomingBirthdays(contacts, tt.from, tt.days)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: upcomingBirthdays returned %d reminders, want %+v", tt.name, len(got), tt.want)
+			continue
+		}
+		for i, w := range tt.want {
+			if got[i].Contact.Name != w.name || got[i].Date != w.date || got[i].Days != w.days || got[i].Age != w.age {
+				t.Errorf("%s: reminder %d = %+v, want %+v", tt.name, i, got[i], w)
+			}
+		}
+	}
+}
+
+func TestIsAnniversary(t *testing.T) {
+	tests := map[int]bool{0: false, 10: true, 25: false, 30: true, 45: false, 50: true, 55: true, 61: false}
+	for age, want := range tests {
+		if got := isAnniversary(age); got != want {
+			t.Errorf("isAnniversary(%d) = %v, want %v", age, got, want)
+		}
+	}
+}
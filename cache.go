@@ -0,0 +1,133 @@
+package epgc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheStats - counters of lookup cache
+type CacheStats struct {
+	Hits          int64 `json:"hits"`
+	Misses        int64 `json:"misses"`
+	Invalidations int64 `json:"invalidations"`
+	Entries       int   `json:"entries"`
+}
+
+type cacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// lookupCache - cache of select lists and dictionary items (scopes, posts, ranks, kinds, departments, siren types),
+// key is "entity:key" where entity is name of table
+type lookupCache struct {
+	sync.Mutex
+	ttl           time.Duration
+	entries       map[string]cacheEntry
+	hits          int64
+	misses        int64
+	invalidations int64
+}
+
+// EnableCache - cache select lists and dictionary items for ttl, call before using Edb from other goroutines.
+// Cache is invalidated by Create, Update and Delete of this Edb, use WatchCache for changes by other clients.
+func (e *Edb) EnableCache(ttl time.Duration) {
+	e.cache = &lookupCache{ttl: ttl, entries: make(map[string]cacheEntry)}
+}
+
+// CacheStats - hits, misses, invalidations and number of entries of cache
+func (e *Edb) CacheStats() CacheStats {
+	var stats CacheStats
+	if e.cache == nil {
+		return stats
+	}
+	e.cache.Lock()
+	defer e.cache.Unlock()
+	stats.Hits = e.cache.hits
+	stats.Misses = e.cache.misses
+	stats.Invalidations = e.cache.invalidations
+	stats.Entries = len(e.cache.entries)
+	return stats
+}
+
+// InvalidateCache - remove cached items of entities, all items without entities
+func (e *Edb) InvalidateCache(entities ...string) {
+	if e.cache == nil {
+		return
+	}
+	e.cache.Lock()
+	defer e.cache.Unlock()
+	e.cache.invalidations++
+	if len(entities) == 0 {
+		e.cache.entries = make(map[string]cacheEntry)
+		return
+	}
+	for key := range e.cache.entries {
+		for _, entity := range entities {
+			if strings.HasPrefix(key, entity+":") {
+				delete(e.cache.entries, key)
+			}
+		}
+	}
+}
+
+// WatchCache - invalidate cache on changes from LISTEN/NOTIFY until ctx is done
+func (e *Edb) WatchCache(ctx context.Context) {
+	if e.cache == nil {
+		return
+	}
+	events := e.Subscribe(ctx)
+	go func() {
+		for event := range events {
+			if event.Operation == ChangeResync {
+				e.InvalidateCache()
+			} else {
+				e.InvalidateCache(event.Entity)
+			}
+		}
+	}()
+}
+
+func cacheKey(entity string, key interface{}) string {
+	return fmt.Sprintf("%s:%v", entity, key)
+}
+
+func (e *Edb) cacheGet(key string) (interface{}, bool) {
+	if e.cache == nil {
+		return nil, false
+	}
+	e.cache.Lock()
+	defer e.cache.Unlock()
+	entry, ok := e.cache.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		e.cache.misses++
+		return nil, false
+	}
+	e.cache.hits++
+	return entry.value, true
+}
+
+func (e *Edb) cacheSet(key string, value interface{}) {
+	if e.cache == nil {
+		return
+	}
+	e.cache.Lock()
+	e.cache.entries[key] = cacheEntry{value: value, expires: time.Now().Add(e.cache.ttl)}
+	e.cache.Unlock()
+}
+
+// cacheSelect - copy of cached select list, so caller can change it
+func (e *Edb) cacheSelect(key string) ([]SelectItem, bool) {
+	value, ok := e.cacheGet(key)
+	if !ok {
+		return nil, false
+	}
+	return append([]SelectItem{}, value.([]SelectItem)...), true
+}
+
+func (e *Edb) cacheSetSelect(key string, items []SelectItem) {
+	e.cacheSet(key, append([]SelectItem{}, items...))
+}
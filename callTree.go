@@ -0,0 +1,252 @@
+package epgc
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+)
+
+// ErrCallTreeCycle - caller of node is node itself or one of nodes called by it
+var ErrCallTreeCycle = errors.New("node of call tree can not call itself")
+
+// ErrCallTreeList - caller of node belongs to other notification list
+var ErrCallTreeList = errors.New("caller belongs to other notification list")
+
+// CallTreeNode - contact or company in call tree of notification list, node is alerted by its parent,
+// roots are alerted by dispatcher
+type CallTreeNode struct {
+	ID         int64          `sql:"id" json:"id"`
+	ListID     int64          `sql:"list_id" json:"list_id"`
+	ParentID   int64          `sql:"parent_id, null" json:"parent_id"`
+	ContactID  int64          `sql:"contact_id, null" json:"contact_id"`
+	CompanyID  int64          `sql:"company_id, null" json:"company_id"`
+	Channel    string         `sql:"channel" json:"channel"`
+	Name       string         `sql:"-" json:"name"`
+	CallerName string         `sql:"-" json:"caller_name"`
+	Level      int64          `sql:"-" json:"level"`
+	Children   []CallTreeNode `sql:"-" json:"children"`
+}
+
+func scanCallTreeNodes(rows *sql.Rows) ([]CallTreeNode, error) {
+	var nodes []CallTreeNode
+	for rows.Next() {
+		var (
+			sID        sql.NullInt64
+			sListID    sql.NullInt64
+			sParentID  sql.NullInt64
+			sContactID sql.NullInt64
+			sCompanyID sql.NullInt64
+			sChannel   sql.NullString
+			sName      sql.NullString
+			node       CallTreeNode
+		)
+		err := rows.Scan(&sID, &sListID, &sParentID, &sContactID, &sCompanyID, &sChannel, &sName)
+		if err != nil {
+			log.Println("scanCallTreeNodes rows.Scan ", err)
+			return nodes, err
+		}
+		node.ID = n2i(sID)
+		node.ListID = n2i(sListID)
+		node.ParentID = n2i(sParentID)
+		node.ContactID = n2i(sContactID)
+		node.CompanyID = n2i(sCompanyID)
+		node.Channel = n2s(sChannel)
+		node.Name = n2s(sName)
+		nodes = append(nodes, node)
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Println("scanCallTreeNodes rows.Err ", err)
+	}
+	return nodes, err
+}
+
+// buildCallTree - nest nodes under their callers, nodes without caller in list and nodes of cycles are roots
+func buildCallTree(nodes []CallTreeNode) []CallTreeNode {
+	roots, children := treeLinks(len(nodes), func(i int) int64 {
+		return nodes[i].ID
+	}, func(i int) int64 {
+		return nodes[i].ParentID
+	})
+	var build func(i int, caller string, level int64) CallTreeNode
+	build = func(i int, caller string, level int64) CallTreeNode {
+		node := nodes[i]
+		node.Level = level
+		node.CallerName = caller
+		node.Children = []CallTreeNode{}
+		for _, c := range children[i] {
+			node.Children = append(node.Children, build(c, node.Name, level+1))
+		}
+		return node
+	}
+	tree := []CallTreeNode{}
+	for _, root := range roots {
+		tree = append(tree, build(root, "", 0))
+	}
+	return tree
+}
+
+func (e *Edb) getCallTreeNodes(listID int64) ([]CallTreeNode, error) {
+	rows, err := e.db.Query(`
+		SELECT
+			n.id,
+			n.list_id,
+			n.parent_id,
+			n.contact_id,
+			n.company_id,
+			n.channel,
+			COALESCE(c.name, o.name)
+		FROM
+			call_tree_nodes AS n
+		LEFT JOIN
+			contacts AS c ON c.id = n.contact_id
+		LEFT JOIN
+			companies AS o ON o.id = n.company_id
+		WHERE
+			n.list_id = $1
+		ORDER BY
+			n.id ASC
+	`, listID)
+	if err != nil {
+		log.Println("getCallTreeNodes e.db.Query ", err)
+		return []CallTreeNode{}, err
+	}
+	return scanCallTreeNodes(rows)
+}
+
+// GetCallTree - get call tree of notification list
+func (e *Edb) GetCallTree(listID int64) ([]CallTreeNode, error) {
+	nodes, err := e.getCallTreeNodes(listID)
+	if err != nil {
+		return []CallTreeNode{}, err
+	}
+	return buildCallTree(nodes), nil
+}
+
+// checkCallTreeNode - node must be contact or company, caller must be in same list and must not be called by node
+func (e *Edb) checkCallTreeNode(node *CallTreeNode) error {
+	if (node.ContactID == 0) == (node.CompanyID == 0) {
+		return ErrNotificationMember
+	}
+	channel, err := checkChannel(node.Channel)
+	if err != nil {
+		return err
+	}
+	node.Channel = channel
+	if node.ParentID == 0 {
+		return nil
+	}
+	if node.ParentID == node.ID {
+		return ErrCallTreeCycle
+	}
+	nodes, err := e.getCallTreeNodes(node.ListID)
+	if err != nil {
+		return err
+	}
+	parents := make(map[int64]int64)
+	for _, n := range nodes {
+		parents[n.ID] = n.ParentID
+	}
+	if _, ok := parents[node.ParentID]; !ok {
+		return ErrCallTreeList
+	}
+	for id, depth := node.ParentID, 0; id != 0 && depth <= len(nodes); id, depth = parents[id], depth+1 {
+		if id == node.ID {
+			return ErrCallTreeCycle
+		}
+	}
+	return nil
+}
+
+// CreateCallTreeNode - create new node of call tree
+func (e *Edb) CreateCallTreeNode(node CallTreeNode) (int64, error) {
+	err := e.checkCallTreeNode(&node)
+	if err != nil {
+		log.Println("CreateCallTreeNode checkCallTreeNode ", err)
+		return 0, err
+	}
+	err = e.db.QueryRow(`
+		INSERT INTO
+			call_tree_nodes (
+				list_id,
+				parent_id,
+				contact_id,
+				company_id,
+				channel,
+				created_at
+			)
+		VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			now()
+		)
+		RETURNING
+			id
+	`, i2n(node.ListID), i2n(node.ParentID), i2n(node.ContactID), i2n(node.CompanyID), node.Channel).Scan(&node.ID)
+	if err != nil {
+		log.Println("CreateCallTreeNode e.db.QueryRow ", err)
+		return 0, err
+	}
+	return node.ID, nil
+}
+
+// UpdateCallTreeNode - save node of call tree changes
+func (e *Edb) UpdateCallTreeNode(node CallTreeNode) error {
+	err := e.checkCallTreeNode(&node)
+	if err != nil {
+		log.Println("UpdateCallTreeNode checkCallTreeNode ", err)
+		return err
+	}
+	_, err = e.db.Exec(`
+		UPDATE
+			call_tree_nodes
+		SET
+			list_id = $2,
+			parent_id = $3,
+			contact_id = $4,
+			company_id = $5,
+			channel = $6,
+			updated_at = now()
+		WHERE
+			id = $1
+	`, i2n(node.ID), i2n(node.ListID), i2n(node.ParentID), i2n(node.ContactID), i2n(node.CompanyID), node.Channel)
+	if err != nil {
+		log.Println("UpdateCallTreeNode e.db.Exec ", err)
+	}
+	return err
+}
+
+// DeleteCallTreeNode - delete node of call tree, nodes called by it are moved to its caller
+func (e *Edb) DeleteCallTreeNode(id int64) error {
+	if id == 0 {
+		return nil
+	}
+	_, err := e.db.Exec(`
+		UPDATE
+			call_tree_nodes AS c
+		SET
+			parent_id = d.parent_id,
+			updated_at = now()
+		FROM
+			call_tree_nodes AS d
+		WHERE
+			c.parent_id = $1 AND d.id = $1
+	`, id)
+	if err != nil {
+		log.Println("DeleteCallTreeNode e.db.Exec ", id, err)
+		return err
+	}
+	_, err = e.db.Exec(`
+		DELETE FROM
+			call_tree_nodes
+		WHERE
+			id = $1
+	`, id)
+	if err != nil {
+		log.Println("DeleteCallTreeNode e.db.Exec ", id, err)
+	}
+	return err
+}
@@ -0,0 +1,257 @@
+package epgc
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const cardDAVBook = "contacts/"
+
+type cardDAVHandler struct {
+	e      *Edb
+	prefix string
+}
+
+type cardDAVReport struct {
+	Hrefs []string `xml:"DAV: href"`
+}
+
+type cardDAVResponse struct {
+	Href         string
+	Collection   bool
+	AddressBook  bool
+	DisplayName  string
+	ETag         string
+	CTag         string
+	Data         string
+	WithData     bool
+	WithHomeSets bool
+	NotFound     bool
+}
+
+// CardDAVHandler - read only CardDAV address book with all contacts, prefix is path where handler is mounted,
+// "/.well-known/carddav" should be redirected to prefix. Authentication is up to caller.
+func (e *Edb) CardDAVHandler(prefix string) http.Handler {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &cardDAVHandler{e: e, prefix: prefix}
+}
+
+func cardDAVETag(card string) string {
+	sum := sha1.Sum([]byte(card))
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func (h *cardDAVHandler) cardHref(id int64) string {
+	return h.prefix + cardDAVBook + strconv.FormatInt(id, 10) + ".vcf"
+}
+
+// cardID - contact id from path like prefix/contacts/12.vcf
+func (h *cardDAVHandler) cardID(path string) (int64, bool) {
+	name := strings.TrimPrefix(path, h.prefix+cardDAVBook)
+	if name == path || !strings.HasSuffix(name, ".vcf") {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(strings.TrimSuffix(name, ".vcf"), 10, 64)
+	return id, err == nil
+}
+
+func (h *cardDAVHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("DAV", "1, 3, addressbook")
+	switch r.Method {
+	case "OPTIONS":
+		w.Header().Set("Allow", "OPTIONS, GET, HEAD, PROPFIND, REPORT")
+		w.WriteHeader(http.StatusOK)
+	case "GET", "HEAD":
+		h.get(w, r)
+	case "PROPFIND":
+		h.propfind(w, r)
+	case "REPORT":
+		h.report(w, r)
+	default:
+		w.Header().Set("Allow", "OPTIONS, GET, HEAD, PROPFIND, REPORT")
+		http.Error(w, "read only address book", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *cardDAVHandler) get(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.cardID(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	card, err := h.e.GetContactVCard(id)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if card == "" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/vcard; charset=utf-8")
+	w.Header().Set("ETag", cardDAVETag(card))
+	if r.Method == "HEAD" {
+		return
+	}
+	_, _ = io.WriteString(w, card)
+}
+
+func (h *cardDAVHandler) cards(withData bool) ([]cardDAVResponse, string, error) {
+	var responses []cardDAVResponse
+	contacts, err := h.e.getContactsFull(`true`)
+	if err != nil {
+		return responses, "", err
+	}
+	ctag := sha1.New()
+	for _, contact := range contacts {
+		card := contact.VCard()
+		etag := cardDAVETag(card)
+		_, _ = io.WriteString(ctag, etag)
+		response := cardDAVResponse{Href: h.cardHref(contact.ID), ETag: etag}
+		if withData {
+			response.Data = card
+			response.WithData = true
+		}
+		responses = append(responses, response)
+	}
+	return responses, `"` + hex.EncodeToString(ctag.Sum(nil)) + `"`, nil
+}
+
+func (h *cardDAVHandler) propfind(w http.ResponseWriter, r *http.Request) {
+	var responses []cardDAVResponse
+	depth := r.Header.Get("Depth")
+	root := cardDAVResponse{Href: h.prefix, Collection: true, WithHomeSets: true}
+	switch {
+	case r.URL.Path == h.prefix || r.URL.Path+"/" == h.prefix:
+		responses = append(responses, root)
+		if depth == "1" {
+			_, ctag, err := h.cards(false)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			responses = append(responses, cardDAVResponse{Href: h.prefix + cardDAVBook, Collection: true, AddressBook: true, DisplayName: "EDDS", CTag: ctag})
+		}
+	case r.URL.Path == h.prefix+cardDAVBook || r.URL.Path+"/" == h.prefix+cardDAVBook:
+		cards, ctag, err := h.cards(false)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		responses = append(responses, cardDAVResponse{Href: h.prefix + cardDAVBook, Collection: true, AddressBook: true, DisplayName: "EDDS", CTag: ctag, WithHomeSets: true})
+		if depth == "1" {
+			responses = append(responses, cards...)
+		}
+	default:
+		id, ok := h.cardID(r.URL.Path)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		card, err := h.e.GetContactVCard(id)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if card == "" {
+			http.NotFound(w, r)
+			return
+		}
+		responses = append(responses, cardDAVResponse{Href: h.cardHref(id), ETag: cardDAVETag(card)})
+	}
+	h.multistatus(w, responses)
+}
+
+func (h *cardDAVHandler) report(w http.ResponseWriter, r *http.Request) {
+	var (
+		report    cardDAVReport
+		responses []cardDAVResponse
+	)
+	err := xml.NewDecoder(r.Body).Decode(&report)
+	if err != nil && err != io.EOF {
+		http.Error(w, "bad report body", http.StatusBadRequest)
+		return
+	}
+	// addressbook-multiget lists wanted cards, unknown cards get 404 status as RFC 6352 requires,
+	// addressbook-query filters are not supported and return all cards
+	if len(report.Hrefs) > 0 {
+		for _, href := range report.Hrefs {
+			id, ok := h.cardID(href)
+			if !ok {
+				responses = append(responses, cardDAVResponse{Href: href, NotFound: true})
+				continue
+			}
+			card, err := h.e.GetContactVCard(id)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			if card == "" {
+				responses = append(responses, cardDAVResponse{Href: href, NotFound: true})
+				continue
+			}
+			responses = append(responses, cardDAVResponse{Href: href, ETag: cardDAVETag(card), Data: card, WithData: true})
+		}
+	} else {
+		responses, _, err = h.cards(true)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+	h.multistatus(w, responses)
+}
+
+func (h *cardDAVHandler) multistatus(w http.ResponseWriter, responses []cardDAVResponse) {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<d:multistatus xmlns:d="DAV:" xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:cs="http://calendarserver.org/ns/">`)
+	for _, response := range responses {
+		if response.NotFound {
+			b.WriteString(`<d:response><d:href>` + xmlEscape(response.Href) + `</d:href><d:status>HTTP/1.1 404 Not Found</d:status></d:response>`)
+			continue
+		}
+		b.WriteString(`<d:response><d:href>` + xmlEscape(response.Href) + `</d:href><d:propstat><d:prop>`)
+		if response.Collection {
+			b.WriteString(`<d:resourcetype><d:collection/>`)
+			if response.AddressBook {
+				b.WriteString(`<card:addressbook/>`)
+			}
+			b.WriteString(`</d:resourcetype>`)
+		} else {
+			b.WriteString(`<d:resourcetype/><d:getcontenttype>text/vcard; charset=utf-8</d:getcontenttype>`)
+		}
+		if response.WithHomeSets {
+			b.WriteString(`<d:current-user-principal><d:href>` + xmlEscape(h.prefix) + `</d:href></d:current-user-principal>`)
+			b.WriteString(`<card:addressbook-home-set><d:href>` + xmlEscape(h.prefix) + `</d:href></card:addressbook-home-set>`)
+		}
+		if response.DisplayName != "" {
+			b.WriteString(`<d:displayname>` + xmlEscape(response.DisplayName) + `</d:displayname>`)
+		}
+		if response.CTag != "" {
+			b.WriteString(`<cs:getctag>` + xmlEscape(response.CTag) + `</cs:getctag>`)
+		}
+		if response.ETag != "" {
+			b.WriteString(`<d:getetag>` + xmlEscape(response.ETag) + `</d:getetag>`)
+		}
+		if response.WithData {
+			b.WriteString(`<card:address-data>` + xmlEscape(response.Data) + `</card:address-data>`)
+		}
+		b.WriteString(`</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`)
+	}
+	b.WriteString(`</d:multistatus>`)
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(207)
+	_, err := io.WriteString(w, b.String())
+	if err != nil {
+		log.Println("cardDAVHandler multistatus io.WriteString ", err)
+	}
+}
@@ -0,0 +1,32 @@
+package epgc
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCardDAVMultigetNotFound(t *testing.T) {
+	h := &cardDAVHandler{prefix: "/dav/"}
+	body := `<?xml version="1.0" encoding="utf-8"?>` +
+		`<card:addressbook-multiget xmlns:d="DAV:" xmlns:card="urn:ietf:params:xml:ns:carddav">` +
+		`<d:prop><d:getetag/><card:address-data/></d:prop>` +
+		`<d:href>/dav/contacts/abc.vcf</d:href><d:href>/other/1.vcf</d:href>` +
+		`</card:addressbook-multiget>`
+	r := httptest.NewRequest("REPORT", "/dav/contacts/", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != 207 {
+		t.Fatalf("status = %d, want 207", w.Code)
+	}
+	got := w.Body.String()
+	for _, href := range []string{"/dav/contacts/abc.vcf", "/other/1.vcf"} {
+		want := `<d:response><d:href>` + href + `</d:href><d:status>HTTP/1.1 404 Not Found</d:status></d:response>`
+		if !strings.Contains(got, want) {
+			t.Errorf("missing 404 response for %s in %s", href, got)
+		}
+	}
+	if strings.Contains(got, "200 OK") {
+		t.Errorf("unexpected found response in %s", got)
+	}
+}
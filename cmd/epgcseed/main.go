@@ -0,0 +1,45 @@
+// Command epgcseed fills database with generated companies, contacts, practices and sirens for demo and load testing.
+//
+//	epgcseed -dsn "user=edds dbname=edds_demo sslmode=disable" -seed 1 -companies 1000
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/serbe/epgc"
+)
+
+func main() {
+	var opt epgc.SeedOptions
+	dsn := flag.String("dsn", os.Getenv("EPGC_DSN"), "connection string of database, EPGC_DSN by default")
+	flag.Int64Var(&opt.Seed, "seed", 1, "seed of random generator")
+	flag.IntVar(&opt.Companies, "companies", 50, "number of companies")
+	flag.IntVar(&opt.ContactsPerCompany, "contacts", 5, "contacts per company")
+	flag.IntVar(&opt.PracticesPerCompany, "practices", 3, "practices per company")
+	flag.IntVar(&opt.SirensPerCompany, "sirens", 1, "sirens per company")
+	flag.IntVar(&opt.FirstYear, "first-year", 0, "first year of practices, 5 years before last year by default")
+	flag.IntVar(&opt.LastYear, "last-year", epgc.DefaultSeedYear, "last year of practices")
+	flag.Parse()
+	// zero in flags is no rows, in SeedOptions it is default
+	for _, count := range []*int{&opt.Companies, &opt.ContactsPerCompany, &opt.PracticesPerCompany, &opt.SirensPerCompany} {
+		if *count == 0 {
+			*count = epgc.SeedNone
+		}
+	}
+	if *dsn == "" {
+		log.Fatal("epgcseed: -dsn or EPGC_DSN is required")
+	}
+	e, err := epgc.Open(*dsn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer e.Close()
+	report, err := e.Seed(opt)
+	if err != nil {
+		log.Fatal(err)
+	}
+	_ = json.NewEncoder(os.Stdout).Encode(report)
+}
@@ -7,24 +7,28 @@ import (
 
 // Company is struct for company
 type Company struct {
-	ID        int64            `sql:"id" json:"id"`
-	Name      string           `sql:"name" json:"name"`
-	Address   string           `sql:"address, null" json:"address"`
-	Scope     Scope            `sql:"-"`
-	ScopeID   int64            `sql:"scope_id, null" json:"scope_id"`
-	Note      string           `sql:"note, null" json:"note"`
-	Emails    []Email          `sql:"-"`
-	Phones    []Phone          `sql:"-"`
-	Faxes     []Phone          `sql:"-"`
-	Practices []Practice       `sql:"-"`
-	Contacts  []ContactCompany `sql:"-"`
-	CreatedAt string           `sql:"created_at" json:"created_at"`
-	UpdatedAt string           `sql:"updated_at" json:"updated_at"`
+	ID           int64            `sql:"id" json:"id"`
+	Name         string           `sql:"name" json:"name"`
+	Address      string           `sql:"address, null" json:"address"`
+	Scope        Scope            `sql:"-"`
+	ScopeID      int64            `sql:"scope_id, null" json:"scope_id"`
+	ParentID     int64            `sql:"parent_id, null" json:"parent_id"`
+	Note         string           `sql:"note, null" json:"note"`
+	Emails       []Email          `sql:"-"`
+	Phones       []Phone          `sql:"-"`
+	Faxes        []Phone          `sql:"-"`
+	Practices    []Practice       `sql:"-"`
+	Contacts     []ContactCompany `sql:"-"`
+	Departments  []DepartmentNode `sql:"-"`
+	AddressParts Address          `sql:"-"`
+	CreatedAt    string           `sql:"created_at" json:"created_at"`
+	UpdatedAt    string           `sql:"updated_at" json:"updated_at"`
 }
 
 // CompanyList is struct for list company
 type CompanyList struct {
 	ID        int64    `json:"id"`
+	ParentID  int64    `json:"parent_id"`
 	Name      string   `json:"name"`
 	Address   string   `json:"address"`
 	ScopeName string   `json:"scope_name"`
@@ -36,17 +40,18 @@ type CompanyList struct {
 
 func scanCompany(row *sql.Row) (Company, error) {
 	var (
-		sID      sql.NullInt64
-		sName    sql.NullString
-		sAddress sql.NullString
-		sScopeID sql.NullInt64
-		sNote    sql.NullString
-		sEmails  sql.NullString
-		sPhones  sql.NullString
-		sFaxes   sql.NullString
-		company  Company
+		sID       sql.NullInt64
+		sName     sql.NullString
+		sAddress  sql.NullString
+		sScopeID  sql.NullInt64
+		sParentID sql.NullInt64
+		sNote     sql.NullString
+		sEmails   sql.NullString
+		sPhones   sql.NullString
+		sFaxes    sql.NullString
+		company   Company
 	)
-	err := row.Scan(&sID, &sName, &sAddress, &sScopeID, &sNote, &sEmails, &sPhones, &sFaxes)
+	err := row.Scan(&sID, &sName, &sAddress, &sScopeID, &sParentID, &sNote, &sEmails, &sPhones, &sFaxes)
 	if err != nil {
 		log.Println("scanScope row.Scan ", err)
 		return company, err
@@ -55,6 +60,7 @@ func scanCompany(row *sql.Row) (Company, error) {
 	company.Name = n2s(sName)
 	company.Address = n2s(sAddress)
 	company.ScopeID = n2i(sScopeID)
+	company.ParentID = n2i(sParentID)
 	company.Note = n2s(sNote)
 	company.Emails = n2emails(sEmails)
 	company.Phones = n2phones(sPhones)
@@ -67,6 +73,7 @@ func scanCompaniesList(rows *sql.Rows) ([]CompanyList, error) {
 	for rows.Next() {
 		var (
 			sID        sql.NullInt64
+			sParentID  sql.NullInt64
 			sName      sql.NullString
 			sAddress   sql.NullString
 			sScopeName sql.NullString
@@ -76,12 +83,13 @@ func scanCompaniesList(rows *sql.Rows) ([]CompanyList, error) {
 			sPractices sql.NullString
 			company    CompanyList
 		)
-		err := rows.Scan(&sID, &sName, &sAddress, &sScopeName, &sEmails, &sPhones, &sFaxes, &sPractices)
+		err := rows.Scan(&sID, &sParentID, &sName, &sAddress, &sScopeName, &sEmails, &sPhones, &sFaxes, &sPractices)
 		if err != nil {
 			log.Println("scanCompaniesList rows.Scan ", err)
 			return companies, err
 		}
 		company.ID = n2i(sID)
+		company.ParentID = n2i(sParentID)
 		company.Name = n2s(sName)
 		company.Address = n2s(sAddress)
 		company.ScopeName = n2s(sScopeName)
@@ -130,12 +138,13 @@ func (e *Edb) GetCompany(id int64) (Company, error) {
 	if id == 0 {
 		return Company{}, nil
 	}
-	stmt, err := e.db.Prepare(`
+	stmt, err := e.prepare(`
 		SELECT
 			c.id,
 			c.name,
 			c.address,
 			c.scope_id,
+			c.parent_id,
 			c.note,
 			array_to_string(array_agg(DISTINCT e.email),',') AS email,
 			array_to_string(array_agg(DISTINCT p.phone),',') AS phone,
@@ -154,7 +163,7 @@ func (e *Edb) GetCompany(id int64) (Company, error) {
 			c.id
 	`)
 	if err != nil {
-		log.Println("GetCompany e.db.Prepare ", err)
+		log.Println("GetCompany e.prepare ", err)
 		return Company{}, err
 	}
 	row := stmt.QueryRow(id)
@@ -164,6 +173,16 @@ func (e *Edb) GetCompany(id int64) (Company, error) {
 		return Company{}, err
 	}
 	company.Practices, err = e.GetPracticeCompany(id)
+	if err != nil {
+		log.Println("GetCompany GetPracticeCompany ", err)
+		return company, err
+	}
+	company.Departments, err = e.GetCompanyDepartments(id)
+	if err != nil {
+		log.Println("GetCompany GetCompanyDepartments ", err)
+		return company, err
+	}
+	company.AddressParts, err = e.GetCompanyAddress(id)
 	return company, err
 }
 
@@ -172,6 +191,7 @@ func (e *Edb) GetCompanyList() ([]CompanyList, error) {
 	rows, err := e.db.Query(`
 		SELECT
 			c.id,
+			c.parent_id,
 			c.name,
 			c.address,
 			s.name AS scope_name,
@@ -226,12 +246,18 @@ func (e *Edb) GetCompanySelect() ([]SelectItem, error) {
 
 // CreateCompany - create new company
 func (e *Edb) CreateCompany(company Company) (int64, error) {
-	stmt, err := e.db.Prepare(`
+	err := e.checkCompanyParent(0, company.ParentID)
+	if err != nil {
+		log.Println("CreateCompany checkCompanyParent ", err)
+		return 0, err
+	}
+	stmt, err := e.prepare(`
 		INSERT INTO
 			companies (
 				name,
 				address,
 				scope_id,
+				parent_id,
 				note,
 				created_at
 			)
@@ -240,28 +266,30 @@ func (e *Edb) CreateCompany(company Company) (int64, error) {
 			$2,
 			$3,
 			$4,
+			$5,
 			now()
 		)
 		RETURNING id
 	`)
 	if err != nil {
-		log.Println("CreateCompany e.db.Prepare ", err)
+		log.Println("CreateCompany e.prepare ", err)
 		return 0, err
 	}
-	err = stmt.QueryRow(s2n(company.Name), s2n(company.Address), i2n(company.ScopeID), s2n(company.Note)).Scan(&company.ID)
+	err = stmt.QueryRow(s2n(company.Name), s2n(company.Address), i2n(company.ScopeID), i2n(company.ParentID), s2n(company.Note)).Scan(&company.ID)
 	if err != nil {
 		log.Println("CreateScope db.QueryRow ", err)
 		return 0, err
 	}
+	_ = e.saveAddress("company_id", company.ID, company.AddressParts, company.Address)
 	_ = e.CreateCompanyEmails(company)
 	_ = e.CreateCompanyPhones(company, false)
 	_ = e.CreateCompanyPhones(company, true)
 	return company.ID, nil
 }
 
-// UpdateCompany - save company changes
+// UpdateCompany - save company changes, parent company is not changed, it is set by SetCompanyParent
 func (e *Edb) UpdateCompany(company Company) error {
-	stmt, err := e.db.Prepare(`
+	stmt, err := e.prepare(`
 		UPDATE
 			companies
 		SET
@@ -273,7 +301,7 @@ func (e *Edb) UpdateCompany(company Company) error {
 		WHERE id=$1
 	`)
 	if err != nil {
-		log.Println("UpdateCompany e.db.Prepare ", err)
+		log.Println("UpdateCompany e.prepare ", err)
 		return err
 	}
 	_, err = stmt.Exec(i2n(company.ID), s2n(company.Name), s2n(company.Address), i2n(company.ScopeID), s2n(company.Note))
@@ -281,19 +309,67 @@ func (e *Edb) UpdateCompany(company Company) error {
 		log.Println("UpdateCompany stmt.Exec ", err)
 		return err
 	}
+	_ = e.saveAddress("company_id", company.ID, company.AddressParts, company.Address)
 	_ = e.CreateCompanyEmails(company)
 	_ = e.CreateCompanyPhones(company, false)
 	_ = e.CreateCompanyPhones(company, true)
 	return nil
 }
 
-// DeleteCompany - delete company by id
+// DeleteCompany - delete company by id with its departments
 func (e *Edb) DeleteCompany(id int64) error {
 	if id == 0 {
 		return nil
 	}
 	e.DeleteAllCompanyPhones(id)
+	_ = e.deleteAddress("company_id", id)
+	_ = e.deleteNotificationTargets("company_id", id)
+	_ = e.deleteIncidentParticipants("company_id", id)
+	_ = e.deletePracticeParticipants("company_id", id)
+	// subordinate companies are moved to parent of deleted company
 	_, err := e.db.Exec(`
+		UPDATE
+			companies AS c
+		SET
+			parent_id = d.parent_id,
+			updated_at = now()
+		FROM
+			companies AS d
+		WHERE
+			c.parent_id = $1 AND d.id = $1
+	`, id)
+	if err != nil {
+		log.Println("DeleteCompany e.db.Exec ", id, err)
+		return err
+	}
+	// departments of company are deleted, contacts in them stay without department
+	_, err = e.db.Exec(`
+		UPDATE
+			contacts AS c
+		SET
+			department_id = NULL,
+			updated_at = now()
+		FROM
+			departments AS d
+		WHERE
+			c.department_id = d.id AND d.company_id = $1
+	`, id)
+	if err != nil {
+		log.Println("DeleteCompany e.db.Exec ", id, err)
+		return err
+	}
+	_, err = e.db.Exec(`
+		DELETE FROM
+			departments
+		WHERE
+			company_id = $1
+	`, id)
+	e.InvalidateCache("departments")
+	if err != nil {
+		log.Println("DeleteCompany e.db.Exec ", id, err)
+		return err
+	}
+	_, err = e.db.Exec(`
 		DELETE FROM
 			companies
 		WHERE
@@ -313,11 +389,14 @@ func (e *Edb) companyCreateTable() error {
 				name TEXT,
 				address TEXT,
 				scope_id BIGINT,
+				parent_id BIGINT,
 				note TEXT,
 				created_at timestamp without time zone,
 				updated_at timestamp without time zone,
 				UNIQUE(name, scope_id)
-			)
+			);
+		ALTER TABLE companies ADD COLUMN IF NOT EXISTS parent_id BIGINT;
+		CREATE INDEX IF NOT EXISTS companies_parent_id_idx ON companies (parent_id);
 	`
 	_, err := e.db.Exec(str)
 	if err != nil {
@@ -0,0 +1,333 @@
+package epgc
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// ErrCompanyCycle - parent of company is company itself or one of its subordinates
+var ErrCompanyCycle = errors.New("company can not be subordinate to itself")
+
+// companyTreeDepth - limit of recursion, protects queries from cycles made outside of UpdateCompany
+const companyTreeDepth = 64
+
+// CompanyNode - company in tree of subordination with number of contacts and practices
+// of company itself and of whole subtree
+type CompanyNode struct {
+	ID               int64         `json:"id"`
+	ParentID         int64         `json:"parent_id"`
+	Name             string        `json:"name"`
+	ScopeName        string        `json:"scope_name"`
+	Level            int64         `json:"level"`
+	Contacts         int64         `json:"contacts"`
+	Practices        int64         `json:"practices"`
+	SubtreeContacts  int64         `json:"subtree_contacts"`
+	SubtreePractices int64         `json:"subtree_practices"`
+	Children         []CompanyNode `json:"children"`
+}
+
+func scanCompanyNodes(rows *sql.Rows) ([]CompanyNode, error) {
+	var nodes []CompanyNode
+	for rows.Next() {
+		var (
+			sID        sql.NullInt64
+			sParentID  sql.NullInt64
+			sName      sql.NullString
+			sScopeName sql.NullString
+			sLevel     sql.NullInt64
+			sContacts  sql.NullInt64
+			sPractices sql.NullInt64
+			node       CompanyNode
+		)
+		err := rows.Scan(&sID, &sParentID, &sName, &sScopeName, &sLevel, &sContacts, &sPractices)
+		if err != nil {
+			log.Println("scanCompanyNodes rows.Scan ", err)
+			return nodes, err
+		}
+		node.ID = n2i(sID)
+		node.ParentID = n2i(sParentID)
+		node.Name = n2s(sName)
+		node.ScopeName = n2s(sScopeName)
+		node.Level = n2i(sLevel)
+		node.Contacts = n2i(sContacts)
+		node.Practices = n2i(sPractices)
+		nodes = append(nodes, node)
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Println("scanCompanyNodes rows.Err ", err)
+	}
+	return nodes, err
+}
+
+// treeLinks - forest of n nodes given by id and parent id of node with index. Nodes without parent in list are
+// roots, node of cycle not reached from other roots becomes root too, so every node is in forest once.
+// Roots and children are indexes of nodes in order of list.
+func treeLinks(n int, id func(i int) int64, parentID func(i int) int64) ([]int, [][]int) {
+	index := make(map[int64]int, n)
+	for i := 0; i < n; i++ {
+		index[id(i)] = i
+	}
+	var (
+		roots    []int
+		children = make([][]int, n)
+		linked   = make([][]int, n)
+		visited  = make([]bool, n)
+	)
+	for i := 0; i < n; i++ {
+		parent, ok := index[parentID(i)]
+		if parentID(i) != 0 && ok && parent != i {
+			linked[parent] = append(linked[parent], i)
+		} else {
+			roots = append(roots, i)
+		}
+	}
+	var visit func(i int)
+	visit = func(i int) {
+		visited[i] = true
+		for _, child := range linked[i] {
+			if !visited[child] {
+				children[i] = append(children[i], child)
+				visit(child)
+			}
+		}
+	}
+	for _, root := range roots {
+		visit(root)
+	}
+	for i := 0; i < n; i++ {
+		if !visited[i] {
+			roots = append(roots, i)
+			visit(i)
+		}
+	}
+	return roots, children
+}
+
+// buildCompanyTree - nest nodes under their parents and sum counts of subtrees,
+// nodes without parent in list and nodes of cycles are roots
+func buildCompanyTree(nodes []CompanyNode) []CompanyNode {
+	roots, children := treeLinks(len(nodes), func(i int) int64 {
+		return nodes[i].ID
+	}, func(i int) int64 {
+		return nodes[i].ParentID
+	})
+	var build func(i int, level int64) CompanyNode
+	build = func(i int, level int64) CompanyNode {
+		node := nodes[i]
+		node.Level = level
+		node.SubtreeContacts = node.Contacts
+		node.SubtreePractices = node.Practices
+		node.Children = []CompanyNode{}
+		for _, c := range children[i] {
+			child := build(c, level+1)
+			node.SubtreeContacts += child.SubtreeContacts
+			node.SubtreePractices += child.SubtreePractices
+			node.Children = append(node.Children, child)
+		}
+		return node
+	}
+	tree := []CompanyNode{}
+	for _, root := range roots {
+		tree = append(tree, build(root, 0))
+	}
+	return tree
+}
+
+// getCompanyNodes - companies of subtrees starting at rows matching rootFilter on companies AS c
+func (e *Edb) getCompanyNodes(rootFilter string, args ...interface{}) ([]CompanyNode, error) {
+	rows, err := e.db.Query(`
+		WITH RECURSIVE
+			tree AS (
+				SELECT
+					c.id,
+					0 AS level
+				FROM
+					companies AS c
+				WHERE
+					`+rootFilter+`
+				UNION ALL
+				SELECT
+					c.id,
+					t.level + 1
+				FROM
+					companies AS c
+				JOIN
+					tree AS t ON c.parent_id = t.id
+				WHERE
+					t.level < `+strconv.Itoa(companyTreeDepth)+`
+			)
+		SELECT
+			c.id,
+			c.parent_id,
+			c.name,
+			s.name AS scope_name,
+			t.level,
+			(SELECT count(*) FROM contacts AS co WHERE co.company_id = c.id) AS contacts,
+			(SELECT count(*) FROM practices AS p WHERE p.company_id = c.id) AS practices
+		FROM
+			(SELECT id, min(level) AS level FROM tree GROUP BY id) AS t
+		JOIN
+			companies AS c ON c.id = t.id
+		LEFT JOIN
+			scopes AS s ON c.scope_id = s.id
+		ORDER BY
+			t.level ASC,
+			c.name ASC
+	`, args...)
+	if err != nil {
+		log.Println("getCompanyNodes e.db.Query ", err)
+		return []CompanyNode{}, err
+	}
+	return scanCompanyNodes(rows)
+}
+
+// GetCompanyTree - get all companies as tree of subordination, companies without parent are roots
+func (e *Edb) GetCompanyTree() ([]CompanyNode, error) {
+	nodes, err := e.getCompanyNodes(`c.parent_id IS NULL OR NOT EXISTS (SELECT 1 FROM companies AS p WHERE p.id = c.parent_id)`)
+	if err != nil {
+		log.Println("GetCompanyTree getCompanyNodes ", err)
+		return []CompanyNode{}, err
+	}
+	return buildCompanyTree(nodes), nil
+}
+
+// GetCompanySubtree - get company with all its subordinate companies
+func (e *Edb) GetCompanySubtree(id int64) (CompanyNode, error) {
+	nodes, err := e.getCompanyNodes(`c.id = $1`, id)
+	if err != nil {
+		log.Println("GetCompanySubtree getCompanyNodes ", err)
+		return CompanyNode{}, err
+	}
+	if len(nodes) == 0 {
+		return CompanyNode{}, sql.ErrNoRows
+	}
+	// company is the only root when its id is dropped from parents
+	nodes[0].ParentID = 0
+	return buildCompanyTree(nodes)[0], nil
+}
+
+// GetCompanyAncestors - get parent companies from root to direct parent
+func (e *Edb) GetCompanyAncestors(id int64) ([]SelectItem, error) {
+	rows, err := e.db.Query(`
+		WITH RECURSIVE
+			up AS (
+				SELECT
+					c.id,
+					c.parent_id,
+					c.name,
+					0 AS depth
+				FROM
+					companies AS c
+				WHERE
+					c.id = $1
+				UNION ALL
+				SELECT
+					c.id,
+					c.parent_id,
+					c.name,
+					u.depth + 1
+				FROM
+					companies AS c
+				JOIN
+					up AS u ON c.id = u.parent_id
+				WHERE
+					u.depth < `+strconv.Itoa(companyTreeDepth)+` AND c.id <> $1
+			)
+		SELECT
+			id,
+			name
+		FROM
+			up
+		WHERE
+			depth > 0
+		ORDER BY
+			depth DESC
+	`, id)
+	if err != nil {
+		log.Println("GetCompanyAncestors e.db.Query ", err)
+		return []SelectItem{}, err
+	}
+	return scanCompaniesSelect(rows)
+}
+
+// GetCompanyPath - get names of parent companies and company itself separated by " / "
+func (e *Edb) GetCompanyPath(id int64) (string, error) {
+	ancestors, err := e.GetCompanyAncestors(id)
+	if err != nil {
+		log.Println("GetCompanyPath GetCompanyAncestors ", err)
+		return "", err
+	}
+	company, err := e.GetCompany(id)
+	if err != nil {
+		log.Println("GetCompanyPath GetCompany ", err)
+		return "", err
+	}
+	var names []string
+	for _, item := range ancestors {
+		names = append(names, item.Name)
+	}
+	return strings.Join(append(names, company.Name), " / "), nil
+}
+
+// SetCompanyParent - make company subordinate to parent company, parentID 0 makes company top level.
+// sql.ErrNoRows if parent company is missing, ErrCompanyCycle if parent is company itself or in its subtree
+func (e *Edb) SetCompanyParent(id, parentID int64) error {
+	err := e.checkCompanyParent(id, parentID)
+	if err != nil {
+		log.Println("SetCompanyParent checkCompanyParent ", err)
+		return err
+	}
+	_, err = e.db.Exec(`
+		UPDATE
+			companies
+		SET
+			parent_id = $2,
+			updated_at = now()
+		WHERE
+			id = $1
+	`, id, i2n(parentID))
+	if err != nil {
+		log.Println("SetCompanyParent e.db.Exec ", err)
+	}
+	return err
+}
+
+// checkCompanyParent - sql.ErrNoRows if parent company is missing, ErrCompanyCycle if parentID is company itself
+// or in its subtree
+func (e *Edb) checkCompanyParent(id, parentID int64) error {
+	if parentID == 0 {
+		return nil
+	}
+	if id == parentID {
+		return ErrCompanyCycle
+	}
+	var sID sql.NullInt64
+	err := e.db.QueryRow(`
+		SELECT
+			id
+		FROM
+			companies
+		WHERE
+			id = $1
+	`, parentID).Scan(&sID)
+	if err != nil {
+		return err
+	}
+	if id == 0 {
+		return nil
+	}
+	ancestors, err := e.GetCompanyAncestors(parentID)
+	if err != nil {
+		return err
+	}
+	for _, item := range ancestors {
+		if item.ID == id {
+			return ErrCompanyCycle
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package epgc
+
+import "testing"
+
+func TestBuildCompanyTree(t *testing.T) {
+	nodes := []CompanyNode{
+		{ID: 1, Name: "Министерство", Contacts: 2},
+		{ID: 5, ParentID: 99, Name: "Сирота", Contacts: 1},
+		{ID: 2, ParentID: 1, Name: "Управление", Contacts: 3, Practices: 1},
+		{ID: 3, ParentID: 1, Name: "Учреждение", Practices: 2},
+		{ID: 4, ParentID: 2, Name: "Филиал", Contacts: 4, Practices: 5},
+	}
+	tree := buildCompanyTree(nodes)
+	if len(tree) != 2 || tree[0].ID != 1 || tree[1].ID != 5 {
+		t.Fatalf("roots = %+v", tree)
+	}
+	root := tree[0]
+	if root.SubtreeContacts != 9 || root.SubtreePractices != 8 || len(root.Children) != 2 {
+		t.Errorf("root = %+v", root)
+	}
+	branch := root.Children[0]
+	if branch.ID != 2 || branch.Level != 1 || branch.SubtreeContacts != 7 || len(branch.Children) != 1 || branch.Children[0].Level != 2 {
+		t.Errorf("branch = %+v", branch)
+	}
+	if tree[1].Level != 0 || len(tree[1].Children) != 0 {
+		t.Errorf("orphan = %+v", tree[1])
+	}
+}
+
+// companyTreeIDs - ids of all nodes of tree in depth first order
+func companyTreeIDs(tree []CompanyNode) []int64 {
+	var ids []int64
+	for _, node := range tree {
+		ids = append(ids, node.ID)
+		ids = append(ids, companyTreeIDs(node.Children)...)
+	}
+	return ids
+}
+
+func TestBuildCompanyTreeCycle(t *testing.T) {
+	// rows changed outside of UpdateCompany may form cycle, every company is still shown once
+	nodes := []CompanyNode{
+		{ID: 1, ParentID: 0, Name: "Корень"},
+		{ID: 2, ParentID: 3, Name: "А", Contacts: 1},
+		{ID: 3, ParentID: 2, Name: "Б", Contacts: 2},
+		{ID: 4, ParentID: 3, Name: "В", Contacts: 4},
+		{ID: 5, ParentID: 5, Name: "Сам себе"},
+	}
+	tree := buildCompanyTree(nodes)
+	ids := companyTreeIDs(tree)
+	want := []int64{1, 5, 2, 3, 4}
+	if len(ids) != len(want) {
+		t.Fatalf("tree ids = %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("tree ids = %v, want %v", ids, want)
+		}
+	}
+	if len(tree) != 3 || tree[2].ID != 2 || tree[2].SubtreeContacts != 7 || tree[2].Children[0].Children[0].Level != 2 {
+		t.Errorf("cycle root = %+v", tree)
+	}
+}
+
+func TestTreeLinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		parents map[int64]int64
+		ids     []int64
+		roots   []int
+	}{
+		{"empty", nil, nil, nil},
+		{"chain", map[int64]int64{2: 1, 3: 2}, []int64{1, 2, 3}, []int{0}},
+		{"orphan", map[int64]int64{2: 9}, []int64{1, 2}, []int{0, 1}},
+		{"cycle", map[int64]int64{1: 3, 2: 1, 3: 2}, []int64{1, 2, 3}, []int{0}},
+		{"two cycles", map[int64]int64{1: 2, 2: 1, 3: 4, 4: 3}, []int64{1, 2, 3, 4}, []int{0, 2}},
+	}
+	for _, tt := range tests {
+		roots, children := treeLinks(len(tt.ids), func(i int) int64 {
+			return tt.ids[i]
+		}, func(i int) int64 {
+			return tt.parents[tt.ids[i]]
+		})
+		if len(roots) != len(tt.roots) {
+			t.Errorf("%s: roots = %v, want %v", tt.name, roots, tt.roots)
+			continue
+		}
+		for i := range roots {
+			if roots[i] != tt.roots[i] {
+				t.Errorf("%s: roots = %v, want %v", tt.name, roots, tt.roots)
+			}
+		}
+		seen := make(map[int]int)
+		for _, root := range roots {
+			seen[root]++
+		}
+		for _, list := range children {
+			for _, child := range list {
+				seen[child]++
+			}
+		}
+		for i := range tt.ids {
+			if seen[i] != 1 {
+				t.Errorf("%s: node %d is in forest %d times", tt.name, tt.ids[i], seen[i])
+			}
+		}
+	}
+}
+
+func TestBuildDepartmentTree(t *testing.T) {
+	tree := buildDepartmentTree([]DepartmentNode{
+		{ID: 1, Name: "Дирекция", Contacts: 1},
+		{ID: 2, ParentID: 1, Name: "Бухгалтерия", Contacts: 2},
+		{ID: 3, ParentID: 4, Name: "Цех", Contacts: 3},
+		{ID: 4, ParentID: 3, Name: "Участок", Contacts: 4},
+	})
+	if len(tree) != 2 || tree[0].SubtreeContacts != 3 || tree[0].Children[0].Level != 1 {
+		t.Fatalf("department roots = %+v", tree)
+	}
+	if tree[1].ID != 3 || tree[1].SubtreeContacts != 7 || len(tree[1].Children) != 1 || tree[1].Children[0].ID != 4 {
+		t.Errorf("department cycle = %+v", tree[1])
+	}
+}
@@ -2,6 +2,7 @@ package epgc
 
 import (
 	"log"
+	"strconv"
 
 	"database/sql"
 
@@ -12,6 +13,9 @@ import (
 type Contact struct {
 	ID           int64       `sql:"id" json:"id"`
 	Name         string      `sql:"name" json:"name"`
+	Surname      string      `sql:"surname, null" json:"surname"`
+	FirstName    string      `sql:"first_name, null" json:"first_name"`
+	Patronymic   string      `sql:"patronymic, null" json:"patronymic"`
 	Company      Company     `sql:"-"`
 	CompanyID    int64       `sql:"company_id, null" json:"company_id"`
 	Department   Department  `sql:"-"`
@@ -36,6 +40,7 @@ type Contact struct {
 type ContactList struct {
 	ID          int64    `json:"id"`
 	Name        string   `json:"name"`
+	ShortName   string   `json:"short_name"`
 	CompanyID   int64    `json:"company_id"`
 	CompanyName string   `json:"company_name"`
 	PostName    string   `json:"post_name"`
@@ -43,19 +48,31 @@ type ContactList struct {
 	Faxes       []string `json:"faxes"`
 }
 
+// Contact list orders
+const (
+	ContactOrderName    = "name"
+	ContactOrderSurname = "surname"
+)
+
 // ContactCompany is struct for company
 type ContactCompany struct {
-	ID             int64  `json:"id"`
-	Name           string `json:"name"`
-	DepartmentName string `json:"department_name"`
-	PostName       string `json:"post_name"`
-	PostGOName     string `json:"post_go_name"`
+	ID             int64    `json:"id"`
+	Name           string   `json:"name"`
+	DepartmentName string   `json:"department_name"`
+	PostName       string   `json:"post_name"`
+	PostGOName     string   `json:"post_go_name"`
+	Phones         []string `json:"phones"`
+	Faxes          []string `json:"faxes"`
+	Emails         []string `json:"emails"`
 }
 
 func scanContact(row *sql.Row) (Contact, error) {
 	var (
 		sID           sql.NullInt64
 		sName         sql.NullString
+		sSurname      sql.NullString
+		sFirstName    sql.NullString
+		sPatronymic   sql.NullString
 		sCompanyID    sql.NullInt64
 		sDepartmentID sql.NullInt64
 		sPostID       sql.NullInt64
@@ -68,13 +85,16 @@ func scanContact(row *sql.Row) (Contact, error) {
 		sFaxes        sql.NullString
 		contact       Contact
 	)
-	err := row.Scan(&sID, &sName, &sCompanyID, &sDepartmentID, &sPostID, &sPostGOID, &sRankID, &sBirthday, &sNote, &sEmails, &sPhones, &sFaxes)
+	err := row.Scan(&sID, &sName, &sSurname, &sFirstName, &sPatronymic, &sCompanyID, &sDepartmentID, &sPostID, &sPostGOID, &sRankID, &sBirthday, &sNote, &sEmails, &sPhones, &sFaxes)
 	if err != nil {
 		log.Println("scanContact row.Scan ", err)
 		return Contact{}, err
 	}
 	contact.ID = n2i(sID)
 	contact.Name = n2s(sName)
+	contact.Surname = n2s(sSurname)
+	contact.FirstName = n2s(sFirstName)
+	contact.Patronymic = n2s(sPatronymic)
 	contact.CompanyID = n2i(sCompanyID)
 	contact.DepartmentID = n2i(sDepartmentID)
 	contact.PostID = n2i(sPostID)
@@ -95,6 +115,9 @@ func scanContactsList(rows *sql.Rows) ([]ContactList, error) {
 		var (
 			sID          sql.NullInt64
 			sName        sql.NullString
+			sSurname     sql.NullString
+			sFirstName   sql.NullString
+			sPatronymic  sql.NullString
 			sCompanyID   sql.NullInt64
 			sCompanyName sql.NullString
 			sPostName    sql.NullString
@@ -102,13 +125,14 @@ func scanContactsList(rows *sql.Rows) ([]ContactList, error) {
 			sFaxes       sql.NullString
 			contact      ContactList
 		)
-		err := rows.Scan(&sID, &sName, &sCompanyID, &sCompanyName, &sPostName, &sPhones, &sFaxes)
+		err := rows.Scan(&sID, &sName, &sSurname, &sFirstName, &sPatronymic, &sCompanyID, &sCompanyName, &sPostName, &sPhones, &sFaxes)
 		if err != nil {
 			log.Println("scanContactsList rows.Scan ", err)
 			return contacts, err
 		}
 		contact.ID = n2i(sID)
 		contact.Name = n2s(sName)
+		contact.ShortName = Contact{Name: contact.Name, Surname: n2s(sSurname), FirstName: n2s(sFirstName), Patronymic: n2s(sPatronymic)}.Initials()
 		contact.CompanyID = n2i(sCompanyID)
 		contact.CompanyName = n2s(sCompanyName)
 		contact.PostName = n2s(sPostName)
@@ -151,21 +175,29 @@ func scanContactsCompany(rows *sql.Rows) ([]ContactCompany, error) {
 	var contacts []ContactCompany
 	for rows.Next() {
 		var (
-			sID         sql.NullInt64
-			sName       sql.NullString
-			sPostName   sql.NullString
-			sPostGOName sql.NullString
-			contact     ContactCompany
+			sID             sql.NullInt64
+			sName           sql.NullString
+			sDepartmentName sql.NullString
+			sPostName       sql.NullString
+			sPostGOName     sql.NullString
+			sPhones         sql.NullString
+			sFaxes          sql.NullString
+			sEmails         sql.NullString
+			contact         ContactCompany
 		)
-		err := rows.Scan(&sID, &sName, &sPostName, &sPostGOName)
+		err := rows.Scan(&sID, &sName, &sDepartmentName, &sPostName, &sPostGOName, &sPhones, &sFaxes, &sEmails)
 		if err != nil {
 			log.Println("scanContactsCompany rows.Scan ", err)
 			return contacts, err
 		}
 		contact.ID = n2i(sID)
 		contact.Name = n2s(sName)
+		contact.DepartmentName = n2s(sDepartmentName)
 		contact.PostName = n2s(sPostName)
 		contact.PostGOName = n2s(sPostGOName)
+		contact.Phones = n2as(sPhones)
+		contact.Faxes = n2as(sFaxes)
+		contact.Emails = n2as(sEmails)
 		contacts = append(contacts, contact)
 	}
 	err := rows.Err()
@@ -175,15 +207,123 @@ func scanContactsCompany(rows *sql.Rows) ([]ContactCompany, error) {
 	return contacts, err
 }
 
+func scanContactsFull(rows *sql.Rows) ([]Contact, error) {
+	var contacts []Contact
+	for rows.Next() {
+		var (
+			sID             sql.NullInt64
+			sName           sql.NullString
+			sSurname        sql.NullString
+			sFirstName      sql.NullString
+			sPatronymic     sql.NullString
+			sCompanyID      sql.NullInt64
+			sCompanyName    sql.NullString
+			sDepartmentName sql.NullString
+			sPostName       sql.NullString
+			sPostGOName     sql.NullString
+			sBirthday       pq.NullTime
+			sNote           sql.NullString
+			sEmails         sql.NullString
+			sPhones         sql.NullString
+			sFaxes          sql.NullString
+			contact         Contact
+		)
+		err := rows.Scan(&sID, &sName, &sSurname, &sFirstName, &sPatronymic, &sCompanyID, &sCompanyName, &sDepartmentName, &sPostName, &sPostGOName, &sBirthday, &sNote, &sEmails, &sPhones, &sFaxes)
+		if err != nil {
+			log.Println("scanContactsFull rows.Scan ", err)
+			return contacts, err
+		}
+		contact.ID = n2i(sID)
+		contact.Name = n2s(sName)
+		contact.Surname = n2s(sSurname)
+		contact.FirstName = n2s(sFirstName)
+		contact.Patronymic = n2s(sPatronymic)
+		contact.CompanyID = n2i(sCompanyID)
+		contact.Company.ID = contact.CompanyID
+		contact.Company.Name = n2s(sCompanyName)
+		contact.Department.Name = n2s(sDepartmentName)
+		contact.Post.Name = n2s(sPostName)
+		contact.PostGO.Name = n2s(sPostGOName)
+		contact.Birthday = n2sd(sBirthday)
+		contact.Note = n2s(sNote)
+		contact.Emails = n2emails(sEmails)
+		contact.Phones = n2phones(sPhones)
+		contact.Faxes = n2faxes(sFaxes)
+		contacts = append(contacts, contact)
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Println("scanContactsFull rows.Err ", err)
+	}
+	return contacts, err
+}
+
+// getContactsFull - get contacts with names of company, department and posts, filter is sql condition on contacts AS c
+// and companies AS co
+func (e *Edb) getContactsFull(filter string, args ...interface{}) ([]Contact, error) {
+	rows, err := e.db.Query(`
+		SELECT
+			c.id,
+			c.name,
+			c.surname,
+			c.first_name,
+			c.patronymic,
+			c.company_id,
+			co.name AS company_name,
+			d.name AS department_name,
+			po.name AS post_name,
+			pog.name AS post_go_name,
+			c.birthday,
+			c.note,
+			array_to_string(array_agg(DISTINCT e.email),',') AS email,
+			array_to_string(array_agg(DISTINCT ph.phone),',') AS phone,
+			array_to_string(array_agg(DISTINCT f.phone),',') AS fax
+		FROM
+			contacts AS c
+		LEFT JOIN
+			companies AS co ON c.company_id = co.id
+		LEFT JOIN
+			departments AS d ON c.department_id = d.id
+		LEFT JOIN
+			posts AS po ON c.post_id = po.id
+		LEFT JOIN
+			posts AS pog ON c.post_go_id = pog.id
+		LEFT JOIN
+			emails AS e ON c.id = e.contact_id
+		LEFT JOIN
+			phones AS ph ON c.id = ph.contact_id AND ph.fax = false
+		LEFT JOIN
+			phones AS f ON c.id = f.contact_id AND f.fax = true
+		WHERE
+			`+filter+`
+		GROUP BY
+			c.id,
+			co.name,
+			d.name,
+			po.name,
+			pog.name
+		ORDER BY
+			c.name ASC
+	`, args...)
+	if err != nil {
+		log.Println("getContactsFull e.db.Query ", err)
+		return []Contact{}, err
+	}
+	return scanContactsFull(rows)
+}
+
 // GetContact - get one contact by id
 func (e *Edb) GetContact(id int64) (Contact, error) {
 	if id == 0 {
 		return Contact{}, nil
 	}
-	stmt, err := e.db.Prepare(`
+	stmt, err := e.prepare(`
 		SELECT
 			c.id,
 			c.name,
+			c.surname,
+			c.first_name,
+			c.patronymic,
 			c.company_id,
 			c.department_id,
 			c.post_id,
@@ -208,7 +348,7 @@ func (e *Edb) GetContact(id int64) (Contact, error) {
 			c.id
 	`)
 	if err != nil {
-		log.Println("GetContact e.db.Prepare ", err)
+		log.Println("GetContact e.prepare ", err)
 		return Contact{}, err
 	}
 	row := stmt.QueryRow(id)
@@ -219,10 +359,22 @@ func (e *Edb) GetContact(id int64) (Contact, error) {
 
 // GetContactList - get all contacts for list
 func (e *Edb) GetContactList() ([]ContactList, error) {
+	return e.GetContactListOrder(ContactOrderName)
+}
+
+// GetContactListOrder - get all contacts for list sorted by full name or by surname
+func (e *Edb) GetContactListOrder(order string) ([]ContactList, error) {
+	orderBy := "c.name ASC"
+	if order == ContactOrderSurname {
+		orderBy = "c.surname ASC, c.first_name ASC, c.patronymic ASC, c.name ASC"
+	}
 	rows, err := e.db.Query(`
 		SELECT
 			c.id,
 			c.name,
+			c.surname,
+			c.first_name,
+			c.patronymic,
 			co.id AS company_id,
 			co.name AS company_name,
 			po.name AS post_name,
@@ -243,10 +395,10 @@ func (e *Edb) GetContactList() ([]ContactList, error) {
 			co.id,
 			po.name
 		ORDER BY
-			name ASC
+			` + orderBy + `
 	`)
 	if err != nil {
-		log.Println("GetContactList e.db.Query ", err)
+		log.Println("GetContactListOrder e.db.Query ", err)
 		return []ContactList{}, err
 	}
 	contacts, err := scanContactsList(rows)
@@ -274,30 +426,73 @@ func (e *Edb) GetContactSelect() ([]SelectItem, error) {
 
 // GetContactCompany - get all contacts from company
 func (e *Edb) GetContactCompany(id int64) ([]ContactCompany, error) {
-	stmt, err := e.db.Prepare(`
+	return e.GetContactDepartment(id, 0)
+}
+
+// GetContactDepartment - get contacts from company working in department or its sub-departments,
+// all contacts of company when departmentID is 0
+func (e *Edb) GetContactDepartment(id int64, departmentID int64) ([]ContactCompany, error) {
+	stmt, err := e.prepare(`
+		WITH RECURSIVE
+			sub AS (
+				SELECT
+					d.id,
+					0 AS depth
+				FROM
+					departments AS d
+				WHERE
+					d.id = $2
+				UNION ALL
+				SELECT
+					d.id,
+					s.depth + 1
+				FROM
+					departments AS d
+				JOIN
+					sub AS s ON d.parent_id = s.id
+				WHERE
+					s.depth < ` + strconv.Itoa(companyTreeDepth) + `
+			)
 		SELECT
 			c.id,
 			c.name,
+			d.name AS department_name,
 			po.name AS post_name,
-			pog.name AS post_go_name
+			pog.name AS post_go_name,
+			array_to_string(array_agg(DISTINCT ph.phone),',') AS phone,
+			array_to_string(array_agg(DISTINCT f.phone),',') AS fax,
+			array_to_string(array_agg(DISTINCT em.email),',') AS email
 		FROM
 			contacts AS c
+		LEFT JOIN
+			departments AS d ON c.department_id = d.id
 		LEFT JOIN
 			posts AS po ON c.post_id = po.id
 		LEFT JOIN
 			posts AS pog ON c.post_go_id = pog.id
+		LEFT JOIN
+			phones AS ph ON c.id = ph.contact_id AND ph.fax = false
+		LEFT JOIN
+			phones AS f ON c.id = f.contact_id AND f.fax = true
+		LEFT JOIN
+			emails AS em ON c.id = em.contact_id
 		WHERE
-			c.company_id = $1
+			c.company_id = $1 AND ($2 = 0 OR c.department_id IN (SELECT id FROM sub))
+		GROUP BY
+			c.id,
+			d.name,
+			po.name,
+			pog.name
 		ORDER BY
 			name ASC
 	`)
 	if err != nil {
-		log.Println("GetContactCompany e.db.Prepare ", err)
+		log.Println("GetContactDepartment e.prepare ", err)
 		return []ContactCompany{}, err
 	}
-	rows, err := stmt.Query(id)
+	rows, err := stmt.Query(id, departmentID)
 	if err != nil {
-		log.Println("GetContactCompany e.db.Query ", err)
+		log.Println("GetContactDepartment e.db.Query ", err)
 		return []ContactCompany{}, err
 	}
 	contacts, err := scanContactsCompany(rows)
@@ -306,10 +501,13 @@ func (e *Edb) GetContactCompany(id int64) ([]ContactCompany, error) {
 
 // CreateContact - create new contact
 func (e *Edb) CreateContact(contact Contact) (int64, error) {
-	stmt, err := e.db.Prepare(`
+	stmt, err := e.prepare(`
 		INSERT INTO
 			contacts (
 				name,
+				surname,
+				first_name,
+				patronymic,
 				company_id,
 				department_id,
 				post_id,
@@ -328,16 +526,20 @@ func (e *Edb) CreateContact(contact Contact) (int64, error) {
 			$6,
 			$7,
 			$8,
+			$9,
+			$10,
+			$11,
 			now()
 		)
 		RETURNING
 			id
 	`)
 	if err != nil {
-		log.Println("CreateContact e.db.Prepare ", err)
+		log.Println("CreateContact e.prepare ", err)
 		return 0, err
 	}
-	err = stmt.QueryRow(s2n(contact.Name), i2n(contact.CompanyID), i2n(contact.DepartmentID), i2n(contact.PostID), i2n(contact.PostGOID), i2n(contact.RankID), sd2n(contact.Birthday), s2n(contact.Note)).Scan(&contact.ID)
+	contact.setNameParts()
+	err = stmt.QueryRow(s2n(contact.Name), s2n(contact.Surname), s2n(contact.FirstName), s2n(contact.Patronymic), i2n(contact.CompanyID), i2n(contact.DepartmentID), i2n(contact.PostID), i2n(contact.PostGOID), i2n(contact.RankID), sd2n(contact.Birthday), s2n(contact.Note)).Scan(&contact.ID)
 	if err != nil {
 		log.Println("CreateContact db.QueryRow ", err)
 		return 0, err
@@ -351,7 +553,7 @@ func (e *Edb) CreateContact(contact Contact) (int64, error) {
 
 // UpdateContact - save contact changes
 func (e *Edb) UpdateContact(contact Contact) error {
-	stmt, err := e.db.Prepare(`
+	stmt, err := e.prepare(`
 		UPDATE
 			contacts
 		SET
@@ -363,15 +565,36 @@ func (e *Edb) UpdateContact(contact Contact) error {
 			rank_id=$7,
 			birthday=$8,
 			note=$9,
+			surname=$10,
+			first_name=$11,
+			patronymic=$12,
 			updated_at = now()
 		WHERE
 			id = $1
 	`)
 	if err != nil {
-		log.Println("UpdateContact e.db.Prepare ", err)
+		log.Println("UpdateContact e.prepare ", err)
+		return err
+	}
+	var sName, sSurname, sFirstName, sPatronymic sql.NullString
+	err = e.db.QueryRow(`
+		SELECT
+			name,
+			surname,
+			first_name,
+			patronymic
+		FROM
+			contacts
+		WHERE
+			id = $1
+	`, contact.ID).Scan(&sName, &sSurname, &sFirstName, &sPatronymic)
+	if err != nil && err != sql.ErrNoRows {
+		log.Println("UpdateContact e.db.QueryRow ", err)
 		return err
 	}
-	_, err = stmt.Exec(i2n(contact.ID), s2n(contact.Name), i2n(contact.CompanyID), i2n(contact.DepartmentID), i2n(contact.PostID), i2n(contact.PostGOID), i2n(contact.RankID), sd2n(contact.Birthday), s2n(contact.Note))
+	contact.resetNameParts(n2s(sName), PersonName{Surname: n2s(sSurname), FirstName: n2s(sFirstName), Patronymic: n2s(sPatronymic)})
+	contact.setNameParts()
+	_, err = stmt.Exec(i2n(contact.ID), s2n(contact.Name), i2n(contact.CompanyID), i2n(contact.DepartmentID), i2n(contact.PostID), i2n(contact.PostGOID), i2n(contact.RankID), sd2n(contact.Birthday), s2n(contact.Note), s2n(contact.Surname), s2n(contact.FirstName), s2n(contact.Patronymic))
 	if err != nil {
 		log.Println("UpdateContact stmt.Exec ", err)
 		return err
@@ -393,7 +616,23 @@ func (e *Edb) DeleteContact(id int64) error {
 		log.Println("DeleteContact DeleteAllContactPhones ", err)
 		return err
 	}
-	e.db.Exec(`
+	err = e.deleteNotificationTargets("contact_id", id)
+	if err != nil {
+		return err
+	}
+	err = e.deleteIncidentParticipants("contact_id", id)
+	if err != nil {
+		return err
+	}
+	err = e.deleteContactShifts(id)
+	if err != nil {
+		return err
+	}
+	err = e.deletePracticeParticipants("contact_id", id)
+	if err != nil {
+		return err
+	}
+	_, err = e.db.Exec(`
 		DELETE FROM
 			contacts
 		WHERE
@@ -411,6 +650,9 @@ func (e *Edb) contactCreateTable() error {
 			contacts (
 				id bigserial primary key,
 				name text,
+				surname text,
+				first_name text,
+				patronymic text,
 				company_id bigint,
 				department_id bigint,
 				post_id bigint,
@@ -421,7 +663,11 @@ func (e *Edb) contactCreateTable() error {
 				created_at TIMESTAMP without time zone,
 				updated_at TIMESTAMP without time zone,
 				UNIQUE(name, birthday)
-			)
+			);
+		ALTER TABLE contacts ADD COLUMN IF NOT EXISTS surname text;
+		ALTER TABLE contacts ADD COLUMN IF NOT EXISTS first_name text;
+		ALTER TABLE contacts ADD COLUMN IF NOT EXISTS patronymic text;
+		CREATE INDEX IF NOT EXISTS contacts_surname_idx ON contacts (surname, first_name, patronymic);
 	`
 	_, err := e.db.Exec(str)
 	if err != nil {
@@ -429,3 +675,42 @@ func (e *Edb) contactCreateTable() error {
 	}
 	return err
 }
+
+// SplitContactNames - fill surname, first name and patronymic of contacts where they are empty from name
+func (e *Edb) SplitContactNames() error {
+	rows, err := e.db.Query(`
+		SELECT
+			id,
+			name
+		FROM
+			contacts
+		WHERE
+			surname IS NULL AND first_name IS NULL AND patronymic IS NULL
+	`)
+	if err != nil {
+		log.Println("SplitContactNames e.db.Query ", err)
+		return err
+	}
+	contacts, err := scanContactsSelect(rows)
+	if err != nil {
+		return err
+	}
+	for _, contact := range contacts {
+		name := ParseName(contact.Name)
+		_, err = e.db.Exec(`
+			UPDATE
+				contacts
+			SET
+				surname = $2,
+				first_name = $3,
+				patronymic = $4
+			WHERE
+				id = $1
+		`, contact.ID, s2n(name.Surname), s2n(name.FirstName), s2n(name.Patronymic))
+		if err != nil {
+			log.Println("SplitContactNames e.db.Exec ", contact.ID, err)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,422 @@
+package epgc
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Case - russian grammatical case
+type Case int
+
+// Grammatical cases
+const (
+	Nominative Case = iota
+	Genitive
+	Dative
+	Accusative
+	Instrumental
+)
+
+// Gender - grammatical gender of person
+type Gender int
+
+// Genders
+const (
+	GenderUnknown Gender = iota
+	GenderMale
+	GenderFemale
+)
+
+// endings - endings for genitive, dative, accusative and instrumental cases
+type endings [4]string
+
+var caseNames = map[string]Case{
+	"nominative":   Nominative,
+	"nom":          Nominative,
+	"именительный": Nominative,
+	"и":            Nominative,
+	"genitive":     Genitive,
+	"gen":          Genitive,
+	"родительный":  Genitive,
+	"р":            Genitive,
+	"dative":       Dative,
+	"dat":          Dative,
+	"дательный":    Dative,
+	"д":            Dative,
+	"accusative":   Accusative,
+	"acc":          Accusative,
+	"винительный":  Accusative,
+	"в":            Accusative,
+	"instrumental": Instrumental,
+	"ins":          Instrumental,
+	"творительный": Instrumental,
+	"т":            Instrumental,
+}
+
+// femaleSoftNames - female first names ending with "ь"
+var femaleSoftNames = map[string]bool{"любовь": true, "нинель": true, "адель": true, "рахиль": true, "юдифь": true, "эсфирь": true}
+
+// maleVowelNames - male first names ending with "а" or "я"
+var maleVowelNames = map[string]bool{"никита": true, "илья": true, "фома": true, "кузьма": true, "лука": true, "савва": true, "фока": true, "данила": true, "гаврила": true}
+
+// fleetingVowelNames - male first names with fleeting vowel in oblique cases
+var fleetingVowelNames = map[string]string{"лев": "льв", "павел": "павл", "пётр": "петр"}
+
+// CaseByName - case by english or russian name like "dative", "dat", "дательный" or "д"
+func CaseByName(name string) Case {
+	return caseNames[strings.ToLower(strings.TrimSpace(name))]
+}
+
+func lastRunes(word string, n int) string {
+	r := []rune(word)
+	if len(r) < n {
+		return string(r)
+	}
+	return string(r[len(r)-n:])
+}
+
+// beforeEnding - letter before ending of n letters
+func beforeEnding(lower string, n int) string {
+	r := []rune(lower)
+	if len(r) <= n {
+		return ""
+	}
+	return string(r[len(r)-n-1])
+}
+
+func isUpperWord(word string) bool {
+	letters := 0
+	for _, r := range word {
+		if unicode.IsLetter(r) {
+			letters++
+			if !unicode.IsUpper(r) {
+				return false
+			}
+		}
+	}
+	return letters > 1
+}
+
+// inflect - replace last cut letters of word with ending for case, keeping upper case words upper
+func inflect(word string, cut int, e endings, c Case) string {
+	if c == Nominative {
+		return word
+	}
+	r := []rune(word)
+	if cut > len(r) {
+		return word
+	}
+	ending := e[c-1]
+	if isUpperWord(word) {
+		ending = strings.ToUpper(ending)
+	}
+	return string(r[:len(r)-cut]) + ending
+}
+
+func hasAnySuffix(word string, suffixes ...string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(word, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// aEndings - endings of words on "а" by previous letter
+func aEndings(lower string) endings {
+	prev := beforeEnding(lower, 1)
+	e := endings{"ы", "е", "у", "ой"}
+	if letterIn("гкхжшчщ", prev) {
+		e[0] = "и"
+	}
+	if letterIn("жшчщц", prev) {
+		e[3] = "ей"
+	}
+	return e
+}
+
+// consonantEndings - endings of masculine words on consonant
+func consonantEndings(lower string) endings {
+	if letterIn("жшчщц", lastRunes(lower, 1)) {
+		return endings{"а", "у", "а", "ем"}
+	}
+	return endings{"а", "у", "а", "ом"}
+}
+
+// letterIn - letter is not empty and one of set
+func letterIn(set string, letter string) bool {
+	return letter != "" && strings.Contains(set, letter)
+}
+
+func isConsonant(letter string) bool {
+	return letterIn("бвгджзклмнпрстфхцчшщ", letter)
+}
+
+func declineSurname(surname string, g Gender, c Case) string {
+	if c == Nominative || surname == "" {
+		return surname
+	}
+	if strings.Contains(surname, "-") {
+		parts := strings.Split(surname, "-")
+		for i := range parts {
+			parts[i] = declineSurname(parts[i], g, c)
+		}
+		return strings.Join(parts, "-")
+	}
+	lower := strings.ToLower(surname)
+	last := lastRunes(lower, 1)
+	if g == GenderFemale {
+		switch {
+		case hasAnySuffix(lower, "ова", "ева", "ёва", "ина", "ына"):
+			return inflect(surname, 1, endings{"ой", "ой", "у", "ой"}, c)
+		case hasAnySuffix(lower, "ская", "цкая") || (strings.HasSuffix(lower, "ая") && !letterIn("жшчщ", beforeEnding(lower, 2))):
+			return inflect(surname, 2, endings{"ой", "ой", "ую", "ой"}, c)
+		case strings.HasSuffix(lower, "ая"):
+			return inflect(surname, 2, endings{"ей", "ей", "ую", "ей"}, c)
+		case strings.HasSuffix(lower, "яя"):
+			return inflect(surname, 2, endings{"ей", "ей", "юю", "ей"}, c)
+		case strings.HasSuffix(lower, "ия"):
+			return inflect(surname, 1, endings{"и", "и", "ю", "ей"}, c)
+		case last == "а":
+			return inflect(surname, 1, aEndings(lower), c)
+		case last == "я":
+			return inflect(surname, 1, endings{"и", "е", "ю", "ей"}, c)
+		}
+		return surname
+	}
+	switch {
+	case hasAnySuffix(lower, "ых", "их") || letterIn("оеиуюыэ", last):
+		return surname
+	case hasAnySuffix(lower, "ов", "ев", "ёв", "ин", "ын"):
+		return inflect(surname, 0, endings{"а", "у", "а", "ым"}, c)
+	case hasAnySuffix(lower, "ый"):
+		return inflect(surname, 2, endings{"ого", "ому", "ого", "ым"}, c)
+	case hasAnySuffix(lower, "ой"):
+		if letterIn("гкхжшчщ", beforeEnding(lower, 2)) {
+			return inflect(surname, 2, endings{"ого", "ому", "ого", "им"}, c)
+		}
+		return inflect(surname, 2, endings{"ого", "ому", "ого", "ым"}, c)
+	case hasAnySuffix(lower, "кий", "гий", "хий"):
+		return inflect(surname, 2, endings{"ого", "ому", "ого", "им"}, c)
+	case hasAnySuffix(lower, "жий", "ший", "чий", "щий"):
+		return inflect(surname, 2, endings{"его", "ему", "его", "им"}, c)
+	case strings.HasSuffix(lower, "ия"):
+		return inflect(surname, 1, endings{"и", "и", "ю", "ей"}, c)
+	case last == "ь" || last == "й":
+		return inflect(surname, 1, endings{"я", "ю", "я", "ем"}, c)
+	case last == "а":
+		return inflect(surname, 1, aEndings(lower), c)
+	case last == "я":
+		return inflect(surname, 1, endings{"и", "е", "ю", "ей"}, c)
+	case isConsonant(last):
+		return inflect(surname, 0, consonantEndings(lower), c)
+	}
+	return surname
+}
+
+func declineFirstName(name string, g Gender, c Case) string {
+	if c == Nominative || name == "" || isInitials(name) {
+		return name
+	}
+	lower := strings.ToLower(name)
+	last := lastRunes(lower, 1)
+	if g == GenderFemale {
+		switch {
+		case strings.HasSuffix(lower, "ия"):
+			return inflect(name, 1, endings{"и", "и", "ю", "ей"}, c)
+		case last == "а":
+			return inflect(name, 1, aEndings(lower), c)
+		case last == "я":
+			return inflect(name, 1, endings{"и", "е", "ю", "ей"}, c)
+		case last == "ь":
+			return inflect(name, 1, endings{"и", "и", "ь", "ью"}, c)
+		}
+		return name
+	}
+	if stem, ok := fleetingVowelNames[lower]; ok {
+		r, _ := utf8.DecodeRuneInString(name)
+		first, size := utf8.DecodeRuneInString(stem)
+		if unicode.IsUpper(r) {
+			stem = string(unicode.ToUpper(first)) + stem[size:]
+		}
+		return inflect(stem, 0, endings{"а", "у", "а", "ом"}, c)
+	}
+	switch {
+	case last == "й" || last == "ь":
+		return inflect(name, 1, endings{"я", "ю", "я", "ем"}, c)
+	case last == "а":
+		return inflect(name, 1, aEndings(lower), c)
+	case last == "я":
+		return inflect(name, 1, endings{"и", "е", "ю", "ей"}, c)
+	case isConsonant(last):
+		return inflect(name, 0, consonantEndings(lower), c)
+	}
+	return name
+}
+
+func declinePatronymic(patronymic string, g Gender, c Case) string {
+	if c == Nominative || patronymic == "" || isInitials(patronymic) {
+		return patronymic
+	}
+	lower := strings.ToLower(patronymic)
+	switch {
+	case strings.HasSuffix(lower, "ич"):
+		return inflect(patronymic, 0, endings{"а", "у", "а", "ем"}, c)
+	case strings.HasSuffix(lower, "на"):
+		return inflect(patronymic, 1, endings{"ы", "е", "у", "ой"}, c)
+	}
+	return patronymic
+}
+
+// Gender - gender by patronymic, first name or surname
+func (n PersonName) Gender() Gender {
+	patronymic := strings.ToLower(n.Patronymic)
+	switch {
+	case hasAnySuffix(patronymic, "ич", "оглы", "улы"):
+		return GenderMale
+	case hasAnySuffix(patronymic, "на", "кызы"):
+		return GenderFemale
+	}
+	first := strings.ToLower(n.FirstName)
+	if first != "" && !isInitials(n.FirstName) {
+		switch {
+		case femaleSoftNames[first]:
+			return GenderFemale
+		case maleVowelNames[first]:
+			return GenderMale
+		case hasAnySuffix(first, "а", "я"):
+			return GenderFemale
+		}
+		return GenderMale
+	}
+	surname := strings.ToLower(n.Surname)
+	switch {
+	case hasAnySuffix(surname, "ова", "ева", "ёва", "ина", "ына", "ая"):
+		return GenderFemale
+	case hasAnySuffix(surname, "ов", "ев", "ёв", "ин", "ын", "ий", "ый", "ой"):
+		return GenderMale
+	}
+	return GenderUnknown
+}
+
+// Decline - name in case, gender is taken from patronymic or first name
+func (n PersonName) Decline(c Case) PersonName {
+	g := n.Gender()
+	if g == GenderUnknown {
+		g = GenderMale
+	}
+	return PersonName{
+		Surname:    declineSurname(n.Surname, g, c),
+		FirstName:  declineFirstName(n.FirstName, g, c),
+		Patronymic: declinePatronymic(n.Patronymic, g, c),
+	}
+}
+
+func isAdjective(lower string) bool {
+	return utf8.RuneCountInString(lower) > 3 && hasAnySuffix(lower, "ый", "ий", "ой", "ая", "яя")
+}
+
+func declineAdjective(word string, c Case) string {
+	lower := strings.ToLower(word)
+	prev := beforeEnding(lower, 2)
+	switch {
+	case strings.HasSuffix(lower, "ая") && letterIn("жшчщ", prev):
+		return inflect(word, 2, endings{"ей", "ей", "ую", "ей"}, c)
+	case strings.HasSuffix(lower, "ая"):
+		return inflect(word, 2, endings{"ой", "ой", "ую", "ой"}, c)
+	case strings.HasSuffix(lower, "яя"):
+		return inflect(word, 2, endings{"ей", "ей", "юю", "ей"}, c)
+	case strings.HasSuffix(lower, "ый"):
+		return inflect(word, 2, endings{"ого", "ому", "ого", "ым"}, c)
+	case strings.HasSuffix(lower, "ой") && letterIn("гкхжшчщ", prev):
+		return inflect(word, 2, endings{"ого", "ому", "ого", "им"}, c)
+	case strings.HasSuffix(lower, "ой"):
+		return inflect(word, 2, endings{"ого", "ому", "ого", "ым"}, c)
+	case strings.HasSuffix(lower, "ий") && letterIn("гкх", prev):
+		return inflect(word, 2, endings{"ого", "ому", "ого", "им"}, c)
+	case strings.HasSuffix(lower, "ий"):
+		return inflect(word, 2, endings{"его", "ему", "его", "им"}, c)
+	}
+	return word
+}
+
+// declineNoun - decline animate noun of post like "начальник" or "заместитель"
+func declineNoun(word string, c Case) string {
+	if strings.Contains(word, "-") {
+		parts := strings.Split(word, "-")
+		for i := range parts {
+			parts[i] = declineNoun(parts[i], c)
+		}
+		return strings.Join(parts, "-")
+	}
+	lower := strings.ToLower(word)
+	last := lastRunes(lower, 1)
+	switch {
+	case last == "ь" || last == "й":
+		return inflect(word, 1, endings{"я", "ю", "я", "ем"}, c)
+	case last == "а":
+		return inflect(word, 1, aEndings(lower), c)
+	case last == "я":
+		return inflect(word, 1, endings{"и", "е", "ю", "ей"}, c)
+	case isConsonant(last):
+		return inflect(word, 0, consonantEndings(lower), c)
+	}
+	return word
+}
+
+// DeclinePost - decline leading adjectives and first noun of post name, "Начальник отдела" in dative is "Начальнику отдела"
+func DeclinePost(post string, c Case) string {
+	if c == Nominative {
+		return post
+	}
+	words := strings.Fields(post)
+	for i, word := range words {
+		lower := strings.ToLower(word)
+		first, _ := utf8.DecodeRuneInString(word)
+		if isUpperWord(word) || !unicode.IsLetter(first) {
+			break
+		}
+		if isAdjective(lower) {
+			words[i] = declineAdjective(word, c)
+			continue
+		}
+		words[i] = declineNoun(word, c)
+		break
+	}
+	return strings.Join(words, " ")
+}
+
+// DeclineName - decline full name like "Иванов Иван Иванович"
+func DeclineName(name string, c Case) string {
+	return ParseName(name).Decline(c).Full()
+}
+
+// NameCase - full name of contact in case
+func (c Contact) NameCase(cs Case) string {
+	return c.PersonName().Decline(cs).Full()
+}
+
+// InitialsCase - surname with initials of contact in case like "Иванову И.И."
+func (c Contact) InitialsCase(cs Case) string {
+	return c.PersonName().Decline(cs).Initials()
+}
+
+// PostCase - post of contact in case, Post must be filled
+func (c Contact) PostCase(cs Case) string {
+	return DeclinePost(c.Post.Name, cs)
+}
+
+// DeclensionFuncs - functions for text/template and html/template:
+// {{declineName .Name "dative"}}, {{declineInitials .Name "дательный"}}, {{declinePost .Post.Name "д"}}
+var DeclensionFuncs = map[string]interface{}{
+	"declineName": func(name string, c string) string {
+		return DeclineName(name, CaseByName(c))
+	},
+	"declineInitials": func(name string, c string) string {
+		return ParseName(name).Decline(CaseByName(c)).Initials()
+	},
+	"declinePost": func(post string, c string) string {
+		return DeclinePost(post, CaseByName(c))
+	},
+}
@@ -0,0 +1,96 @@
+package epgc
+
+import "testing"
+
+func TestDeclineName(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Case
+		want string
+	}{
+		{"Иванов Иван Иванович", Nominative, "Иванов Иван Иванович"},
+		{"Иванов Иван Иванович", Genitive, "Иванова Ивана Ивановича"},
+		{"Иванов Иван Иванович", Dative, "Иванову Ивану Ивановичу"},
+		{"Иванов Иван Иванович", Accusative, "Иванова Ивана Ивановича"},
+		{"Иванов Иван Иванович", Instrumental, "Ивановым Иваном Ивановичем"},
+		{"Иванова Мария Ивановна", Genitive, "Ивановой Марии Ивановны"},
+		{"Иванова Мария Ивановна", Dative, "Ивановой Марии Ивановне"},
+		{"Иванова Мария Ивановна", Accusative, "Иванову Марию Ивановну"},
+		{"Иванова Мария Ивановна", Instrumental, "Ивановой Марией Ивановной"},
+		{"Петрова Анна Сергеевна", Dative, "Петровой Анне Сергеевне"},
+		{"Сидоров Павел Петрович", Genitive, "Сидорова Павла Петровича"},
+		{"Сидоров Павел Петрович", Dative, "Сидорову Павлу Петровичу"},
+		{"Сидоров Павел Петрович", Instrumental, "Сидоровым Павлом Петровичем"},
+		{"Кузнецов Пётр Ильич", Genitive, "Кузнецова Петра Ильича"},
+		{"Кузнецов Пётр Ильич", Dative, "Кузнецову Петру Ильичу"},
+		{"Толстой Лев Николаевич", Dative, "Толстому Льву Николаевичу"},
+		{"Орлова Любовь Петровна", Genitive, "Орловой Любови Петровны"},
+		{"Орлова Любовь Петровна", Dative, "Орловой Любови Петровне"},
+		{"Орлова Любовь Петровна", Accusative, "Орлову Любовь Петровну"},
+		{"Орлова Любовь Петровна", Instrumental, "Орловой Любовью Петровной"},
+		{"Иванов И.И.", Dative, "Иванову И.И."},
+	}
+	for _, tt := range tests {
+		if got := DeclineName(tt.name, tt.c); got != tt.want {
+			t.Errorf("DeclineName(%q, %d) = %q, want %q", tt.name, tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestDeclinePost(t *testing.T) {
+	tests := []struct {
+		post string
+		c    Case
+		want string
+	}{
+		{"Начальник отдела", Nominative, "Начальник отдела"},
+		{"Начальник отдела", Genitive, "Начальника отдела"},
+		{"Начальник отдела", Dative, "Начальнику отдела"},
+		{"Начальник отдела", Instrumental, "Начальником отдела"},
+		{"Главный инженер", Dative, "Главному инженеру"},
+		{"Генеральный директор", Genitive, "Генерального директора"},
+		{"Заместитель директора", Dative, "Заместителю директора"},
+		{"Старший дежурный ЕДДС", Dative, "Старшему дежурному ЕДДС"},
+		{"Главная медсестра", Dative, "Главной медсестре"},
+		{"Главный врач-терапевт", Dative, "Главному врачу-терапевту"},
+		{"ЕДДС", Dative, "ЕДДС"},
+	}
+	for _, tt := range tests {
+		if got := DeclinePost(tt.post, tt.c); got != tt.want {
+			t.Errorf("DeclinePost(%q, %d) = %q, want %q", tt.post, tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestGender(t *testing.T) {
+	tests := []struct {
+		name string
+		want Gender
+	}{
+		{"Иванов Иван Иванович", GenderMale},
+		{"Иванова Мария Ивановна", GenderFemale},
+		{"Алиев Ахмед Мамед оглы", GenderMale},
+		{"Алиева Лейла Мамед кызы", GenderFemale},
+		{"Орлова Любовь", GenderFemale},
+		{"Смирнов Никита", GenderMale},
+		{"Муромец Илья", GenderMale},
+		{"Смирнова Анна", GenderFemale},
+		{"Иванова И.И.", GenderFemale},
+		{"Петров И.И.", GenderMale},
+		{"Шевчук", GenderUnknown},
+	}
+	for _, tt := range tests {
+		if got := ParseName(tt.name).Gender(); got != tt.want {
+			t.Errorf("Gender(%q) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCaseByName(t *testing.T) {
+	tests := map[string]Case{"dative": Dative, "Д": Dative, " родительный ": Genitive, "ins": Instrumental, "unknown": Nominative}
+	for name, want := range tests {
+		if got := CaseByName(name); got != want {
+			t.Errorf("CaseByName(%q) = %d, want %d", name, got, want)
+		}
+	}
+}
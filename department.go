@@ -5,10 +5,12 @@ import (
 	"log"
 )
 
-// Department - struct for department
+// Department - struct for department, department without company is common for all companies
 type Department struct {
 	ID        int64  `sql:"id" json:"id"`
 	Name      string `sql:"name" json:"name"`
+	CompanyID int64  `sql:"company_id, null" json:"company_id"`
+	ParentID  int64  `sql:"parent_id, null" json:"parent_id"`
 	Note      string `sql:"note, null" json:"note"`
 	CreatedAt string `sql:"created_at" json:"created_at"`
 	UpdatedAt string `sql:"updated_at" json:"updated_at"`
@@ -18,16 +20,20 @@ func scanDepartment(row *sql.Row) (Department, error) {
 	var (
 		sID        sql.NullInt64
 		sName      sql.NullString
+		sCompanyID sql.NullInt64
+		sParentID  sql.NullInt64
 		sNote      sql.NullString
 		department Department
 	)
-	err := row.Scan(&sID, &sName, &sNote)
+	err := row.Scan(&sID, &sName, &sCompanyID, &sParentID, &sNote)
 	if err != nil {
 		log.Println("scanDepartment row.Scan ", err)
 		return department, err
 	}
 	department.ID = n2i(sID)
 	department.Name = n2s(sName)
+	department.CompanyID = n2i(sCompanyID)
+	department.ParentID = n2i(sParentID)
 	department.Note = n2s(sNote)
 	return department, nil
 }
@@ -38,16 +44,20 @@ func scanDepartmentsList(rows *sql.Rows) ([]Department, error) {
 		var (
 			sID        sql.NullInt64
 			sName      sql.NullString
+			sCompanyID sql.NullInt64
+			sParentID  sql.NullInt64
 			sNote      sql.NullString
 			department Department
 		)
-		err := rows.Scan(&sID, &sName, &sNote)
+		err := rows.Scan(&sID, &sName, &sCompanyID, &sParentID, &sNote)
 		if err != nil {
 			log.Println("scanDepartments list rows.Scan ", err)
 			return departments, err
 		}
 		department.ID = n2i(sID)
 		department.Name = n2s(sName)
+		department.CompanyID = n2i(sCompanyID)
+		department.ParentID = n2i(sParentID)
 		department.Note = n2s(sNote)
 		departments = append(departments, department)
 	}
@@ -87,10 +97,15 @@ func (e *Edb) GetDepartment(id int64) (Department, error) {
 	if id == 0 {
 		return Department{}, nil
 	}
+	if item, ok := e.cacheGet(cacheKey("departments", id)); ok {
+		return item.(Department), nil
+	}
 	row := e.db.QueryRow(`
 		SELECT
 			id,
 			name,
+			company_id,
+			parent_id,
 			note
 		FROM
 			departments
@@ -98,6 +113,9 @@ func (e *Edb) GetDepartment(id int64) (Department, error) {
 			id = $1
 	`, id)
 	department, err := scanDepartment(row)
+	if err == nil {
+		e.cacheSet(cacheKey("departments", id), department)
+	}
 	return department, err
 }
 
@@ -107,6 +125,8 @@ func (e *Edb) GetDepartmentList() ([]Department, error) {
 		SELECT
 			id,
 			name,
+			company_id,
+			parent_id,
 			note
 		FROM
 			departments
@@ -122,14 +142,19 @@ func (e *Edb) GetDepartmentList() ([]Department, error) {
 	return departments, err
 }
 
-// GetDepartmentSelect - get all department for select
+// GetDepartmentSelect - get common departments for select
 func (e *Edb) GetDepartmentSelect() ([]SelectItem, error) {
+	if items, ok := e.cacheSelect(cacheKey("departments", "select")); ok {
+		return items, nil
+	}
 	rows, err := e.db.Query(`
 		SELECT
 			id,
 			name
 		FROM
 			departments
+		WHERE
+			company_id IS NULL
 		ORDER BY
 			name ASC
 	`)
@@ -138,58 +163,79 @@ func (e *Edb) GetDepartmentSelect() ([]SelectItem, error) {
 		return []SelectItem{}, err
 	}
 	departments, err := scanDepartmentsSelect(rows)
+	if err == nil {
+		e.cacheSetSelect(cacheKey("departments", "select"), departments)
+	}
 	return departments, err
 }
 
 // CreateDepartment - create new department
 func (e *Edb) CreateDepartment(department Department) (int64, error) {
-	stmt, err := e.db.Prepare(`
+	err := e.checkDepartmentParent(department)
+	if err != nil {
+		log.Println("CreateDepartment checkDepartmentParent ", err)
+		return 0, err
+	}
+	stmt, err := e.prepare(`
 		INSERT INTO
 			departments (
 				name,
+				company_id,
+				parent_id,
 				note,
 				created_at
 			)
 		VALUES (
 			$1,
 			$2,
+			$3,
+			$4,
 			now()
 		)
 		RETURNING
 			id
 	`)
 	if err != nil {
-		log.Println("CreateDepartment e.db.Prepare ", err)
+		log.Println("CreateDepartment e.prepare ", err)
 		return 0, err
 	}
-	err = stmt.QueryRow(s2n(department.Name), s2n(department.Note)).Scan(&department.ID)
+	err = stmt.QueryRow(s2n(department.Name), i2n(department.CompanyID), i2n(department.ParentID), s2n(department.Note)).Scan(&department.ID)
 	if err != nil {
 		log.Println("CreateDepartment db.QueryRow ", err)
 		return 0, err
 	}
+	e.InvalidateCache("departments")
 	return department.ID, nil
 }
 
 // UpdateDepartment - save department changes
 func (e *Edb) UpdateDepartment(s Department) error {
-	stmt, err := e.db.Prepare(`
+	err := e.checkDepartmentParent(s)
+	if err != nil {
+		log.Println("UpdateDepartment checkDepartmentParent ", err)
+		return err
+	}
+	stmt, err := e.prepare(`
 		UPDATE
 			departments
 		SET
 			name=$2,
 			note=$3,
+			company_id=$4,
+			parent_id=$5,
 			updated_at = now()
 		WHERE
 			id = $1
 	`)
 	if err != nil {
-		log.Println("UpdateDepartment e.db.Prepare ", err)
+		log.Println("UpdateDepartment e.prepare ", err)
 		return err
 	}
-	_, err = stmt.Exec(i2n(s.ID), s2n(s.Name), s2n(s.Note))
+	_, err = stmt.Exec(i2n(s.ID), s2n(s.Name), s2n(s.Note), i2n(s.CompanyID), i2n(s.ParentID))
 	if err != nil {
 		log.Println("UpdateDepartment stmt.Exec ", err)
 	}
+	e.InvalidateCache("departments")
 	return err
 }
 
@@ -198,7 +244,23 @@ func (e *Edb) DeleteDepartment(id int64) error {
 	if id == 0 {
 		return nil
 	}
+	// sub-departments are moved to parent of deleted department
 	_, err := e.db.Exec(`
+		UPDATE
+			departments AS c
+		SET
+			parent_id = d.parent_id,
+			updated_at = now()
+		FROM
+			departments AS d
+		WHERE
+			c.parent_id = $1 AND d.id = $1
+	`, id)
+	if err != nil {
+		log.Println("DeleteDepartment e.db.Exec ", id, err)
+		return err
+	}
+	_, err = e.db.Exec(`
 		DELETE FROM
 			departments
 		WHERE
@@ -207,6 +269,7 @@ func (e *Edb) DeleteDepartment(id int64) error {
 	if err != nil {
 		log.Println("DeleteDepartment e.db.Exec ", id, err)
 	}
+	e.InvalidateCache("departments")
 	return err
 }
 
@@ -216,11 +279,17 @@ func (e *Edb) departmentCreateTable() error {
 			departments (
 				id bigserial primary key,
 				name text,
+				company_id bigint,
+				parent_id bigint,
 				note text,
 				created_at TIMESTAMP without time zone,
-				updated_at TIMESTAMP without time zone,
-				UNIQUE(name)
-			)
+				updated_at TIMESTAMP without time zone
+			);
+		ALTER TABLE departments ADD COLUMN IF NOT EXISTS company_id bigint;
+		ALTER TABLE departments ADD COLUMN IF NOT EXISTS parent_id bigint;
+		ALTER TABLE departments DROP CONSTRAINT IF EXISTS departments_name_key;
+		CREATE UNIQUE INDEX IF NOT EXISTS departments_name_idx ON departments (COALESCE(company_id, 0), COALESCE(parent_id, 0), name);
+		CREATE INDEX IF NOT EXISTS departments_parent_id_idx ON departments (parent_id);
 	`
 	_, err := e.db.Exec(str)
 	if err != nil {
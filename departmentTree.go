@@ -0,0 +1,202 @@
+package epgc
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+)
+
+// ErrDepartmentCycle - parent of department is department itself or one of its sub-departments
+var ErrDepartmentCycle = errors.New("department can not be sub-department of itself")
+
+// ErrDepartmentCompany - parent department belongs to other company
+var ErrDepartmentCompany = errors.New("parent department belongs to other company")
+
+// DepartmentNode - department of company in tree with number of contacts of department itself and of whole subtree
+type DepartmentNode struct {
+	ID              int64            `json:"id"`
+	ParentID        int64            `json:"parent_id"`
+	Name            string           `json:"name"`
+	Level           int64            `json:"level"`
+	Contacts        int64            `json:"contacts"`
+	SubtreeContacts int64            `json:"subtree_contacts"`
+	Children        []DepartmentNode `json:"children"`
+}
+
+func scanDepartmentNodes(rows *sql.Rows) ([]DepartmentNode, error) {
+	var nodes []DepartmentNode
+	for rows.Next() {
+		var (
+			sID       sql.NullInt64
+			sParentID sql.NullInt64
+			sName     sql.NullString
+			sContacts sql.NullInt64
+			node      DepartmentNode
+		)
+		err := rows.Scan(&sID, &sParentID, &sName, &sContacts)
+		if err != nil {
+			log.Println("scanDepartmentNodes rows.Scan ", err)
+			return nodes, err
+		}
+		node.ID = n2i(sID)
+		node.ParentID = n2i(sParentID)
+		node.Name = n2s(sName)
+		node.Contacts = n2i(sContacts)
+		nodes = append(nodes, node)
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Println("scanDepartmentNodes rows.Err ", err)
+	}
+	return nodes, err
+}
+
+// buildDepartmentTree - nest nodes under their parents and sum contacts of subtrees,
+// nodes without parent in list and nodes of cycles are roots
+func buildDepartmentTree(nodes []DepartmentNode) []DepartmentNode {
+	roots, children := treeLinks(len(nodes), func(i int) int64 {
+		return nodes[i].ID
+	}, func(i int) int64 {
+		return nodes[i].ParentID
+	})
+	var build func(i int, level int64) DepartmentNode
+	build = func(i int, level int64) DepartmentNode {
+		node := nodes[i]
+		node.Level = level
+		node.SubtreeContacts = node.Contacts
+		node.Children = []DepartmentNode{}
+		for _, c := range children[i] {
+			child := build(c, level+1)
+			node.SubtreeContacts += child.SubtreeContacts
+			node.Children = append(node.Children, child)
+		}
+		return node
+	}
+	tree := []DepartmentNode{}
+	for _, root := range roots {
+		tree = append(tree, build(root, 0))
+	}
+	return tree
+}
+
+// GetCompanyDepartments - get departments of company as tree
+func (e *Edb) GetCompanyDepartments(companyID int64) ([]DepartmentNode, error) {
+	rows, err := e.db.Query(`
+		SELECT
+			d.id,
+			d.parent_id,
+			d.name,
+			(SELECT count(*) FROM contacts AS c WHERE c.department_id = d.id) AS contacts
+		FROM
+			departments AS d
+		WHERE
+			d.company_id = $1
+		ORDER BY
+			d.name ASC
+	`, companyID)
+	if err != nil {
+		log.Println("GetCompanyDepartments e.db.Query ", err)
+		return []DepartmentNode{}, err
+	}
+	nodes, err := scanDepartmentNodes(rows)
+	if err != nil {
+		return []DepartmentNode{}, err
+	}
+	return buildDepartmentTree(nodes), nil
+}
+
+// GetCompanyDepartmentSelect - get common departments and departments of company for select
+func (e *Edb) GetCompanyDepartmentSelect(companyID int64) ([]SelectItem, error) {
+	rows, err := e.db.Query(`
+		SELECT
+			id,
+			name
+		FROM
+			departments
+		WHERE
+			company_id IS NULL OR company_id = $1
+		ORDER BY
+			company_id NULLS FIRST,
+			name ASC
+	`, companyID)
+	if err != nil {
+		log.Println("GetCompanyDepartmentSelect e.db.Query ", err)
+		return []SelectItem{}, err
+	}
+	return scanDepartmentsSelect(rows)
+}
+
+// GetDepartmentAncestors - get parent departments from root to direct parent
+func (e *Edb) GetDepartmentAncestors(id int64) ([]SelectItem, error) {
+	rows, err := e.db.Query(`
+		WITH RECURSIVE
+			up AS (
+				SELECT
+					d.id,
+					d.parent_id,
+					d.name,
+					0 AS depth
+				FROM
+					departments AS d
+				WHERE
+					d.id = $1
+				UNION ALL
+				SELECT
+					d.id,
+					d.parent_id,
+					d.name,
+					u.depth + 1
+				FROM
+					departments AS d
+				JOIN
+					up AS u ON d.id = u.parent_id
+				WHERE
+					u.depth < `+strconv.Itoa(companyTreeDepth)+` AND d.id <> $1
+			)
+		SELECT
+			id,
+			name
+		FROM
+			up
+		WHERE
+			depth > 0
+		ORDER BY
+			depth DESC
+	`, id)
+	if err != nil {
+		log.Println("GetDepartmentAncestors e.db.Query ", err)
+		return []SelectItem{}, err
+	}
+	return scanDepartmentsSelect(rows)
+}
+
+// checkDepartmentParent - parent must belong to same company and must not be department itself or its sub-department
+func (e *Edb) checkDepartmentParent(department Department) error {
+	if department.ParentID == 0 {
+		return nil
+	}
+	if department.ParentID == department.ID {
+		return ErrDepartmentCycle
+	}
+	parent, err := e.GetDepartment(department.ParentID)
+	if err != nil {
+		return err
+	}
+	if parent.CompanyID != department.CompanyID {
+		return ErrDepartmentCompany
+	}
+	if department.ID == 0 {
+		return nil
+	}
+	ancestors, err := e.GetDepartmentAncestors(department.ParentID)
+	if err != nil {
+		return err
+	}
+	for _, item := range ancestors {
+		if item.ID == department.ID {
+			return ErrDepartmentCycle
+		}
+	}
+	return nil
+}
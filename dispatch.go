@@ -0,0 +1,917 @@
+package epgc
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Delivery channels
+const (
+	DeliverySMS   = "sms"
+	DeliveryEmail = "email"
+	DeliveryVoice = "voice"
+)
+
+// Delivery states
+const (
+	DeliveryPending      = "pending"
+	DeliverySent         = "sent"
+	DeliveryFailed       = "failed"
+	DeliveryAcknowledged = "acknowledged"
+	// DeliveryCancelled - delivery is not needed anymore, recipient acknowledged message by other delivery
+	// or delivery with lower priority is acknowledged before escalation
+	DeliveryCancelled = "cancelled"
+	// DeliverySending - delivery is claimed by RunDispatch and is being sent
+	DeliverySending = "sending"
+)
+
+// Dispatch states
+const (
+	DispatchRunning = "running"
+	DispatchDone    = "done"
+)
+
+// deliveryClaimTimeout - delivery left in DeliverySending by stopped RunDispatch is claimed again after it
+const deliveryClaimTimeout = 10 * time.Minute
+
+// ErrUnknownAck - acknowledgement token does not match any delivery
+var ErrUnknownAck = errors.New("unknown acknowledgement token")
+
+// ErrNoRecipients - dispatch has no contacts or companies with addresses for requested channels
+var ErrNoRecipients = errors.New("dispatch has no recipients")
+
+// DeliveryMessage - message for one address of recipient
+type DeliveryMessage struct {
+	DispatchID int64  `json:"dispatch_id"`
+	DeliveryID int64  `json:"delivery_id"`
+	Name       string `json:"name"`
+	Address    string `json:"address"`
+	Subject    string `json:"subject"`
+	Body       string `json:"body"`
+	AckToken   string `json:"ack_token"`
+}
+
+// DeliveryChannel - driver of delivery channel, returns id of message in external system
+type DeliveryChannel interface {
+	Send(ctx context.Context, msg DeliveryMessage) (string, error)
+}
+
+// DeliveryChannelFunc - function as DeliveryChannel
+type DeliveryChannelFunc func(ctx context.Context, msg DeliveryMessage) (string, error)
+
+// Send - call f
+func (f DeliveryChannelFunc) Send(ctx context.Context, msg DeliveryMessage) (string, error) {
+	return f(ctx, msg)
+}
+
+// DispatchRequest - message for notification list or contacts and companies, phones get DeliverySMS and
+// DeliveryVoice, emails get DeliveryEmail, Channels are DeliverySMS and DeliveryEmail by default
+type DispatchRequest struct {
+	ListID     int64    `json:"list_id"`
+	ContactIDs []int64  `json:"contact_ids"`
+	CompanyIDs []int64  `json:"company_ids"`
+	Subject    string   `json:"subject"`
+	Body       string   `json:"body"`
+	Channels   []string `json:"channels"`
+}
+
+// DispatchOptions - options of sending of dispatch
+type DispatchOptions struct {
+	// MaxAttempts - attempts of every delivery, 3 by default
+	MaxAttempts int
+	// RetryDelay - delay before second attempt, it grows with every attempt, one minute by default
+	RetryDelay time.Duration
+	// AckFormat - line with acknowledgement token added to body, like "Для подтверждения ответьте %s"
+	AckFormat string
+}
+
+// Dispatch - message sent to recipients
+type Dispatch struct {
+	ID         int64  `sql:"id" json:"id"`
+	ListID     int64  `sql:"list_id, null" json:"list_id"`
+	Subject    string `sql:"subject, null" json:"subject"`
+	Body       string `sql:"body, null" json:"body"`
+	Status     string `sql:"status" json:"status"`
+	FinishedAt string `sql:"finished_at, null" json:"finished_at"`
+	CreatedAt  string `sql:"created_at" json:"created_at"`
+	UpdatedAt  string `sql:"updated_at" json:"updated_at"`
+}
+
+// Delivery - state of dispatch for one address of recipient. Priority and EscalateAfter are copied from member
+// of notification list, delivery is sent when deliveries with lower priority stay unacknowledged for EscalateAfter
+// minutes after their first attempt
+type Delivery struct {
+	ID             int64  `sql:"id" json:"id"`
+	DispatchID     int64  `sql:"dispatch_id" json:"dispatch_id"`
+	ContactID      int64  `sql:"contact_id, null" json:"contact_id"`
+	CompanyID      int64  `sql:"company_id, null" json:"company_id"`
+	Name           string `sql:"name, null" json:"name"`
+	Channel        string `sql:"channel" json:"channel"`
+	Address        string `sql:"address" json:"address"`
+	Priority       int64  `sql:"priority" json:"priority"`
+	EscalateAfter  int64  `sql:"escalate_after, null" json:"escalate_after"`
+	Status         string `sql:"status" json:"status"`
+	Attempts       int64  `sql:"attempts" json:"attempts"`
+	LastError      string `sql:"last_error, null" json:"last_error"`
+	ProviderID     string `sql:"provider_id, null" json:"provider_id"`
+	AckToken       string `sql:"ack_token" json:"-"`
+	SentAt         string `sql:"sent_at, null" json:"sent_at"`
+	AcknowledgedAt string `sql:"acknowledged_at, null" json:"acknowledged_at"`
+}
+
+// DispatchReport - dispatch with its deliveries and their counts by state and channel
+type DispatchReport struct {
+	Dispatch     Dispatch                  `json:"dispatch"`
+	Recipients   int                       `json:"recipients"`
+	Acknowledged int                       `json:"acknowledged"`
+	ByStatus     map[string]int            `json:"by_status"`
+	ByChannel    map[string]map[string]int `json:"by_channel"`
+	Deliveries   []Delivery                `json:"deliveries"`
+}
+
+func ackToken() (string, error) {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	return hex.EncodeToString(b), err
+}
+
+// dispatchDeliveries - deliveries of targets for requested channels
+func dispatchDeliveries(targets []NotificationTarget, channels []string) []Delivery {
+	if len(channels) == 0 {
+		channels = []string{DeliverySMS, DeliveryEmail}
+	}
+	var deliveries []Delivery
+	for _, target := range targets {
+		for _, channel := range channels {
+			var addresses []string
+			switch channel {
+			case DeliverySMS, DeliveryVoice:
+				for _, phone := range target.Phones {
+					addresses = append(addresses, fmt.Sprint(phone))
+				}
+			case DeliveryEmail:
+				addresses = target.Emails
+			}
+			for _, address := range addresses {
+				deliveries = append(deliveries, Delivery{
+					ContactID:     target.ContactID,
+					CompanyID:     target.CompanyID,
+					Name:          target.Name,
+					Channel:       channel,
+					Address:       address,
+					Priority:      target.Priority,
+					EscalateAfter: target.EscalateAfter,
+				})
+			}
+		}
+	}
+	return deliveries
+}
+
+// dispatchTargets - targets of notification list or of contacts and companies of request
+func (e *Edb) dispatchTargets(req DispatchRequest) ([]NotificationTarget, error) {
+	if req.ListID != 0 {
+		resolution, err := e.ResolveNotificationList(req.ListID)
+		return resolution.Targets, err
+	}
+	var targets []NotificationTarget
+	rows, err := e.db.Query(`
+		SELECT
+			id,
+			0,
+			name
+		FROM
+			contacts
+		WHERE
+			id = ANY($1)
+		UNION ALL
+		SELECT
+			0,
+			id,
+			name
+		FROM
+			companies
+		WHERE
+			id = ANY($2)
+	`, pq.Array(req.ContactIDs), pq.Array(req.CompanyIDs))
+	if err != nil {
+		log.Println("dispatchTargets e.db.Query ", err)
+		return targets, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			sName  sql.NullString
+			target = NotificationTarget{Channel: ChannelAll}
+		)
+		err = rows.Scan(&target.ContactID, &target.CompanyID, &sName)
+		if err != nil {
+			log.Println("dispatchTargets rows.Scan ", err)
+			return targets, err
+		}
+		target.Name = n2s(sName)
+		targets = append(targets, target)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Println("dispatchTargets rows.Err ", err)
+		return targets, err
+	}
+	channels, err := e.getNotificationChannels(req.ContactIDs, req.CompanyIDs)
+	if err != nil {
+		return targets, err
+	}
+	return resolveTargets(targets, channels).Targets, nil
+}
+
+// CreateDispatch - create dispatch with pending delivery for every address of recipients
+func (e *Edb) CreateDispatch(req DispatchRequest) (int64, error) {
+	targets, err := e.dispatchTargets(req)
+	if err != nil {
+		return 0, err
+	}
+	deliveries := dispatchDeliveries(targets, req.Channels)
+	if len(deliveries) == 0 {
+		return 0, ErrNoRecipients
+	}
+	tx, err := e.db.Begin()
+	if err != nil {
+		log.Println("CreateDispatch e.db.Begin ", err)
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	var id int64
+	err = tx.QueryRow(`
+		INSERT INTO
+			dispatches (
+				list_id,
+				subject,
+				body,
+				status,
+				created_at
+			)
+		VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			now()
+		)
+		RETURNING
+			id
+	`, i2n(req.ListID), s2n(req.Subject), s2n(req.Body), DispatchRunning).Scan(&id)
+	if err != nil {
+		log.Println("CreateDispatch tx.QueryRow ", err)
+		return 0, err
+	}
+	for _, delivery := range deliveries {
+		token, err := ackToken()
+		if err != nil {
+			log.Println("CreateDispatch ackToken ", err)
+			return 0, err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO
+				dispatch_deliveries (
+					dispatch_id,
+					contact_id,
+					company_id,
+					name,
+					channel,
+					address,
+					priority,
+					escalate_after,
+					status,
+					attempts,
+					ack_token,
+					next_attempt_at,
+					created_at
+				)
+			VALUES (
+				$1,
+				$2,
+				$3,
+				$4,
+				$5,
+				$6,
+				$7,
+				$8,
+				$9,
+				0,
+				$10,
+				now(),
+				now()
+			)
+		`, id, i2n(delivery.ContactID), i2n(delivery.CompanyID), s2n(delivery.Name), delivery.Channel, delivery.Address,
+			delivery.Priority, i2n(delivery.EscalateAfter), DeliveryPending, token)
+		if err != nil {
+			log.Println("CreateDispatch tx.Exec ", err)
+			return 0, err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Println("CreateDispatch tx.Commit ", err)
+		return 0, err
+	}
+	return id, nil
+}
+
+// dueDelivery - delivery claimed for sending, its time of attempt has come
+type dueDelivery struct {
+	msg      DeliveryMessage
+	channel  string
+	attempts int
+}
+
+// dueDeliveries - claim pending deliveries of dispatch which time of attempt has come and which lower priorities
+// are escalated, claimed deliveries get DeliverySending so other RunDispatch of same dispatch skips them
+func (e *Edb) dueDeliveries(id int64) ([]dueDelivery, error) {
+	rows, err := e.db.Query(`
+		WITH
+			due AS (
+				SELECT
+					dd.id
+				FROM
+					dispatch_deliveries AS dd
+				WHERE
+					dd.dispatch_id = $1
+					AND (
+						dd.status = $2 AND dd.next_attempt_at <= now()
+						OR dd.status = $3 AND dd.updated_at <= now() - $4 * interval '1 microsecond'
+					)
+					AND NOT EXISTS (
+						SELECT
+							1
+						FROM
+							dispatch_deliveries AS l
+						WHERE
+							l.dispatch_id = dd.dispatch_id AND l.priority < dd.priority
+							AND (l.escalate_at IS NULL AND l.status IN ($2, $3) OR l.escalate_at > now())
+					)
+				FOR UPDATE SKIP LOCKED
+			)
+		UPDATE
+			dispatch_deliveries AS dd
+		SET
+			status = $3,
+			escalate_at = COALESCE(dd.escalate_at, now() + COALESCE(dd.escalate_after, 0) * interval '1 minute'),
+			updated_at = now()
+		FROM
+			due,
+			dispatches AS d
+		WHERE
+			dd.id = due.id AND d.id = dd.dispatch_id
+		RETURNING
+			dd.id,
+			dd.name,
+			dd.channel,
+			dd.address,
+			dd.ack_token,
+			dd.attempts,
+			d.subject,
+			d.body
+	`, id, DeliveryPending, DeliverySending, int64(deliveryClaimTimeout/time.Microsecond))
+	if err != nil {
+		log.Println("dueDeliveries e.db.Query ", err)
+		return nil, err
+	}
+	defer rows.Close()
+	var due []dueDelivery
+	for rows.Next() {
+		var (
+			sName    sql.NullString
+			sSubject sql.NullString
+			sBody    sql.NullString
+			item     = dueDelivery{msg: DeliveryMessage{DispatchID: id}}
+		)
+		err = rows.Scan(&item.msg.DeliveryID, &sName, &item.channel, &item.msg.Address, &item.msg.AckToken, &item.attempts, &sSubject, &sBody)
+		if err != nil {
+			log.Println("dueDeliveries rows.Scan ", err)
+			return nil, err
+		}
+		item.msg.Name = n2s(sName)
+		item.msg.Subject = n2s(sSubject)
+		item.msg.Body = n2s(sBody)
+		due = append(due, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Println("dueDeliveries rows.Err ", err)
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].msg.DeliveryID < due[j].msg.DeliveryID
+	})
+	return due, err
+}
+
+// releaseDeliveries - return claimed deliveries which were not sent to pending
+func (e *Edb) releaseDeliveries(due []dueDelivery) error {
+	ids := make([]int64, len(due))
+	for i, item := range due {
+		ids[i] = item.msg.DeliveryID
+	}
+	_, err := e.db.Exec(`
+		UPDATE
+			dispatch_deliveries
+		SET
+			status = $2,
+			updated_at = now()
+		WHERE
+			id = ANY($1) AND status = $3
+	`, pq.Array(ids), DeliveryPending, DeliverySending)
+	if err != nil {
+		log.Println("releaseDeliveries e.db.Exec ", err)
+	}
+	return err
+}
+
+// sendDelivery - send one delivery and save its state, failed attempt is retried after growing delay
+// until MaxAttempts, delivery without driver fails at once
+func (e *Edb) sendDelivery(ctx context.Context, item dueDelivery, channels map[string]DeliveryChannel, opt DispatchOptions) error {
+	var (
+		providerID string
+		err        error
+	)
+	driver, ok := channels[item.channel]
+	if ok {
+		msg := item.msg
+		if opt.AckFormat != "" {
+			msg.Body = strings.TrimSuffix(msg.Body, "\n") + "\n" + fmt.Sprintf(opt.AckFormat, msg.AckToken)
+		}
+		providerID, err = driver.Send(ctx, msg)
+	} else {
+		err = fmt.Errorf("no driver for channel %s", item.channel)
+	}
+	attempts := item.attempts + 1
+	if err == nil {
+		_, err = e.db.Exec(`
+			UPDATE
+				dispatch_deliveries
+			SET
+				status = $2,
+				attempts = $3,
+				provider_id = $4,
+				last_error = NULL,
+				sent_at = now(),
+				updated_at = now()
+			WHERE
+				id = $1 AND status = $5
+		`, item.msg.DeliveryID, DeliverySent, attempts, s2n(providerID), DeliverySending)
+		if err != nil {
+			log.Println("sendDelivery e.db.Exec ", err)
+		}
+		return err
+	}
+	status := DeliveryPending
+	if !ok || attempts >= opt.MaxAttempts {
+		status = DeliveryFailed
+	}
+	_, err = e.db.Exec(`
+		UPDATE
+			dispatch_deliveries
+		SET
+			status = $2,
+			attempts = $3,
+			last_error = $4,
+			next_attempt_at = now() + $5 * interval '1 microsecond',
+			updated_at = now()
+		WHERE
+			id = $1 AND status = $6
+	`, item.msg.DeliveryID, status, attempts, err.Error(), int64(opt.RetryDelay/time.Microsecond)*int64(attempts), DeliverySending)
+	if err != nil {
+		log.Println("sendDelivery e.db.Exec ", err)
+	}
+	return err
+}
+
+// nextAttempt - time of next attempt of pending deliveries of dispatch with escalation of lower priorities,
+// deliveries claimed by other RunDispatch are waited until claim expires, false without pending deliveries
+func (e *Edb) nextAttempt(id int64) (time.Duration, bool, error) {
+	var wait sql.NullFloat64
+	err := e.db.QueryRow(`
+		SELECT
+			EXTRACT(EPOCH FROM min(
+				GREATEST(
+					CASE WHEN dd.status = $2 THEN dd.next_attempt_at ELSE dd.updated_at + $4 * interval '1 microsecond' END,
+					(
+						SELECT
+							max(l.escalate_at)
+						FROM
+							dispatch_deliveries AS l
+						WHERE
+							l.dispatch_id = dd.dispatch_id AND l.priority < dd.priority
+					)
+				)
+			) - now())
+		FROM
+			dispatch_deliveries AS dd
+		WHERE
+			dd.dispatch_id = $1 AND dd.status IN ($2, $3)
+	`, id, DeliveryPending, DeliverySending, int64(deliveryClaimTimeout/time.Microsecond)).Scan(&wait)
+	if err != nil {
+		log.Println("nextAttempt e.db.QueryRow ", err)
+		return 0, false, err
+	}
+	if !wait.Valid {
+		return 0, false, nil
+	}
+	if wait.Float64 < 0 {
+		return 0, true, nil
+	}
+	return time.Duration(wait.Float64 * float64(time.Second)), true, nil
+}
+
+// RunDispatch - send pending deliveries of dispatch through drivers of channels and retry failed ones
+// until all deliveries are sent, failed or acknowledged, or ctx is done
+func (e *Edb) RunDispatch(ctx context.Context, id int64, channels map[string]DeliveryChannel, opt DispatchOptions) error {
+	if opt.MaxAttempts <= 0 {
+		opt.MaxAttempts = 3
+	}
+	if opt.RetryDelay <= 0 {
+		opt.RetryDelay = time.Minute
+	}
+	for {
+		due, err := e.dueDeliveries(id)
+		if err != nil {
+			return err
+		}
+		for i, item := range due {
+			if ctx.Err() != nil {
+				_ = e.releaseDeliveries(due[i:])
+				return ctx.Err()
+			}
+			err = e.sendDelivery(ctx, item, channels, opt)
+			if err != nil {
+				_ = e.releaseDeliveries(due[i:])
+				return err
+			}
+		}
+		wait, pending, err := e.nextAttempt(id)
+		if err != nil {
+			return err
+		}
+		if !pending {
+			break
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	_, err := e.db.Exec(`
+		UPDATE
+			dispatches
+		SET
+			status = $2,
+			finished_at = now(),
+			updated_at = now()
+		WHERE
+			id = $1
+	`, id, DispatchDone)
+	if err != nil {
+		log.Println("RunDispatch e.db.Exec ", err)
+	}
+	return err
+}
+
+// SendDispatch - create dispatch, send it and get report
+func (e *Edb) SendDispatch(ctx context.Context, req DispatchRequest, channels map[string]DeliveryChannel, opt DispatchOptions) (DispatchReport, error) {
+	id, err := e.CreateDispatch(req)
+	if err != nil {
+		return DispatchReport{}, err
+	}
+	err = e.RunDispatch(ctx, id, channels, opt)
+	if err != nil {
+		report, _ := e.GetDispatchReport(id)
+		return report, err
+	}
+	return e.GetDispatchReport(id)
+}
+
+// acknowledge - mark deliveries of recipient as acknowledged, other pending deliveries of recipient and deliveries
+// with higher priority which were not tried yet are cancelled, they are not escalated anymore
+func (e *Edb) acknowledge(where string, args ...interface{}) (int64, error) {
+	var n int64
+	err := e.db.QueryRow(`
+		WITH
+			acked AS (
+				UPDATE
+					dispatch_deliveries
+				SET
+					status = '`+DeliveryAcknowledged+`',
+					acknowledged_at = now(),
+					updated_at = now()
+				WHERE
+					`+where+` AND status IN ('`+DeliveryPending+`', '`+DeliverySending+`', '`+DeliverySent+`')
+				RETURNING
+					id,
+					dispatch_id,
+					contact_id,
+					company_id,
+					priority
+			),
+			cancelled AS (
+				UPDATE
+					dispatch_deliveries AS dd
+				SET
+					status = '`+DeliveryCancelled+`',
+					updated_at = now()
+				FROM
+					acked AS a
+				WHERE
+					dd.dispatch_id = a.dispatch_id
+					AND (
+						dd.contact_id IS NOT DISTINCT FROM a.contact_id AND dd.company_id IS NOT DISTINCT FROM a.company_id
+						OR dd.priority > a.priority AND dd.attempts = 0
+					)
+					AND dd.status = '`+DeliveryPending+`'
+					AND dd.id NOT IN (SELECT id FROM acked)
+			)
+		SELECT
+			count(*)
+		FROM
+			acked
+	`, args...).Scan(&n)
+	if err != nil {
+		log.Println("acknowledge e.db.QueryRow ", err)
+	}
+	return n, err
+}
+
+// AcknowledgeDelivery - recipient confirmed message with token from it
+func (e *Edb) AcknowledgeDelivery(token string) error {
+	n, err := e.acknowledge(`ack_token = $1`, token)
+	if err == nil && n == 0 {
+		var exists bool
+		err = e.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM dispatch_deliveries WHERE ack_token = $1)`, token).Scan(&exists)
+		if err == nil && !exists {
+			err = ErrUnknownAck
+		}
+	}
+	return err
+}
+
+// AcknowledgeRecipient - operator confirmed that contact or company received dispatch, like by phone call
+func (e *Edb) AcknowledgeRecipient(dispatchID, contactID, companyID int64) error {
+	_, err := e.acknowledge(`dispatch_id = $1 AND contact_id IS NOT DISTINCT FROM $2 AND company_id IS NOT DISTINCT FROM $3`,
+		dispatchID, i2n(contactID), i2n(companyID))
+	return err
+}
+
+func scanDispatches(rows *sql.Rows) ([]Dispatch, error) {
+	var dispatches []Dispatch
+	for rows.Next() {
+		var (
+			sID         sql.NullInt64
+			sListID     sql.NullInt64
+			sSubject    sql.NullString
+			sBody       sql.NullString
+			sStatus     sql.NullString
+			sFinishedAt pq.NullTime
+			sCreatedAt  pq.NullTime
+			dispatch    Dispatch
+		)
+		err := rows.Scan(&sID, &sListID, &sSubject, &sBody, &sStatus, &sFinishedAt, &sCreatedAt)
+		if err != nil {
+			log.Println("scanDispatches rows.Scan ", err)
+			return dispatches, err
+		}
+		dispatch.ID = n2i(sID)
+		dispatch.ListID = n2i(sListID)
+		dispatch.Subject = n2s(sSubject)
+		dispatch.Body = n2s(sBody)
+		dispatch.Status = n2s(sStatus)
+		dispatch.FinishedAt = n2st(sFinishedAt)
+		dispatch.CreatedAt = n2st(sCreatedAt)
+		dispatches = append(dispatches, dispatch)
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Println("scanDispatches rows.Err ", err)
+	}
+	return dispatches, err
+}
+
+// GetDispatches - get all dispatches from newest
+func (e *Edb) GetDispatches() ([]Dispatch, error) {
+	rows, err := e.db.Query(`
+		SELECT
+			id,
+			list_id,
+			subject,
+			body,
+			status,
+			finished_at,
+			created_at
+		FROM
+			dispatches
+		ORDER BY
+			id DESC
+	`)
+	if err != nil {
+		log.Println("GetDispatches e.db.Query ", err)
+		return []Dispatch{}, err
+	}
+	return scanDispatches(rows)
+}
+
+func scanDeliveries(rows *sql.Rows) ([]Delivery, error) {
+	var deliveries []Delivery
+	for rows.Next() {
+		var (
+			sID             sql.NullInt64
+			sDispatchID     sql.NullInt64
+			sContactID      sql.NullInt64
+			sCompanyID      sql.NullInt64
+			sName           sql.NullString
+			sChannel        sql.NullString
+			sAddress        sql.NullString
+			sPriority       sql.NullInt64
+			sEscalateAfter  sql.NullInt64
+			sStatus         sql.NullString
+			sAttempts       sql.NullInt64
+			sLastError      sql.NullString
+			sProviderID     sql.NullString
+			sSentAt         pq.NullTime
+			sAcknowledgedAt pq.NullTime
+			delivery        Delivery
+		)
+		err := rows.Scan(&sID, &sDispatchID, &sContactID, &sCompanyID, &sName, &sChannel, &sAddress, &sPriority,
+			&sEscalateAfter, &sStatus, &sAttempts, &sLastError, &sProviderID, &sSentAt, &sAcknowledgedAt)
+		if err != nil {
+			log.Println("scanDeliveries rows.Scan ", err)
+			return deliveries, err
+		}
+		delivery.ID = n2i(sID)
+		delivery.DispatchID = n2i(sDispatchID)
+		delivery.ContactID = n2i(sContactID)
+		delivery.CompanyID = n2i(sCompanyID)
+		delivery.Name = n2s(sName)
+		delivery.Channel = n2s(sChannel)
+		delivery.Address = n2s(sAddress)
+		delivery.Priority = n2i(sPriority)
+		delivery.EscalateAfter = n2i(sEscalateAfter)
+		delivery.Status = n2s(sStatus)
+		delivery.Attempts = n2i(sAttempts)
+		delivery.LastError = n2s(sLastError)
+		delivery.ProviderID = n2s(sProviderID)
+		delivery.SentAt = n2st(sSentAt)
+		delivery.AcknowledgedAt = n2st(sAcknowledgedAt)
+		deliveries = append(deliveries, delivery)
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Println("scanDeliveries rows.Err ", err)
+	}
+	return deliveries, err
+}
+
+// GetDispatchReport - get dispatch with deliveries, counts of deliveries by state and channel,
+// number of recipients and of recipients who acknowledged it
+func (e *Edb) GetDispatchReport(id int64) (DispatchReport, error) {
+	report := DispatchReport{
+		ByStatus:   make(map[string]int),
+		ByChannel:  make(map[string]map[string]int),
+		Deliveries: []Delivery{},
+	}
+	rows, err := e.db.Query(`
+		SELECT
+			id,
+			list_id,
+			subject,
+			body,
+			status,
+			finished_at,
+			created_at
+		FROM
+			dispatches
+		WHERE
+			id = $1
+	`, id)
+	if err != nil {
+		log.Println("GetDispatchReport e.db.Query ", err)
+		return report, err
+	}
+	dispatches, err := scanDispatches(rows)
+	if err != nil {
+		return report, err
+	}
+	if len(dispatches) == 0 {
+		return report, sql.ErrNoRows
+	}
+	report.Dispatch = dispatches[0]
+	rows, err = e.db.Query(`
+		SELECT
+			id,
+			dispatch_id,
+			contact_id,
+			company_id,
+			name,
+			channel,
+			address,
+			priority,
+			escalate_after,
+			status,
+			attempts,
+			last_error,
+			provider_id,
+			sent_at,
+			acknowledged_at
+		FROM
+			dispatch_deliveries
+		WHERE
+			dispatch_id = $1
+		ORDER BY
+			id ASC
+	`, id)
+	if err != nil {
+		log.Println("GetDispatchReport e.db.Query ", err)
+		return report, err
+	}
+	report.Deliveries, err = scanDeliveries(rows)
+	if err != nil {
+		return report, err
+	}
+	acknowledged := make(map[string]bool)
+	for _, delivery := range report.Deliveries {
+		key := targetKey(delivery.ContactID, delivery.CompanyID)
+		acknowledged[key] = acknowledged[key] || delivery.Status == DeliveryAcknowledged
+		report.ByStatus[delivery.Status]++
+		if report.ByChannel[delivery.Channel] == nil {
+			report.ByChannel[delivery.Channel] = make(map[string]int)
+		}
+		report.ByChannel[delivery.Channel][delivery.Status]++
+	}
+	report.Recipients = len(acknowledged)
+	for _, ok := range acknowledged {
+		if ok {
+			report.Acknowledged++
+		}
+	}
+	return report, nil
+}
+
+func (e *Edb) dispatchCreateTable() error {
+	str := `
+		CREATE TABLE IF NOT EXISTS
+			dispatches (
+				id bigserial primary key,
+				list_id bigint,
+				subject text,
+				body text,
+				status text,
+				finished_at TIMESTAMP without time zone,
+				created_at TIMESTAMP without time zone,
+				updated_at TIMESTAMP without time zone
+			);
+		CREATE TABLE IF NOT EXISTS
+			dispatch_deliveries (
+				id bigserial primary key,
+				dispatch_id bigint,
+				contact_id bigint,
+				company_id bigint,
+				name text,
+				channel text,
+				address text,
+				priority bigint,
+				escalate_after bigint,
+				escalate_at TIMESTAMP without time zone,
+				status text,
+				attempts bigint,
+				last_error text,
+				provider_id text,
+				ack_token text,
+				next_attempt_at TIMESTAMP without time zone,
+				sent_at TIMESTAMP without time zone,
+				acknowledged_at TIMESTAMP without time zone,
+				created_at TIMESTAMP without time zone,
+				updated_at TIMESTAMP without time zone,
+				UNIQUE (ack_token)
+			);
+		ALTER TABLE dispatch_deliveries ADD COLUMN IF NOT EXISTS priority bigint;
+		ALTER TABLE dispatch_deliveries ADD COLUMN IF NOT EXISTS escalate_after bigint;
+		ALTER TABLE dispatch_deliveries ADD COLUMN IF NOT EXISTS escalate_at TIMESTAMP without time zone;
+		CREATE INDEX IF NOT EXISTS dispatch_deliveries_dispatch_id_idx ON dispatch_deliveries (dispatch_id, status);
+	`
+	_, err := e.db.Exec(str)
+	if err != nil {
+		log.Println("dispatchCreateTable e.db.Exec ", err)
+	}
+	return err
+}
@@ -0,0 +1,196 @@
+package epgc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+)
+
+// SMTPChannel - send deliveries by mail, Auth may be nil for local relay
+type SMTPChannel struct {
+	Addr string
+	Auth smtp.Auth
+	From string
+}
+
+// Send - send plain text mail to address of delivery, connection is closed when ctx is done
+func (c SMTPChannel) Send(ctx context.Context, msg DeliveryMessage) (string, error) {
+	err := ctx.Err()
+	if err != nil {
+		return "", err
+	}
+	to := []string{msg.Address}
+	err = c.sendMail(ctx, to, smtpMessage(c.From, to, msg.Subject, msg.Body))
+	if err != nil {
+		log.Println("SMTPChannel sendMail ", err)
+		return "", err
+	}
+	return "", nil
+}
+
+// sendMail - smtp.SendMail on connection with deadline of ctx, smtp.SendMail itself has no timeout
+func (c SMTPChannel) sendMail(ctx context.Context, to []string, data []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-done:
+		}
+	}()
+	host, _, _ := net.SplitHostPort(c.Addr)
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		err = client.StartTLS(&tls.Config{ServerName: host})
+		if err != nil {
+			return err
+		}
+	}
+	if c.Auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		err = client.Auth(c.Auth)
+		if err != nil {
+			return err
+		}
+	}
+	err = client.Mail(c.From)
+	if err != nil {
+		return err
+	}
+	for _, addr := range to {
+		err = client.Rcpt(addr)
+		if err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// HTTPSMSChannel - send deliveries through http sms gateway. Gateway gets POST with json
+// {"to": "79001234567", "text": "...", "sender": "EDDS"} and answers {"id": "..."} with 2xx status
+type HTTPSMSChannel struct {
+	URL    string
+	Token  string
+	Sender string
+	Client *http.Client
+}
+
+type smsRequest struct {
+	To     string `json:"to"`
+	Text   string `json:"text"`
+	Sender string `json:"sender,omitempty"`
+}
+
+type smsResponse struct {
+	ID json.RawMessage `json:"id"`
+}
+
+// Send - post text of delivery to gateway
+func (c HTTPSMSChannel) Send(ctx context.Context, msg DeliveryMessage) (string, error) {
+	text := msg.Body
+	if msg.Subject != "" {
+		text = msg.Subject + "\n" + text
+	}
+	body, err := json.Marshal(smsRequest{To: msg.Address, Text: text, Sender: c.Sender})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		log.Println("HTTPSMSChannel http.NewRequest ", err)
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Println("HTTPSMSChannel client.Do ", err)
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err != nil {
+		log.Println("HTTPSMSChannel ioutil.ReadAll ", err)
+		return "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if len(data) > 200 {
+			data = data[:200]
+		}
+		err = fmt.Errorf("sms gateway: %s: %s", resp.Status, bytes.TrimSpace(data))
+		log.Println("HTTPSMSChannel ", err)
+		return "", err
+	}
+	var answer smsResponse
+	if json.Unmarshal(data, &answer) != nil || len(answer.ID) == 0 {
+		return "", nil
+	}
+	// id may be string or number
+	if id, err := strconv.Unquote(string(answer.ID)); err == nil {
+		return id, nil
+	}
+	return string(answer.ID), nil
+}
+
+// VoiceStubChannel - stub of voice channel until SIP gateway is connected, writes calls to W
+type VoiceStubChannel struct {
+	W io.Writer
+}
+
+// Send - write call of address with text of delivery
+func (c VoiceStubChannel) Send(ctx context.Context, msg DeliveryMessage) (string, error) {
+	err := ctx.Err()
+	if err != nil {
+		return "", err
+	}
+	_, err = fmt.Fprintf(c.W, "call %s (%s): %s\n", msg.Address, msg.Name, msg.Body)
+	if err != nil {
+		return "", err
+	}
+	return "voice-" + strconv.FormatInt(msg.DeliveryID, 10), nil
+}
@@ -0,0 +1,297 @@
+package epgc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/serbe/epgc"
+	"github.com/serbe/epgc/epgctest"
+)
+
+func TestSMTPChannel(t *testing.T) {
+	server := epgctest.NewSMTPServer(t)
+	channel := epgc.SMTPChannel{Addr: server.Addr, From: "edds@example.com"}
+	msg := epgc.DeliveryMessage{Address: "ivanov@example.com", Subject: "Оповещение", Body: "Сбор в 10:00"}
+	_, err := channel.Send(context.Background(), msg)
+	check(t, err)
+	mails := server.Mails()
+	if len(mails) != 1 || mails[0].From != "edds@example.com" || len(mails[0].To) != 1 || mails[0].To[0] != "ivanov@example.com" {
+		t.Fatalf("SMTPServer mails = %+v", mails)
+	}
+	if !strings.Contains(mails[0].Data, "Сбор в 10:00") {
+		t.Errorf("mail data = %q", mails[0].Data)
+	}
+	server.FailNext(1)
+	_, err = channel.Send(context.Background(), msg)
+	if err == nil {
+		t.Error("SMTPChannel.Send to failing server err = nil")
+	}
+}
+
+func TestSMTPChannelDeadline(t *testing.T) {
+	// server accepts connection and never answers
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	check(t, err)
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+			_, _ = io.Copy(ioutil.Discard, conn)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = epgc.SMTPChannel{Addr: ln.Addr().String(), From: "edds@example.com"}.Send(ctx, epgc.DeliveryMessage{Address: "ivanov@example.com", Body: "Сбор"})
+	if err == nil {
+		t.Error("SMTPChannel.Send to silent server err = nil")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("SMTPChannel.Send to silent server took %v", elapsed)
+	}
+}
+
+func TestHTTPSMSChannel(t *testing.T) {
+	server := epgctest.NewSMSServer(t)
+	channel := epgc.HTTPSMSChannel{URL: server.URL, Token: "secret", Sender: "EDDS"}
+	id, err := channel.Send(context.Background(), epgc.DeliveryMessage{Address: "79001234567", Body: "Сбор в 10:00"})
+	check(t, err)
+	messages := server.Messages()
+	if id != "sms-1" || len(messages) != 1 || messages[0].To != "79001234567" || messages[0].Token != "secret" || messages[0].Sender != "EDDS" {
+		t.Errorf("HTTPSMSChannel.Send = %q, messages = %+v", id, messages)
+	}
+	server.FailNext(1)
+	_, err = channel.Send(context.Background(), epgc.DeliveryMessage{Address: "79001234567", Body: "test"})
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("HTTPSMSChannel.Send to failing gateway err = %v", err)
+	}
+}
+
+func TestVoiceStubChannel(t *testing.T) {
+	var buf bytes.Buffer
+	id, err := epgc.VoiceStubChannel{W: &buf}.Send(context.Background(), epgc.DeliveryMessage{DeliveryID: 7, Address: "111", Name: "Иванов", Body: "Сбор"})
+	check(t, err)
+	if id != "voice-7" || buf.String() != "call 111 (Иванов): Сбор\n" {
+		t.Errorf("VoiceStubChannel.Send = %q, %q", id, buf.String())
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	e := epgctest.Open(t)
+	f := epgctest.NewFixtures(t, e)
+	company := f.Company("ООО Ромашка")
+	head := f.Contact("Иванов Иван Иванович", company.ID)
+	deputy := f.Contact("Петров Петр Петрович", company.ID)
+	_, err := e.CreatePhone(epgc.Phone{ContactID: head.ID, Phone: 79001111111})
+	check(t, err)
+	_, err = e.CreatePhone(epgc.Phone{ContactID: deputy.ID, Phone: 79002222222})
+	check(t, err)
+	_, err = e.CreateEmail(epgc.Email{ContactID: deputy.ID, Email: "petrov@example.com"})
+	check(t, err)
+	listID, err := e.CreateNotificationList(epgc.NotificationList{
+		Name: "Руководство",
+		Members: []epgc.NotificationMember{
+			{ContactID: head.ID, Priority: 1},
+			{ContactID: deputy.ID, Priority: 2},
+		},
+	})
+	check(t, err)
+
+	smtpServer := epgctest.NewSMTPServer(t)
+	smsServer := epgctest.NewSMSServer(t)
+	smsServer.FailNext(1)
+	channels := map[string]epgc.DeliveryChannel{
+		epgc.DeliverySMS:   epgc.HTTPSMSChannel{URL: smsServer.URL},
+		epgc.DeliveryEmail: epgc.SMTPChannel{Addr: smtpServer.Addr, From: "edds@example.com"},
+		epgc.DeliveryVoice: epgc.DeliveryChannelFunc(func(ctx context.Context, msg epgc.DeliveryMessage) (string, error) {
+			return "", errors.New("line is busy")
+		}),
+	}
+	opt := epgc.DispatchOptions{MaxAttempts: 2, RetryDelay: 10 * time.Millisecond, AckFormat: "Код подтверждения %s"}
+	req := epgc.DispatchRequest{
+		ListID:   listID,
+		Subject:  "Оповещение",
+		Body:     "Сбор в 10:00",
+		Channels: []string{epgc.DeliverySMS, epgc.DeliveryEmail, epgc.DeliveryVoice},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	report, err := e.SendDispatch(ctx, req, channels, opt)
+	check(t, err)
+	if report.Dispatch.Status != epgc.DispatchDone || report.Recipients != 2 || len(report.Deliveries) != 5 {
+		t.Fatalf("SendDispatch report = %+v", report)
+	}
+	if report.ByChannel[epgc.DeliverySMS][epgc.DeliverySent] != 2 || report.ByChannel[epgc.DeliveryVoice][epgc.DeliveryFailed] != 2 ||
+		report.ByStatus[epgc.DeliverySent] != 3 {
+		t.Errorf("SendDispatch counts = %+v", report.ByChannel)
+	}
+	for _, delivery := range report.Deliveries {
+		if delivery.Channel == epgc.DeliveryVoice && (delivery.Attempts != 2 || delivery.LastError != "line is busy") {
+			t.Errorf("voice delivery = %+v", delivery)
+		}
+	}
+	messages := smsServer.Messages()
+	if len(messages) != 2 || len(smtpServer.Mails()) != 1 {
+		t.Fatalf("sent sms = %+v, mails = %+v", messages, smtpServer.Mails())
+	}
+
+	token := messages[0].Text[strings.LastIndex(messages[0].Text, " ")+1:]
+	check(t, e.AcknowledgeDelivery(token))
+	if err = e.AcknowledgeDelivery("unknown"); err != epgc.ErrUnknownAck {
+		t.Errorf("AcknowledgeDelivery unknown token err = %v", err)
+	}
+	check(t, e.AcknowledgeRecipient(report.Dispatch.ID, head.ID, 0))
+	check(t, e.AcknowledgeRecipient(report.Dispatch.ID, deputy.ID, 0))
+	report, err = e.GetDispatchReport(report.Dispatch.ID)
+	check(t, err)
+	if report.Acknowledged != 2 || report.ByStatus[epgc.DeliveryAcknowledged] != 3 {
+		t.Errorf("GetDispatchReport after acknowledgement = %+v", report.ByStatus)
+	}
+
+	_, err = e.CreateDispatch(epgc.DispatchRequest{ContactIDs: []int64{head.ID}, Channels: []string{epgc.DeliveryEmail}})
+	if err != epgc.ErrNoRecipients {
+		t.Errorf("CreateDispatch without emails err = %v", err)
+	}
+}
+
+func TestDispatchEscalation(t *testing.T) {
+	e := epgctest.Open(t)
+	f := epgctest.NewFixtures(t, e)
+	company := f.Company("ООО Ромашка")
+	head := f.Contact("Иванов Иван Иванович", company.ID)
+	deputy := f.Contact("Петров Петр Петрович", company.ID)
+	_, err := e.CreatePhone(epgc.Phone{ContactID: head.ID, Phone: 79001111111})
+	check(t, err)
+	_, err = e.CreatePhone(epgc.Phone{ContactID: deputy.ID, Phone: 79002222222})
+	check(t, err)
+	var sent []epgc.DeliveryMessage
+	channels := map[string]epgc.DeliveryChannel{
+		epgc.DeliverySMS: epgc.DeliveryChannelFunc(func(ctx context.Context, msg epgc.DeliveryMessage) (string, error) {
+			sent = append(sent, msg)
+			return "", nil
+		}),
+	}
+	dispatch := func(name string, escalateAfter int64, timeout time.Duration) (epgc.DispatchReport, error) {
+		listID, err := e.CreateNotificationList(epgc.NotificationList{
+			Name: name,
+			Members: []epgc.NotificationMember{
+				{ContactID: head.ID, Priority: 1, EscalateAfter: escalateAfter},
+				{ContactID: deputy.ID, Priority: 2},
+			},
+		})
+		check(t, err)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		sent = nil
+		return e.SendDispatch(ctx, epgc.DispatchRequest{ListID: listID, Body: "Сбор"}, channels, epgc.DispatchOptions{})
+	}
+
+	// deputy waits for minute of escalation, head acknowledges before it
+	report, err := dispatch("Эскалация через минуту", 1, 500*time.Millisecond)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("SendDispatch before escalation err = %v", err)
+	}
+	if len(sent) != 1 || sent[0].Address != "79001111111" {
+		t.Fatalf("sent before escalation = %+v", sent)
+	}
+	for _, delivery := range report.Deliveries {
+		if delivery.ContactID == deputy.ID && (delivery.Status != epgc.DeliveryPending || delivery.Attempts != 0 || delivery.Priority != 2) {
+			t.Errorf("delivery of second priority before escalation = %+v", delivery)
+		}
+	}
+	check(t, e.AcknowledgeDelivery(sent[0].AckToken))
+	report, err = e.GetDispatchReport(report.Dispatch.ID)
+	check(t, err)
+	if report.ByStatus[epgc.DeliveryAcknowledged] != 1 || report.ByStatus[epgc.DeliveryCancelled] != 1 {
+		t.Errorf("deliveries after acknowledgement of first priority = %+v", report.ByStatus)
+	}
+
+	// nobody acknowledges, second priority is sent after first one
+	report, err = dispatch("Эскалация сразу", 0, 10*time.Second)
+	check(t, err)
+	if len(sent) != 2 || sent[0].Address != "79001111111" || sent[1].Address != "79002222222" {
+		t.Fatalf("sent with escalation = %+v", sent)
+	}
+	if report.Dispatch.Status != epgc.DispatchDone || report.ByStatus[epgc.DeliverySent] != 2 {
+		t.Errorf("SendDispatch with escalation = %+v", report.ByStatus)
+	}
+}
+
+func TestDispatchCallTree(t *testing.T) {
+	e := epgctest.Open(t)
+	f := epgctest.NewFixtures(t, e)
+	company := f.Company("ООО Ромашка")
+	head := f.Contact("Иванов Иван Иванович", company.ID)
+	deputy := f.Contact("Петров Петр Петрович", company.ID)
+	worker := f.Contact("Сидоров Сидор Сидорович", company.ID)
+	for _, phone := range []epgc.Phone{
+		{ContactID: head.ID, Phone: 79001111111},
+		{ContactID: deputy.ID, Phone: 79002222222},
+		{ContactID: worker.ID, Phone: 79003333333},
+	} {
+		_, err := e.CreatePhone(phone)
+		check(t, err)
+	}
+	var sent []epgc.DeliveryMessage
+	channels := map[string]epgc.DeliveryChannel{
+		epgc.DeliverySMS: epgc.DeliveryChannelFunc(func(ctx context.Context, msg epgc.DeliveryMessage) (string, error) {
+			sent = append(sent, msg)
+			return "", nil
+		}),
+	}
+	dispatch := func(name string, escalateAfter int64, timeout time.Duration) (epgc.DispatchReport, error) {
+		listID, err := e.CreateNotificationList(epgc.NotificationList{
+			Name: name,
+			Members: []epgc.NotificationMember{
+				{ContactID: deputy.ID, Priority: 2},
+				{ContactID: head.ID, Priority: 1, EscalateAfter: escalateAfter},
+			},
+		})
+		check(t, err)
+		_, err = e.CreateCallTreeNode(epgc.CallTreeNode{ListID: listID, ContactID: worker.ID})
+		check(t, err)
+		resolution, err := e.ResolveNotificationList(listID)
+		check(t, err)
+		if len(resolution.Targets) != 3 || resolution.Targets[0].Priority != 1 || resolution.Targets[1].Priority != 2 ||
+			resolution.Targets[2].ContactID != worker.ID || resolution.Targets[2].Priority != 3 {
+			t.Fatalf("ResolveNotificationList targets = %+v", resolution.Targets)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		sent = nil
+		return e.SendDispatch(ctx, epgc.DispatchRequest{ListID: listID, Body: "Сбор"}, channels, epgc.DispatchOptions{})
+	}
+
+	// acknowledgement of first member cancels second member and call tree
+	report, err := dispatch("Дерево после списка", 1, 500*time.Millisecond)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("SendDispatch before escalation err = %v", err)
+	}
+	if len(sent) != 1 || sent[0].Address != "79001111111" {
+		t.Fatalf("sent before escalation = %+v", sent)
+	}
+	check(t, e.AcknowledgeDelivery(sent[0].AckToken))
+	report, err = e.GetDispatchReport(report.Dispatch.ID)
+	check(t, err)
+	if report.ByStatus[epgc.DeliveryAcknowledged] != 1 || report.ByStatus[epgc.DeliveryCancelled] != 2 {
+		t.Errorf("deliveries after acknowledgement of first member = %+v", report.ByStatus)
+	}
+
+	// nobody acknowledges, call tree is alerted after all members
+	report, err = dispatch("Дерево сразу", 0, 10*time.Second)
+	check(t, err)
+	if len(sent) != 3 || sent[0].Address != "79001111111" || sent[1].Address != "79002222222" || sent[2].Address != "79003333333" {
+		t.Fatalf("sent with call tree = %+v", sent)
+	}
+	if report.Dispatch.Status != epgc.DispatchDone || report.ByStatus[epgc.DeliverySent] != 3 {
+		t.Errorf("SendDispatch with call tree = %+v", report.ByStatus)
+	}
+}
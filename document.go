@@ -0,0 +1,323 @@
+package epgc
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	htmltemplate "html/template"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DocumentData - data for document templates
+type DocumentData struct {
+	Date     string
+	Practice Practice
+	Company  Company
+	Contact  Contact
+	Siren    Siren
+	Contacts []Contact
+}
+
+var (
+	docTagRe      = regexp.MustCompile(`<[^>]*>`)
+	docControlRe  = regexp.MustCompile(`^\{\{-?\s*(if|else|end|range|with|define|template|block|break|continue)\b|^\{\{-?\s*/\*`)
+	docParagraphs = []*regexp.Regexp{
+		regexp.MustCompile(`(?s)<w:p[ >].*?</w:p>`),
+		regexp.MustCompile(`(?s)<text:p[ >].*?</text:p>`),
+		regexp.MustCompile(`(?s)<w:tr[ >].*?</w:tr>`),
+		regexp.MustCompile(`(?s)<table:table-row[ >].*?</table:table-row>`),
+	}
+	docQuotes = strings.NewReplacer("&quot;", `"`, "&apos;", "'", "&amp;", "&", "&lt;", "<", "&gt;", ">", "“", `"`, "”", `"`, "«", `"`, "»", `"`, "„", `"`)
+)
+
+// DocumentFuncs - functions for document templates: dates, cases of names and posts
+func DocumentFuncs() map[string]interface{} {
+	funcs := map[string]interface{}{
+		"date": setStrMonth,
+		"today": func() string {
+			return time.Now().Format("02.01.2006")
+		},
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"initials": func(name string) string {
+			return ParseName(name).Initials()
+		},
+		"contactName": func(c Contact, cs string) string {
+			return c.NameCase(CaseByName(cs))
+		},
+		"contactInitials": func(c Contact, cs string) string {
+			return c.InitialsCase(CaseByName(cs))
+		},
+		"contactPost": func(c Contact, cs string) string {
+			return c.PostCase(CaseByName(cs))
+		},
+		"xml": xmlEscape,
+	}
+	for name, f := range DeclensionFuncs {
+		funcs[name] = f
+	}
+	return funcs
+}
+
+// PracticeDocumentData - data of practice with kind, company and its contacts
+func (e *Edb) PracticeDocumentData(id int64) (DocumentData, error) {
+	data := DocumentData{Date: setStrMonth(time.Now().Format("02.01.2006"))}
+	practice, err := e.GetPractice(id)
+	if err != nil {
+		return data, err
+	}
+	practice.DateStr = setStrMonth(practice.DateOfPractice)
+	practice.Kind, err = e.GetKind(practice.KindID)
+	if err != nil {
+		return data, err
+	}
+	data.Practice = practice
+	company, err := e.CompanyDocumentData(practice.CompanyID)
+	if err != nil {
+		return data, err
+	}
+	data.Company = company.Company
+	data.Contacts = company.Contacts
+	data.Practice.Company = data.Company
+	return data, nil
+}
+
+// CompanyDocumentData - data of company with scope and contacts
+func (e *Edb) CompanyDocumentData(id int64) (DocumentData, error) {
+	data := DocumentData{Date: setStrMonth(time.Now().Format("02.01.2006"))}
+	company, err := e.GetCompany(id)
+	if err != nil {
+		return data, err
+	}
+	company.Scope, err = e.GetScope(company.ScopeID)
+	if err != nil {
+		return data, err
+	}
+	data.Company = company
+	if id != 0 {
+		data.Contacts, err = e.getContactsFull(`c.company_id = $1`, id)
+	}
+	return data, err
+}
+
+// ContactDocumentData - data of contact with company, department, posts and rank
+func (e *Edb) ContactDocumentData(id int64) (DocumentData, error) {
+	data := DocumentData{Date: setStrMonth(time.Now().Format("02.01.2006"))}
+	contact, err := e.GetContact(id)
+	if err != nil {
+		return data, err
+	}
+	contact.Department, err = e.GetDepartment(contact.DepartmentID)
+	if err != nil {
+		return data, err
+	}
+	contact.Post, err = e.GetPost(contact.PostID)
+	if err != nil {
+		return data, err
+	}
+	contact.PostGO, err = e.GetPost(contact.PostGOID)
+	if err != nil {
+		return data, err
+	}
+	contact.Rank, err = e.GetRank(contact.RankID)
+	if err != nil {
+		return data, err
+	}
+	company, err := e.CompanyDocumentData(contact.CompanyID)
+	if err != nil {
+		return data, err
+	}
+	contact.Company = company.Company
+	data.Contact = contact
+	data.Company = company.Company
+	data.Contacts = company.Contacts
+	return data, nil
+}
+
+// SirenDocumentData - data of siren with type, company and responsible contact
+func (e *Edb) SirenDocumentData(id int64) (DocumentData, error) {
+	data := DocumentData{Date: setStrMonth(time.Now().Format("02.01.2006"))}
+	siren, err := e.GetSiren(id)
+	if err != nil {
+		return data, err
+	}
+	siren.Type, err = e.GetSirenType(siren.TypeID)
+	if err != nil {
+		return data, err
+	}
+	if siren.ContactID != 0 {
+		contact, err := e.ContactDocumentData(siren.ContactID)
+		if err != nil {
+			return data, err
+		}
+		siren.Contact = contact.Contact
+		data.Contact = contact.Contact
+	}
+	company, err := e.CompanyDocumentData(siren.CompanyID)
+	if err != nil {
+		return data, err
+	}
+	siren.Company = company.Company
+	data.Siren = siren
+	data.Company = company.Company
+	data.Contacts = company.Contacts
+	return data, nil
+}
+
+// RenderHTML - render html/template document
+func RenderHTML(w io.Writer, tmpl string, data interface{}) error {
+	t, err := htmltemplate.New("document").Funcs(DocumentFuncs()).Parse(tmpl)
+	if err != nil {
+		log.Println("RenderHTML Parse ", err)
+		return err
+	}
+	err = t.Execute(w, data)
+	if err != nil {
+		log.Println("RenderHTML Execute ", err)
+	}
+	return err
+}
+
+// cleanDocumentActions - remove markup that office editors insert inside {{ }} and escape output of actions
+func cleanDocumentActions(doc string) string {
+	var (
+		b      strings.Builder
+		copied int
+		start  = -1
+		last   = -1
+		inTag  bool
+	)
+	for i := 0; i < len(doc); i++ {
+		switch ch := doc[i]; {
+		case ch == '<':
+			inTag = true
+		case ch == '>':
+			inTag = false
+		case inTag:
+		case start < 0 && ch == '{' && last >= 0 && doc[last] == '{':
+			start = last
+			last = i
+		case start >= 0 && ch == '}' && last >= 0 && doc[last] == '}':
+			b.WriteString(doc[copied:start])
+			b.WriteString(escapeDocumentAction(docQuotes.Replace(docTagRe.ReplaceAllString(doc[start:i+1], ""))))
+			copied = i + 1
+			start = -1
+			last = -1
+		default:
+			last = i
+		}
+	}
+	b.WriteString(doc[copied:])
+	return b.String()
+}
+
+// escapeDocumentAction - pipe output of action to xml escaping, control actions are not changed
+func escapeDocumentAction(action string) string {
+	if docControlRe.MatchString(action) {
+		return action
+	}
+	var left, right string
+	body := action[2 : len(action)-2]
+	if strings.HasPrefix(body, "-") {
+		left = "-"
+		body = body[1:]
+	}
+	if strings.HasSuffix(body, "-") {
+		right = "-"
+		body = body[:len(body)-1]
+	}
+	return "{{" + left + body + " | xml " + right + "}}"
+}
+
+// prepareDocument - clean actions and replace paragraphs and table rows with only control action by action itself
+func prepareDocument(doc string) string {
+	doc = cleanDocumentActions(doc)
+	for _, re := range docParagraphs {
+		doc = re.ReplaceAllStringFunc(doc, func(element string) string {
+			text := strings.TrimSpace(docTagRe.ReplaceAllString(element, ""))
+			if strings.HasPrefix(text, "{{") && strings.HasSuffix(text, "}}") && strings.Count(text, "{{") == 1 && docControlRe.MatchString(text) {
+				return text
+			}
+			return element
+		})
+	}
+	return doc
+}
+
+func isDocumentPart(name string) bool {
+	switch {
+	case name == "word/document.xml", name == "content.xml", name == "styles.xml":
+		return true
+	case strings.HasPrefix(name, "word/header") && strings.HasSuffix(name, ".xml"):
+		return true
+	case strings.HasPrefix(name, "word/footer") && strings.HasSuffix(name, ".xml"):
+		return true
+	}
+	return false
+}
+
+// RenderDocument - render docx or odt template with text/template actions like {{.Company.Name}}
+// or {{contactName .Contact "dative"}} in text. Paragraph or table row containing only {{range}}, {{if}} or {{end}}
+// is removed, so rows of table between them are repeated.
+func RenderDocument(w io.Writer, tmpl io.ReaderAt, size int64, data interface{}) error {
+	zr, err := zip.NewReader(tmpl, size)
+	if err != nil {
+		log.Println("RenderDocument zip.NewReader ", err)
+		return err
+	}
+	found := false
+	for _, f := range zr.File {
+		if f.Name == "word/document.xml" || f.Name == "content.xml" {
+			found = true
+		}
+	}
+	if !found {
+		return errors.New("RenderDocument: template is not docx or odt")
+	}
+	zw := zip.NewWriter(w)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			log.Println("RenderDocument f.Open ", err)
+			return err
+		}
+		var body bytes.Buffer
+		_, err = io.Copy(&body, rc)
+		rc.Close()
+		if err != nil {
+			log.Println("RenderDocument io.Copy ", err)
+			return err
+		}
+		if isDocumentPart(f.Name) {
+			t, err := template.New(f.Name).Funcs(DocumentFuncs()).Parse(prepareDocument(body.String()))
+			if err != nil {
+				log.Println("RenderDocument Parse ", f.Name, err)
+				return err
+			}
+			var out bytes.Buffer
+			err = t.Execute(&out, data)
+			if err != nil {
+				log.Println("RenderDocument Execute ", f.Name, err)
+				return err
+			}
+			body = out
+		}
+		header := f.FileHeader
+		fw, err := zw.CreateHeader(&header)
+		if err != nil {
+			log.Println("RenderDocument zw.CreateHeader ", err)
+			return err
+		}
+		_, err = fw.Write(body.Bytes())
+		if err != nil {
+			log.Println("RenderDocument fw.Write ", err)
+			return err
+		}
+	}
+	return zw.Close()
+}
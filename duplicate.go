@@ -0,0 +1,787 @@
+package epgc
+
+import (
+	"database/sql"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/lib/pq"
+)
+
+// DuplicatePair - pair of possibly same contacts or companies
+type DuplicatePair struct {
+	FirstID    int64    `json:"first_id"`
+	FirstName  string   `json:"first_name"`
+	SecondID   int64    `json:"second_id"`
+	SecondName string   `json:"second_name"`
+	Score      float64  `json:"score"`
+	Reasons    []string `json:"reasons"`
+}
+
+type duplicateItem struct {
+	id       int64
+	name     string
+	norm     string
+	birthday string
+	grams    map[string]bool
+}
+
+// legalForms - abbreviations of legal forms ignored when company names compared
+var legalForms = map[string]bool{
+	"ооо": true, "оао": true, "зао": true, "пао": true, "ао": true, "нао": true, "ип": true,
+	"муп": true, "гуп": true, "фгуп": true, "фгбу": true, "гбу": true, "мбу": true, "мку": true,
+	"гку": true, "мау": true, "гау": true, "мбоу": true, "мкоу": true, "гбоу": true, "мбдоу": true,
+	"нко": true, "ано": true, "тсж": true, "снт": true, "пк": true,
+}
+
+// normalizeName - lower case words without punctuation, legal forms and order, so "ООО Ромашка" equals "Ромашка ООО"
+func normalizeName(name string, company bool) string {
+	name = strings.Replace(strings.ToLower(name), "ё", "е", -1)
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var result []string
+	for _, word := range words {
+		if company && legalForms[word] {
+			continue
+		}
+		result = append(result, word)
+	}
+	sort.Strings(result)
+	return strings.Join(result, " ")
+}
+
+// trigrams - set of trigrams of words like in pg_trgm
+func trigrams(norm string) map[string]bool {
+	grams := make(map[string]bool)
+	for _, word := range strings.Fields(norm) {
+		r := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(r); i++ {
+			grams[string(r[i:i+3])] = true
+		}
+	}
+	return grams
+}
+
+func (e *Edb) duplicateValues(query string) (map[int64][]string, error) {
+	values := make(map[int64][]string)
+	rows, err := e.db.Query(query)
+	if err != nil {
+		log.Println("duplicateValues e.db.Query ", err)
+		return values, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			sID    sql.NullInt64
+			sValue sql.NullString
+		)
+		err = rows.Scan(&sID, &sValue)
+		if err != nil {
+			log.Println("duplicateValues rows.Scan ", err)
+			return values, err
+		}
+		if sValue.Valid && sValue.String != "" {
+			values[n2i(sID)] = append(values[n2i(sID)], strings.ToLower(n2s(sValue)))
+		}
+	}
+	return values, rows.Err()
+}
+
+// maxDuplicateBucket - trigrams shared by more names are too common to pick candidates, pairs are still
+// found by other trigrams, same normalized name, phone or email
+const maxDuplicateBucket = 100
+
+// findDuplicates - score pairs by trigram similarity of normalized names and shared phones or emails,
+// only pairs sharing a rare trigram, name, phone or email are compared
+func findDuplicates(items []duplicateItem, phones, emails map[int64][]string, threshold float64) []DuplicatePair {
+	type pairKey struct{ a, b int }
+	var (
+		pairs      []DuplicatePair
+		candidates = make(map[pairKey]bool)
+		reasons    = make(map[pairKey][]string)
+		index      = make(map[string][]int)
+		names      = make(map[string][]int)
+	)
+	key := func(a, b int) pairKey {
+		if a > b {
+			a, b = b, a
+		}
+		return pairKey{a, b}
+	}
+	for i, item := range items {
+		for gram := range item.grams {
+			index[gram] = append(index[gram], i)
+		}
+		if item.norm != "" {
+			names[item.norm] = append(names[item.norm], i)
+		}
+	}
+	for _, bucket := range index {
+		if len(bucket) > maxDuplicateBucket {
+			continue
+		}
+		for x, i := range bucket {
+			for _, j := range bucket[:x] {
+				candidates[key(i, j)] = true
+			}
+		}
+	}
+	for _, bucket := range names {
+		for x, i := range bucket {
+			for _, j := range bucket[:x] {
+				candidates[key(i, j)] = true
+			}
+		}
+	}
+	for _, shares := range []struct {
+		reason string
+		values map[int64][]string
+	}{
+		{"phone", phones},
+		{"email", emails},
+	} {
+		owners := make(map[string][]int)
+		for i, item := range items {
+			for _, value := range shares.values[item.id] {
+				for _, j := range owners[value] {
+					k := key(i, j)
+					candidates[k] = true
+					reasons[k] = append(reasons[k], shares.reason+" "+value)
+				}
+				owners[value] = append(owners[value], i)
+			}
+		}
+	}
+	for k := range candidates {
+		a, b := items[k.a], items[k.b]
+		if a.id == b.id {
+			continue
+		}
+		count := 0
+		for gram := range a.grams {
+			if b.grams[gram] {
+				count++
+			}
+		}
+		var score float64
+		union := len(a.grams) + len(b.grams) - count
+		if union > 0 {
+			score = float64(count) / float64(union)
+		}
+		pairReasons := reasons[k]
+		if a.norm != "" && a.norm == b.norm {
+			score = 1
+			pairReasons = append([]string{"same name"}, pairReasons...)
+		} else if score > 0 {
+			pairReasons = append([]string{"similar name"}, pairReasons...)
+		}
+		score += 0.3 * float64(len(reasons[k]))
+		if score > 1 {
+			score = 1
+		}
+		if a.birthday != "" && b.birthday != "" && a.birthday != b.birthday {
+			score = score / 2
+			pairReasons = append(pairReasons, "different birthday")
+		}
+		if score < threshold {
+			continue
+		}
+		if a.id > b.id {
+			a, b = b, a
+		}
+		pairs = append(pairs, DuplicatePair{
+			FirstID:    a.id,
+			FirstName:  a.name,
+			SecondID:   b.id,
+			SecondName: b.name,
+			Score:      score,
+			Reasons:    pairReasons,
+		})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Score != pairs[j].Score {
+			return pairs[i].Score > pairs[j].Score
+		}
+		if pairs[i].FirstID != pairs[j].FirstID {
+			return pairs[i].FirstID < pairs[j].FirstID
+		}
+		return pairs[i].SecondID < pairs[j].SecondID
+	})
+	return pairs
+}
+
+// FindContactDuplicates - get pairs of possibly duplicated contacts with score not less than threshold (0..1)
+func (e *Edb) FindContactDuplicates(threshold float64) ([]DuplicatePair, error) {
+	var items []duplicateItem
+	rows, err := e.db.Query(`
+		SELECT
+			id,
+			name,
+			birthday
+		FROM
+			contacts
+	`)
+	if err != nil {
+		log.Println("FindContactDuplicates e.db.Query ", err)
+		return []DuplicatePair{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			sID       sql.NullInt64
+			sName     sql.NullString
+			sBirthday pq.NullTime
+		)
+		err = rows.Scan(&sID, &sName, &sBirthday)
+		if err != nil {
+			log.Println("FindContactDuplicates rows.Scan ", err)
+			return []DuplicatePair{}, err
+		}
+		norm := normalizeName(n2s(sName), false)
+		items = append(items, duplicateItem{id: n2i(sID), name: n2s(sName), norm: norm, birthday: n2sd(sBirthday), grams: trigrams(norm)})
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Println("FindContactDuplicates rows.Err ", err)
+		return []DuplicatePair{}, err
+	}
+	phones, err := e.duplicateValues(`SELECT contact_id, phone::text FROM phones WHERE contact_id IS NOT NULL`)
+	if err != nil {
+		return []DuplicatePair{}, err
+	}
+	emails, err := e.duplicateValues(`SELECT contact_id, email FROM emails WHERE contact_id IS NOT NULL`)
+	if err != nil {
+		return []DuplicatePair{}, err
+	}
+	return findDuplicates(items, phones, emails, threshold), nil
+}
+
+// FindCompanyDuplicates - get pairs of possibly duplicated companies with score not less than threshold (0..1)
+func (e *Edb) FindCompanyDuplicates(threshold float64) ([]DuplicatePair, error) {
+	var items []duplicateItem
+	rows, err := e.db.Query(`
+		SELECT
+			id,
+			name
+		FROM
+			companies
+	`)
+	if err != nil {
+		log.Println("FindCompanyDuplicates e.db.Query ", err)
+		return []DuplicatePair{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			sID   sql.NullInt64
+			sName sql.NullString
+		)
+		err = rows.Scan(&sID, &sName)
+		if err != nil {
+			log.Println("FindCompanyDuplicates rows.Scan ", err)
+			return []DuplicatePair{}, err
+		}
+		norm := normalizeName(n2s(sName), true)
+		items = append(items, duplicateItem{id: n2i(sID), name: n2s(sName), norm: norm, grams: trigrams(norm)})
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Println("FindCompanyDuplicates rows.Err ", err)
+		return []DuplicatePair{}, err
+	}
+	phones, err := e.duplicateValues(`SELECT company_id, phone::text FROM phones WHERE company_id IS NOT NULL`)
+	if err != nil {
+		return []DuplicatePair{}, err
+	}
+	emails, err := e.duplicateValues(`SELECT company_id, email FROM emails WHERE company_id IS NOT NULL`)
+	if err != nil {
+		return []DuplicatePair{}, err
+	}
+	return findDuplicates(items, phones, emails, threshold), nil
+}
+
+// execTx - run queries with same args in transaction, rollback on error
+func execTx(tx *instrumentedTx, name string, queries []string, args ...interface{}) error {
+	for _, query := range queries {
+		_, err := tx.Exec(query, args...)
+		if err != nil {
+			log.Println(name+" tx.Exec ", err)
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return nil
+}
+
+// lockMerged - lock survivor and duplicate rows of table till end of transaction, sql.ErrNoRows if any of them is missing
+func lockMerged(tx *instrumentedTx, name string, table string, survivorID int64, duplicateID int64) error {
+	var count int
+	err := tx.QueryRow(`
+		SELECT
+			count(*)
+		FROM (
+			SELECT
+				id
+			FROM
+				`+table+`
+			WHERE
+				id IN ($1, $2)
+			FOR UPDATE
+		) AS locked
+	`, survivorID, duplicateID).Scan(&count)
+	if err == nil && count != 2 {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		log.Println(name+" lockMerged ", err)
+		_ = tx.Rollback()
+	}
+	return err
+}
+
+// MergeContacts - move phones, emails, sirens, notification lists, call tree nodes, deliveries, incidents,
+// shifts and practice participations of duplicate contact to survivor, fill empty fields of survivor and delete duplicate,
+// sql.ErrNoRows if survivor or duplicate is missing
+func (e *Edb) MergeContacts(survivorID int64, duplicateID int64) error {
+	if survivorID == 0 || duplicateID == 0 || survivorID == duplicateID {
+		return nil
+	}
+	tx, err := e.db.Begin()
+	if err != nil {
+		log.Println("MergeContacts e.db.Begin ", err)
+		return err
+	}
+	err = lockMerged(tx, "MergeContacts", "contacts", survivorID, duplicateID)
+	if err != nil {
+		return err
+	}
+	var birthday pq.NullTime
+	err = tx.QueryRow(`SELECT birthday FROM contacts WHERE id = $1`, duplicateID).Scan(&birthday)
+	if err != nil {
+		log.Println("MergeContacts tx.QueryRow ", err)
+		_ = tx.Rollback()
+		return err
+	}
+	err = execTx(tx, "MergeContacts", []string{`
+		UPDATE
+			contacts AS s
+		SET
+			company_id = COALESCE(s.company_id, d.company_id),
+			department_id = COALESCE(s.department_id, d.department_id),
+			post_id = COALESCE(s.post_id, d.post_id),
+			post_go_id = COALESCE(s.post_go_id, d.post_go_id),
+			rank_id = COALESCE(s.rank_id, d.rank_id),
+			note = CASE WHEN COALESCE(s.note, '') = '' THEN d.note ELSE s.note END,
+			updated_at = now()
+		FROM
+			contacts AS d
+		WHERE
+			s.id = $1 AND d.id = $2
+	`, `
+		DELETE FROM
+			phones AS d
+		USING
+			phones AS s
+		WHERE
+			d.contact_id = $2 AND s.contact_id = $1 AND d.phone = s.phone AND d.fax = s.fax
+	`, `
+		UPDATE
+			phones
+		SET
+			contact_id = $1,
+			updated_at = now()
+		WHERE
+			contact_id = $2
+	`, `
+		DELETE FROM
+			emails AS d
+		USING
+			emails AS s
+		WHERE
+			d.contact_id = $2 AND s.contact_id = $1 AND lower(d.email) = lower(s.email)
+	`, `
+		UPDATE
+			emails
+		SET
+			contact_id = $1,
+			updated_at = now()
+		WHERE
+			contact_id = $2
+	`, `
+		UPDATE
+			sirens
+		SET
+			contact_id = $1,
+			updated_at = now()
+		WHERE
+			contact_id = $2
+	`, `
+		DELETE FROM
+			notification_members AS d
+		USING
+			notification_members AS s
+		WHERE
+			d.contact_id = $2 AND s.contact_id = $1 AND d.list_id = s.list_id
+	`, `
+		UPDATE
+			notification_members
+		SET
+			contact_id = $1,
+			updated_at = now()
+		WHERE
+			contact_id = $2
+	`, `
+		UPDATE
+			call_tree_nodes
+		SET
+			contact_id = $1,
+			updated_at = now()
+		WHERE
+			contact_id = $2
+	`, `
+		UPDATE
+			dispatch_deliveries
+		SET
+			contact_id = $1,
+			updated_at = now()
+		WHERE
+			contact_id = $2
+	`, `
+		DELETE FROM
+			incident_participants AS d
+		USING
+			incident_participants AS s
+		WHERE
+			d.contact_id = $2 AND s.contact_id = $1 AND d.incident_id = s.incident_id
+	`, `
+		UPDATE
+			incident_participants
+		SET
+			contact_id = $1
+		WHERE
+			contact_id = $2
+	`, `
+		UPDATE
+			incident_actions
+		SET
+			contact_id = $1,
+			updated_at = now()
+		WHERE
+			contact_id = $2
+	`, `
+		UPDATE
+			shifts
+		SET
+			contact_id = $1,
+			updated_at = now()
+		WHERE
+			contact_id = $2
+	`, `
+		DELETE FROM
+			practice_participants AS d
+		USING
+			practice_participants AS s
+		WHERE
+			d.contact_id = $2 AND s.contact_id = $1 AND d.practice_id = s.practice_id
+	`, `
+		UPDATE
+			practice_participants
+		SET
+			contact_id = $1
+		WHERE
+			contact_id = $2
+	`, `
+		DELETE FROM
+			contacts
+		WHERE
+			id = $2
+	`}, survivorID, duplicateID)
+	if err != nil {
+		return err
+	}
+	// birthday moved after delete of duplicate to not break UNIQUE(name, birthday)
+	if birthday.Valid {
+		_, err = tx.Exec(`
+			UPDATE
+				contacts
+			SET
+				birthday = $2
+			WHERE
+				id = $1 AND birthday IS NULL AND NOT EXISTS (
+					SELECT 1 FROM contacts AS c WHERE c.name = contacts.name AND c.birthday = $2
+				)
+		`, survivorID, birthday)
+		if err != nil {
+			log.Println("MergeContacts tx.Exec birthday ", err)
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Println("MergeContacts tx.Commit ", err)
+	}
+	return err
+}
+
+// MergeCompanies - move phones, emails, address, practices, sirens, contacts, departments, subordinate companies,
+// notification lists, call tree nodes, deliveries, incidents and practice participations of duplicate company
+// to survivor, fill empty fields of survivor and delete duplicate, sql.ErrNoRows if survivor or duplicate is missing.
+// Survivor subordinate to duplicate directly or through other companies gets parent of duplicate, so no cycle is made.
+func (e *Edb) MergeCompanies(survivorID int64, duplicateID int64) error {
+	if survivorID == 0 || duplicateID == 0 || survivorID == duplicateID {
+		return nil
+	}
+	tx, err := e.db.Begin()
+	if err != nil {
+		log.Println("MergeCompanies e.db.Begin ", err)
+		return err
+	}
+	err = lockMerged(tx, "MergeCompanies", "companies", survivorID, duplicateID)
+	if err != nil {
+		return err
+	}
+	err = execTx(tx, "MergeCompanies", []string{`
+		UPDATE
+			companies AS s
+		SET
+			address = CASE WHEN COALESCE(s.address, '') = '' THEN d.address ELSE s.address END,
+			note = CASE WHEN COALESCE(s.note, '') = '' THEN d.note ELSE s.note END,
+			updated_at = now()
+		FROM
+			companies AS d
+		WHERE
+			s.id = $1 AND d.id = $2
+	`, `
+		DELETE FROM
+			phones AS d
+		USING
+			phones AS s
+		WHERE
+			d.company_id = $2 AND s.company_id = $1 AND d.phone = s.phone AND d.fax = s.fax
+	`, `
+		UPDATE
+			phones
+		SET
+			company_id = $1,
+			updated_at = now()
+		WHERE
+			company_id = $2
+	`, `
+		DELETE FROM
+			emails AS d
+		USING
+			emails AS s
+		WHERE
+			d.company_id = $2 AND s.company_id = $1 AND lower(d.email) = lower(s.email)
+	`, `
+		UPDATE
+			emails
+		SET
+			company_id = $1,
+			updated_at = now()
+		WHERE
+			company_id = $2
+	`, `
+		UPDATE
+			practices
+		SET
+			company_id = $1,
+			updated_at = now()
+		WHERE
+			company_id = $2
+	`, `
+		UPDATE
+			sirens
+		SET
+			company_id = $1,
+			updated_at = now()
+		WHERE
+			company_id = $2
+	`, `
+		DELETE FROM
+			notification_members AS d
+		USING
+			notification_members AS s
+		WHERE
+			d.company_id = $2 AND s.company_id = $1 AND d.list_id = s.list_id
+	`, `
+		UPDATE
+			notification_members
+		SET
+			company_id = $1,
+			updated_at = now()
+		WHERE
+			company_id = $2
+	`, `
+		UPDATE
+			call_tree_nodes
+		SET
+			company_id = $1,
+			updated_at = now()
+		WHERE
+			company_id = $2
+	`, `
+		UPDATE
+			dispatch_deliveries
+		SET
+			company_id = $1,
+			updated_at = now()
+		WHERE
+			company_id = $2
+	`, `
+		DELETE FROM
+			incident_participants AS d
+		USING
+			incident_participants AS s
+		WHERE
+			d.company_id = $2 AND s.company_id = $1 AND d.incident_id = s.incident_id
+	`, `
+		UPDATE
+			incident_participants
+		SET
+			company_id = $1
+		WHERE
+			company_id = $2
+	`, `
+		DELETE FROM
+			practice_participants AS d
+		USING
+			practice_participants AS s
+		WHERE
+			d.company_id = $2 AND s.company_id = $1 AND d.practice_id = s.practice_id
+	`, `
+		UPDATE
+			practice_participants
+		SET
+			company_id = $1
+		WHERE
+			company_id = $2
+	`, `
+		UPDATE
+			contacts
+		SET
+			company_id = $1,
+			updated_at = now()
+		WHERE
+			company_id = $2
+	`, `
+		DELETE FROM
+			addresses AS d
+		USING
+			addresses AS s
+		WHERE
+			d.company_id = $2 AND s.company_id = $1
+	`, `
+		UPDATE
+			addresses
+		SET
+			company_id = $1,
+			updated_at = now()
+		WHERE
+			company_id = $2
+	`, `
+		UPDATE
+			contacts AS c
+		SET
+			department_id = s.id,
+			updated_at = now()
+		FROM
+			departments AS d,
+			departments AS s
+		WHERE
+			c.department_id = d.id AND d.company_id = $2 AND d.parent_id IS NULL
+			AND s.company_id = $1 AND s.parent_id IS NULL AND s.name = d.name
+	`, `
+		UPDATE
+			departments AS c
+		SET
+			parent_id = s.id,
+			updated_at = now()
+		FROM
+			departments AS d,
+			departments AS s
+		WHERE
+			c.parent_id = d.id AND d.company_id = $2 AND d.parent_id IS NULL
+			AND s.company_id = $1 AND s.parent_id IS NULL AND s.name = d.name
+	`, `
+		DELETE FROM
+			departments AS d
+		USING
+			departments AS s
+		WHERE
+			d.company_id = $2 AND d.parent_id IS NULL
+			AND s.company_id = $1 AND s.parent_id IS NULL AND s.name = d.name
+	`, `
+		UPDATE
+			departments
+		SET
+			company_id = $1,
+			updated_at = now()
+		WHERE
+			company_id = $2
+	`, `
+		UPDATE
+			companies AS s
+		SET
+			parent_id = NULLIF(d.parent_id, s.id),
+			updated_at = now()
+		FROM
+			companies AS d
+		WHERE
+			s.id = $1 AND d.id = $2 AND d.id IN (
+				WITH RECURSIVE
+					up AS (
+						SELECT
+							parent_id,
+							0 AS depth
+						FROM
+							companies
+						WHERE
+							id = $1
+						UNION ALL
+						SELECT
+							c.parent_id,
+							u.depth + 1
+						FROM
+							companies AS c
+						JOIN
+							up AS u ON c.id = u.parent_id
+						WHERE
+							u.depth < ` + strconv.Itoa(companyTreeDepth) + `
+					)
+				SELECT
+					parent_id
+				FROM
+					up
+			)
+	`, `
+		UPDATE
+			companies
+		SET
+			parent_id = $1,
+			updated_at = now()
+		WHERE
+			parent_id = $2
+	`, `
+		DELETE FROM
+			companies
+		WHERE
+			id = $2
+	`}, survivorID, duplicateID)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Println("MergeCompanies tx.Commit ", err)
+		return err
+	}
+	// departments of duplicate are moved to survivor or merged with its departments
+	e.InvalidateCache("departments")
+	return nil
+}
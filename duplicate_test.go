@@ -0,0 +1,104 @@
+package epgc
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name    string
+		company bool
+		want    string
+	}{
+		{"ООО Ромашка", true, "ромашка"},
+		{"Ромашка, ООО", true, "ромашка"},
+		{"МБОУ «Школа №5»", true, "5 школа"},
+		{"ООО Ромашка", false, "ооо ромашка"},
+		{"Фёдоров  Пётр", false, "петр федоров"},
+		{"Петр Федоров", false, "петр федоров"},
+		{"", false, ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeName(tt.name, tt.company); got != tt.want {
+			t.Errorf("normalizeName(%q, %v) = %q, want %q", tt.name, tt.company, got, tt.want)
+		}
+	}
+}
+
+func duplicateItems(names ...string) []duplicateItem {
+	var items []duplicateItem
+	for i, name := range names {
+		norm := normalizeName(name, true)
+		items = append(items, duplicateItem{id: int64(i + 1), name: name, norm: norm, grams: trigrams(norm)})
+	}
+	return items
+}
+
+func TestFindDuplicates(t *testing.T) {
+	items := duplicateItems("ООО Ромашка", "Ромашка ООО", "Ромашки", "Лютик", "Василек")
+	phones := map[int64][]string{4: {"123456"}, 5: {"123456"}}
+	pairs := findDuplicates(items, phones, nil, 0.3)
+	want := []struct {
+		first, second int64
+		reason        string
+	}{
+		{1, 2, "same name"},
+		{1, 3, "similar name"},
+		{2, 3, "similar name"},
+		{4, 5, "phone 123456"},
+	}
+	if len(pairs) != len(want) {
+		t.Fatalf("findDuplicates = %+v, want %d pairs", pairs, len(want))
+	}
+	found := make(map[[2]int64]DuplicatePair)
+	for _, pair := range pairs {
+		found[[2]int64{pair.FirstID, pair.SecondID}] = pair
+	}
+	for _, w := range want {
+		pair, ok := found[[2]int64{w.first, w.second}]
+		if !ok {
+			t.Errorf("pair %d-%d not found in %+v", w.first, w.second, pairs)
+			continue
+		}
+		if len(pair.Reasons) == 0 || pair.Reasons[0] != w.reason {
+			t.Errorf("pair %d-%d reasons = %v, want %q first", w.first, w.second, pair.Reasons, w.reason)
+		}
+	}
+	if pairs[0].Score != 1 || pairs[0].FirstID != 1 || pairs[0].SecondID != 2 {
+		t.Errorf("first pair = %+v, want 1-2 with score 1", pairs[0])
+	}
+}
+
+func TestFindDuplicatesBirthday(t *testing.T) {
+	items := duplicateItems("Иванов Иван", "Иван Иванов")
+	items[0].birthday = "01.02.1980"
+	items[1].birthday = "03.04.1990"
+	pairs := findDuplicates(items, nil, nil, 0)
+	if len(pairs) != 1 || pairs[0].Score != 0.5 {
+		t.Fatalf("findDuplicates = %+v, want one pair with score 0.5", pairs)
+	}
+}
+
+func TestFindDuplicatesCommonTrigrams(t *testing.T) {
+	var names []string
+	for i := 0; i < maxDuplicateBucket*2; i++ {
+		names = append(names, "Школа "+strconv.Itoa(i))
+	}
+	names = append(names, "Школа Березка", "Школа Березки")
+	items := duplicateItems(names...)
+	pairs := findDuplicates(items, nil, nil, 0)
+	all := len(items) * (len(items) - 1) / 2
+	if len(pairs) >= all {
+		t.Errorf("findDuplicates compared %d pairs, want less than %d when only common trigrams are shared", len(pairs), all)
+	}
+	found := false
+	for _, pair := range pairs {
+		if pair.FirstName == "Школа Березка" && pair.SecondName == "Школа Березки" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("findDuplicates lost pair sharing rare trigrams")
+	}
+}
@@ -98,7 +98,7 @@ func (e *Edb) GetEducation(id int64) (Education, error) {
 	if id == 0 {
 		return Education{}, nil
 	}
-	stmt, err := e.db.Prepare(`
+	stmt, err := e.prepare(`
 		SELECT
 			id,
 			start_date,
@@ -112,7 +112,7 @@ func (e *Edb) GetEducation(id int64) (Education, error) {
 			start_date
 	`)
 	if err != nil {
-		log.Println("GetEducation e.db.Prepare ", err)
+		log.Println("GetEducation e.prepare ", err)
 		return Education{}, err
 	}
 	row := stmt.QueryRow(id)
@@ -155,7 +155,7 @@ func (e *Edb) GetEducationSelect() ([]Education, error) {
 		SELECT
 			id,
 			start_date,
-			end_date,
+			end_date
 		FROM
 			educations
 		ORDER BY
@@ -179,7 +179,7 @@ func (e *Edb) GetEducationSelect() ([]Education, error) {
 
 // CreateEducation - create new education
 func (e *Edb) CreateEducation(education Education) (int64, error) {
-	stmt, err := e.db.Prepare(`
+	stmt, err := e.prepare(`
 		INSERT INTO
 			educations (
 				start_date,
@@ -197,7 +197,7 @@ func (e *Edb) CreateEducation(education Education) (int64, error) {
 			id
 	`)
 	if err != nil {
-		log.Println("CreateEducation e.db.Prepare ", err)
+		log.Println("CreateEducation e.prepare ", err)
 		return 0, err
 	}
 	err = stmt.QueryRow(sd2n(education.StartDate), sd2n(education.EndDate), s2n(education.Note)).Scan(&education.ID)
@@ -206,7 +206,7 @@ func (e *Edb) CreateEducation(education Education) (int64, error) {
 
 // UpdateEducation - save changes to education
 func (e *Edb) UpdateEducation(education Education) error {
-	stmt, err := e.db.Prepare(`
+	stmt, err := e.prepare(`
 		UPDATE
 			educations
 		SET
@@ -218,7 +218,7 @@ func (e *Edb) UpdateEducation(education Education) error {
 			id = $1
 	`)
 	if err != nil {
-		log.Println("UpdateEducation e.db.Prepare ", err)
+		log.Println("UpdateEducation e.prepare ", err)
 		return err
 	}
 	_, err = stmt.Exec(education.ID, sd2n(education.StartDate), sd2n(education.EndDate), s2n(education.Note))
@@ -64,7 +64,7 @@ func (e *Edb) GetEmail(id int64) (Email, error) {
 	if id == 0 {
 		return Email{}, nil
 	}
-	stmt, err := e.db.Prepare(`
+	stmt, err := e.prepare(`
 		SELECT
 			id,
 			company_id,
@@ -76,12 +76,12 @@ func (e *Edb) GetEmail(id int64) (Email, error) {
 			id = $1
 	`)
 	if err != nil {
-		log.Println("GetEmail e.db.Prepare", err)
+		log.Println("GetEmail e.prepare ", err)
 		return Email{}, err
 	}
 	row := stmt.QueryRow(id)
 	email, err := scanEmail(row)
-	return email, nil
+	return email, err
 }
 
 // GetEmails - get all emails for list
@@ -93,7 +93,7 @@ func (e *Edb) GetEmails() ([]Email, error) {
 		FROM
 			emails
 		ORDER BY
-			name ASC
+			email ASC
 	`)
 	if err != nil {
 		log.Println("GetEmailList e.db.Query ", err)
@@ -117,7 +117,7 @@ func (e *Edb) GetCompanyEmails(id int64) ([]Email, error) {
 		WHERE
 			company_id = $1
 		ORDER BY
-			name ASC
+			email ASC
 	`, id)
 	if err != nil {
 		log.Println("GetCompanyEmails e.db.Query ", err)
@@ -141,7 +141,7 @@ func (e *Edb) GetContactEmails(id int64) ([]Email, error) {
 		WHERE
 			contact_id = $1
 		ORDER BY
-			name ASC
+			email ASC
 	`, id)
 	if err != nil {
 		log.Println("GetContactEmails e.db.Query ", err)
@@ -153,7 +153,7 @@ func (e *Edb) GetContactEmails(id int64) ([]Email, error) {
 
 // CreateEmail - create new email
 func (e *Edb) CreateEmail(email Email) (int64, error) {
-	stmt, err := e.db.Prepare(`
+	stmt, err := e.prepare(`
 		INSERT INTO
 			emails (
 				company_id,
@@ -171,7 +171,7 @@ func (e *Edb) CreateEmail(email Email) (int64, error) {
 			id
 	`)
 	if err != nil {
-		log.Println("CreateEmail e.db.Prepare ", err)
+		log.Println("CreateEmail e.prepare ", err)
 		return 0, err
 	}
 	err = stmt.QueryRow(i2n(email.CompanyID), i2n(email.ContactID), s2n(email.Email)).Scan(&email.ID)
@@ -220,7 +220,7 @@ func (e *Edb) CreateContactEmails(contact Contact) error {
 
 // UpdateEmail - save email changes
 func (e *Edb) UpdateEmail(email Email) error {
-	stmt, err := e.db.Prepare(`
+	stmt, err := e.prepare(`
 		UPDATE
 			emails
 		SET
@@ -232,7 +232,7 @@ func (e *Edb) UpdateEmail(email Email) error {
 			id = $1
 	`)
 	if err != nil {
-		log.Println("UpdateEmail e.db.Prepare ", err)
+		log.Println("UpdateEmail e.prepare ", err)
 		return err
 	}
 	_, err = stmt.Exec(i2n(email.ID), i2n(email.CompanyID), i2n(email.ContactID), s2n(email.Email))
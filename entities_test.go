@@ -0,0 +1,1133 @@
+package epgc_test
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/serbe/epgc"
+	"github.com/serbe/epgc/epgctest"
+)
+
+func phoneNumbers(phones []epgc.PhoneSelect) []int64 {
+	var numbers []int64
+	for _, phone := range phones {
+		numbers = append(numbers, phone.Phone)
+	}
+	return numbers
+}
+
+func equalNumbers(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCompany(t *testing.T) {
+	e := epgctest.Open(t)
+	f := epgctest.NewFixtures(t, e)
+	scope := f.Scope("Торговля")
+	id, err := e.CreateCompany(epgc.Company{
+		Name:    "ООО Ромашка",
+		Address: "ул. Ленина, 1",
+		ScopeID: scope.ID,
+		Emails:  []epgc.Email{{Email: "info@romashka.ru"}},
+		Phones:  []epgc.Phone{{Phone: 5551001}, {Phone: 5551002}},
+		Faxes:   []epgc.Phone{{Phone: 5551009}},
+	})
+	check(t, err)
+	company, err := e.GetCompany(id)
+	check(t, err)
+	if company.Name != "ООО Ромашка" || company.ScopeID != scope.ID || len(company.Emails) != 1 || len(company.Phones) != 2 || len(company.Faxes) != 1 {
+		t.Errorf("GetCompany = %+v", company)
+	}
+	phones, err := e.GetCompanyPhones(id, false)
+	check(t, err)
+	if !equalNumbers(phoneNumbers(phones), []int64{5551001, 5551002}) {
+		t.Errorf("GetCompanyPhones = %+v", phones)
+	}
+	faxes, err := e.GetCompanyPhones(id, true)
+	check(t, err)
+	if !equalNumbers(phoneNumbers(faxes), []int64{5551009}) {
+		t.Errorf("GetCompanyPhones fax = %+v", faxes)
+	}
+
+	// phones which are kept must keep their ids
+	var kept int64
+	for _, phone := range phones {
+		if phone.Phone == 5551002 {
+			kept = phone.ID
+		}
+	}
+	company.Name = "ООО Ромашка и К"
+	company.Phones = []epgc.Phone{{Phone: 5551002}, {Phone: 5551003}}
+	company.Faxes = nil
+	company.Emails = []epgc.Email{{Email: "a@romashka.ru"}, {Email: "b@romashka.ru"}}
+	check(t, e.UpdateCompany(company))
+	phones, err = e.GetCompanyPhones(id, false)
+	check(t, err)
+	if !equalNumbers(phoneNumbers(phones), []int64{5551002, 5551003}) || phones[0].ID != kept {
+		t.Errorf("GetCompanyPhones after update = %+v, kept id %d", phones, kept)
+	}
+	faxes, err = e.GetCompanyPhones(id, true)
+	check(t, err)
+	if len(faxes) != 0 {
+		t.Errorf("GetCompanyPhones fax after update = %+v", faxes)
+	}
+	emails, err := e.GetCompanyEmails(id)
+	check(t, err)
+	if len(emails) != 2 || emails[0].Email != "a@romashka.ru" {
+		t.Errorf("GetCompanyEmails = %+v", emails)
+	}
+
+	list, err := e.GetCompanyList()
+	check(t, err)
+	if len(list) != 1 || list[0].Name != "ООО Ромашка и К" || list[0].ScopeName != "Торговля" {
+		t.Errorf("GetCompanyList = %+v", list)
+	}
+	items, err := e.GetCompanySelect()
+	check(t, err)
+	if !equalStrings(selectNames(items), []string{"ООО Ромашка и К"}) {
+		t.Errorf("GetCompanySelect = %+v", items)
+	}
+
+	check(t, e.DeleteCompany(id))
+	_, err = e.GetCompany(id)
+	if err == nil {
+		t.Error("GetCompany of deleted company without error")
+	}
+	phones, err = e.GetCompanyPhonesAll(id, false)
+	check(t, err)
+	if len(phones) != 0 {
+		t.Errorf("phones of deleted company = %+v", phones)
+	}
+}
+
+func TestContact(t *testing.T) {
+	e := epgctest.Open(t)
+	f := epgctest.NewFixtures(t, e)
+	company := f.Company("ООО Ромашка")
+	post := f.Post("Директор", false)
+	department := f.Department("Дирекция")
+	id, err := e.CreateContact(epgc.Contact{
+		Name:         "Иванов Иван Иванович",
+		CompanyID:    company.ID,
+		DepartmentID: department.ID,
+		PostID:       post.ID,
+		Birthday:     "15.05.1980",
+		Emails:       []epgc.Email{{Email: "ivanov@romashka.ru"}},
+		Phones:       []epgc.Phone{{Phone: 5552001}},
+		Faxes:        []epgc.Phone{{Phone: 5552009}},
+	})
+	check(t, err)
+	f.Contact("Петров Пётр Петрович", company.ID)
+	contact, err := e.GetContact(id)
+	check(t, err)
+	if contact.Surname != "Иванов" || contact.FirstName != "Иван" || contact.Patronymic != "Иванович" {
+		t.Errorf("GetContact name parts = %+v", contact)
+	}
+	if contact.Birthday != "15.05.1980" || contact.PostID != post.ID || len(contact.Emails) != 1 || len(contact.Phones) != 1 || len(contact.Faxes) != 1 {
+		t.Errorf("GetContact = %+v", contact)
+	}
+
+	contact.Phones = append(contact.Phones, epgc.Phone{Phone: 5552002})
+	contact.Faxes = nil
+	contact.Emails = nil
+	check(t, e.UpdateContact(contact))
+	phones, err := e.GetContactPhones(id, false)
+	check(t, err)
+	if !equalNumbers(phoneNumbers(phones), []int64{5552001, 5552002}) {
+		t.Errorf("GetContactPhones = %+v", phones)
+	}
+	faxes, err := e.GetContactPhonesAll(id, true)
+	check(t, err)
+	if len(faxes) != 0 {
+		t.Errorf("GetContactPhonesAll fax = %+v", faxes)
+	}
+	emails, err := e.GetContactEmails(id)
+	check(t, err)
+	if len(emails) != 0 {
+		t.Errorf("GetContactEmails = %+v", emails)
+	}
+
+	list, err := e.GetContactList()
+	check(t, err)
+	if len(list) != 2 || list[0].Name != "Иванов Иван Иванович" || list[0].CompanyName != "ООО Ромашка" || list[0].PostName != "Директор" {
+		t.Errorf("GetContactList = %+v", list)
+	}
+	items, err := e.GetContactSelect()
+	check(t, err)
+	if !equalStrings(selectNames(items), []string{"Иванов Иван Иванович", "Петров Пётр Петрович"}) {
+		t.Errorf("GetContactSelect = %+v", items)
+	}
+	staff, err := e.GetContactCompany(company.ID)
+	check(t, err)
+	if len(staff) != 2 || staff[0].DepartmentName != "Дирекция" || len(staff[0].Phones) != 2 {
+		t.Errorf("GetContactCompany = %+v", staff)
+	}
+
+	check(t, e.DeleteContact(id))
+	_, err = e.GetContact(id)
+	if err == nil {
+		t.Error("GetContact of deleted contact without error")
+	}
+	phones, err = e.GetContactPhonesAll(id, false)
+	check(t, err)
+	if len(phones) != 0 {
+		t.Errorf("phones of deleted contact = %+v", phones)
+	}
+}
+
+func TestContactRename(t *testing.T) {
+	e := epgctest.Open(t)
+	f := epgctest.NewFixtures(t, e)
+	contact := f.Contact("Иванов Иван Иванович", 0)
+	contact, err := e.GetContact(contact.ID)
+	check(t, err)
+	contact.Name = "Петров Пётр Петрович"
+	check(t, e.UpdateContact(contact))
+	contact, err = e.GetContact(contact.ID)
+	check(t, err)
+	if contact.Surname != "Петров" || contact.FirstName != "Пётр" || contact.Patronymic != "Петрович" {
+		t.Errorf("name parts after rename = %+v", contact)
+	}
+}
+
+func TestEmailAndPhone(t *testing.T) {
+	e := epgctest.Open(t)
+	f := epgctest.NewFixtures(t, e)
+	company := f.Company("ООО Ромашка")
+	emailID, err := e.CreateEmail(epgc.Email{CompanyID: company.ID, Email: "info@romashka.ru"})
+	check(t, err)
+	email, err := e.GetEmail(emailID)
+	check(t, err)
+	if email.CompanyID != company.ID || email.Email != "info@romashka.ru" {
+		t.Errorf("GetEmail = %+v", email)
+	}
+	email.Email = "office@romashka.ru"
+	check(t, e.UpdateEmail(email))
+	emails, err := e.GetEmails()
+	check(t, err)
+	if len(emails) != 1 || emails[0].Email != "office@romashka.ru" {
+		t.Errorf("GetEmails = %+v", emails)
+	}
+	check(t, e.DeleteEmail(emailID))
+
+	phoneID, err := e.CreatePhone(epgc.Phone{CompanyID: company.ID, Phone: 5553001, Fax: true})
+	check(t, err)
+	phone, err := e.GetPhone(phoneID)
+	check(t, err)
+	if phone.CompanyID != company.ID || phone.Phone != 5553001 || !phone.Fax {
+		t.Errorf("GetPhone = %+v", phone)
+	}
+	list, err := e.GetPhoneList()
+	check(t, err)
+	if len(list) != 1 {
+		t.Errorf("GetPhoneList = %+v", list)
+	}
+	check(t, e.DeleteAllCompanyPhones(company.ID))
+	_, err = e.GetPhone(phoneID)
+	if err == nil {
+		t.Error("GetPhone of deleted phone without error")
+	}
+}
+
+func TestPractice(t *testing.T) {
+	e := epgctest.Open(t)
+	f := epgctest.NewFixtures(t, e)
+	company := f.Company("ООО Ромашка")
+	kind := f.Kind("Тренировка")
+	past := f.Practice(company.ID, kind.ID, "10.03.2020")
+	future := f.Practice(company.ID, kind.ID, "10.03.2099")
+	practice, err := e.GetPractice(past.ID)
+	check(t, err)
+	if practice.CompanyID != company.ID || practice.KindID != kind.ID || practice.DateOfPractice != "10.03.2020" {
+		t.Errorf("GetPractice = %+v", practice)
+	}
+	practice.Topic = "Эвакуация"
+	check(t, e.UpdatePractice(practice))
+	list, err := e.GetPracticeList()
+	check(t, err)
+	if len(list) != 2 || list[0].ID != future.ID || list[1].Topic != "Эвакуация" || list[1].Company.Name != "ООО Ромашка" {
+		t.Errorf("GetPracticeList = %+v", list)
+	}
+	own, err := e.GetPracticeCompany(company.ID)
+	check(t, err)
+	if len(own) != 2 || own[0].ID != past.ID || own[0].Kind.Name != "Тренировка" {
+		t.Errorf("GetPracticeCompany = %+v", own)
+	}
+	near, err := e.GetPracticeNear()
+	check(t, err)
+	if len(near) != 1 || near[0].ID != future.ID {
+		t.Errorf("GetPracticeNear = %+v", near)
+	}
+	check(t, e.DeletePractice(past.ID))
+	check(t, e.DeletePractice(future.ID))
+}
+
+func TestPracticeResults(t *testing.T) {
+	e := epgctest.Open(t)
+	f := epgctest.NewFixtures(t, e)
+	city := f.Scope("Город")
+	district := f.Scope("Район")
+	kind := f.Kind("Тренировка")
+	organizer := f.Company("ООО Ромашка", city.ID)
+	guest := f.Company("ООО Лютик", city.ID)
+	contact := f.Contact("Иванов Иван Иванович", organizer.ID)
+	other := f.Company("ООО Василёк", district.ID)
+
+	id, err := e.CreatePractice(epgc.Practice{
+		CompanyID:      organizer.ID,
+		KindID:         kind.ID,
+		Topic:          "Эвакуация",
+		DateOfPractice: "10.12.2025",
+		Status:         epgc.PracticeHeld,
+		HeldDate:       "14.01.2026",
+		Score:          4,
+		Result:         "Эвакуация за 6 минут",
+		Remarks:        "Не работало оповещение на 3 этаже",
+		CompanyIDs:     []int64{guest.ID, guest.ID},
+		ContactIDs:     []int64{contact.ID},
+	})
+	check(t, err)
+	_, err = e.CreatePractice(epgc.Practice{CompanyID: organizer.ID, KindID: kind.ID, DateOfPractice: "20.03.2026", Status: epgc.PracticeCancelled})
+	check(t, err)
+	_, err = e.CreatePractice(epgc.Practice{CompanyID: other.ID, KindID: kind.ID, DateOfPractice: "01.06.2026", Status: epgc.PracticeHeld, Score: 2})
+	check(t, err)
+	_, err = e.CreatePractice(epgc.Practice{CompanyID: other.ID, DateOfPractice: "01.07.2026"})
+	check(t, err)
+	_, err = e.CreatePractice(epgc.Practice{CompanyID: other.ID, Status: "unknown"})
+	if err != epgc.ErrPracticeStatus {
+		t.Errorf("CreatePractice with bad status err = %v", err)
+	}
+	_, err = e.CreatePractice(epgc.Practice{CompanyID: other.ID, Score: 6})
+	if err != epgc.ErrPracticeScore {
+		t.Errorf("CreatePractice with bad score err = %v", err)
+	}
+
+	docID, err := e.CreatePracticeDocument(epgc.PracticeDocument{PracticeID: id, Name: "Акт.txt", Data: []byte("Акт проведения тренировки")})
+	check(t, err)
+	_, err = e.CreatePracticeDocument(epgc.PracticeDocument{PracticeID: id, Name: "Пустой.txt"})
+	if err != epgc.ErrPracticeDocumentSize {
+		t.Errorf("CreatePracticeDocument without data err = %v", err)
+	}
+	practice, err := e.GetPractice(id)
+	check(t, err)
+	if practice.HeldDate != "14.01.2026" || practice.Score != 4 || practice.Remarks == "" || len(practice.Companies) != 1 ||
+		practice.Companies[0].Name != guest.Name || len(practice.ContactIDs) != 1 || len(practice.Documents) != 1 {
+		t.Errorf("GetPractice = %+v", practice)
+	}
+	document, err := e.GetPracticeDocument(docID)
+	check(t, err)
+	if string(document.Data) != "Акт проведения тренировки" || !strings.HasPrefix(document.ContentType, "text/plain") ||
+		document.Size != int64(len(document.Data)) || practice.Documents[0].Data != nil {
+		t.Errorf("GetPracticeDocument = %+v", document)
+	}
+
+	// practice without participants keeps them on update
+	check(t, e.UpdatePractice(epgc.Practice{ID: id, CompanyID: organizer.ID, KindID: kind.ID, DateOfPractice: "10.12.2025", Status: epgc.PracticeHeld, Score: 5}))
+	practice, err = e.GetPractice(id)
+	check(t, err)
+	if practice.HeldDate != "10.12.2025" || practice.Score != 5 || len(practice.Companies) != 1 || len(practice.Contacts) != 1 {
+		t.Errorf("GetPractice after update = %+v", practice)
+	}
+
+	report, err := e.GetPracticeReport(2025)
+	check(t, err)
+	if len(report) != 1 || report[0].ScopeID != city.ID || report[0].Held != 1 || report[0].AverageScore != 5 ||
+		report[0].Companies != 2 || report[0].Contacts != 1 {
+		t.Errorf("GetPracticeReport(2025) = %+v", report)
+	}
+	report, err = e.GetPracticeReport(2026)
+	check(t, err)
+	if len(report) != 2 || report[0].ScopeName != "Город" || report[0].Cancelled != 1 || report[0].Evaluated != 0 ||
+		report[1].ScopeName != "Район" || report[1].Total != 2 || report[1].Held != 1 || report[1].Planned != 1 || report[1].AverageScore != 2 {
+		t.Errorf("GetPracticeReport(2026) = %+v", report)
+	}
+
+	check(t, e.DeleteContact(contact.ID))
+	check(t, e.DeletePracticeDocument(docID))
+	practice, err = e.GetPractice(id)
+	check(t, err)
+	if len(practice.Contacts) != 0 || len(practice.Documents) != 0 {
+		t.Errorf("GetPractice after delete of contact and document = %+v", practice)
+	}
+	check(t, e.DeletePractice(id))
+}
+
+func TestSiren(t *testing.T) {
+	e := epgctest.Open(t)
+	f := epgctest.NewFixtures(t, e)
+	company := f.Company("ООО Ромашка")
+	contact := f.Contact("Иванов Иван Иванович", company.ID)
+	sirenType := f.SirenType("С-40", 400)
+	created := f.Siren(12, sirenType.ID, company.ID)
+	siren, err := e.GetSiren(created.ID)
+	check(t, err)
+	if siren.NumID != 12 || siren.TypeID != sirenType.ID || siren.CompanyID != company.ID {
+		t.Errorf("GetSiren = %+v", siren)
+	}
+	siren.ContactID = contact.ID
+	siren.Note = "на крыше"
+	check(t, e.UpdateSiren(siren))
+	list, err := e.GetSirenList()
+	check(t, err)
+	if len(list) != 1 || list[0].ContactID != contact.ID || list[0].Note != "на крыше" {
+		t.Errorf("GetSirenList = %+v", list)
+	}
+	check(t, e.DeleteSiren(siren.ID))
+	list, err = e.GetSirenList()
+	check(t, err)
+	if len(list) != 0 {
+		t.Errorf("GetSirenList after delete = %+v", list)
+	}
+}
+
+func TestCompanyHierarchy(t *testing.T) {
+	e := epgctest.Open(t)
+	f := epgctest.NewFixtures(t, e)
+	ministry := f.Company("Министерство")
+	department := f.Company("Управление")
+	branch := f.Company("Филиал")
+	check(t, e.SetCompanyParent(department.ID, ministry.ID))
+	check(t, e.SetCompanyParent(branch.ID, department.ID))
+	f.Contact("Иванов Иван Иванович", branch.ID)
+	f.Practice(department.ID, f.Kind("Тренировка").ID, "10.03.2020")
+
+	path, err := e.GetCompanyPath(branch.ID)
+	check(t, err)
+	if path != "Министерство / Управление / Филиал" {
+		t.Errorf("GetCompanyPath = %q", path)
+	}
+	ancestors, err := e.GetCompanyAncestors(branch.ID)
+	check(t, err)
+	if !equalStrings(selectNames(ancestors), []string{"Министерство", "Управление"}) {
+		t.Errorf("GetCompanyAncestors = %+v", ancestors)
+	}
+	tree, err := e.GetCompanyTree()
+	check(t, err)
+	if len(tree) != 1 || tree[0].SubtreeContacts != 1 || tree[0].SubtreePractices != 1 || tree[0].Children[0].Children[0].ID != branch.ID {
+		t.Errorf("GetCompanyTree = %+v", tree)
+	}
+	subtree, err := e.GetCompanySubtree(department.ID)
+	check(t, err)
+	if subtree.ID != department.ID || subtree.Level != 0 || len(subtree.Children) != 1 {
+		t.Errorf("GetCompanySubtree = %+v", subtree)
+	}
+
+	if err := e.SetCompanyParent(ministry.ID, branch.ID); err != epgc.ErrCompanyCycle {
+		t.Errorf("SetCompanyParent with cycle = %v", err)
+	}
+	if err := e.SetCompanyParent(ministry.ID, ministry.ID); err != epgc.ErrCompanyCycle {
+		t.Errorf("SetCompanyParent with itself as parent = %v", err)
+	}
+	if _, err := e.CreateCompany(epgc.Company{Name: "Сирота", ParentID: branch.ID + 1000}); err != sql.ErrNoRows {
+		t.Errorf("CreateCompany with missing parent = %v, want sql.ErrNoRows", err)
+	}
+	if err := e.SetCompanyParent(branch.ID, branch.ID+1000); err != sql.ErrNoRows {
+		t.Errorf("SetCompanyParent with missing parent = %v, want sql.ErrNoRows", err)
+	}
+	// company built without ParentID keeps its parent on update
+	check(t, e.UpdateCompany(epgc.Company{ID: branch.ID, Name: branch.Name, Address: branch.Address}))
+	company, err := e.GetCompany(branch.ID)
+	check(t, err)
+	if company.ParentID != department.ID {
+		t.Errorf("parent after UpdateCompany = %d, want %d", company.ParentID, department.ID)
+	}
+
+	check(t, e.DeleteCompany(department.ID))
+	company, err = e.GetCompany(branch.ID)
+	check(t, err)
+	if company.ParentID != ministry.ID {
+		t.Errorf("parent after delete of middle company = %d", company.ParentID)
+	}
+}
+
+func TestMergeCompaniesCycle(t *testing.T) {
+	e := epgctest.Open(t)
+	f := epgctest.NewFixtures(t, e)
+	root := f.Company("Главк")
+	duplicate := f.Company("Главк дубль")
+	middle := f.Company("Отдел")
+	survivor := f.Company("Главк основной")
+	check(t, e.SetCompanyParent(duplicate.ID, root.ID))
+	check(t, e.SetCompanyParent(middle.ID, duplicate.ID))
+	check(t, e.SetCompanyParent(survivor.ID, middle.ID))
+
+	check(t, e.MergeCompanies(survivor.ID, duplicate.ID))
+	company, err := e.GetCompany(survivor.ID)
+	check(t, err)
+	if company.ParentID != root.ID {
+		t.Errorf("parent of survivor = %d, want %d", company.ParentID, root.ID)
+	}
+	company, err = e.GetCompany(middle.ID)
+	check(t, err)
+	if company.ParentID != survivor.ID {
+		t.Errorf("parent of middle company = %d, want %d", company.ParentID, survivor.ID)
+	}
+	ancestors, err := e.GetCompanyAncestors(middle.ID)
+	check(t, err)
+	if !equalStrings(selectNames(ancestors), []string{"Главк", "Главк основной"}) {
+		t.Errorf("GetCompanyAncestors after merge = %+v", ancestors)
+	}
+}
+
+func TestCompanyDepartments(t *testing.T) {
+	e := epgctest.Open(t)
+	f := epgctest.NewFixtures(t, e)
+	common := f.Department("Бухгалтерия")
+	company := f.Company("ООО Ромашка")
+	other := f.Company("ООО Лютик")
+	staff := f.CompanyDepartment("Отдел кадров", company.ID, 0)
+	f.CompanyDepartment("Отдел кадров", other.ID, 0)
+	hiring := f.CompanyDepartment("Сектор подбора", company.ID, staff.ID)
+	for name, departmentID := range map[string]int64{
+		"Иванов Иван Иванович":    staff.ID,
+		"Петров Пётр Петрович":    hiring.ID,
+		"Сидоров Сидор Сидорович": common.ID,
+	} {
+		_, err := e.CreateContact(epgc.Contact{Name: name, CompanyID: company.ID, DepartmentID: departmentID})
+		check(t, err)
+	}
+
+	got, err := e.GetCompany(company.ID)
+	check(t, err)
+	if len(got.Departments) != 1 || got.Departments[0].SubtreeContacts != 2 || len(got.Departments[0].Children) != 1 || got.Departments[0].Children[0].ID != hiring.ID {
+		t.Errorf("GetCompany departments = %+v", got.Departments)
+	}
+	contacts, err := e.GetContactDepartment(company.ID, staff.ID)
+	check(t, err)
+	if len(contacts) != 2 {
+		t.Errorf("GetContactDepartment subtree = %+v", contacts)
+	}
+	contacts, err = e.GetContactDepartment(company.ID, hiring.ID)
+	check(t, err)
+	if len(contacts) != 1 || contacts[0].Name != "Петров Пётр Петрович" {
+		t.Errorf("GetContactDepartment leaf = %+v", contacts)
+	}
+	contacts, err = e.GetContactCompany(company.ID)
+	check(t, err)
+	if len(contacts) != 3 {
+		t.Errorf("GetContactCompany = %+v", contacts)
+	}
+	items, err := e.GetDepartmentSelect()
+	check(t, err)
+	if !equalStrings(selectNames(items), []string{"Бухгалтерия"}) {
+		t.Errorf("GetDepartmentSelect = %+v", items)
+	}
+
+	staff.ParentID = hiring.ID
+	if err := e.UpdateDepartment(staff); err != epgc.ErrDepartmentCycle {
+		t.Errorf("UpdateDepartment with cycle = %v", err)
+	}
+	_, err = e.CreateDepartment(epgc.Department{Name: "Чужой сектор", CompanyID: other.ID, ParentID: hiring.ID})
+	if err != epgc.ErrDepartmentCompany {
+		t.Errorf("CreateDepartment under department of other company = %v", err)
+	}
+	_, err = e.CreateDepartment(epgc.Department{Name: "Отдел кадров", CompanyID: company.ID})
+	if err == nil {
+		t.Error("CreateDepartment with same name in same place without error")
+	}
+
+	check(t, e.DeleteCompany(company.ID))
+	if _, err := e.GetDepartment(hiring.ID); err != sql.ErrNoRows {
+		t.Errorf("GetDepartment of deleted company = %v, want sql.ErrNoRows", err)
+	}
+	contacts, err = e.GetContactCompany(company.ID)
+	check(t, err)
+	for _, contact := range contacts {
+		if contact.DepartmentName != "" && contact.DepartmentName != common.Name {
+			t.Errorf("contact %s in department %s of deleted company", contact.Name, contact.DepartmentName)
+		}
+	}
+}
+
+func TestAddressGroups(t *testing.T) {
+	e := epgctest.Open(t)
+	f := epgctest.NewFixtures(t, e)
+	for name, address := range map[string]string{
+		"ООО Ромашка": "Городищенский р-н, р.п. Городище, ул. Ленина, 1",
+		"ООО Лютик":   "Городищенский район, п. Кузьмичи, ул. Мира, д. 2",
+		"ООО Василёк": "Камышинский р-н, с. Лебяжье, ул. Садовая, д. 3",
+		"ООО Пион":    "",
+	} {
+		_, err := e.CreateCompany(epgc.Company{Name: name, Address: address})
+		check(t, err)
+	}
+	groups, err := e.GetCompanyAddressGroups(epgc.AddressDistrict)
+	check(t, err)
+	if len(groups) != 3 || groups[0].Name != "Городищенский р-н" || len(groups[0].Items) != 2 || groups[2].Name != "" {
+		t.Errorf("GetCompanyAddressGroups = %+v", groups)
+	}
+
+	sirenType := f.SirenType("С-40", 400)
+	id, err := e.CreateSiren(epgc.Siren{NumID: 1, TypeID: sirenType.ID, Address: "р.п. Городище ул.Ленина д.1", Latitude: "48.8", Longitude: "44.5"})
+	check(t, err)
+	siren, err := e.GetSiren(id)
+	check(t, err)
+	if siren.AddressParts.Settlement != "р.п. Городище" || siren.AddressParts.House != "д. 1" || siren.AddressParts.Latitude != 48.8 {
+		t.Errorf("GetSiren address = %+v", siren.AddressParts)
+	}
+	sirenGroups, err := e.GetSirenAddressGroups(epgc.AddressSettlement)
+	check(t, err)
+	if len(sirenGroups) != 1 || sirenGroups[0].Name != "р.п. Городище" {
+		t.Errorf("GetSirenAddressGroups = %+v", sirenGroups)
+	}
+
+	// loaded siren keeps old parts, they are parsed again from edited text, coordinates of siren win
+	siren.Address = "п. Кузьмичи ул. Мира д. 2"
+	siren.Latitude, siren.Longitude = "48.9", "44.6"
+	check(t, e.UpdateSiren(siren))
+	siren, err = e.GetSiren(id)
+	check(t, err)
+	if siren.AddressParts.Settlement != "п. Кузьмичи" || siren.AddressParts.Source != siren.Address || siren.AddressParts.Latitude != 48.9 || siren.AddressParts.Longitude != 44.6 {
+		t.Errorf("GetSiren address after edit = %+v", siren.AddressParts)
+	}
+	company, err := e.GetCompany(f.Company("ООО Астра").ID)
+	check(t, err)
+	company.Address = "Камышинский р-н, с. Лебяжье, ул. Садовая, д. 3"
+	check(t, e.UpdateCompany(company))
+	address, err := e.GetCompanyAddress(company.ID)
+	check(t, err)
+	if address.District != "Камышинский р-н" || address.Street == "ул. Тестовая" {
+		t.Errorf("GetCompanyAddress after edit = %+v", address)
+	}
+	check(t, e.DeleteSiren(id))
+	address, err = e.GetSirenAddress(id)
+	check(t, err)
+	if !address.IsEmpty() {
+		t.Errorf("address of deleted siren = %+v", address)
+	}
+}
+
+func TestGeocode(t *testing.T) {
+	e := epgctest.Open(t)
+	f := epgctest.NewFixtures(t, e)
+	registry := "address;latitude;longitude\n" +
+		"Волгоградская обл., г. Волгоград, ул. Ленина, д. 5;48.70;44.50\n" +
+		"Волгоградская обл., г. Волгоград, ул. Ленина, д. 7;48.72;44.52\n" +
+		"Волгоградская обл., г. Волгоград, ул. Мира, д. 1;48.80;44.60\n" +
+		"Волгоградская обл., г. Волгоград, ул. Мира, д. 2;;\n"
+	report, err := e.ImportGeoRegistry(strings.NewReader(registry), epgc.GeoImportOptions{Comma: ';', Source: "test"})
+	check(t, err)
+	if report.Imported != 3 || report.Skipped != 1 {
+		t.Errorf("ImportGeoRegistry = %+v", report)
+	}
+
+	result, err := e.Geocode("Волгоград, Ленина 5")
+	check(t, err)
+	if result.Precision != epgc.GeoHouse || result.Address.Region != "Волгоградская обл." || result.Address.Latitude != 48.70 {
+		t.Errorf("Geocode house = %+v", result)
+	}
+	result, err = e.Geocode("г. Волгоград, ул. Ленина, д. 99")
+	check(t, err)
+	if result.Precision != epgc.GeoStreet || result.Address.House != "д. 99" || result.Address.Latitude < 48.70 || result.Address.Latitude > 48.72 {
+		t.Errorf("Geocode street = %+v", result)
+	}
+	result, err = e.Geocode("г. Камышин, ул. Ленина, д. 5")
+	check(t, err)
+	if result.Precision != "" {
+		t.Errorf("Geocode unknown = %+v", result)
+	}
+
+	companyID, err := e.CreateCompany(epgc.Company{Name: "ООО Ромашка", Address: "г. Волгоград, ул. Мира, д. 1"})
+	check(t, err)
+	_, err = e.CreateCompany(epgc.Company{Name: "ООО Лютик", Address: "г. Камышин, ул. Мира, д. 1"})
+	check(t, err)
+	sirenType := f.SirenType("С-40", 400)
+	sirenID, err := e.CreateSiren(epgc.Siren{NumID: 1, TypeID: sirenType.ID, Address: "г. Волгоград, ул. Ленина, д. 7"})
+	check(t, err)
+	streetSirenID, err := e.CreateSiren(epgc.Siren{NumID: 2, TypeID: sirenType.ID, Address: "г. Волгоград, ул. Ленина, д. 99"})
+	check(t, err)
+
+	dry, err := e.GeocodeAll(epgc.GeocodeOptions{DryRun: true})
+	check(t, err)
+	if dry.Resolved[epgc.GeoHouse] != 2 || dry.Resolved[epgc.GeoStreet] != 1 || len(dry.Unresolved) != 1 || dry.Unresolved[0].Address != "г. Камышин, ул. Мира, д. 1" {
+		t.Errorf("GeocodeAll dry run = %+v", dry)
+	}
+	address, err := e.GetCompanyAddress(companyID)
+	check(t, err)
+	if address.Latitude != 0 {
+		t.Errorf("dry run saved address = %+v", address)
+	}
+
+	_, err = e.GeocodeAll(epgc.GeocodeOptions{})
+	check(t, err)
+	address, err = e.GetCompanyAddress(companyID)
+	check(t, err)
+	if address.Latitude != 48.80 || address.Region != "Волгоградская обл." || address.Precision != epgc.GeoHouse {
+		t.Errorf("geocoded company address = %+v", address)
+	}
+	siren, err := e.GetSiren(sirenID)
+	check(t, err)
+	if siren.Latitude != "48.720000" || siren.AddressParts.Longitude != 44.52 {
+		t.Errorf("geocoded siren = %+v", siren)
+	}
+	// siren coordinates are written only at house precision by default
+	siren, err = e.GetSiren(streetSirenID)
+	check(t, err)
+	if siren.Latitude != "" || siren.AddressParts.Precision != epgc.GeoStreet || siren.AddressParts.Latitude == 0 {
+		t.Errorf("siren geocoded at street precision = %+v", siren)
+	}
+	again, err := e.GeocodeAll(epgc.GeocodeOptions{})
+	check(t, err)
+	if len(again.Resolved) != 0 || len(again.Unresolved) != 1 {
+		t.Errorf("GeocodeAll second run = %+v", again)
+	}
+}
+
+func TestNotificationList(t *testing.T) {
+	e := epgctest.Open(t)
+	f := epgctest.NewFixtures(t, e)
+	company := f.Company("ООО Ромашка")
+	head := f.Contact("Иванов Иван Иванович", company.ID)
+	deputy := f.Contact("Петров Петр Петрович", company.ID)
+	worker := f.Contact("Сидоров Сидор Сидорович", company.ID)
+	for _, phone := range []epgc.Phone{
+		{ContactID: head.ID, Phone: 111},
+		{ContactID: deputy.ID, Phone: 222},
+		{ContactID: worker.ID, Phone: 111},
+		{CompanyID: company.ID, Phone: 333},
+		{CompanyID: company.ID, Phone: 444, Fax: true},
+	} {
+		_, err := e.CreatePhone(phone)
+		check(t, err)
+	}
+	_, err := e.CreateEmail(epgc.Email{ContactID: deputy.ID, Email: "petrov@example.com"})
+	check(t, err)
+
+	_, err = e.CreateNotificationList(epgc.NotificationList{Name: "bad", Members: []epgc.NotificationMember{{ContactID: head.ID, CompanyID: company.ID}}})
+	if err != epgc.ErrNotificationMember {
+		t.Errorf("CreateNotificationList with bad member err = %v", err)
+	}
+	members := []epgc.NotificationMember{
+		{CompanyID: company.ID, Priority: 2, Channel: epgc.ChannelFax},
+		{ContactID: head.ID, Priority: 1, EscalateAfter: 5},
+	}
+	listID, err := e.CreateNotificationList(epgc.NotificationList{Name: "Сбор руководства", Members: members})
+	check(t, err)
+	if members[1].Channel != "" {
+		t.Errorf("CreateNotificationList changed members of caller = %+v", members)
+	}
+	list, err := e.GetNotificationList(listID)
+	check(t, err)
+	if len(list.Members) != 2 || list.Members[0].ContactID != head.ID || list.Members[0].Channel != epgc.ChannelAll {
+		t.Errorf("GetNotificationList = %+v", list)
+	}
+
+	rootID, err := e.CreateCallTreeNode(epgc.CallTreeNode{ListID: listID, ContactID: head.ID})
+	check(t, err)
+	deputyID, err := e.CreateCallTreeNode(epgc.CallTreeNode{ListID: listID, ParentID: rootID, ContactID: deputy.ID})
+	check(t, err)
+	_, err = e.CreateCallTreeNode(epgc.CallTreeNode{ListID: listID, ParentID: deputyID, ContactID: worker.ID})
+	check(t, err)
+	err = e.UpdateCallTreeNode(epgc.CallTreeNode{ID: rootID, ListID: listID, ParentID: deputyID, ContactID: head.ID})
+	if err != epgc.ErrCallTreeCycle {
+		t.Errorf("UpdateCallTreeNode cycle err = %v", err)
+	}
+	tree, err := e.GetCallTree(listID)
+	check(t, err)
+	if len(tree) != 1 || len(tree[0].Children) != 1 || tree[0].Children[0].Children[0].CallerName != deputy.Name {
+		t.Errorf("GetCallTree = %+v", tree)
+	}
+
+	resolution, err := e.ResolveNotificationList(listID)
+	check(t, err)
+	if len(resolution.Targets) != 4 {
+		t.Fatalf("ResolveNotificationList targets = %+v", resolution.Targets)
+	}
+	if !equalNumbers(resolution.Phones, []int64{111, 222}) || !equalNumbers(resolution.Faxes, []int64{444}) {
+		t.Errorf("ResolveNotificationList phones = %v, faxes = %v", resolution.Phones, resolution.Faxes)
+	}
+	if len(resolution.Emails) != 1 || resolution.Targets[3].NotifiedBy != deputy.Name || len(resolution.Targets[3].Phones) != 0 {
+		t.Errorf("ResolveNotificationList = %+v", resolution)
+	}
+
+	check(t, e.DeleteContact(deputy.ID))
+	tree, err = e.GetCallTree(listID)
+	check(t, err)
+	if len(tree) != 1 || len(tree[0].Children) != 1 || tree[0].Children[0].ContactID != worker.ID {
+		t.Errorf("GetCallTree after delete of contact = %+v", tree)
+	}
+	check(t, e.DeleteNotificationList(listID))
+	tree, err = e.GetCallTree(listID)
+	check(t, err)
+	if len(tree) != 0 {
+		t.Errorf("GetCallTree of deleted list = %+v", tree)
+	}
+}
+
+func TestIncident(t *testing.T) {
+	e := epgctest.Open(t)
+	f := epgctest.NewFixtures(t, e)
+	fire := f.IncidentType("Пожар")
+	flood := f.IncidentType("Подтопление")
+	company := f.Company("ООО Ромашка")
+	contact := f.Contact("Иванов Иван Иванович", company.ID)
+
+	id, err := e.CreateIncident(epgc.Incident{
+		ReceivedAt:  "18.10.2026 23:40",
+		Source:      "112",
+		TypeID:      fire.ID,
+		Severity:    epgc.SeverityHigh,
+		Address:     "г. Волгоград, ул. Ленина, д. 5",
+		Latitude:    48.7080,
+		Longitude:   44.5133,
+		Description: "Возгорание склада",
+		CompanyIDs:  []int64{company.ID},
+		ContactIDs:  []int64{contact.ID, contact.ID},
+	})
+	check(t, err)
+	_, err = e.CreateIncident(epgc.Incident{ReceivedAt: "19.10.2026 08:00", TypeID: flood.ID, Severity: epgc.SeverityLow, Description: "Подтопление подвала"})
+	check(t, err)
+	_, err = e.CreateIncident(epgc.Incident{Status: "unknown"})
+	if err != epgc.ErrIncidentStatus {
+		t.Errorf("CreateIncident with bad status err = %v", err)
+	}
+
+	_, err = e.CreateIncidentAction(epgc.IncidentAction{IncidentID: id, ActionAt: "18.10.2026 23:45", ContactID: contact.ID, Description: "Выслан расчёт"})
+	check(t, err)
+	_, err = e.CreateIncidentAction(epgc.IncidentAction{IncidentID: id, ActionAt: "18.10.2026 23:42", Description: "Оповещён руководитель"})
+	check(t, err)
+	incident, err := e.GetIncident(id)
+	check(t, err)
+	if incident.Status != epgc.IncidentOpen || incident.ReceivedAt != "18.10.2026 23:40" || incident.Type.Name != "Пожар" ||
+		len(incident.Companies) != 1 || len(incident.Contacts) != 1 || incident.Contacts[0].Name != contact.Name {
+		t.Errorf("GetIncident = %+v", incident)
+	}
+	if len(incident.Actions) != 2 || incident.Actions[0].Description != "Оповещён руководитель" || incident.Actions[1].ContactName != contact.Name {
+		t.Errorf("GetIncident actions = %+v", incident.Actions)
+	}
+	// incident without CompanyIDs and ContactIDs keeps its participants
+	updated := incident
+	updated.CompanyIDs, updated.ContactIDs = nil, nil
+	check(t, e.UpdateIncident(updated))
+	incident, err = e.GetIncident(id)
+	check(t, err)
+	if len(incident.Companies) != 1 || len(incident.Contacts) != 1 {
+		t.Errorf("participants after update without them = %+v, %+v", incident.Companies, incident.Contacts)
+	}
+
+	list, err := e.GetIncidentList(epgc.IncidentFilter{From: "18.10.2026", To: "18.10.2026"})
+	check(t, err)
+	if len(list) != 1 || list[0].ID != id || list[0].TypeName != "Пожар" {
+		t.Errorf("GetIncidentList by period = %+v", list)
+	}
+	list, err = e.GetIncidentList(epgc.IncidentFilter{Severity: epgc.SeverityMedium})
+	check(t, err)
+	if len(list) != 1 || list[0].ID != id {
+		t.Errorf("GetIncidentList by severity = %+v", list)
+	}
+	list, err = e.GetIncidentList(epgc.IncidentFilter{TypeID: flood.ID})
+	check(t, err)
+	if len(list) != 1 || list[0].Description != "Подтопление подвала" {
+		t.Errorf("GetIncidentList by type = %+v", list)
+	}
+	list, err = e.GetIncidentList(epgc.IncidentFilter{CompanyID: company.ID})
+	check(t, err)
+	if len(list) != 1 || list[0].ID != id {
+		t.Errorf("GetIncidentList by company = %+v", list)
+	}
+
+	sirenType := f.SirenType("С-40", 500)
+	nearID, err := e.CreateSiren(epgc.Siren{NumID: 1, TypeID: sirenType.ID, Latitude: "48.7100", Longitude: "44.5133"})
+	check(t, err)
+	_, err = e.CreateSiren(epgc.Siren{NumID: 2, TypeID: sirenType.ID, Latitude: "48.7080", Longitude: "44.5233"})
+	check(t, err)
+	_, err = e.CreateSiren(epgc.Siren{NumID: 3, TypeID: sirenType.ID, Latitude: "48.8", Longitude: "44.9"})
+	check(t, err)
+	sirens, err := e.GetIncidentSirens(id, 1000)
+	check(t, err)
+	if len(sirens) != 2 || sirens[0].ID != nearID || !sirens[0].Covers || sirens[1].Covers {
+		t.Errorf("GetIncidentSirens = %+v", sirens)
+	}
+
+	check(t, e.CloseIncident(id, "Пожар ликвидирован"))
+	list, err = e.GetIncidentList(epgc.IncidentFilter{Status: epgc.IncidentClosed})
+	check(t, err)
+	if len(list) != 1 || list[0].ID != id {
+		t.Errorf("GetIncidentList by status = %+v", list)
+	}
+	incident, err = e.GetIncident(id)
+	check(t, err)
+	if incident.ClosedAt == "" || incident.Result != "Пожар ликвидирован" {
+		t.Errorf("closed incident = %+v", incident)
+	}
+
+	check(t, e.DeleteContact(contact.ID))
+	incident, err = e.GetIncident(id)
+	check(t, err)
+	if len(incident.Contacts) != 0 || len(incident.Actions) != 2 || incident.Actions[1].ContactID != 0 {
+		t.Errorf("incident after delete of contact = %+v", incident)
+	}
+	check(t, e.DeleteIncident(id))
+	incident, err = e.GetIncident(id)
+	if err == nil {
+		t.Errorf("GetIncident of deleted incident = %+v", incident)
+	}
+}
+
+func TestRoster(t *testing.T) {
+	e := epgctest.Open(t)
+	f := epgctest.NewFixtures(t, e)
+	company := f.Company("ЕДДС")
+	first := f.Contact("Иванов Иван Иванович", company.ID)
+	second := f.Contact("Петров Пётр Петрович", company.ID)
+	check(t, e.CreateContactPhones(epgc.Contact{ID: first.ID, Phones: []epgc.Phone{{Phone: 89001234567}}}, false))
+
+	day, err := e.CreateShiftTemplate(epgc.ShiftTemplate{Name: "Дневная", StartTime: "08:00", Duration: 12 * 60})
+	check(t, err)
+	full, err := e.CreateShiftTemplate(epgc.ShiftTemplate{Name: "Сутки", StartTime: "08:00", Duration: 24 * 60, MinRest: 72 * 60})
+	check(t, err)
+	_, err = e.CreateShiftTemplate(epgc.ShiftTemplate{Name: "Ошибка", StartTime: "8 утра", Duration: 60})
+	if err != epgc.ErrShiftTime {
+		t.Errorf("CreateShiftTemplate with bad start err = %v", err)
+	}
+
+	dayID, err := e.CreateShift(epgc.Shift{TemplateID: day, ContactID: first.ID, StartsAt: "19.10.2026"})
+	check(t, err)
+	fullID, err := e.CreateShift(epgc.Shift{TemplateID: full, ContactID: second.ID, StartsAt: "19.10.2026"})
+	check(t, err)
+	_, err = e.CreateShift(epgc.Shift{ContactID: first.ID, StartsAt: "19.10.2026 18:00", EndsAt: "19.10.2026 22:00"})
+	if err != epgc.ErrShiftOverlap {
+		t.Errorf("CreateShift of same contact twice err = %v", err)
+	}
+	conflicts, err := e.CheckShift(epgc.Shift{TemplateID: day, ContactID: second.ID, StartsAt: "21.10.2026"})
+	check(t, err)
+	if len(conflicts) != 1 || conflicts[0].Kind != epgc.ConflictRest || conflicts[0].OtherShiftID != fullID || conflicts[0].Gap != 24*60 {
+		t.Errorf("CheckShift after 24 hours shift = %+v", conflicts)
+	}
+	// short rest is allowed and reported for period
+	restID, err := e.CreateShift(epgc.Shift{TemplateID: day, ContactID: second.ID, StartsAt: "21.10.2026"})
+	check(t, err)
+	conflicts, err = e.GetRosterConflicts("20.10.2026", "21.10.2026")
+	check(t, err)
+	if len(conflicts) != 1 || conflicts[0].ShiftID != restID {
+		t.Errorf("GetRosterConflicts = %+v", conflicts)
+	}
+
+	roster, err := e.GetRoster("19.10.2026", "19.10.2026")
+	check(t, err)
+	if len(roster) != 2 || roster[0].ID != dayID || roster[0].StartsAt != "19.10.2026 08:00" || roster[0].EndsAt != "19.10.2026 20:00" ||
+		roster[0].TemplateName != "Дневная" || len(roster[0].Phones) != 1 || roster[1].ContactName != second.Name {
+		t.Errorf("GetRoster = %+v", roster)
+	}
+	duty, err := e.GetOnDuty("19.10.2026 21:00")
+	check(t, err)
+	if len(duty) != 1 || duty[0].ID != fullID {
+		t.Errorf("GetOnDuty = %+v", duty)
+	}
+
+	open, err := e.CreateIncident(epgc.Incident{ReceivedAt: "19.10.2026 10:00", Description: "Обрыв линии"})
+	check(t, err)
+	closed, err := e.CreateIncident(epgc.Incident{ReceivedAt: "19.10.2026 11:00", Description: "Ложный вызов"})
+	check(t, err)
+	check(t, e.CloseIncident(closed, "Не подтвердился"))
+	_, err = e.SaveShiftHandover(epgc.ShiftHandover{ShiftID: dayID, Note: "Линия не восстановлена"})
+	check(t, err)
+	handover, err := e.GetShiftHandover(dayID)
+	check(t, err)
+	if handover.Note != "Линия не восстановлена" || len(handover.Incidents) != 1 || handover.Incidents[0].ID != open {
+		t.Errorf("GetShiftHandover = %+v", handover)
+	}
+	_, err = e.SaveShiftHandover(epgc.ShiftHandover{ShiftID: dayID, Note: "Без происшествий", IncidentIDs: []int64{}})
+	check(t, err)
+	handover, err = e.GetLastHandover()
+	check(t, err)
+	if handover.ShiftID != dayID || handover.Note != "Без происшествий" || len(handover.Incidents) != 0 {
+		t.Errorf("GetLastHandover = %+v", handover)
+	}
+
+	check(t, e.DeleteShiftTemplate(day))
+	check(t, e.DeleteShift(restID))
+	roster, err = e.GetRoster("19.10.2026", "25.10.2026")
+	check(t, err)
+	if len(roster) != 2 || roster[0].TemplateID != 0 || roster[0].EndsAt != "19.10.2026 20:00" {
+		t.Errorf("GetRoster after delete = %+v", roster)
+	}
+}
+
+func TestReports(t *testing.T) {
+	e := epgctest.Open(t)
+	f := epgctest.NewFixtures(t, e)
+	city := f.Scope("Город")
+	district := f.Scope("Район")
+	drill := f.Kind("Тренировка")
+	exercise := f.Kind("Учение")
+	chief := f.Post("Начальник ГО", true)
+	sirenType := f.SirenType("С-40", 500)
+	first := f.Company("ООО Ромашка", city.ID)
+	second := f.Company("ООО Лютик", city.ID)
+	third := f.Company("ООО Василёк", district.ID)
+	_, err := e.CreateContact(epgc.Contact{Name: "Иванов Иван Иванович", CompanyID: first.ID, PostGOID: chief.ID})
+	check(t, err)
+	_, err = e.CreateContact(epgc.Contact{Name: "Петров Пётр Петрович", CompanyID: third.ID, PostGOID: chief.ID})
+	check(t, err)
+	f.Contact("Сидоров Сидор Сидорович", second.ID)
+	f.Practice(first.ID, drill.ID, "10.03.2026")
+	f.Practice(first.ID, drill.ID, "20.03.2026")
+	f.Practice(third.ID, exercise.ID, "15.04.2026")
+	f.Practice(second.ID, drill.ID, "15.04.2025")
+	_, err = e.CreateSiren(epgc.Siren{NumID: 1, TypeID: sirenType.ID, CompanyID: first.ID, Stage: 1})
+	check(t, err)
+	_, err = e.CreateSiren(epgc.Siren{NumID: 2, TypeID: sirenType.ID, CompanyID: first.ID, Stage: 1})
+	check(t, err)
+	_, err = e.CreateSiren(epgc.Siren{NumID: 3, TypeID: sirenType.ID, CompanyID: third.ID, Stage: 2})
+	check(t, err)
+	_, err = e.CreateEducation(epgc.Education{StartDate: "02.02.2026", EndDate: "06.02.2026"})
+	check(t, err)
+	_, err = e.CreateEducation(epgc.Education{StartDate: "16.02.2026", EndDate: "20.02.2026"})
+	check(t, err)
+	year := epgc.ReportFilter{From: "01.01.2026", To: "31.12.2026"}
+
+	companies, err := e.GetCompanyStats(year)
+	check(t, err)
+	if len(companies) != 2 || companies[0].ScopeName != "Город" || companies[0].Companies != 2 || companies[0].WithContacts != 2 ||
+		companies[0].WithPractices != 1 || companies[0].WithSirens != 1 || companies[1].Companies != 1 {
+		t.Errorf("GetCompanyStats = %+v", companies)
+	}
+	practices, err := e.GetPracticeStats(epgc.ReportFilter{From: year.From, To: year.To, ScopeID: city.ID})
+	check(t, err)
+	if len(practices) != 1 || practices[0].KindName != "Тренировка" || practices[0].Month != 3 || practices[0].Total != 2 || practices[0].Planned != 2 {
+		t.Errorf("GetPracticeStats by scope = %+v", practices)
+	}
+	practices, err = e.GetPracticeStats(epgc.ReportFilter{KindID: drill.ID})
+	check(t, err)
+	if len(practices) != 2 || practices[0].Year != 2025 || practices[1].Year != 2026 {
+		t.Errorf("GetPracticeStats by kind = %+v", practices)
+	}
+	contacts, err := e.GetContactGOStats(epgc.ReportFilter{})
+	check(t, err)
+	if len(contacts) != 2 || contacts[0].ScopeName != "Город" || contacts[0].PostGOName != "Начальник ГО" || contacts[0].Contacts != 1 {
+		t.Errorf("GetContactGOStats = %+v", contacts)
+	}
+	sirens, err := e.GetSirenStats(epgc.ReportFilter{})
+	check(t, err)
+	if len(sirens) != 2 || sirens[0].Stage != 1 || sirens[0].Sirens != 2 || sirens[0].Companies != 1 || sirens[1].TypeName != "С-40" {
+		t.Errorf("GetSirenStats = %+v", sirens)
+	}
+	educations, err := e.GetEducationStats(year)
+	check(t, err)
+	if len(educations) != 1 || educations[0].Month != 2 || educations[0].Educations != 2 || educations[0].Days != 10 {
+		t.Errorf("GetEducationStats = %+v", educations)
+	}
+
+	var csv strings.Builder
+	check(t, e.ExportReportCSV(&csv, epgc.ReportSirens, epgc.ReportFilter{ScopeID: district.ID}))
+	if csv.String() != "Этап,Тип сирены,Сирен,Организаций\n2,С-40,1,1\n" {
+		t.Errorf("ExportReportCSV = %q", csv.String())
+	}
+	var xlsx bytes.Buffer
+	check(t, e.ExportReportXLSX(&xlsx, epgc.ReportPractices, year))
+	if !bytes.HasPrefix(xlsx.Bytes(), []byte("PK")) {
+		t.Errorf("ExportReportXLSX is not zip")
+	}
+	if err = e.ExportReportCSV(&csv, "unknown", year); err != epgc.ErrUnknownReport {
+		t.Errorf("ExportReportCSV of unknown report err = %v", err)
+	}
+}
+
+func TestImportDryRun(t *testing.T) {
+	e := epgctest.Open(t)
+	f := epgctest.NewFixtures(t, e)
+	f.Company("ООО Ромашка")
+	csv := "company,scope,contact\nООО Ромашка,,Иванов Иван Иванович\n\nООО Ромашка,Новая сфера,\n"
+	report, err := e.ImportCSV(strings.NewReader(csv), epgc.ImportOptions{CreateMissing: true, DryRun: true})
+	check(t, err)
+	var actions []string
+	for _, row := range report.Rows {
+		actions = append(actions, row.Entity+":"+row.Action)
+		if row.ID < 0 {
+			t.Errorf("dry run row with placeholder id %+v", row)
+		}
+	}
+	want := "company:update contact:insert scope:insert company:insert"
+	if strings.Join(actions, " ") != want || report.Rejected != 0 {
+		t.Errorf("ImportCSV dry run = %v, want %v", actions, want)
+	}
+	// empty line of file is counted
+	if len(report.Rows) == 4 && report.Rows[3].Line != 4 {
+		t.Errorf("ImportCSV line after empty line = %d", report.Rows[3].Line)
+	}
+	scopes, err := e.GetScopeSelect()
+	check(t, err)
+	if len(scopes) != 0 {
+		t.Errorf("dry run created scopes %+v", scopes)
+	}
+}
+
+func TestImportCompanyDepartment(t *testing.T) {
+	e := epgctest.Open(t)
+	f := epgctest.NewFixtures(t, e)
+	company := f.Company("ООО Ромашка")
+	f.Department("Отдел кадров")
+	common := f.Department("Бухгалтерия")
+	staff := f.CompanyDepartment("Отдел кадров", company.ID, 0)
+	csv := "company,contact,department\n" +
+		"ООО Ромашка,Иванов Иван Иванович,отдел  кадров\n" +
+		"ООО Ромашка,Петров Пётр Петрович,Бухгалтерия\n"
+	report, err := e.ImportCSV(strings.NewReader(csv), epgc.ImportOptions{})
+	check(t, err)
+	departments := make(map[string]int64)
+	for _, row := range report.Rows {
+		if row.Entity != "contact" {
+			continue
+		}
+		contact, err := e.GetContact(row.ID)
+		check(t, err)
+		departments[contact.Name] = contact.DepartmentID
+	}
+	if departments["Иванов Иван Иванович"] != staff.ID || departments["Петров Пётр Петрович"] != common.ID {
+		t.Errorf("departments of imported contacts = %v, want %d and %d", departments, staff.ID, common.ID)
+	}
+}
+
+func TestExportDirectory(t *testing.T) {
+	e := epgctest.Open(t)
+	f := epgctest.NewFixtures(t, e)
+	scope := f.Scope("Образование")
+	company := f.Company("Школа №1", scope.ID)
+	contact := f.Contact("Иванов Иван Иванович", company.ID)
+	check(t, e.CreateContactEmails(epgc.Contact{ID: contact.ID, Emails: []epgc.Email{{Email: "ivanov@example.ru"}}}))
+	var csv strings.Builder
+	check(t, e.ExportDirectoryCSV(&csv, epgc.DirectoryOptions{Columns: []string{epgc.ColumnContact, epgc.ColumnPhones, epgc.ColumnEmails}}))
+	want := "ФИО,Телефоны,Эл. почта\n,,\nИванов Иван Иванович,,ivanov@example.ru\n"
+	if csv.String() != want {
+		t.Errorf("ExportDirectoryCSV = %q, want %q", csv.String(), want)
+	}
+	staff, err := e.GetContactCompany(company.ID)
+	check(t, err)
+	if len(staff) != 1 || len(staff[0].Phones) != 0 || staff[0].Phones == nil {
+		t.Errorf("GetContactCompany phones of contact without phones = %+v", staff)
+	}
+}
+
+func TestMergeMissing(t *testing.T) {
+	e := epgctest.Open(t)
+	f := epgctest.NewFixtures(t, e)
+	company := f.Company("ООО Ромашка")
+	contact := f.Contact("Иванов Иван Иванович", company.ID)
+	if err := e.MergeContacts(contact.ID+1000, contact.ID); err != sql.ErrNoRows {
+		t.Errorf("MergeContacts missing survivor = %v, want sql.ErrNoRows", err)
+	}
+	if err := e.MergeContacts(contact.ID, contact.ID+1000); err != sql.ErrNoRows {
+		t.Errorf("MergeContacts missing duplicate = %v, want sql.ErrNoRows", err)
+	}
+	if err := e.MergeCompanies(company.ID+1000, company.ID); err != sql.ErrNoRows {
+		t.Errorf("MergeCompanies missing survivor = %v, want sql.ErrNoRows", err)
+	}
+	if err := e.MergeCompanies(company.ID, company.ID+1000); err != sql.ErrNoRows {
+		t.Errorf("MergeCompanies missing duplicate = %v, want sql.ErrNoRows", err)
+	}
+	_, err := e.GetContact(contact.ID)
+	check(t, err)
+	_, err = e.GetCompany(company.ID)
+	check(t, err)
+}
@@ -2,6 +2,7 @@ package epgc
 
 import (
 	"fmt"
+	"time"
 
 	"database/sql"
 	// need to sql dialect
@@ -10,8 +11,11 @@ import (
 
 // Edb struct to store *DB
 type Edb struct {
-	db  *sql.DB
-	log bool
+	db    *instrumentedDB
+	dsn   string
+	log   bool
+	cache *lookupCache
+	stmts stmtCache
 }
 
 // SelectItem - struct for select element
@@ -20,12 +24,54 @@ type SelectItem struct {
 	Name string `json:"name"`
 }
 
+// Option - option of Open
+type Option func(*Edb)
+
+// WithLogSQL - log sql queries
+func WithLogSQL(logsql bool) Option {
+	return func(e *Edb) {
+		e.log = logsql
+	}
+}
+
+// WithCache - cache select lists and dictionary items for ttl
+func WithCache(ttl time.Duration) Option {
+	return func(e *Edb) {
+		e.EnableCache(ttl)
+	}
+}
+
+// WithMetrics - send duration and error of every query to observer, *Metrics also gets stats of connection pool
+func WithMetrics(observer Observer) Option {
+	return func(e *Edb) {
+		e.db.observer = observer
+		if m, ok := observer.(*Metrics); ok {
+			m.Lock()
+			m.stats = e.db.Stats
+			m.Unlock()
+		}
+	}
+}
+
+// WithTracer - start span for every query with method, entity and id of entity
+func WithTracer(tracer Tracer) Option {
+	return func(e *Edb) {
+		e.db.tracer = tracer
+	}
+}
+
 // InitDB initialize database
 func InitDB(dbname string, user string, password string, sslmode string, logsql bool) (*Edb, error) {
-	e := new(Edb)
 	// sslmode=disable
 	opt := fmt.Sprintf("user=%s password=%s dbname=%s sslmode=%s", user, password, dbname, sslmode)
-	db, err := sql.Open("postgres", opt)
+	return Open(opt, WithLogSQL(logsql))
+}
+
+// Open - open database by connection string like "user=edds dbname=edds sslmode=disable" or
+// "postgres://edds@localhost/edds" and create tables
+func Open(dsn string, options ...Option) (*Edb, error) {
+	e := new(Edb)
+	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return e, err
 	}
@@ -33,8 +79,11 @@ func InitDB(dbname string, user string, password string, sslmode string, logsql
 	if err != nil {
 		return e, err
 	}
-	e.db = db
-	e.log = logsql
+	e.db = &instrumentedDB{DB: db}
+	e.dsn = dsn
+	for _, option := range options {
+		option(e)
+	}
 	err = e.createAllTables()
 	return e, err
 }
@@ -92,5 +141,37 @@ func (e *Edb) createAllTables() error {
 	if err != nil {
 		return err
 	}
+	err = e.addressCreateTable()
+	if err != nil {
+		return err
+	}
+	err = e.geoCreateTable()
+	if err != nil {
+		return err
+	}
+	err = e.notificationCreateTable()
+	if err != nil {
+		return err
+	}
+	err = e.dispatchCreateTable()
+	if err != nil {
+		return err
+	}
+	err = e.incidentTypeCreateTable()
+	if err != nil {
+		return err
+	}
+	err = e.incidentCreateTable()
+	if err != nil {
+		return err
+	}
+	err = e.rosterCreateTable()
+	if err != nil {
+		return err
+	}
+	err = e.notifyCreateTriggers()
+	if err != nil {
+		return err
+	}
 	return nil
 }
@@ -0,0 +1,51 @@
+//go:build otel
+
+// Package epgcotel - epgc.Tracer on OpenTelemetry, built with tag "otel" and go.opentelemetry.io/otel in GOPATH or vendor.
+//
+//	e, err := epgc.Open(dsn, epgc.WithTracer(epgcotel.NewTracer(otel.GetTracerProvider())))
+package epgcotel
+
+import (
+	"context"
+
+	"github.com/serbe/epgc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName - name of tracer in provider
+const instrumentationName = "github.com/serbe/epgc"
+
+// Tracer - starts client spans of queries with attributes from epgc, like db.operation and epgc.entity
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+type span struct {
+	span trace.Span
+}
+
+// NewTracer - tracer of provider, like otel.GetTracerProvider()
+func NewTracer(provider trace.TracerProvider) *Tracer {
+	return &Tracer{tracer: provider.Tracer(instrumentationName)}
+}
+
+// StartSpan - start span of query, queries of epgc have no context, so span is root of its own trace
+func (t *Tracer) StartSpan(name string, attrs map[string]string) epgc.Span {
+	kv := make([]attribute.KeyValue, 0, len(attrs))
+	for key, value := range attrs {
+		kv = append(kv, attribute.String(key, value))
+	}
+	_, s := t.tracer.Start(context.Background(), name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(kv...))
+	return span{span: s}
+}
+
+// End - record error and end span
+func (s span) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
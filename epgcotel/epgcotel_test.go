@@ -0,0 +1,35 @@
+//go:build otel
+
+package epgcotel
+
+import (
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracer := NewTracer(provider)
+	tracer.StartSpan("epgc.GetCompany", map[string]string{"epgc.entity": "companies", "epgc.id": "1"}).End(nil)
+	tracer.StartSpan("epgc.UpdateCompany", nil).End(errors.New("failed"))
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("ended %d spans, want 2", len(spans))
+	}
+	attrs := make(map[attribute.Key]string)
+	for _, kv := range spans[0].Attributes() {
+		attrs[kv.Key] = kv.Value.AsString()
+	}
+	if spans[0].Name() != "epgc.GetCompany" || attrs["epgc.entity"] != "companies" || attrs["epgc.id"] != "1" {
+		t.Errorf("span = %s %v", spans[0].Name(), attrs)
+	}
+	if spans[0].Status().Code != codes.Unset || spans[1].Status().Code != codes.Error || len(spans[1].Events()) != 1 {
+		t.Errorf("statuses = %v and %v", spans[0].Status(), spans[1].Status())
+	}
+}
@@ -0,0 +1,77 @@
+//go:build prometheus
+
+// Package epgcprom - prometheus.Collector for epgc.Metrics, built with tag "prometheus"
+// and github.com/prometheus/client_golang in GOPATH or vendor.
+//
+//	metrics := epgc.NewMetrics()
+//	e, err := epgc.Open(dsn, epgc.WithMetrics(metrics))
+//	...
+//	prometheus.MustRegister(epgcprom.NewCollector(metrics))
+//	http.Handle("/metrics", promhttp.Handler())
+package epgcprom
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/serbe/epgc"
+)
+
+var queryLabels = []string{"method", "entity", "operation"}
+
+type poolMetric struct {
+	desc  *prometheus.Desc
+	kind  prometheus.ValueType
+	value func(s sql.DBStats) float64
+}
+
+// Collector - query durations, errors and connection pool of Edb as prometheus metrics
+type Collector struct {
+	metrics  *epgc.Metrics
+	duration *prometheus.Desc
+	errors   *prometheus.Desc
+	pool     []poolMetric
+}
+
+// NewCollector - collector of metrics, metrics must be passed to epgc.WithMetrics
+func NewCollector(metrics *epgc.Metrics) *Collector {
+	return &Collector{
+		metrics:  metrics,
+		duration: prometheus.NewDesc("epgc_query_duration_seconds", "Duration of epgc queries.", queryLabels, nil),
+		errors:   prometheus.NewDesc("epgc_query_errors_total", "Errors of epgc queries.", queryLabels, nil),
+		pool: []poolMetric{
+			{prometheus.NewDesc("epgc_pool_max_open_connections", "Maximum number of open connections.", nil, nil), prometheus.GaugeValue, func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }},
+			{prometheus.NewDesc("epgc_pool_open_connections", "Number of open connections.", nil, nil), prometheus.GaugeValue, func(s sql.DBStats) float64 { return float64(s.OpenConnections) }},
+			{prometheus.NewDesc("epgc_pool_in_use_connections", "Number of connections in use.", nil, nil), prometheus.GaugeValue, func(s sql.DBStats) float64 { return float64(s.InUse) }},
+			{prometheus.NewDesc("epgc_pool_idle_connections", "Number of idle connections.", nil, nil), prometheus.GaugeValue, func(s sql.DBStats) float64 { return float64(s.Idle) }},
+			{prometheus.NewDesc("epgc_pool_wait_count_total", "Number of waits for connection.", nil, nil), prometheus.CounterValue, func(s sql.DBStats) float64 { return float64(s.WaitCount) }},
+			{prometheus.NewDesc("epgc_pool_wait_duration_seconds_total", "Time blocked waiting for connection.", nil, nil), prometheus.CounterValue, func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }},
+		},
+	}
+}
+
+// Describe - send descriptions of all metrics
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.duration
+	ch <- c.errors
+	for _, p := range c.pool {
+		ch <- p.desc
+	}
+}
+
+// Collect - send current values of metrics
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, stat := range c.metrics.Stats() {
+		ch <- prometheus.MustNewConstHistogram(c.duration, stat.Count, stat.Sum, stat.Buckets, stat.Method, stat.Entity, stat.Operation)
+		if stat.Errors > 0 {
+			ch <- prometheus.MustNewConstMetric(c.errors, prometheus.CounterValue, float64(stat.Errors), stat.Method, stat.Entity, stat.Operation)
+		}
+	}
+	s, ok := c.metrics.PoolStats()
+	if !ok {
+		return
+	}
+	for _, p := range c.pool {
+		ch <- prometheus.MustNewConstMetric(p.desc, p.kind, p.value(s))
+	}
+}
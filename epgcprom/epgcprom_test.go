@@ -0,0 +1,48 @@
+//go:build prometheus
+
+package epgcprom
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/serbe/epgc"
+)
+
+func TestCollector(t *testing.T) {
+	metrics := epgc.NewMetrics(0.01, 0.1)
+	metrics.ObserveQuery("GetCompany", "companies", "select", 5*time.Millisecond, nil)
+	metrics.ObserveQuery("GetCompany", "companies", "select", 50*time.Millisecond, errors.New("failed"))
+	registry := prometheus.NewPedanticRegistry()
+	err := registry.Register(NewCollector(metrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[string]bool)
+	for _, family := range families {
+		found[family.GetName()] = true
+		switch family.GetName() {
+		case "epgc_query_duration_seconds":
+			h := family.GetMetric()[0].GetHistogram()
+			if h.GetSampleCount() != 2 || len(h.GetBucket()) != 2 || h.GetBucket()[0].GetCumulativeCount() != 1 || h.GetBucket()[1].GetCumulativeCount() != 2 {
+				t.Errorf("histogram = %v", h)
+			}
+		case "epgc_query_errors_total":
+			if v := family.GetMetric()[0].GetCounter().GetValue(); v != 1 {
+				t.Errorf("errors = %g, want 1", v)
+			}
+		}
+	}
+	if !found["epgc_query_duration_seconds"] || !found["epgc_query_errors_total"] {
+		t.Errorf("gathered families = %v", found)
+	}
+	if found["epgc_pool_open_connections"] {
+		t.Error("pool metrics without database")
+	}
+}
@@ -0,0 +1,191 @@
+package epgctest
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Mail - message received by SMTPServer
+type Mail struct {
+	From string
+	To   []string
+	Data string
+}
+
+// SMTPServer - local smtp server keeping received mails, for tests of epgc.SMTPChannel
+type SMTPServer struct {
+	Addr string
+
+	ln    net.Listener
+	mu    sync.Mutex
+	mails []Mail
+	fail  int
+}
+
+// NewSMTPServer - start smtp server on random local port, it is closed with test
+func NewSMTPServer(tb testing.TB) *SMTPServer {
+	tb.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal("epgctest: listen smtp: ", err)
+	}
+	s := &SMTPServer{Addr: ln.Addr().String(), ln: ln}
+	go s.serve()
+	tb.Cleanup(func() {
+		_ = ln.Close()
+	})
+	return s
+}
+
+// FailNext - reject next n mails with temporary error
+func (s *SMTPServer) FailNext(n int) {
+	s.mu.Lock()
+	s.fail = n
+	s.mu.Unlock()
+}
+
+// Mails - received mails
+func (s *SMTPServer) Mails() []Mail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Mail(nil), s.mails...)
+}
+
+func (s *SMTPServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *SMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		_, _ = conn.Write([]byte(line + "\r\n"))
+	}
+	reply("220 localhost ESMTP epgctest")
+	var mail Mail
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			mail = Mail{From: strings.Trim(line[len("MAIL FROM:"):], " <>")}
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			mail.To = append(mail.To, strings.Trim(line[len("RCPT TO:"):], " <>"))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err = r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" || line == ".\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			mail.Data = data.String()
+			s.mu.Lock()
+			fail := s.fail > 0
+			if fail {
+				s.fail--
+			} else {
+				s.mails = append(s.mails, mail)
+			}
+			s.mu.Unlock()
+			if fail {
+				reply("451 temporary failure")
+			} else {
+				reply("250 OK")
+			}
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		case cmd == "RSET", cmd == "NOOP":
+			reply("250 OK")
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+// SMS - message received by SMSServer
+type SMS struct {
+	To     string `json:"to"`
+	Text   string `json:"text"`
+	Sender string `json:"sender"`
+	Token  string `json:"-"`
+}
+
+// SMSServer - local http sms gateway keeping received messages, for tests of epgc.HTTPSMSChannel
+type SMSServer struct {
+	URL string
+
+	mu       sync.Mutex
+	messages []SMS
+	fail     int
+}
+
+// NewSMSServer - start sms gateway on random local port, it is closed with test
+func NewSMSServer(tb testing.TB) *SMSServer {
+	tb.Helper()
+	s := &SMSServer{}
+	server := httptest.NewServer(http.HandlerFunc(s.handle))
+	s.URL = server.URL
+	tb.Cleanup(server.Close)
+	return s
+}
+
+// FailNext - answer next n messages with 503 status
+func (s *SMSServer) FailNext(n int) {
+	s.mu.Lock()
+	s.fail = n
+	s.mu.Unlock()
+}
+
+// Messages - received messages
+func (s *SMSServer) Messages() []SMS {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SMS(nil), s.messages...)
+}
+
+func (s *SMSServer) handle(w http.ResponseWriter, r *http.Request) {
+	var sms SMS
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&sms) != nil || sms.To == "" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	sms.Token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail > 0 {
+		s.fail--
+		http.Error(w, "gateway is busy", http.StatusServiceUnavailable)
+		return
+	}
+	s.messages = append(s.messages, sms)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"id": "sms-" + strconv.Itoa(len(s.messages))})
+}
@@ -0,0 +1,193 @@
+// Package epgctest - disposable PostgreSQL and fixtures for tests of epgc and programs using it.
+//
+// Database is taken from EPGC_TEST_DSN or started by initdb and pg_ctl in temporary directory,
+// tests are skipped when both are unavailable. Every Open gets its own schema, so tests do not
+// see data of each other and may run in parallel.
+//
+//	func TestMain(m *testing.M) {
+//		epgctest.Main(m)
+//	}
+//
+//	func TestSomething(t *testing.T) {
+//		e := epgctest.Open(t)
+//		f := epgctest.NewFixtures(t, e)
+//		company := f.Company("ООО Ромашка")
+//		...
+//	}
+package epgctest
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/serbe/epgc"
+)
+
+// EnvDSN - environment variable with connection string of test database
+const EnvDSN = "EPGC_TEST_DSN"
+
+var server struct {
+	sync.Mutex
+	started bool
+	dsn     string
+	dir     string
+	pgCtl   string
+	err     error
+}
+
+// Main - run tests and stop server started by Open, use it in TestMain
+func Main(m *testing.M) {
+	code := m.Run()
+	Stop()
+	os.Exit(code)
+}
+
+// Stop - stop server started by Open and remove its directory
+func Stop() {
+	server.Lock()
+	defer server.Unlock()
+	if server.dir == "" {
+		return
+	}
+	_ = exec.Command(server.pgCtl, "-D", filepath.Join(server.dir, "data"), "-m", "immediate", "-w", "stop").Run()
+	_ = os.RemoveAll(server.dir)
+	server.dir = ""
+}
+
+// findBinary - postgresql binary from PATH or usual install directories
+func findBinary(name string) (string, error) {
+	path, err := exec.LookPath(name)
+	if err == nil {
+		return path, nil
+	}
+	for _, pattern := range []string{"/usr/lib/postgresql/*/bin/", "/usr/pgsql-*/bin/", "/usr/local/pgsql/bin/", "/opt/homebrew/bin/"} {
+		matches, _ := filepath.Glob(pattern + name)
+		if len(matches) > 0 {
+			return matches[len(matches)-1], nil
+		}
+	}
+	return "", fmt.Errorf("%s not found", name)
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// start - initdb and start server listening only unix socket in temporary directory
+func start() (string, error) {
+	initdb, err := findBinary("initdb")
+	if err != nil {
+		return "", err
+	}
+	server.pgCtl, err = findBinary("pg_ctl")
+	if err != nil {
+		return "", err
+	}
+	port, err := freePort()
+	if err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp("", "epgctest")
+	if err != nil {
+		return "", err
+	}
+	data := filepath.Join(dir, "data")
+	out, err := exec.Command(initdb, "-D", data, "-U", "postgres", "-A", "trust", "-E", "UTF8", "-N").CombinedOutput()
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return "", fmt.Errorf("initdb: %v: %s", err, out)
+	}
+	options := fmt.Sprintf("-p %d -k %s -c listen_addresses='' -F", port, dir)
+	out, err = exec.Command(server.pgCtl, "-D", data, "-l", filepath.Join(dir, "server.log"), "-o", options, "-w", "start").CombinedOutput()
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return "", fmt.Errorf("pg_ctl start: %v: %s", err, out)
+	}
+	server.dir = dir
+	return fmt.Sprintf("host=%s port=%d user=postgres dbname=postgres sslmode=disable", dir, port), nil
+}
+
+// DSN - connection string of test database, test is skipped when there is no database
+func DSN(tb testing.TB) string {
+	tb.Helper()
+	server.Lock()
+	defer server.Unlock()
+	if !server.started {
+		server.started = true
+		server.dsn = os.Getenv(EnvDSN)
+		if server.dsn == "" {
+			server.dsn, server.err = start()
+		}
+	}
+	if server.err != nil {
+		tb.Skipf("no test database: set %s or install postgresql: %v", EnvDSN, server.err)
+	}
+	return server.dsn
+}
+
+// withSearchPath - add search_path to key=value or url connection string
+func withSearchPath(dsn, schema string) string {
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err == nil {
+			q := u.Query()
+			q.Set("search_path", schema)
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+	}
+	return dsn + " search_path=" + schema
+}
+
+// SchemaDSN - connection string to new empty schema, schema is dropped after test
+func SchemaDSN(tb testing.TB) string {
+	tb.Helper()
+	dsn := DSN(tb)
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	buf := make([]byte, 6)
+	_, _ = rand.Read(buf)
+	schema := "epgctest_" + hex.EncodeToString(buf)
+	_, err = db.Exec(`CREATE SCHEMA ` + schema)
+	if err != nil {
+		_ = db.Close()
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		_, _ = db.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+		_ = db.Close()
+	})
+	return withSearchPath(dsn, schema)
+}
+
+// Open - Edb with all tables in new empty schema, it is closed and schema is dropped after test
+func Open(tb testing.TB, options ...epgc.Option) *epgc.Edb {
+	tb.Helper()
+	e, err := epgc.Open(SchemaDSN(tb), options...)
+	if e != nil {
+		tb.Cleanup(func() {
+			_ = e.Close()
+		})
+	}
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return e
+}
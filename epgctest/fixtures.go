@@ -0,0 +1,148 @@
+package epgctest
+
+import (
+	"testing"
+
+	"github.com/serbe/epgc"
+)
+
+// Fixtures - creators of entities which fail test on error
+type Fixtures struct {
+	tb testing.TB
+	e  *epgc.Edb
+}
+
+// NewFixtures - fixtures on e
+func NewFixtures(tb testing.TB, e *epgc.Edb) *Fixtures {
+	return &Fixtures{tb: tb, e: e}
+}
+
+func (f *Fixtures) check(err error) {
+	f.tb.Helper()
+	if err != nil {
+		f.tb.Fatal(err)
+	}
+}
+
+// Scope - create scope
+func (f *Fixtures) Scope(name string) epgc.Scope {
+	f.tb.Helper()
+	scope := epgc.Scope{Name: name}
+	var err error
+	scope.ID, err = f.e.CreateScope(scope)
+	f.check(err)
+	return scope
+}
+
+// Post - create post, go is post in civil defence
+func (f *Fixtures) Post(name string, goPost bool) epgc.Post {
+	f.tb.Helper()
+	post := epgc.Post{Name: name, GO: goPost}
+	var err error
+	post.ID, err = f.e.CreatePost(post)
+	f.check(err)
+	return post
+}
+
+// Rank - create rank
+func (f *Fixtures) Rank(name string) epgc.Rank {
+	f.tb.Helper()
+	rank := epgc.Rank{Name: name}
+	var err error
+	rank.ID, err = f.e.CreateRank(rank)
+	f.check(err)
+	return rank
+}
+
+// Kind - create kind of practice
+func (f *Fixtures) Kind(name string) epgc.Kind {
+	f.tb.Helper()
+	kind := epgc.Kind{Name: name}
+	var err error
+	kind.ID, err = f.e.CreateKind(kind)
+	f.check(err)
+	return kind
+}
+
+// Department - create department
+func (f *Fixtures) Department(name string) epgc.Department {
+	f.tb.Helper()
+	department := epgc.Department{Name: name}
+	var err error
+	department.ID, err = f.e.CreateDepartment(department)
+	f.check(err)
+	return department
+}
+
+// CompanyDepartment - create department of company, parentID is 0 for top level department
+func (f *Fixtures) CompanyDepartment(name string, companyID, parentID int64) epgc.Department {
+	f.tb.Helper()
+	department := epgc.Department{Name: name, CompanyID: companyID, ParentID: parentID}
+	var err error
+	department.ID, err = f.e.CreateDepartment(department)
+	f.check(err)
+	return department
+}
+
+// IncidentType - create type of incident
+func (f *Fixtures) IncidentType(name string) epgc.IncidentType {
+	f.tb.Helper()
+	incidentType := epgc.IncidentType{Name: name}
+	var err error
+	incidentType.ID, err = f.e.CreateIncidentType(incidentType)
+	f.check(err)
+	return incidentType
+}
+
+// SirenType - create siren type
+func (f *Fixtures) SirenType(name string, radius int64) epgc.SirenType {
+	f.tb.Helper()
+	sirenType := epgc.SirenType{Name: name, Radius: radius}
+	var err error
+	sirenType.ID, err = f.e.CreateSirenType(sirenType)
+	f.check(err)
+	return sirenType
+}
+
+// Company - create company with optional scope
+func (f *Fixtures) Company(name string, scopeID ...int64) epgc.Company {
+	f.tb.Helper()
+	company := epgc.Company{Name: name, Address: "ул. Тестовая, 1"}
+	if len(scopeID) > 0 {
+		company.ScopeID = scopeID[0]
+	}
+	var err error
+	company.ID, err = f.e.CreateCompany(company)
+	f.check(err)
+	return company
+}
+
+// Contact - create contact in company, companyID may be 0
+func (f *Fixtures) Contact(name string, companyID int64) epgc.Contact {
+	f.tb.Helper()
+	contact := epgc.Contact{Name: name, CompanyID: companyID}
+	var err error
+	contact.ID, err = f.e.CreateContact(contact)
+	f.check(err)
+	return contact
+}
+
+// Practice - create practice of company at date like "02.01.2006"
+func (f *Fixtures) Practice(companyID, kindID int64, date string) epgc.Practice {
+	f.tb.Helper()
+	practice := epgc.Practice{CompanyID: companyID, KindID: kindID, Topic: "Тренировка", DateOfPractice: date}
+	var err error
+	practice.ID, err = f.e.CreatePractice(practice)
+	f.check(err)
+	return practice
+}
+
+// Siren - create siren of type in company
+func (f *Fixtures) Siren(numID, typeID, companyID int64) epgc.Siren {
+	f.tb.Helper()
+	siren := epgc.Siren{NumID: numID, TypeID: typeID, CompanyID: companyID, Address: "ул. Тестовая, 1"}
+	var err error
+	siren.ID, err = f.e.CreateSiren(siren)
+	f.check(err)
+	return siren
+}
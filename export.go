@@ -0,0 +1,349 @@
+package epgc
+
+import (
+	"bytes"
+	"encoding/csv"
+	"html/template"
+	"io"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Directory columns
+const (
+	ColumnScope      = "scope"
+	ColumnCompany    = "company"
+	ColumnAddress    = "address"
+	ColumnContact    = "contact"
+	ColumnDepartment = "department"
+	ColumnPost       = "post"
+	ColumnPostGO     = "post_go"
+	ColumnPhones     = "phones"
+	ColumnFaxes      = "faxes"
+	ColumnEmails     = "emails"
+)
+
+// Directory groupings
+const (
+	GroupByScope   = "scope"
+	GroupByCompany = "company"
+)
+
+var columnTitles = map[string]string{
+	ColumnScope:      "Сфера деятельности",
+	ColumnCompany:    "Организация",
+	ColumnAddress:    "Адрес",
+	ColumnContact:    "ФИО",
+	ColumnDepartment: "Отдел",
+	ColumnPost:       "Должность",
+	ColumnPostGO:     "Должность ГО",
+	ColumnPhones:     "Телефоны",
+	ColumnFaxes:      "Факсы",
+	ColumnEmails:     "Эл. почта",
+}
+
+// DefaultDirectoryColumns - columns used when DirectoryOptions.Columns is empty
+var DefaultDirectoryColumns = []string{ColumnScope, ColumnCompany, ColumnContact, ColumnPost, ColumnPhones, ColumnFaxes}
+
+// DirectoryOptions - options for phone directory export
+type DirectoryOptions struct {
+	GroupBy    string
+	Columns    []string
+	Title      string
+	PageRows   int
+	PDFCommand []string
+}
+
+// DirectoryCompany - company with contacts in phone directory
+type DirectoryCompany struct {
+	Company  CompanyList      `json:"company"`
+	Contacts []ContactCompany `json:"contacts"`
+}
+
+// DirectoryGroup - group of companies in phone directory
+type DirectoryGroup struct {
+	Name      string             `json:"name"`
+	Companies []DirectoryCompany `json:"companies"`
+}
+
+type directoryLine struct {
+	Group   string
+	Company string
+	Values  []string
+}
+
+type directoryPage struct {
+	Number int
+	Lines  []directoryLine
+}
+
+func (opt DirectoryOptions) columns() []string {
+	if len(opt.Columns) == 0 {
+		return DefaultDirectoryColumns
+	}
+	return opt.Columns
+}
+
+func (opt DirectoryOptions) title() string {
+	if opt.Title == "" {
+		return "Телефонный справочник"
+	}
+	return opt.Title
+}
+
+func joinNotEmpty(list []string) string {
+	var result []string
+	for _, s := range list {
+		if s != "" {
+			result = append(result, s)
+		}
+	}
+	return strings.Join(result, ", ")
+}
+
+func directoryCompanyValue(company CompanyList, column string) string {
+	switch column {
+	case ColumnScope:
+		return company.ScopeName
+	case ColumnCompany:
+		return company.Name
+	case ColumnAddress:
+		return company.Address
+	case ColumnPhones:
+		return joinNotEmpty(company.Phones)
+	case ColumnFaxes:
+		return joinNotEmpty(company.Faxes)
+	case ColumnEmails:
+		return joinNotEmpty(company.Emails)
+	}
+	return ""
+}
+
+func directoryContactValue(company CompanyList, contact ContactCompany, column string) string {
+	switch column {
+	case ColumnScope, ColumnCompany, ColumnAddress:
+		return directoryCompanyValue(company, column)
+	case ColumnContact:
+		return contact.Name
+	case ColumnDepartment:
+		return contact.DepartmentName
+	case ColumnPost:
+		return contact.PostName
+	case ColumnPostGO:
+		return contact.PostGOName
+	case ColumnPhones:
+		return joinNotEmpty(contact.Phones)
+	case ColumnFaxes:
+		return joinNotEmpty(contact.Faxes)
+	case ColumnEmails:
+		return joinNotEmpty(contact.Emails)
+	}
+	return ""
+}
+
+// GetDirectory - get companies with contacts grouped by scope or in one group
+func (e *Edb) GetDirectory(opt DirectoryOptions) ([]DirectoryGroup, error) {
+	var groups []DirectoryGroup
+	companies, err := e.GetCompanyList()
+	if err != nil {
+		log.Println("GetDirectory GetCompanyList ", err)
+		return groups, err
+	}
+	index := make(map[string]int)
+	for _, company := range companies {
+		contacts, err := e.GetContactCompany(company.ID)
+		if err != nil {
+			log.Println("GetDirectory GetContactCompany ", err)
+			return groups, err
+		}
+		name := ""
+		if opt.GroupBy != GroupByCompany {
+			name = company.ScopeName
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(groups)
+			index[name] = i
+			groups = append(groups, DirectoryGroup{Name: name})
+		}
+		groups[i].Companies = append(groups[i].Companies, DirectoryCompany{Company: company, Contacts: contacts})
+	}
+	return groups, nil
+}
+
+// directoryRecords - flat rows of directory, one row for company and one for every contact
+func directoryRecords(groups []DirectoryGroup, columns []string) [][]string {
+	var (
+		header  []string
+		records [][]string
+	)
+	for _, column := range columns {
+		header = append(header, columnTitles[column])
+	}
+	records = append(records, header)
+	for _, group := range groups {
+		for _, company := range group.Companies {
+			var record []string
+			for _, column := range columns {
+				record = append(record, directoryCompanyValue(company.Company, column))
+			}
+			records = append(records, record)
+			for _, contact := range company.Contacts {
+				record = nil
+				for _, column := range columns {
+					record = append(record, directoryContactValue(company.Company, contact, column))
+				}
+				records = append(records, record)
+			}
+		}
+	}
+	return records
+}
+
+// ExportDirectoryCSV - write phone directory as csv
+func (e *Edb) ExportDirectoryCSV(w io.Writer, opt DirectoryOptions) error {
+	groups, err := e.GetDirectory(opt)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	err = cw.WriteAll(directoryRecords(groups, opt.columns()))
+	if err != nil {
+		log.Println("ExportDirectoryCSV cw.WriteAll ", err)
+	}
+	return err
+}
+
+// ExportDirectoryXLSX - write phone directory as xlsx
+func (e *Edb) ExportDirectoryXLSX(w io.Writer, opt DirectoryOptions) error {
+	groups, err := e.GetDirectory(opt)
+	if err != nil {
+		return err
+	}
+	err = writeXLSX(w, opt.title(), directoryRecords(groups, opt.columns()))
+	if err != nil {
+		log.Println("ExportDirectoryXLSX writeXLSX ", err)
+	}
+	return err
+}
+
+var directoryTemplate = template.Must(template.New("directory").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: "DejaVu Sans", Arial, sans-serif; font-size: 10pt; }
+table { width: 100%; border-collapse: collapse; }
+th, td { border: 1px solid #000; padding: 2px 4px; vertical-align: top; }
+.group td { font-weight: bold; font-size: 12pt; background: #ddd; }
+.company td { font-weight: bold; }
+.page { page-break-after: always; }
+.page:last-child { page-break-after: auto; }
+.footer { text-align: right; font-size: 8pt; margin-top: 4px; }
+</style>
+</head>
+<body>
+{{range .Pages}}<div class="page">
+<h1>{{$.Title}}</h1>
+<table>
+<tr>{{range $.Header}}<th>{{.}}</th>{{end}}</tr>
+{{range .Lines}}{{if .Group}}<tr class="group"><td colspan="{{len $.Header}}">{{.Group}}</td></tr>
+{{else if .Company}}<tr class="company">{{range .Values}}<td>{{.}}</td>{{end}}</tr>
+{{else}}<tr>{{range .Values}}<td>{{.}}</td>{{end}}</tr>
+{{end}}{{end}}</table>
+<div class="footer">{{$.Date}}, страница {{.Number}} из {{len $.Pages}}</div>
+</div>
+{{end}}</body>
+</html>
+`))
+
+// ExportDirectoryHTML - write phone directory as paginated printable html
+func (e *Edb) ExportDirectoryHTML(w io.Writer, opt DirectoryOptions) error {
+	groups, err := e.GetDirectory(opt)
+	if err != nil {
+		return err
+	}
+	var (
+		header []string
+		lines  []directoryLine
+		pages  []directoryPage
+	)
+	columns := opt.columns()
+	for _, column := range columns {
+		header = append(header, columnTitles[column])
+	}
+	for _, group := range groups {
+		if group.Name != "" {
+			lines = append(lines, directoryLine{Group: group.Name})
+		}
+		for _, company := range group.Companies {
+			line := directoryLine{Company: company.Company.Name}
+			for _, column := range columns {
+				line.Values = append(line.Values, directoryCompanyValue(company.Company, column))
+			}
+			lines = append(lines, line)
+			for _, contact := range company.Contacts {
+				line = directoryLine{}
+				for _, column := range columns {
+					line.Values = append(line.Values, directoryContactValue(company.Company, contact, column))
+				}
+				lines = append(lines, line)
+			}
+		}
+	}
+	pageRows := opt.PageRows
+	if pageRows <= 0 {
+		pageRows = 40
+	}
+	for i := 0; i < len(lines); i += pageRows {
+		end := i + pageRows
+		if end > len(lines) {
+			end = len(lines)
+		}
+		pages = append(pages, directoryPage{Number: len(pages) + 1, Lines: lines[i:end]})
+	}
+	err = directoryTemplate.Execute(w, struct {
+		Title  string
+		Date   string
+		Header []string
+		Pages  []directoryPage
+	}{
+		Title:  opt.title(),
+		Date:   setStrMonth(time.Now().Format("02.01.2006")),
+		Header: header,
+		Pages:  pages,
+	})
+	if err != nil {
+		log.Println("ExportDirectoryHTML directoryTemplate.Execute ", err)
+	}
+	return err
+}
+
+// ExportDirectoryPDF - write phone directory as pdf, html is converted by external command
+// reading html from stdin and writing pdf to stdout, by default wkhtmltopdf
+func (e *Edb) ExportDirectoryPDF(w io.Writer, opt DirectoryOptions) error {
+	var (
+		html   bytes.Buffer
+		stderr bytes.Buffer
+	)
+	err := e.ExportDirectoryHTML(&html, opt)
+	if err != nil {
+		return err
+	}
+	command := opt.PDFCommand
+	if len(command) == 0 {
+		command = []string{"wkhtmltopdf", "--quiet", "--encoding", "utf-8", "-", "-"}
+	}
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = &html
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if err != nil {
+		log.Println("ExportDirectoryPDF cmd.Run ", err, stderr.String())
+	}
+	return err
+}
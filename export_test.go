@@ -0,0 +1,83 @@
+package epgc
+
+import (
+	"bytes"
+	"database/sql"
+	"testing"
+)
+
+func testDirectory() []DirectoryGroup {
+	return []DirectoryGroup{{
+		Name: "Образование",
+		Companies: []DirectoryCompany{{
+			Company: CompanyList{Name: "Школа №1", ScopeName: "Образование", Phones: []string{"123456", ""}, Emails: []string{"school@example.ru"}},
+			Contacts: []ContactCompany{{
+				Name:     "Иванов Иван Иванович",
+				PostName: "Директор",
+				Phones:   []string{"79001234567"},
+				Faxes:    []string{},
+				Emails:   []string{"ivanov@example.ru", "director@example.ru"},
+			}},
+		}},
+	}}
+}
+
+func TestDirectoryRecords(t *testing.T) {
+	records := directoryRecords(testDirectory(), []string{ColumnCompany, ColumnContact, ColumnPost, ColumnPhones, ColumnFaxes, ColumnEmails})
+	want := [][]string{
+		{"Организация", "ФИО", "Должность", "Телефоны", "Факсы", "Эл. почта"},
+		{"Школа №1", "", "", "123456", "", "school@example.ru"},
+		{"Школа №1", "Иванов Иван Иванович", "Директор", "79001234567", "", "ivanov@example.ru, director@example.ru"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("directoryRecords = %q", records)
+	}
+	for i := range want {
+		for j := range want[i] {
+			if records[i][j] != want[i][j] {
+				t.Errorf("directoryRecords[%d][%d] = %q, want %q", i, j, records[i][j], want[i][j])
+			}
+		}
+	}
+}
+
+func TestDirectoryXLSX(t *testing.T) {
+	records := directoryRecords(testDirectory(), DefaultDirectoryColumns)
+	var buf bytes.Buffer
+	if err := writeXLSX(&buf, "Телефонный справочник организаций города на 2026 год", records); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readXLSX(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[2][2] != "Иванов Иван Иванович" || got[1][0] != "Образование" {
+		t.Errorf("readXLSX of directory = %q", got)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("workbook")) {
+		t.Error("xlsx without workbook")
+	}
+}
+
+func TestXLSXSheetName(t *testing.T) {
+	for name, want := range map[string]string{
+		"":           "Sheet1",
+		"Справочник": "Справочник",
+		"Отчёт [2026]: итоги/месяц?":               "Отчёт 2026 итогимесяц",
+		"Телефонный справочник организаций города": "Телефонный справочник организац",
+	} {
+		got := xlsxSheetName(name)
+		if got != want || len([]rune(got)) > maxXLSXSheetName {
+			t.Errorf("xlsxSheetName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestN2AS(t *testing.T) {
+	if got := n2as(sql.NullString{}); got == nil || len(got) != 0 {
+		t.Errorf("n2as of null = %q", got)
+	}
+	if got := n2as(sql.NullString{String: "1,2", Valid: true}); len(got) != 2 || got[1] != "2" {
+		t.Errorf("n2as = %q", got)
+	}
+}
@@ -0,0 +1,558 @@
+package epgc
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Geocoding precisions, from best to worst
+const (
+	GeoHouse      = "house"
+	GeoStreet     = "street"
+	GeoSettlement = "settlement"
+)
+
+var geoPrecisionRank = map[string]int{
+	GeoHouse:      3,
+	GeoStreet:     2,
+	GeoSettlement: 1,
+}
+
+// DefaultGeoMapping - mapping of registry fields to csv headers used when GeoImportOptions.Mapping is empty,
+// full address in "address" column is parsed, separate columns take precedence over it
+var DefaultGeoMapping = map[string]string{
+	"address":     "address",
+	"postal_code": "postal_code",
+	"region":      "region",
+	"district":    "district",
+	"settlement":  "settlement",
+	"street":      "street",
+	"house":       "house",
+	"building":    "building",
+	"latitude":    "latitude",
+	"longitude":   "longitude",
+}
+
+// geoHeaderAliases - other usual names of coordinate columns in registry extracts
+var geoHeaderAliases = map[string][]string{
+	"latitude":  {"lat", "широта"},
+	"longitude": {"lon", "lng", "долгота"},
+}
+
+// GeoImportOptions - options for import of address registry
+type GeoImportOptions struct {
+	Mapping map[string]string
+	Comma   rune
+	// Source - name of registry, like "gar" or "osm", rows of same source are replaced with Replace
+	Source  string
+	Replace bool
+}
+
+// GeoImportReport - result of import of address registry
+type GeoImportReport struct {
+	Imported int      `json:"imported"`
+	Skipped  int      `json:"skipped"`
+	Errors   []string `json:"errors"`
+}
+
+// GeoResult - address found in registry, Precision is empty when address is not found
+type GeoResult struct {
+	Address   Address `json:"address"`
+	Precision string  `json:"precision"`
+}
+
+// GeocodeOptions - options of batch geocoding
+type GeocodeOptions struct {
+	// Overwrite - geocode addresses which already have coordinates
+	Overwrite bool
+	// MinPrecision - results with worse precision are reported as unresolved, GeoSettlement by default
+	MinPrecision string
+	// SirenPrecision - coordinates of siren are written only from results with this or better precision,
+	// GeoHouse by default
+	SirenPrecision string
+	DryRun         bool
+}
+
+// GeocodeItem - result of geocoding of address of company or siren
+type GeocodeItem struct {
+	Entity     string  `json:"entity"`
+	ID         int64   `json:"id"`
+	Address    string  `json:"address"`
+	Normalized string  `json:"normalized"`
+	Precision  string  `json:"precision"`
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+}
+
+// GeocodeReport - result of batch geocoding, number of resolved addresses by precision and list of unresolved ones
+type GeocodeReport struct {
+	DryRun     bool           `json:"dry_run"`
+	Resolved   map[string]int `json:"resolved"`
+	Unresolved []GeocodeItem  `json:"unresolved"`
+}
+
+// geoName - lower case name without types of address parts, "г. Волгоград" and "Волгоград" give "волгоград"
+func geoName(value string) string {
+	var words []string
+	for _, token := range addressTokens(strings.ToLower(strings.Replace(value, "ё", "е", -1))) {
+		key := addressKey(token)
+		if _, ok := addressWords[key]; ok || key == "д" {
+			continue
+		}
+		words = append(words, strings.Trim(token, ".,"))
+	}
+	return strings.Join(words, " ")
+}
+
+// geoKeys - match keys of address: district, settlement, street, house and building
+type geoKeys struct {
+	district, settlement, street, house, building string
+}
+
+func addressGeoKeys(a Address) geoKeys {
+	return geoKeys{
+		district:   geoName(a.District),
+		settlement: geoName(a.Settlement),
+		street:     geoName(a.Street),
+		house:      geoName(a.House),
+		building:   geoName(a.Building),
+	}
+}
+
+// geoRecord - address of registry from csv record, false when there are no coordinates
+func geoRecord(record []string, columns map[string]int) (Address, bool, error) {
+	value := func(field string) string {
+		if i, ok := columns[field]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	a := ParseAddress(value("address"))
+	for _, column := range []struct {
+		field string
+		kind  int
+		value *string
+	}{
+		{"region", addressRegion, &a.Region},
+		{"district", addressDistrict, &a.District},
+		{"settlement", addressSettlement, &a.Settlement},
+		{"street", addressStreet, &a.Street},
+		{"house", addressHouse, &a.House},
+		{"building", addressBuilding, &a.Building},
+	} {
+		if v := value(column.field); v != "" {
+			*column.value = geoPart(column.kind, v)
+		}
+	}
+	if v := value("postal_code"); v != "" {
+		a.PostalCode = v
+	}
+	lat, lon := value("latitude"), value("longitude")
+	if lat == "" || lon == "" {
+		return a, false, nil
+	}
+	var err error
+	a.Latitude, err = strconv.ParseFloat(strings.Replace(lat, ",", ".", 1), 64)
+	if err != nil {
+		return a, false, fmt.Errorf("latitude %q: %v", lat, err)
+	}
+	a.Longitude, err = strconv.ParseFloat(strings.Replace(lon, ",", ".", 1), 64)
+	if err != nil {
+		return a, false, fmt.Errorf("longitude %q: %v", lon, err)
+	}
+	return a, a.Settlement != "", nil
+}
+
+// geoPart - canonical value of separate column of registry, value without type like "Ленина" is kept as is
+func geoPart(kind int, value string) string {
+	var a Address
+	a.parsePart(value)
+	parsed := map[int]string{
+		addressRegion:     a.Region,
+		addressDistrict:   a.District,
+		addressSettlement: a.Settlement,
+		addressStreet:     a.Street,
+		addressHouse:      a.House,
+		addressBuilding:   a.Building,
+	}[kind]
+	if parsed == "" {
+		return value
+	}
+	return parsed
+}
+
+func geoColumns(header []string, mapping map[string]string) map[string]int {
+	if len(mapping) == 0 {
+		mapping = DefaultGeoMapping
+	}
+	columns := make(map[string]int)
+	for field, name := range mapping {
+		names := []string{name}
+		if len(mapping) == len(DefaultGeoMapping) && DefaultGeoMapping[field] == name {
+			names = append(names, geoHeaderAliases[field]...)
+		}
+		for i, h := range header {
+			for _, n := range names {
+				if importKey(h) == importKey(n) {
+					columns[field] = i
+				}
+			}
+		}
+	}
+	return columns
+}
+
+// ImportGeoRegistry - import addresses with coordinates from csv extract of registry (FIAS/GAR, OSM),
+// rows without coordinates or settlement are skipped
+func (e *Edb) ImportGeoRegistry(r io.Reader, opt GeoImportOptions) (GeoImportReport, error) {
+	var report GeoImportReport
+	cr := csv.NewReader(r)
+	if opt.Comma != 0 {
+		cr.Comma = opt.Comma
+	}
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err != nil {
+		log.Println("ImportGeoRegistry cr.Read ", err)
+		return report, err
+	}
+	columns := geoColumns(header, opt.Mapping)
+	_, hasAddress := columns["address"]
+	_, hasSettlement := columns["settlement"]
+	_, hasLatitude := columns["latitude"]
+	_, hasLongitude := columns["longitude"]
+	if !hasLatitude || !hasLongitude || (!hasAddress && !hasSettlement) {
+		return report, fmt.Errorf("ImportGeoRegistry: header has no coordinates or address columns")
+	}
+	tx, err := e.db.Begin()
+	if err != nil {
+		log.Println("ImportGeoRegistry e.db.Begin ", err)
+		return report, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if opt.Replace {
+		_, err = tx.Exec(`DELETE FROM geo_addresses WHERE $1 = '' OR source = $1`, opt.Source)
+		if err != nil {
+			log.Println("ImportGeoRegistry tx.Exec ", err)
+			return report, err
+		}
+	}
+	stmt, err := tx.Prepare(pq.CopyIn("geo_addresses",
+		"source", "postal_code", "region", "district", "settlement", "street", "house", "building", "latitude", "longitude",
+		"district_key", "settlement_key", "street_key", "house_key", "building_key"))
+	if err != nil {
+		log.Println("ImportGeoRegistry tx.Prepare ", err)
+		return report, err
+	}
+	line := 1
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			_ = stmt.Close()
+			log.Println("ImportGeoRegistry cr.Read ", err)
+			return report, err
+		}
+		a, ok, err := geoRecord(record, columns)
+		if err != nil && len(report.Errors) < 100 {
+			report.Errors = append(report.Errors, fmt.Sprintf("line %d: %v", line, err))
+		}
+		if !ok {
+			report.Skipped++
+			continue
+		}
+		keys := addressGeoKeys(a)
+		_, err = stmt.Exec(opt.Source, a.PostalCode, a.Region, a.District, a.Settlement, a.Street, a.House, a.Building, a.Latitude, a.Longitude,
+			keys.district, keys.settlement, keys.street, keys.house, keys.building)
+		if err != nil {
+			_ = stmt.Close()
+			log.Println("ImportGeoRegistry stmt.Exec ", err)
+			return report, err
+		}
+		report.Imported++
+	}
+	_, err = stmt.Exec()
+	if err != nil {
+		log.Println("ImportGeoRegistry stmt.Exec ", err)
+		return report, err
+	}
+	err = stmt.Close()
+	if err != nil {
+		log.Println("ImportGeoRegistry stmt.Close ", err)
+		return report, err
+	}
+	_, err = tx.Exec(`ANALYZE geo_addresses`)
+	if err != nil {
+		log.Println("ImportGeoRegistry tx.Exec ", err)
+		return report, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Println("ImportGeoRegistry tx.Commit ", err)
+	}
+	return report, err
+}
+
+// geoLookup - find registry address by keys, house and street levels use house and street keys,
+// rows of same district are preferred and coordinates of street and settlement are averaged
+func (e *Edb) geoLookup(precision string, keys geoKeys) (Address, bool, error) {
+	var (
+		conds []string
+		args  = []interface{}{keys.district, keys.building, precision == GeoHouse}
+	)
+	add := func(column, value string) {
+		args = append(args, value)
+		conds = append(conds, column+" = $"+strconv.Itoa(len(args)))
+	}
+	add("settlement_key", keys.settlement)
+	if precision == GeoHouse || precision == GeoStreet {
+		add("street_key", keys.street)
+	}
+	if precision == GeoHouse {
+		add("house_key", keys.house)
+	}
+	row := e.db.QueryRow(`
+		SELECT
+			postal_code,
+			region,
+			district,
+			settlement,
+			street,
+			house,
+			building,
+			CASE WHEN $3 THEN latitude ELSE avg_latitude END,
+			CASE WHEN $3 THEN longitude ELSE avg_longitude END
+		FROM (
+			SELECT
+				g.*,
+				avg(latitude) OVER w AS avg_latitude,
+				avg(longitude) OVER w AS avg_longitude,
+				row_number() OVER (ORDER BY (district_key = $1) DESC, (building_key = $2) DESC, id) AS n
+			FROM
+				geo_addresses AS g
+			WHERE
+				`+strings.Join(conds, " AND ")+`
+			WINDOW
+				w AS (PARTITION BY district_key)
+		) AS t
+		WHERE
+			n = 1
+	`, args...)
+	var (
+		sPostalCode sql.NullString
+		sRegion     sql.NullString
+		sDistrict   sql.NullString
+		sSettlement sql.NullString
+		sStreet     sql.NullString
+		sHouse      sql.NullString
+		sBuilding   sql.NullString
+		sLatitude   sql.NullFloat64
+		sLongitude  sql.NullFloat64
+		a           Address
+	)
+	err := row.Scan(&sPostalCode, &sRegion, &sDistrict, &sSettlement, &sStreet, &sHouse, &sBuilding, &sLatitude, &sLongitude)
+	if err == sql.ErrNoRows {
+		return a, false, nil
+	}
+	if err != nil {
+		log.Println("geoLookup row.Scan ", err)
+		return a, false, err
+	}
+	a.PostalCode = n2s(sPostalCode)
+	a.Region = n2s(sRegion)
+	a.District = n2s(sDistrict)
+	a.Settlement = n2s(sSettlement)
+	a.Street = n2s(sStreet)
+	a.House = n2s(sHouse)
+	a.Building = n2s(sBuilding)
+	a.Latitude = n2f(sLatitude)
+	a.Longitude = n2f(sLongitude)
+	return a, true, nil
+}
+
+// geocodeAddress - resolve parsed address with best available precision
+func (e *Edb) geocodeAddress(a Address) (GeoResult, error) {
+	result := GeoResult{Address: a}
+	keys := addressGeoKeys(a)
+	if keys.settlement == "" {
+		return result, nil
+	}
+	for _, precision := range []string{GeoHouse, GeoStreet, GeoSettlement} {
+		if (precision == GeoHouse && keys.house == "") || (precision != GeoSettlement && keys.street == "") {
+			continue
+		}
+		found, ok, err := e.geoLookup(precision, keys)
+		if err != nil {
+			return result, err
+		}
+		if !ok {
+			continue
+		}
+		result.Precision = precision
+		result.Address.Region = found.Region
+		result.Address.District = found.District
+		result.Address.Settlement = found.Settlement
+		if precision != GeoSettlement {
+			result.Address.Street = found.Street
+		}
+		if precision == GeoHouse {
+			result.Address.House = found.House
+			if found.Building != "" {
+				result.Address.Building = found.Building
+			}
+			if found.PostalCode != "" {
+				result.Address.PostalCode = found.PostalCode
+			}
+		}
+		result.Address.Latitude = found.Latitude
+		result.Address.Longitude = found.Longitude
+		return result, nil
+	}
+	return result, nil
+}
+
+// Geocode - find coordinates and normalized address of text address in imported registry
+func (e *Edb) Geocode(text string) (GeoResult, error) {
+	result, err := e.geocodeAddress(ParseAddress(text))
+	if err != nil {
+		log.Println("Geocode geocodeAddress ", err)
+	}
+	return result, err
+}
+
+// GeocodeAll - resolve addresses of companies and sirens without coordinates, structured address with precision
+// and coordinates of siren with SirenPrecision are saved unless DryRun
+func (e *Edb) GeocodeAll(opt GeocodeOptions) (GeocodeReport, error) {
+	report := GeocodeReport{DryRun: opt.DryRun, Resolved: make(map[string]int), Unresolved: []GeocodeItem{}}
+	minRank := geoPrecisionRank[opt.MinPrecision]
+	if minRank == 0 {
+		minRank = geoPrecisionRank[GeoSettlement]
+	}
+	sirenRank := geoPrecisionRank[opt.SirenPrecision]
+	if sirenRank == 0 {
+		sirenRank = geoPrecisionRank[GeoHouse]
+	}
+	for _, owner := range []struct {
+		entity string
+		table  string
+		column string
+	}{{"companies", "companies", "company_id"}, {"sirens", "sirens", "siren_id"}} {
+		rows, err := e.db.Query(`
+			SELECT
+				t.id,
+				t.address
+			FROM
+				`+owner.table+` AS t
+			LEFT JOIN
+				addresses AS a ON a.`+owner.column+` = t.id
+			WHERE
+				COALESCE(t.address, '') <> '' AND ($1 OR a.latitude IS NULL)
+			ORDER BY
+				t.id
+		`, opt.Overwrite)
+		if err != nil {
+			log.Println("GeocodeAll e.db.Query ", err)
+			return report, err
+		}
+		var items []GeocodeItem
+		for rows.Next() {
+			var item GeocodeItem
+			err = rows.Scan(&item.ID, &item.Address)
+			if err != nil {
+				rows.Close()
+				log.Println("GeocodeAll rows.Scan ", err)
+				return report, err
+			}
+			item.Entity = owner.entity
+			items = append(items, item)
+		}
+		rows.Close()
+		for _, item := range items {
+			result, err := e.geocodeAddress(ParseAddress(item.Address))
+			if err != nil {
+				return report, err
+			}
+			item.Normalized = result.Address.String()
+			item.Precision = result.Precision
+			item.Latitude = result.Address.Latitude
+			item.Longitude = result.Address.Longitude
+			if geoPrecisionRank[result.Precision] < minRank {
+				report.Unresolved = append(report.Unresolved, item)
+				continue
+			}
+			report.Resolved[result.Precision]++
+			if opt.DryRun {
+				continue
+			}
+			result.Address.Precision = result.Precision
+			err = e.saveAddress(owner.column, item.ID, result.Address, item.Address)
+			if err != nil {
+				return report, err
+			}
+			if owner.table == "sirens" && geoPrecisionRank[result.Precision] >= sirenRank {
+				_, err = e.db.Exec(`
+					UPDATE
+						sirens
+					SET
+						latitude = $2,
+						longitude = $3,
+						updated_at = now()
+					WHERE
+						id = $1 AND ($4 OR COALESCE(latitude, '') = '' OR COALESCE(longitude, '') = '')
+				`, item.ID,
+					strconv.FormatFloat(item.Latitude, 'f', 6, 64),
+					strconv.FormatFloat(item.Longitude, 'f', 6, 64),
+					opt.Overwrite)
+				if err != nil {
+					log.Println("GeocodeAll e.db.Exec ", err)
+					return report, err
+				}
+			}
+		}
+	}
+	return report, nil
+}
+
+func (e *Edb) geoCreateTable() error {
+	str := `
+		CREATE TABLE IF NOT EXISTS
+			geo_addresses (
+				id bigserial primary key,
+				source text,
+				postal_code text,
+				region text,
+				district text,
+				settlement text,
+				street text,
+				house text,
+				building text,
+				latitude double precision,
+				longitude double precision,
+				district_key text,
+				settlement_key text,
+				street_key text,
+				house_key text,
+				building_key text
+			);
+		CREATE INDEX IF NOT EXISTS geo_addresses_key_idx ON geo_addresses (settlement_key, street_key, house_key);
+	`
+	_, err := e.db.Exec(str)
+	if err != nil {
+		log.Println("geoCreateTable e.db.Exec ", err)
+	}
+	return err
+}
@@ -0,0 +1,41 @@
+package epgc
+
+import "testing"
+
+func TestGeoName(t *testing.T) {
+	tests := map[string]string{
+		"г. Волгоград":      "волгоград",
+		"Волгоград":         "волгоград",
+		"ул. Ленина":        "ленина",
+		"Ленина улица":      "ленина",
+		"д. 5":              "5",
+		"Городищенский р-н": "городищенский",
+		"пр-кт Победы":      "победы",
+		"ул. Королёва":      "королева",
+	}
+	for value, want := range tests {
+		if got := geoName(value); got != want {
+			t.Errorf("geoName(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestGeoRecord(t *testing.T) {
+	columns := geoColumns([]string{"Address", "Street", "lat", "lon"}, nil)
+	a, ok, err := geoRecord([]string{"400001, г. Волгоград, пр. Мира, д. 5к2", "Ленина", "48,7", "44.5"}, columns)
+	if err != nil || !ok {
+		t.Fatalf("geoRecord = %v, %v", ok, err)
+	}
+	want := Address{PostalCode: "400001", Settlement: "г. Волгоград", Street: "Ленина", House: "д. 5", Building: "корп. 2", Latitude: 48.7, Longitude: 44.5}
+	if a != want {
+		t.Errorf("geoRecord =\n%+v, want\n%+v", a, want)
+	}
+	_, ok, err = geoRecord([]string{"г. Волгоград, ул. Мира, д. 1", "", "", ""}, columns)
+	if ok || err != nil {
+		t.Errorf("geoRecord without coordinates = %v, %v", ok, err)
+	}
+	_, ok, err = geoRecord([]string{"г. Волгоград, ул. Мира, д. 1", "", "north", "44"}, columns)
+	if ok || err == nil {
+		t.Errorf("geoRecord with bad latitude = %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,621 @@
+package epgc
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Import actions
+const (
+	ImportInsert = "insert"
+	ImportUpdate = "update"
+	ImportReject = "reject"
+)
+
+// ImportMapping - map of import field to column header in file
+type ImportMapping map[string]string
+
+// DefaultImportMapping - mapping used when ImportOptions.Mapping is empty, headers match field names
+var DefaultImportMapping = ImportMapping{
+	"company":        "company",
+	"address":        "address",
+	"scope":          "scope",
+	"company_note":   "company_note",
+	"company_emails": "company_emails",
+	"company_phones": "company_phones",
+	"company_faxes":  "company_faxes",
+	"contact":        "contact",
+	"department":     "department",
+	"post":           "post",
+	"post_go":        "post_go",
+	"rank":           "rank",
+	"birthday":       "birthday",
+	"contact_note":   "contact_note",
+	"emails":         "emails",
+	"phones":         "phones",
+	"faxes":          "faxes",
+}
+
+// ImportOptions - options for import
+type ImportOptions struct {
+	Mapping       ImportMapping
+	CreateMissing bool
+	DryRun        bool
+	Comma         rune
+}
+
+// ImportRow - result of import of one entity from file row
+type ImportRow struct {
+	Line   int    `json:"line"`
+	Entity string `json:"entity"`
+	Name   string `json:"name"`
+	ID     int64  `json:"id"`
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+}
+
+// ImportReport - result of import
+type ImportReport struct {
+	DryRun   bool        `json:"dry_run"`
+	Inserted int         `json:"inserted"`
+	Updated  int         `json:"updated"`
+	Rejected int         `json:"rejected"`
+	Rows     []ImportRow `json:"rows"`
+}
+
+type importLookup struct {
+	name   string
+	ids    map[string]int64
+	create func(name string) (int64, error)
+}
+
+type importer struct {
+	e         *Edb
+	opt       ImportOptions
+	columns   map[string]int
+	report    ImportReport
+	lookups   map[string]*importLookup
+	companies map[string]int64
+	contacts  map[string]int64
+	// companyDepartments - ids of departments by name for company, company departments hide common ones
+	companyDepartments map[int64]map[string]int64
+	// planned - last placeholder id of lookup value created in dry run, placeholders are negative
+	planned int64
+}
+
+func importKey(val string) string {
+	return strings.ToLower(strings.Join(strings.Fields(val), " "))
+}
+
+func splitImportCell(val string, seps string) []string {
+	var result []string
+	for _, s := range strings.FieldsFunc(val, func(r rune) bool {
+		return strings.ContainsRune(seps, r)
+	}) {
+		s = strings.TrimSpace(s)
+		if s != "" {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// splitImportPhones - split cell like "8 (800) 123-45-67, 123456" into phones
+func splitImportPhones(val string) []Phone {
+	var phones []Phone
+	for _, s := range splitImportCell(val, ",;/\n") {
+		digits := strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, s)
+		i, err := strconv.ParseInt(digits, 10, 64)
+		if err == nil {
+			phones = append(phones, Phone{Phone: i})
+		}
+	}
+	return phones
+}
+
+func splitImportEmails(val string) []Email {
+	var emails []Email
+	for _, s := range splitImportCell(val, ",; \n") {
+		if strings.Contains(s, "@") {
+			emails = append(emails, Email{Email: strings.ToLower(s)})
+		}
+	}
+	return emails
+}
+
+// maxExcelSerial - excel serial date of 31.12.9999
+const maxExcelSerial = 2958465
+
+// parseImportDate - convert date from file to 02.01.2006, also accepts ISO dates and excel serial dates.
+// Numbers from 1000 to 2999 are years, not serial dates, and are rejected
+func parseImportDate(val string) (string, error) {
+	val = strings.TrimSpace(val)
+	if val == "" {
+		return "", nil
+	}
+	for _, layout := range []string{"02.01.2006", "2.1.2006", "2006-01-02"} {
+		t, err := time.Parse(layout, val)
+		if err == nil {
+			return t.Format("02.01.2006"), nil
+		}
+	}
+	serial, err := strconv.ParseFloat(val, 64)
+	if err == nil && serial > 0 && serial <= maxExcelSerial && (serial < 1000 || serial >= 3000) {
+		t := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(serial))
+		return t.Format("02.01.2006"), nil
+	}
+	return "", fmt.Errorf("wrong date %s", val)
+}
+
+func selectToLookup(name string, items []SelectItem, create func(string) (int64, error)) *importLookup {
+	l := &importLookup{name: name, ids: make(map[string]int64), create: create}
+	for _, item := range items {
+		l.ids[importKey(item.Name)] = item.ID
+	}
+	return l
+}
+
+func (e *Edb) importLookups() (map[string]*importLookup, error) {
+	lookups := make(map[string]*importLookup)
+	scopes, err := e.GetScopeSelect()
+	if err != nil {
+		return lookups, err
+	}
+	lookups["scope"] = selectToLookup("scope", scopes, func(name string) (int64, error) {
+		return e.CreateScope(Scope{Name: name})
+	})
+	departments, err := e.GetDepartmentSelect()
+	if err != nil {
+		return lookups, err
+	}
+	lookups["department"] = selectToLookup("department", departments, func(name string) (int64, error) {
+		return e.CreateDepartment(Department{Name: name})
+	})
+	posts, err := e.GetPostSelect(false)
+	if err != nil {
+		return lookups, err
+	}
+	lookups["post"] = selectToLookup("post", posts, func(name string) (int64, error) {
+		return e.CreatePost(Post{Name: name, GO: false})
+	})
+	postsGO, err := e.GetPostSelect(true)
+	if err != nil {
+		return lookups, err
+	}
+	lookups["post_go"] = selectToLookup("post_go", postsGO, func(name string) (int64, error) {
+		return e.CreatePost(Post{Name: name, GO: true})
+	})
+	ranks, err := e.GetRankSelect()
+	if err != nil {
+		return lookups, err
+	}
+	lookups["rank"] = selectToLookup("rank", ranks, func(name string) (int64, error) {
+		return e.CreateRank(Rank{Name: name})
+	})
+	return lookups, nil
+}
+
+func (im *importer) value(record []string, field string) string {
+	col, ok := im.columns[field]
+	if !ok || col >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[col])
+}
+
+func (im *importer) add(row ImportRow) {
+	switch row.Action {
+	case ImportInsert:
+		im.report.Inserted++
+	case ImportUpdate:
+		im.report.Updated++
+	case ImportReject:
+		im.report.Rejected++
+	}
+	im.report.Rows = append(im.report.Rows, row)
+}
+
+// resolve - get id of lookup by name, missing values created only with CreateMissing option
+func (im *importer) resolve(line int, field string, name string) (int64, error) {
+	if name == "" {
+		return 0, nil
+	}
+	l := im.lookups[field]
+	key := importKey(name)
+	if id, ok := l.ids[key]; ok {
+		return id, nil
+	}
+	if !im.opt.CreateMissing {
+		return 0, fmt.Errorf("unknown %s %s", field, name)
+	}
+	row := ImportRow{Line: line, Entity: l.name, Name: name, Action: ImportInsert}
+	if im.opt.DryRun {
+		// value is not created, placeholder id keeps it distinct from empty value and other new values
+		im.planned--
+		l.ids[key] = im.planned
+		im.add(row)
+		return im.planned, nil
+	}
+	id, err := l.create(name)
+	if err != nil {
+		return 0, err
+	}
+	l.ids[key] = id
+	row.ID = id
+	im.add(row)
+	return id, nil
+}
+
+// resolveDepartment - get id of department of company by name, common department is used when company
+// has no department with this name
+func (im *importer) resolveDepartment(line int, companyID int64, name string) (int64, error) {
+	if name == "" {
+		return 0, nil
+	}
+	// company created in dry run has no departments
+	if companyID > 0 {
+		ids, ok := im.companyDepartments[companyID]
+		if !ok {
+			items, err := im.e.GetCompanyDepartmentSelect(companyID)
+			if err != nil {
+				return 0, err
+			}
+			ids = make(map[string]int64)
+			// departments of company go after common ones and replace them
+			for _, item := range items {
+				ids[importKey(item.Name)] = item.ID
+			}
+			im.companyDepartments[companyID] = ids
+		}
+		if id, ok := ids[importKey(name)]; ok {
+			return id, nil
+		}
+	}
+	return im.resolve(line, "department", name)
+}
+
+func (im *importer) findCompany(name string, scopeID int64) (int64, error) {
+	var id sql.NullInt64
+	// scope created in dry run has no companies
+	if scopeID < 0 {
+		return 0, nil
+	}
+	err := im.e.db.QueryRow(`
+		SELECT
+			id
+		FROM
+			companies
+		WHERE
+			name = $1 AND scope_id IS NOT DISTINCT FROM $2
+	`, name, i2n(scopeID)).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return n2i(id), err
+}
+
+func (im *importer) findContact(name string, birthday string) (int64, error) {
+	var id sql.NullInt64
+	err := im.e.db.QueryRow(`
+		SELECT
+			id
+		FROM
+			contacts
+		WHERE
+			name = $1 AND birthday IS NOT DISTINCT FROM $2
+	`, name, sd2n(birthday)).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return n2i(id), err
+}
+
+func mergePhones(old []Phone, add []Phone) []Phone {
+	for _, p := range add {
+		found := false
+		for _, o := range old {
+			if o.Phone == p.Phone {
+				found = true
+			}
+		}
+		if !found {
+			old = append(old, p)
+		}
+	}
+	return old
+}
+
+func mergeEmails(old []Email, add []Email) []Email {
+	for _, m := range add {
+		found := false
+		for _, o := range old {
+			if strings.EqualFold(o.Email, m.Email) {
+				found = true
+			}
+		}
+		if !found {
+			old = append(old, m)
+		}
+	}
+	return old
+}
+
+func (im *importer) importCompany(line int, record []string) (int64, error) {
+	name := im.value(record, "company")
+	scopeID, err := im.resolve(line, "scope", im.value(record, "scope"))
+	if err != nil {
+		return 0, err
+	}
+	key := importKey(name) + "|" + strconv.FormatInt(scopeID, 10)
+	if id, ok := im.companies[key]; ok {
+		return id, nil
+	}
+	company := Company{
+		Name:    name,
+		Address: im.value(record, "address"),
+		ScopeID: scopeID,
+		Note:    im.value(record, "company_note"),
+		Emails:  splitImportEmails(im.value(record, "company_emails")),
+		Phones:  splitImportPhones(im.value(record, "company_phones")),
+		Faxes:   splitImportPhones(im.value(record, "company_faxes")),
+	}
+	id, err := im.findCompany(name, scopeID)
+	if err != nil {
+		return 0, err
+	}
+	row := ImportRow{Line: line, Entity: "company", Name: name, ID: id}
+	if id == 0 {
+		row.Action = ImportInsert
+		if !im.opt.DryRun {
+			id, err = im.e.CreateCompany(company)
+			if err != nil {
+				return 0, err
+			}
+			row.ID = id
+		}
+	} else {
+		row.Action = ImportUpdate
+		if !im.opt.DryRun {
+			old, err := im.e.GetCompany(id)
+			if err != nil {
+				return 0, err
+			}
+			if company.Address != "" {
+				old.Address = company.Address
+			}
+			if company.Note != "" {
+				old.Note = company.Note
+			}
+			old.Emails = mergeEmails(old.Emails, company.Emails)
+			old.Phones = mergePhones(old.Phones, company.Phones)
+			old.Faxes = mergePhones(old.Faxes, company.Faxes)
+			err = im.e.UpdateCompany(old)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	im.companies[key] = id
+	im.add(row)
+	return id, nil
+}
+
+func (im *importer) importContact(line int, record []string, companyID int64) error {
+	name := im.value(record, "contact")
+	birthday, err := parseImportDate(im.value(record, "birthday"))
+	if err != nil {
+		return err
+	}
+	contact := Contact{
+		Name:      name,
+		CompanyID: companyID,
+		Birthday:  birthday,
+		Note:      im.value(record, "contact_note"),
+		Emails:    splitImportEmails(im.value(record, "emails")),
+		Phones:    splitImportPhones(im.value(record, "phones")),
+		Faxes:     splitImportPhones(im.value(record, "faxes")),
+	}
+	for _, lookup := range []struct {
+		field string
+		id    *int64
+	}{
+		{"post", &contact.PostID},
+		{"post_go", &contact.PostGOID},
+		{"rank", &contact.RankID},
+	} {
+		*lookup.id, err = im.resolve(line, lookup.field, im.value(record, lookup.field))
+		if err != nil {
+			return err
+		}
+	}
+	contact.DepartmentID, err = im.resolveDepartment(line, companyID, im.value(record, "department"))
+	if err != nil {
+		return err
+	}
+	key := importKey(name) + "|" + birthday
+	if _, ok := im.contacts[key]; ok {
+		return fmt.Errorf("duplicate contact %s in file", name)
+	}
+	id, err := im.findContact(name, birthday)
+	if err != nil {
+		return err
+	}
+	row := ImportRow{Line: line, Entity: "contact", Name: name, ID: id}
+	if id == 0 {
+		row.Action = ImportInsert
+		if !im.opt.DryRun {
+			id, err = im.e.CreateContact(contact)
+			if err != nil {
+				return err
+			}
+			row.ID = id
+		}
+	} else {
+		row.Action = ImportUpdate
+		if !im.opt.DryRun {
+			old, err := im.e.GetContact(id)
+			if err != nil {
+				return err
+			}
+			if contact.CompanyID != 0 {
+				old.CompanyID = contact.CompanyID
+			}
+			if contact.DepartmentID != 0 {
+				old.DepartmentID = contact.DepartmentID
+			}
+			if contact.PostID != 0 {
+				old.PostID = contact.PostID
+			}
+			if contact.PostGOID != 0 {
+				old.PostGOID = contact.PostGOID
+			}
+			if contact.RankID != 0 {
+				old.RankID = contact.RankID
+			}
+			if contact.Note != "" {
+				old.Note = contact.Note
+			}
+			old.Emails = mergeEmails(old.Emails, contact.Emails)
+			old.Phones = mergePhones(old.Phones, contact.Phones)
+			old.Faxes = mergePhones(old.Faxes, contact.Faxes)
+			err = im.e.UpdateContact(old)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	im.contacts[key] = id
+	im.add(row)
+	return nil
+}
+
+// emptyImportRecord - row without values, like empty row between blocks of sheet
+func emptyImportRecord(record []string) bool {
+	for _, val := range record {
+		if strings.TrimSpace(val) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func (im *importer) importRecord(line int, record []string) {
+	var (
+		companyID int64
+		err       error
+	)
+	if emptyImportRecord(record) {
+		return
+	}
+	company := im.value(record, "company")
+	contact := im.value(record, "contact")
+	if company == "" && contact == "" {
+		im.add(ImportRow{Line: line, Action: ImportReject, Reason: "empty company and contact name"})
+		return
+	}
+	if company != "" {
+		companyID, err = im.importCompany(line, record)
+		if err != nil {
+			im.add(ImportRow{Line: line, Entity: "company", Name: company, Action: ImportReject, Reason: err.Error()})
+			return
+		}
+	}
+	if contact != "" {
+		err = im.importContact(line, record, companyID)
+		if err != nil {
+			im.add(ImportRow{Line: line, Entity: "contact", Name: contact, Action: ImportReject, Reason: err.Error()})
+		}
+	}
+}
+
+// ImportRecords - import companies and contacts from rows, first row is header
+func (e *Edb) ImportRecords(records [][]string, opt ImportOptions) (ImportReport, error) {
+	im := importer{
+		e:         e,
+		opt:       opt,
+		columns:   make(map[string]int),
+		companies: make(map[string]int64),
+		contacts:  make(map[string]int64),
+
+		companyDepartments: make(map[int64]map[string]int64),
+	}
+	im.report.DryRun = opt.DryRun
+	if len(records) == 0 {
+		return im.report, nil
+	}
+	mapping := opt.Mapping
+	if len(mapping) == 0 {
+		mapping = DefaultImportMapping
+	}
+	for field, header := range mapping {
+		for i, h := range records[0] {
+			if importKey(h) == importKey(header) {
+				im.columns[field] = i
+			}
+		}
+	}
+	if _, ok := im.columns["company"]; !ok {
+		if _, ok := im.columns["contact"]; !ok {
+			return im.report, fmt.Errorf("ImportRecords: no company or contact column in header")
+		}
+	}
+	var err error
+	im.lookups, err = e.importLookups()
+	if err != nil {
+		log.Println("ImportRecords importLookups ", err)
+		return im.report, err
+	}
+	for i, record := range records[1:] {
+		im.importRecord(i+2, record)
+	}
+	return im.report, nil
+}
+
+// ImportCSV - import companies and contacts from csv
+func (e *Edb) ImportCSV(r io.Reader, opt ImportOptions) (ImportReport, error) {
+	cr := csv.NewReader(r)
+	if opt.Comma != 0 {
+		cr.Comma = opt.Comma
+	}
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	var records [][]string
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Println("ImportCSV cr.Read ", err)
+			return ImportReport{DryRun: opt.DryRun}, err
+		}
+		// empty lines are skipped by reader, they are kept so lines of report match lines of file
+		line, _ := cr.FieldPos(0)
+		for len(records) < line-1 {
+			records = append(records, []string{})
+		}
+		records = append(records, record)
+	}
+	return e.ImportRecords(records, opt)
+}
+
+// ImportXLSX - import companies and contacts from first sheet of xlsx
+func (e *Edb) ImportXLSX(r io.ReaderAt, size int64, opt ImportOptions) (ImportReport, error) {
+	records, err := readXLSX(r, size)
+	if err != nil {
+		log.Println("ImportXLSX readXLSX ", err)
+		return ImportReport{DryRun: opt.DryRun}, err
+	}
+	return e.ImportRecords(records, opt)
+}
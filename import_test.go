@@ -0,0 +1,68 @@
+package epgc
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+)
+
+func TestParseImportDate(t *testing.T) {
+	for val, want := range map[string]string{
+		"":           "",
+		"05.03.1990": "05.03.1990",
+		"5.3.1990":   "05.03.1990",
+		"1990-03-05": "05.03.1990",
+		"32937":      "05.03.1990",
+		"32937.5":    "05.03.1990",
+	} {
+		got, err := parseImportDate(val)
+		if err != nil || got != want {
+			t.Errorf("parseImportDate(%q) = %q, %v, want %q", val, got, err, want)
+		}
+	}
+	for _, val := range []string{"1990", "2024", "-5", "3000000", "завтра"} {
+		if got, err := parseImportDate(val); err == nil {
+			t.Errorf("parseImportDate(%q) = %q, want error", val, got)
+		}
+	}
+}
+
+func TestSplitImportCells(t *testing.T) {
+	phones := splitImportPhones("8 (800) 123-45-67, 123456; нет")
+	if len(phones) != 2 || phones[0].Phone != 88001234567 || phones[1].Phone != 123456 {
+		t.Errorf("splitImportPhones = %+v", phones)
+	}
+	emails := splitImportEmails("Info@Example.ru; без почты, mail@example.ru")
+	if len(emails) != 2 || emails[0].Email != "info@example.ru" || emails[1].Email != "mail@example.ru" {
+		t.Errorf("splitImportEmails = %+v", emails)
+	}
+	if !emptyImportRecord([]string{"", " "}) || emptyImportRecord([]string{"", "a"}) {
+		t.Error("emptyImportRecord")
+	}
+}
+
+func TestReadXLSXSkippedRows(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.Write([]byte(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+		`<row r="1"><c r="A1" t="inlineStr"><is><t>company</t></is></c></row>` +
+		`<row r="4"><c r="B4"><v>7.9001234567E10</v></c></row>` +
+		`</sheetData></worksheet>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	records, err := readXLSX(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || records[0][0] != "company" || len(records[1]) != 0 || len(records[2]) != 0 || records[3][1] != "79001234567" {
+		t.Errorf("readXLSX = %q", records)
+	}
+}
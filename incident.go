@@ -0,0 +1,708 @@
+package epgc
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Incident states
+const (
+	IncidentOpen   = "open"
+	IncidentClosed = "closed"
+)
+
+// Incident severities
+const (
+	SeverityLow      = 1
+	SeverityMedium   = 2
+	SeverityHigh     = 3
+	SeverityCritical = 4
+)
+
+// ErrIncidentStatus - unknown status of incident
+var ErrIncidentStatus = errors.New("unknown incident status")
+
+// Incident - event handled by duty dispatch service. Times are in "02.01.2006 15:04" format,
+// incident without ReceivedAt is received now
+type Incident struct {
+	ID          int64            `sql:"id" json:"id"`
+	ReceivedAt  string           `sql:"received_at" json:"received_at"`
+	Source      string           `sql:"source, null" json:"source"`
+	TypeID      int64            `sql:"type_id, null" json:"type_id"`
+	Type        IncidentType     `sql:"-"`
+	Severity    int64            `sql:"severity, null" json:"severity"`
+	Status      string           `sql:"status" json:"status"`
+	Address     string           `sql:"address, null" json:"address"`
+	Latitude    float64          `sql:"latitude, null" json:"latitude"`
+	Longitude   float64          `sql:"longitude, null" json:"longitude"`
+	Description string           `sql:"description, null" json:"description"`
+	Result      string           `sql:"result, null" json:"result"`
+	ClosedAt    string           `sql:"closed_at, null" json:"closed_at"`
+	Note        string           `sql:"note, null" json:"note"`
+	CompanyIDs  []int64          `sql:"-" json:"company_ids"`
+	ContactIDs  []int64          `sql:"-" json:"contact_ids"`
+	Companies   []SelectItem     `sql:"-" json:"companies"`
+	Contacts    []SelectItem     `sql:"-" json:"contacts"`
+	Actions     []IncidentAction `sql:"-" json:"actions"`
+	CreatedAt   string           `sql:"created_at" json:"created_at"`
+	UpdatedAt   string           `sql:"updated_at" json:"updated_at"`
+}
+
+// IncidentList - incident for list
+type IncidentList struct {
+	ID          int64  `json:"id"`
+	ReceivedAt  string `json:"received_at"`
+	TypeName    string `json:"type_name"`
+	Severity    int64  `json:"severity"`
+	Status      string `json:"status"`
+	Address     string `json:"address"`
+	Description string `json:"description"`
+}
+
+// IncidentFilter - filter of incident list, From and To are dates in "02.01.2006" format and both are included,
+// Severity is minimal severity, zero values are not used
+type IncidentFilter struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	TypeID    int64  `json:"type_id"`
+	Status    string `json:"status"`
+	Severity  int64  `json:"severity"`
+	CompanyID int64  `json:"company_id"`
+}
+
+// IncidentAction - action taken on incident
+type IncidentAction struct {
+	ID          int64  `sql:"id" json:"id"`
+	IncidentID  int64  `sql:"incident_id" json:"incident_id"`
+	ActionAt    string `sql:"action_at" json:"action_at"`
+	ContactID   int64  `sql:"contact_id, null" json:"contact_id"`
+	ContactName string `sql:"-" json:"contact_name"`
+	Description string `sql:"description, null" json:"description"`
+}
+
+// NearbySiren - siren near incident, Covers is true when incident is within radius of siren type
+type NearbySiren struct {
+	ID       int64   `json:"id"`
+	NumID    int64   `json:"num_id"`
+	Address  string  `json:"address"`
+	TypeName string  `json:"type_name"`
+	Radius   int64   `json:"radius"`
+	Distance float64 `json:"distance"`
+	Covers   bool    `json:"covers"`
+}
+
+// st2n - time in "02.01.2006 15:04", "02.01.2006 15:04:05" or "02.01.2006" format
+func st2n(val string) pq.NullTime {
+	for _, layout := range []string{"02.01.2006 15:04", "02.01.2006 15:04:05", "02.01.2006"} {
+		t, err := time.Parse(layout, strings.TrimSpace(val))
+		if err == nil {
+			return pq.NullTime{Time: t, Valid: true}
+		}
+	}
+	return pq.NullTime{}
+}
+
+// n2stm - time in "02.01.2006 15:04" format
+func n2stm(val pq.NullTime) string {
+	if !val.Valid {
+		return ""
+	}
+	return val.Time.Format("02.01.2006 15:04")
+}
+
+// geoDistance - distance between points in meters
+func geoDistance(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371000
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+func scanIncident(row *sql.Row) (Incident, error) {
+	var (
+		sID          sql.NullInt64
+		sReceivedAt  pq.NullTime
+		sSource      sql.NullString
+		sTypeID      sql.NullInt64
+		sSeverity    sql.NullInt64
+		sStatus      sql.NullString
+		sAddress     sql.NullString
+		sLatitude    sql.NullFloat64
+		sLongitude   sql.NullFloat64
+		sDescription sql.NullString
+		sResult      sql.NullString
+		sClosedAt    pq.NullTime
+		sNote        sql.NullString
+		incident     Incident
+	)
+	err := row.Scan(&sID, &sReceivedAt, &sSource, &sTypeID, &sSeverity, &sStatus, &sAddress, &sLatitude, &sLongitude,
+		&sDescription, &sResult, &sClosedAt, &sNote)
+	if err != nil {
+		log.Println("scanIncident row.Scan ", err)
+		return incident, err
+	}
+	incident.ID = n2i(sID)
+	incident.ReceivedAt = n2stm(sReceivedAt)
+	incident.Source = n2s(sSource)
+	incident.TypeID = n2i(sTypeID)
+	incident.Severity = n2i(sSeverity)
+	incident.Status = n2s(sStatus)
+	incident.Address = n2s(sAddress)
+	incident.Latitude = n2f(sLatitude)
+	incident.Longitude = n2f(sLongitude)
+	incident.Description = n2s(sDescription)
+	incident.Result = n2s(sResult)
+	incident.ClosedAt = n2stm(sClosedAt)
+	incident.Note = n2s(sNote)
+	return incident, nil
+}
+
+func scanIncidentList(rows *sql.Rows) ([]IncidentList, error) {
+	var incidents []IncidentList
+	for rows.Next() {
+		var (
+			sID          sql.NullInt64
+			sReceivedAt  pq.NullTime
+			sTypeName    sql.NullString
+			sSeverity    sql.NullInt64
+			sStatus      sql.NullString
+			sAddress     sql.NullString
+			sDescription sql.NullString
+			incident     IncidentList
+		)
+		err := rows.Scan(&sID, &sReceivedAt, &sTypeName, &sSeverity, &sStatus, &sAddress, &sDescription)
+		if err != nil {
+			log.Println("scanIncidentList rows.Scan ", err)
+			return incidents, err
+		}
+		incident.ID = n2i(sID)
+		incident.ReceivedAt = n2stm(sReceivedAt)
+		incident.TypeName = n2s(sTypeName)
+		incident.Severity = n2i(sSeverity)
+		incident.Status = n2s(sStatus)
+		incident.Address = n2s(sAddress)
+		incident.Description = n2s(sDescription)
+		incidents = append(incidents, incident)
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Println("scanIncidentList rows.Err ", err)
+	}
+	return incidents, err
+}
+
+func scanIncidentActions(rows *sql.Rows) ([]IncidentAction, error) {
+	var actions []IncidentAction
+	for rows.Next() {
+		var (
+			sID          sql.NullInt64
+			sIncidentID  sql.NullInt64
+			sActionAt    pq.NullTime
+			sContactID   sql.NullInt64
+			sContactName sql.NullString
+			sDescription sql.NullString
+			action       IncidentAction
+		)
+		err := rows.Scan(&sID, &sIncidentID, &sActionAt, &sContactID, &sContactName, &sDescription)
+		if err != nil {
+			log.Println("scanIncidentActions rows.Scan ", err)
+			return actions, err
+		}
+		action.ID = n2i(sID)
+		action.IncidentID = n2i(sIncidentID)
+		action.ActionAt = n2stm(sActionAt)
+		action.ContactID = n2i(sContactID)
+		action.ContactName = n2s(sContactName)
+		action.Description = n2s(sDescription)
+		actions = append(actions, action)
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Println("scanIncidentActions rows.Err ", err)
+	}
+	return actions, err
+}
+
+// GetIncident - get one incident by id with type, companies, contacts and actions
+func (e *Edb) GetIncident(id int64) (Incident, error) {
+	if id == 0 {
+		return Incident{}, nil
+	}
+	row := e.db.QueryRow(`
+		SELECT
+			id,
+			received_at,
+			source,
+			type_id,
+			severity,
+			status,
+			address,
+			latitude,
+			longitude,
+			description,
+			result,
+			closed_at,
+			note
+		FROM
+			incidents
+		WHERE
+			id = $1
+	`, id)
+	incident, err := scanIncident(row)
+	if err != nil {
+		return incident, err
+	}
+	incident.Type, err = e.GetIncidentType(incident.TypeID)
+	if err != nil {
+		return incident, err
+	}
+	incident.Companies, incident.Contacts, err = e.getParticipants("incident_participants", "incident_id", id)
+	if err != nil {
+		return incident, err
+	}
+	incident.CompanyIDs, incident.ContactIDs = []int64{}, []int64{}
+	for _, item := range incident.Companies {
+		incident.CompanyIDs = append(incident.CompanyIDs, item.ID)
+	}
+	for _, item := range incident.Contacts {
+		incident.ContactIDs = append(incident.ContactIDs, item.ID)
+	}
+	incident.Actions, err = e.GetIncidentActions(id)
+	return incident, err
+}
+
+// GetIncidentList - get incidents by filter from newest
+func (e *Edb) GetIncidentList(filter IncidentFilter) ([]IncidentList, error) {
+	rows, err := e.db.Query(`
+		SELECT
+			i.id,
+			i.received_at,
+			t.name,
+			i.severity,
+			i.status,
+			i.address,
+			i.description
+		FROM
+			incidents AS i
+		LEFT JOIN
+			incident_types AS t ON t.id = i.type_id
+		WHERE
+			($1::date IS NULL OR i.received_at >= $1)
+			AND ($2::date IS NULL OR i.received_at < $2::date + 1)
+			AND ($3 = 0 OR i.type_id = $3)
+			AND ($4 = '' OR i.status = $4)
+			AND ($5 = 0 OR i.severity >= $5)
+			AND ($6 = 0 OR EXISTS (
+				SELECT 1 FROM incident_participants AS p WHERE p.incident_id = i.id AND p.company_id = $6
+			))
+		ORDER BY
+			i.received_at DESC,
+			i.id DESC
+	`, sd2n(filter.From), sd2n(filter.To), filter.TypeID, filter.Status, filter.Severity, filter.CompanyID)
+	if err != nil {
+		log.Println("GetIncidentList e.db.Query ", err)
+		return []IncidentList{}, err
+	}
+	return scanIncidentList(rows)
+}
+
+// checkIncident - fill default status and coordinates of address found in imported registry
+func (e *Edb) checkIncident(incident *Incident) error {
+	switch incident.Status {
+	case "":
+		incident.Status = IncidentOpen
+	case IncidentOpen, IncidentClosed:
+	default:
+		return ErrIncidentStatus
+	}
+	if incident.Latitude == 0 && incident.Longitude == 0 && incident.Address != "" {
+		result, err := e.geocodeAddress(ParseAddress(incident.Address))
+		if err != nil {
+			return err
+		}
+		if result.Precision != "" {
+			incident.Latitude, incident.Longitude = result.Address.Latitude, result.Address.Longitude
+		}
+	}
+	return nil
+}
+
+// CreateIncident - create new incident with companies and contacts
+func (e *Edb) CreateIncident(incident Incident) (int64, error) {
+	err := e.checkIncident(&incident)
+	if err != nil {
+		log.Println("CreateIncident checkIncident ", err)
+		return 0, err
+	}
+	err = e.db.QueryRow(`
+		INSERT INTO
+			incidents (
+				received_at,
+				source,
+				type_id,
+				severity,
+				status,
+				address,
+				latitude,
+				longitude,
+				description,
+				result,
+				closed_at,
+				note,
+				created_at
+			)
+		VALUES (
+			COALESCE($1::timestamp, now()),
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7,
+			$8,
+			$9,
+			$10,
+			CASE WHEN $5 = '`+IncidentClosed+`' THEN COALESCE($11::timestamp, now()) END,
+			$12,
+			now()
+		)
+		RETURNING
+			id
+	`, st2n(incident.ReceivedAt), s2n(incident.Source), i2n(incident.TypeID), i2n(incident.Severity), incident.Status,
+		s2n(incident.Address), f2n(incident.Latitude), f2n(incident.Longitude), s2n(incident.Description),
+		s2n(incident.Result), st2n(incident.ClosedAt), s2n(incident.Note)).Scan(&incident.ID)
+	if err != nil {
+		log.Println("CreateIncident e.db.QueryRow ", err)
+		return 0, err
+	}
+	return incident.ID, e.saveParticipants("incident_participants", "incident_id", incident.ID, incident.CompanyIDs, incident.ContactIDs)
+}
+
+// UpdateIncident - save incident changes, companies and contacts are replaced when CompanyIDs or ContactIDs are not nil
+func (e *Edb) UpdateIncident(incident Incident) error {
+	err := e.checkIncident(&incident)
+	if err != nil {
+		log.Println("UpdateIncident checkIncident ", err)
+		return err
+	}
+	_, err = e.db.Exec(`
+		UPDATE
+			incidents
+		SET
+			received_at = COALESCE($2::timestamp, received_at),
+			source = $3,
+			type_id = $4,
+			severity = $5,
+			status = $6,
+			address = $7,
+			latitude = $8,
+			longitude = $9,
+			description = $10,
+			result = $11,
+			closed_at = CASE WHEN $6 = '`+IncidentClosed+`' THEN COALESCE($12::timestamp, closed_at, now()) END,
+			note = $13,
+			updated_at = now()
+		WHERE
+			id = $1
+	`, i2n(incident.ID), st2n(incident.ReceivedAt), s2n(incident.Source), i2n(incident.TypeID), i2n(incident.Severity),
+		incident.Status, s2n(incident.Address), f2n(incident.Latitude), f2n(incident.Longitude), s2n(incident.Description),
+		s2n(incident.Result), st2n(incident.ClosedAt), s2n(incident.Note))
+	if err != nil {
+		log.Println("UpdateIncident e.db.Exec ", err)
+		return err
+	}
+	return e.saveParticipants("incident_participants", "incident_id", incident.ID, incident.CompanyIDs, incident.ContactIDs)
+}
+
+// CloseIncident - close incident with result
+func (e *Edb) CloseIncident(id int64, result string) error {
+	_, err := e.db.Exec(`
+		UPDATE
+			incidents
+		SET
+			status = $2,
+			result = $3,
+			closed_at = now(),
+			updated_at = now()
+		WHERE
+			id = $1
+	`, id, IncidentClosed, s2n(result))
+	if err != nil {
+		log.Println("CloseIncident e.db.Exec ", err)
+	}
+	return err
+}
+
+// DeleteIncident - delete incident with its actions
+func (e *Edb) DeleteIncident(id int64) error {
+	if id == 0 {
+		return nil
+	}
+	for _, query := range []string{
+		`DELETE FROM incident_actions WHERE incident_id = $1`,
+		`DELETE FROM shift_handover_incidents WHERE incident_id = $1`,
+		`DELETE FROM incident_participants WHERE incident_id = $1`,
+		`DELETE FROM incidents WHERE id = $1`,
+	} {
+		_, err := e.db.Exec(query, id)
+		if err != nil {
+			log.Println("DeleteIncident e.db.Exec ", id, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// GetIncidentActions - get actions of incident in order of time
+func (e *Edb) GetIncidentActions(id int64) ([]IncidentAction, error) {
+	rows, err := e.db.Query(`
+		SELECT
+			a.id,
+			a.incident_id,
+			a.action_at,
+			a.contact_id,
+			c.name,
+			a.description
+		FROM
+			incident_actions AS a
+		LEFT JOIN
+			contacts AS c ON c.id = a.contact_id
+		WHERE
+			a.incident_id = $1
+		ORDER BY
+			a.action_at ASC,
+			a.id ASC
+	`, id)
+	if err != nil {
+		log.Println("GetIncidentActions e.db.Query ", err)
+		return []IncidentAction{}, err
+	}
+	return scanIncidentActions(rows)
+}
+
+// CreateIncidentAction - add action to incident, action without ActionAt is taken now
+func (e *Edb) CreateIncidentAction(action IncidentAction) (int64, error) {
+	err := e.db.QueryRow(`
+		INSERT INTO
+			incident_actions (
+				incident_id,
+				action_at,
+				contact_id,
+				description,
+				created_at
+			)
+		VALUES (
+			$1,
+			COALESCE($2::timestamp, now()),
+			$3,
+			$4,
+			now()
+		)
+		RETURNING
+			id
+	`, i2n(action.IncidentID), st2n(action.ActionAt), i2n(action.ContactID), s2n(action.Description)).Scan(&action.ID)
+	if err != nil {
+		log.Println("CreateIncidentAction e.db.QueryRow ", err)
+		return 0, err
+	}
+	return action.ID, nil
+}
+
+// UpdateIncidentAction - save action changes
+func (e *Edb) UpdateIncidentAction(action IncidentAction) error {
+	_, err := e.db.Exec(`
+		UPDATE
+			incident_actions
+		SET
+			action_at = COALESCE($2::timestamp, action_at),
+			contact_id = $3,
+			description = $4,
+			updated_at = now()
+		WHERE
+			id = $1
+	`, i2n(action.ID), st2n(action.ActionAt), i2n(action.ContactID), s2n(action.Description))
+	if err != nil {
+		log.Println("UpdateIncidentAction e.db.Exec ", err)
+	}
+	return err
+}
+
+// DeleteIncidentAction - delete action by id
+func (e *Edb) DeleteIncidentAction(id int64) error {
+	_, err := e.db.Exec(`
+		DELETE FROM
+			incident_actions
+		WHERE
+			id = $1
+	`, id)
+	if err != nil {
+		log.Println("DeleteIncidentAction e.db.Exec ", id, err)
+	}
+	return err
+}
+
+// GetIncidentSirens - get sirens within radius in meters from incident ordered by distance,
+// coordinates of sirens are taken from their addresses or latitude and longitude fields
+func (e *Edb) GetIncidentSirens(id int64, radius float64) ([]NearbySiren, error) {
+	incident, err := e.GetIncident(id)
+	if err != nil {
+		return []NearbySiren{}, err
+	}
+	if incident.Latitude == 0 && incident.Longitude == 0 {
+		return []NearbySiren{}, nil
+	}
+	rows, err := e.db.Query(`
+		SELECT
+			s.id,
+			s.num_id,
+			s.address,
+			t.name,
+			t.radius,
+			a.latitude,
+			a.longitude,
+			s.latitude,
+			s.longitude
+		FROM
+			sirens AS s
+		LEFT JOIN
+			sirentypes AS t ON t.id = s.type_id
+		LEFT JOIN
+			addresses AS a ON a.siren_id = s.id
+	`)
+	if err != nil {
+		log.Println("GetIncidentSirens e.db.Query ", err)
+		return []NearbySiren{}, err
+	}
+	defer rows.Close()
+	sirens := []NearbySiren{}
+	for rows.Next() {
+		var (
+			sID        sql.NullInt64
+			sNumID     sql.NullInt64
+			sAddress   sql.NullString
+			sTypeName  sql.NullString
+			sRadius    sql.NullInt64
+			sLatitude  sql.NullFloat64
+			sLongitude sql.NullFloat64
+			sLatText   sql.NullString
+			sLonText   sql.NullString
+		)
+		err = rows.Scan(&sID, &sNumID, &sAddress, &sTypeName, &sRadius, &sLatitude, &sLongitude, &sLatText, &sLonText)
+		if err != nil {
+			log.Println("GetIncidentSirens rows.Scan ", err)
+			return sirens, err
+		}
+		lat, lon := n2f(sLatitude), n2f(sLongitude)
+		if !sLatitude.Valid || !sLongitude.Valid {
+			var latErr, lonErr error
+			lat, latErr = strconv.ParseFloat(strings.Replace(n2s(sLatText), ",", ".", 1), 64)
+			lon, lonErr = strconv.ParseFloat(strings.Replace(n2s(sLonText), ",", ".", 1), 64)
+			if latErr != nil || lonErr != nil {
+				continue
+			}
+		}
+		distance := geoDistance(incident.Latitude, incident.Longitude, lat, lon)
+		if distance > radius {
+			continue
+		}
+		sirens = append(sirens, NearbySiren{
+			ID:       n2i(sID),
+			NumID:    n2i(sNumID),
+			Address:  n2s(sAddress),
+			TypeName: n2s(sTypeName),
+			Radius:   n2i(sRadius),
+			Distance: math.Round(distance),
+			Covers:   distance <= float64(n2i(sRadius)),
+		})
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Println("GetIncidentSirens rows.Err ", err)
+	}
+	sort.SliceStable(sirens, func(i, j int) bool {
+		return sirens[i].Distance < sirens[j].Distance
+	})
+	return sirens, err
+}
+
+// deleteIncidentParticipants - remove deleted contact or company from incidents, actions keep history without contact
+func (e *Edb) deleteIncidentParticipants(owner string, id int64) error {
+	_, err := e.db.Exec(`
+		DELETE FROM
+			incident_participants
+		WHERE
+			`+owner+` = $1
+	`, id)
+	if err == nil && owner == "contact_id" {
+		_, err = e.db.Exec(`
+			UPDATE
+				incident_actions
+			SET
+				contact_id = NULL
+			WHERE
+				contact_id = $1
+		`, id)
+	}
+	if err != nil {
+		log.Println("deleteIncidentParticipants e.db.Exec ", owner, id, err)
+	}
+	return err
+}
+
+func (e *Edb) incidentCreateTable() error {
+	str := `
+		CREATE TABLE IF NOT EXISTS
+			incidents (
+				id bigserial primary key,
+				received_at TIMESTAMP without time zone,
+				source text,
+				type_id bigint,
+				severity bigint,
+				status text,
+				address text,
+				latitude double precision,
+				longitude double precision,
+				description text,
+				result text,
+				closed_at TIMESTAMP without time zone,
+				note text,
+				created_at TIMESTAMP without time zone,
+				updated_at TIMESTAMP without time zone
+			);
+		CREATE INDEX IF NOT EXISTS incidents_received_at_idx ON incidents (received_at);
+		CREATE TABLE IF NOT EXISTS
+			incident_participants (
+				id bigserial primary key,
+				incident_id bigint,
+				company_id bigint,
+				contact_id bigint
+			);
+		CREATE INDEX IF NOT EXISTS incident_participants_incident_id_idx ON incident_participants (incident_id);
+		CREATE TABLE IF NOT EXISTS
+			incident_actions (
+				id bigserial primary key,
+				incident_id bigint,
+				action_at TIMESTAMP without time zone,
+				contact_id bigint,
+				description text,
+				created_at TIMESTAMP without time zone,
+				updated_at TIMESTAMP without time zone
+			);
+		CREATE INDEX IF NOT EXISTS incident_actions_incident_id_idx ON incident_actions (incident_id);
+	`
+	_, err := e.db.Exec(str)
+	if err != nil {
+		log.Println("incidentCreateTable e.db.Exec ", err)
+	}
+	return err
+}
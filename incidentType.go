@@ -0,0 +1,242 @@
+package epgc
+
+import (
+	"database/sql"
+	"log"
+)
+
+// IncidentType - struct for type of incident, like fire or accident
+type IncidentType struct {
+	ID        int64  `sql:"id" json:"id"`
+	Name      string `sql:"name" json:"name"`
+	Note      string `sql:"note, null" json:"note"`
+	CreatedAt string `sql:"created_at" json:"created_at"`
+	UpdatedAt string `sql:"updated_at" json:"updated_at"`
+}
+
+func scanIncidentType(row *sql.Row) (IncidentType, error) {
+	var (
+		sID          sql.NullInt64
+		sName        sql.NullString
+		sNote        sql.NullString
+		incidentType IncidentType
+	)
+	err := row.Scan(&sID, &sName, &sNote)
+	if err != nil {
+		log.Println("scanIncidentType row.Scan ", err)
+		return incidentType, err
+	}
+	incidentType.ID = n2i(sID)
+	incidentType.Name = n2s(sName)
+	incidentType.Note = n2s(sNote)
+	return incidentType, nil
+}
+
+func scanIncidentTypes(rows *sql.Rows) ([]IncidentType, error) {
+	var incidentTypes []IncidentType
+	for rows.Next() {
+		var (
+			sID          sql.NullInt64
+			sName        sql.NullString
+			sNote        sql.NullString
+			incidentType IncidentType
+		)
+		err := rows.Scan(&sID, &sName, &sNote)
+		if err != nil {
+			log.Println("scanIncidentTypes rows.Scan ", err)
+			return incidentTypes, err
+		}
+		incidentType.Name = n2s(sName)
+		incidentType.Note = n2s(sNote)
+		incidentType.ID = n2i(sID)
+		incidentTypes = append(incidentTypes, incidentType)
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Println("scanIncidentTypes rows.Err ", err)
+	}
+	return incidentTypes, err
+}
+
+func scanIncidentTypesSelect(rows *sql.Rows) ([]SelectItem, error) {
+	var incidentTypes []SelectItem
+	for rows.Next() {
+		var (
+			sID          sql.NullInt64
+			sName        sql.NullString
+			incidentType SelectItem
+		)
+		err := rows.Scan(&sID, &sName)
+		if err != nil {
+			log.Println("scanIncidentTypes select rows.Scan ", err)
+			return incidentTypes, err
+		}
+		incidentType.Name = n2s(sName)
+		incidentType.ID = n2i(sID)
+		incidentTypes = append(incidentTypes, incidentType)
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Println("scanIncidentTypesSelect rows.Err ", err)
+	}
+	return incidentTypes, err
+}
+
+// GetIncidentType - get one incident type by id
+func (e *Edb) GetIncidentType(id int64) (IncidentType, error) {
+	if id == 0 {
+		return IncidentType{}, nil
+	}
+	if item, ok := e.cacheGet(cacheKey("incident_types", id)); ok {
+		return item.(IncidentType), nil
+	}
+	row := e.db.QueryRow(`
+		SELECT
+			id,
+			name,
+			note
+		FROM
+			incident_types
+		WHERE
+			id = $1
+	`, id)
+	incidentType, err := scanIncidentType(row)
+	if err == nil {
+		e.cacheSet(cacheKey("incident_types", id), incidentType)
+	}
+	return incidentType, err
+}
+
+// GetIncidentTypeList - get all incident types for list
+func (e *Edb) GetIncidentTypeList() ([]IncidentType, error) {
+	rows, err := e.db.Query(`
+		SELECT
+			id,
+			name,
+			note
+		FROM
+			incident_types
+		ORDER BY
+			name ASC
+	`)
+	if err != nil {
+		log.Println("GetIncidentTypeList e.db.Query ", err)
+		return []IncidentType{}, err
+	}
+	incidentTypes, err := scanIncidentTypes(rows)
+	return incidentTypes, err
+}
+
+// GetIncidentTypeSelect - get all incident types for select
+func (e *Edb) GetIncidentTypeSelect() ([]SelectItem, error) {
+	if items, ok := e.cacheSelect(cacheKey("incident_types", "select")); ok {
+		return items, nil
+	}
+	rows, err := e.db.Query(`
+		SELECT
+			id,
+			name
+		FROM
+			incident_types
+		ORDER BY
+			name ASC
+	`)
+	if err != nil {
+		log.Println("GetIncidentTypeSelect e.db.Query ", err)
+		return []SelectItem{}, err
+	}
+	incidentTypes, err := scanIncidentTypesSelect(rows)
+	if err == nil {
+		e.cacheSetSelect(cacheKey("incident_types", "select"), incidentTypes)
+	}
+	return incidentTypes, err
+}
+
+// CreateIncidentType - create new incident type
+func (e *Edb) CreateIncidentType(incidentType IncidentType) (int64, error) {
+	stmt, err := e.prepare(`
+		INSERT INTO
+			incident_types (
+				name,
+				note,
+				created_at
+			) VALUES (
+				$1,
+				$2,
+				now()
+			)
+		RETURNING
+			id`)
+	if err != nil {
+		log.Println("CreateIncidentType e.prepare ", err)
+		return 0, err
+	}
+	err = stmt.QueryRow(s2n(incidentType.Name), s2n(incidentType.Note)).Scan(&incidentType.ID)
+	if err != nil {
+		log.Println("CreateIncidentType db.QueryRow ", err)
+		return 0, err
+	}
+	e.InvalidateCache("incident_types")
+	return incidentType.ID, nil
+}
+
+// UpdateIncidentType - save incident type changes
+func (e *Edb) UpdateIncidentType(s IncidentType) error {
+	stmt, err := e.prepare(`
+		UPDATE
+			incident_types
+		SET
+			name=$2,
+			note=$3,
+			updated_at = now()
+		WHERE
+			id = $1
+	`)
+	if err != nil {
+		log.Println("UpdateIncidentType e.prepare ", err)
+		return err
+	}
+	_, err = stmt.Exec(i2n(s.ID), s2n(s.Name), s2n(s.Note))
+	if err != nil {
+		log.Println("UpdateIncidentType stmt.Exec ", err)
+	}
+	e.InvalidateCache("incident_types")
+	return err
+}
+
+// DeleteIncidentType - delete incident type by id
+func (e *Edb) DeleteIncidentType(id int64) error {
+	if id == 0 {
+		return nil
+	}
+	_, err := e.db.Exec(`
+		DELETE FROM
+			incident_types
+		WHERE
+			id = $1
+	`, id)
+	if err != nil {
+		log.Println("DeleteIncidentType e.db.Exec ", id, err)
+	}
+	e.InvalidateCache("incident_types")
+	return err
+}
+
+func (e *Edb) incidentTypeCreateTable() error {
+	str := `
+		CREATE TABLE IF NOT EXISTS
+			incident_types (
+				id bigserial primary key,
+				name text,
+				note text,
+				created_at TIMESTAMP without time zone,
+				updated_at TIMESTAMP without time zone,
+				UNIQUE(name)
+			)
+	`
+	_, err := e.db.Exec(str)
+	if err != nil {
+		log.Println("incidentTypeCreateTable e.db.Exec ", err)
+	}
+	return err
+}
@@ -0,0 +1,35 @@
+package epgc
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGeoDistance(t *testing.T) {
+	// Волгоград - Волжский, about 20 km
+	d := geoDistance(48.7080, 44.5133, 48.7858, 44.7797)
+	if d < 20000 || d > 22000 {
+		t.Errorf("geoDistance = %v", d)
+	}
+	if d := geoDistance(48.7, 44.5, 48.7, 44.5); d != 0 {
+		t.Errorf("geoDistance of same point = %v", d)
+	}
+	// one degree of latitude is about 111 km
+	if d := geoDistance(0, 0, 1, 0); math.Abs(d-111195) > 10 {
+		t.Errorf("geoDistance of one degree = %v", d)
+	}
+}
+
+func TestST2N(t *testing.T) {
+	for val, want := range map[string]string{
+		"19.10.2026 10:15":    "19.10.2026 10:15",
+		"19.10.2026 10:15:30": "19.10.2026 10:15",
+		"19.10.2026":          "19.10.2026 00:00",
+		"":                    "",
+		"2026-10-19":          "",
+	} {
+		if got := n2stm(st2n(val)); got != want {
+			t.Errorf("n2stm(st2n(%q)) = %q, want %q", val, got, want)
+		}
+	}
+}
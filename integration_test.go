@@ -0,0 +1,293 @@
+package epgc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/serbe/epgc"
+	"github.com/serbe/epgc/epgctest"
+)
+
+func TestMain(m *testing.M) {
+	epgctest.Main(m)
+}
+
+func check(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func selectNames(items []epgc.SelectItem) []string {
+	var names []string
+	for _, item := range items {
+		names = append(names, item.Name)
+	}
+	return names
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCreateAllTablesTwice(t *testing.T) {
+	dsn := epgctest.SchemaDSN(t)
+	e, err := epgc.Open(dsn)
+	check(t, err)
+	defer e.Close()
+	again, err := epgc.Open(dsn)
+	check(t, err)
+	defer again.Close()
+	_, err = again.GetCompanyList()
+	check(t, err)
+}
+
+func TestSubscribeAfterReopen(t *testing.T) {
+	dsn := epgctest.SchemaDSN(t)
+	e, err := epgc.Open(dsn)
+	check(t, err)
+	defer e.Close()
+	again, err := epgc.Open(dsn)
+	check(t, err)
+	defer again.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := again.Subscribe(ctx, "companies")
+	// listener starts asynchronously
+	time.Sleep(500 * time.Millisecond)
+	id, err := again.CreateCompany(epgc.Company{Name: "ООО Ромашка"})
+	check(t, err)
+	select {
+	case event := <-events:
+		if event.Entity != "companies" || event.ID != id || event.Operation != "INSERT" {
+			t.Errorf("event = %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event after insert")
+	}
+	select {
+	case event := <-events:
+		t.Errorf("second event %+v, trigger is created twice", event)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestScope(t *testing.T) {
+	e := epgctest.Open(t)
+	id, err := e.CreateScope(epgc.Scope{Name: "Промышленность", Note: "заметка"})
+	check(t, err)
+	_, err = e.CreateScope(epgc.Scope{Name: "Образование"})
+	check(t, err)
+	scope, err := e.GetScope(id)
+	check(t, err)
+	if scope.Name != "Промышленность" || scope.Note != "заметка" {
+		t.Errorf("GetScope = %+v", scope)
+	}
+	scope.Name = "Энергетика"
+	check(t, e.UpdateScope(scope))
+	list, err := e.GetScopeList()
+	check(t, err)
+	if len(list) != 2 || list[0].Name != "Образование" || list[1].Name != "Энергетика" {
+		t.Errorf("GetScopeList = %+v", list)
+	}
+	items, err := e.GetScopeSelect()
+	check(t, err)
+	if !equalStrings(selectNames(items), []string{"Образование", "Энергетика"}) {
+		t.Errorf("GetScopeSelect = %+v", items)
+	}
+	check(t, e.DeleteScope(id))
+	_, err = e.GetScope(id)
+	if err == nil {
+		t.Error("GetScope of deleted scope without error")
+	}
+}
+
+func TestRank(t *testing.T) {
+	e := epgctest.Open(t)
+	id, err := e.CreateRank(epgc.Rank{Name: "Майор"})
+	check(t, err)
+	rank, err := e.GetRank(id)
+	check(t, err)
+	if rank.Name != "Майор" {
+		t.Errorf("GetRank = %+v", rank)
+	}
+	rank.Name = "Подполковник"
+	check(t, e.UpdateRank(rank))
+	list, err := e.GetRankList()
+	check(t, err)
+	if len(list) != 1 || list[0].Name != "Подполковник" {
+		t.Errorf("GetRankList = %+v", list)
+	}
+	items, err := e.GetRankSelect()
+	check(t, err)
+	if !equalStrings(selectNames(items), []string{"Подполковник"}) {
+		t.Errorf("GetRankSelect = %+v", items)
+	}
+	check(t, e.DeleteRank(id))
+	list, err = e.GetRankList()
+	check(t, err)
+	if len(list) != 0 {
+		t.Errorf("GetRankList after delete = %+v", list)
+	}
+}
+
+func TestKind(t *testing.T) {
+	e := epgctest.Open(t)
+	id, err := e.CreateKind(epgc.Kind{Name: "КШУ"})
+	check(t, err)
+	kind, err := e.GetKind(id)
+	check(t, err)
+	if kind.Name != "КШУ" {
+		t.Errorf("GetKind = %+v", kind)
+	}
+	kind.Note = "командно-штабное учение"
+	check(t, e.UpdateKind(kind))
+	list, err := e.GetKindList()
+	check(t, err)
+	if len(list) != 1 || list[0].Note != "командно-штабное учение" {
+		t.Errorf("GetKindList = %+v", list)
+	}
+	items, err := e.GetKindSelect()
+	check(t, err)
+	if !equalStrings(selectNames(items), []string{"КШУ"}) {
+		t.Errorf("GetKindSelect = %+v", items)
+	}
+	check(t, e.DeleteKind(id))
+}
+
+func TestIncidentType(t *testing.T) {
+	e := epgctest.Open(t)
+	id, err := e.CreateIncidentType(epgc.IncidentType{Name: "Пожар"})
+	check(t, err)
+	incidentType, err := e.GetIncidentType(id)
+	check(t, err)
+	if incidentType.Name != "Пожар" {
+		t.Errorf("GetIncidentType = %+v", incidentType)
+	}
+	incidentType.Note = "техногенный"
+	check(t, e.UpdateIncidentType(incidentType))
+	list, err := e.GetIncidentTypeList()
+	check(t, err)
+	if len(list) != 1 || list[0].Note != "техногенный" {
+		t.Errorf("GetIncidentTypeList = %+v", list)
+	}
+	items, err := e.GetIncidentTypeSelect()
+	check(t, err)
+	if !equalStrings(selectNames(items), []string{"Пожар"}) {
+		t.Errorf("GetIncidentTypeSelect = %+v", items)
+	}
+	check(t, e.DeleteIncidentType(id))
+}
+
+func TestDepartment(t *testing.T) {
+	e := epgctest.Open(t)
+	id, err := e.CreateDepartment(epgc.Department{Name: "Бухгалтерия"})
+	check(t, err)
+	department, err := e.GetDepartment(id)
+	check(t, err)
+	if department.Name != "Бухгалтерия" {
+		t.Errorf("GetDepartment = %+v", department)
+	}
+	department.Name = "Отдел кадров"
+	check(t, e.UpdateDepartment(department))
+	list, err := e.GetDepartmentList()
+	check(t, err)
+	if len(list) != 1 || list[0].Name != "Отдел кадров" {
+		t.Errorf("GetDepartmentList = %+v", list)
+	}
+	items, err := e.GetDepartmentSelect()
+	check(t, err)
+	if !equalStrings(selectNames(items), []string{"Отдел кадров"}) {
+		t.Errorf("GetDepartmentSelect = %+v", items)
+	}
+	check(t, e.DeleteDepartment(id))
+}
+
+func TestPost(t *testing.T) {
+	e := epgctest.Open(t)
+	id, err := e.CreatePost(epgc.Post{Name: "Директор"})
+	check(t, err)
+	_, err = e.CreatePost(epgc.Post{Name: "Начальник штаба ГО", GO: true})
+	check(t, err)
+	post, err := e.GetPost(id)
+	check(t, err)
+	if post.Name != "Директор" || post.GO {
+		t.Errorf("GetPost = %+v", post)
+	}
+	post.Name = "Генеральный директор"
+	check(t, e.UpdatePost(post))
+	list, err := e.GetPostList()
+	check(t, err)
+	if len(list) != 2 {
+		t.Errorf("GetPostList = %+v", list)
+	}
+	items, err := e.GetPostSelect(false)
+	check(t, err)
+	if !equalStrings(selectNames(items), []string{"Генеральный директор"}) {
+		t.Errorf("GetPostSelect(false) = %+v", items)
+	}
+	items, err = e.GetPostSelect(true)
+	check(t, err)
+	if !equalStrings(selectNames(items), []string{"Начальник штаба ГО"}) {
+		t.Errorf("GetPostSelect(true) = %+v", items)
+	}
+	check(t, e.DeletePost(id))
+}
+
+func TestSirenType(t *testing.T) {
+	e := epgctest.Open(t)
+	id, err := e.CreateSirenType(epgc.SirenType{Name: "С-40", Radius: 400})
+	check(t, err)
+	sirenType, err := e.GetSirenType(id)
+	check(t, err)
+	if sirenType.Name != "С-40" || sirenType.Radius != 400 {
+		t.Errorf("GetSirenType = %+v", sirenType)
+	}
+	sirenType.Radius = 500
+	check(t, e.UpdateSirenType(sirenType))
+	list, err := e.GetSirenTypeList()
+	check(t, err)
+	if len(list) != 1 || list[0].Radius != 500 {
+		t.Errorf("GetSirenTypeList = %+v", list)
+	}
+	items, err := e.GetSirenTypeSelect()
+	check(t, err)
+	if !equalStrings(selectNames(items), []string{"С-40"}) {
+		t.Errorf("GetSirenTypeSelect = %+v", items)
+	}
+	check(t, e.DeleteSirenType(id))
+}
+
+func TestEducation(t *testing.T) {
+	e := epgctest.Open(t)
+	id, err := e.CreateEducation(epgc.Education{StartDate: "01.02.2024", EndDate: "10.02.2024"})
+	check(t, err)
+	education, err := e.GetEducation(id)
+	check(t, err)
+	if education.StartDate != "01.02.2024" || education.EndDate != "10.02.2024" {
+		t.Errorf("GetEducation = %+v", education)
+	}
+	education.Note = "курсы"
+	check(t, e.UpdateEducation(education))
+	list, err := e.GetEducationList()
+	check(t, err)
+	if len(list) != 1 || list[0].Note != "курсы" || list[0].StartStr == "" {
+		t.Errorf("GetEducationList = %+v", list)
+	}
+	selected, err := e.GetEducationSelect()
+	check(t, err)
+	if len(selected) != 1 || selected[0].ID != id {
+		t.Errorf("GetEducationSelect = %+v", selected)
+	}
+	check(t, e.DeleteEducation(id))
+}
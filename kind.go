@@ -87,6 +87,9 @@ func (e *Edb) GetKind(id int64) (Kind, error) {
 	if id == 0 {
 		return Kind{}, nil
 	}
+	if item, ok := e.cacheGet(cacheKey("kinds", id)); ok {
+		return item.(Kind), nil
+	}
 	row := e.db.QueryRow(`
 		SELECT
 			id,
@@ -98,6 +101,9 @@ func (e *Edb) GetKind(id int64) (Kind, error) {
 			id = $1
 	`, id)
 	kind, err := scanKind(row)
+	if err == nil {
+		e.cacheSet(cacheKey("kinds", id), kind)
+	}
 	return kind, err
 }
 
@@ -123,6 +129,9 @@ func (e *Edb) GetKindList() ([]Kind, error) {
 
 // GetKindSelect - get all kind for select
 func (e *Edb) GetKindSelect() ([]SelectItem, error) {
+	if items, ok := e.cacheSelect(cacheKey("kinds", "select")); ok {
+		return items, nil
+	}
 	rows, err := e.db.Query(`
 		SELECT
 			id,
@@ -137,12 +146,15 @@ func (e *Edb) GetKindSelect() ([]SelectItem, error) {
 		return []SelectItem{}, err
 	}
 	kinds, err := scanKindsSelect(rows)
+	if err == nil {
+		e.cacheSetSelect(cacheKey("kinds", "select"), kinds)
+	}
 	return kinds, err
 }
 
 // CreateKind - create new kind
 func (e *Edb) CreateKind(kind Kind) (int64, error) {
-	stmt, err := e.db.Prepare(`
+	stmt, err := e.prepare(`
 		INSERT INTO
 			kinds (
 				name,
@@ -156,7 +168,7 @@ func (e *Edb) CreateKind(kind Kind) (int64, error) {
 		RETURNING
 			id`)
 	if err != nil {
-		log.Println("CreateKind e.db.Prepare ", err)
+		log.Println("CreateKind e.prepare ", err)
 		return 0, err
 	}
 	err = stmt.QueryRow(s2n(kind.Name), s2n(kind.Note)).Scan(&kind.ID)
@@ -164,12 +176,13 @@ func (e *Edb) CreateKind(kind Kind) (int64, error) {
 		log.Println("CreateKind db.QueryRow ", err)
 		return 0, err
 	}
+	e.InvalidateCache("kinds")
 	return kind.ID, nil
 }
 
 // UpdateKind - save kind changes
 func (e *Edb) UpdateKind(s Kind) error {
-	stmt, err := e.db.Prepare(`
+	stmt, err := e.prepare(`
 		UPDATE
 			kinds
 		SET
@@ -180,13 +193,14 @@ func (e *Edb) UpdateKind(s Kind) error {
 			id = $1
 	`)
 	if err != nil {
-		log.Println("UpdateKind e.db.Prepare ", err)
+		log.Println("UpdateKind e.prepare ", err)
 		return err
 	}
 	_, err = stmt.Exec(i2n(s.ID), s2n(s.Name), s2n(s.Note))
 	if err != nil {
 		log.Println("UpdateKind stmt.Exec ", err)
 	}
+	e.InvalidateCache("kinds")
 	return err
 }
 
@@ -204,6 +218,7 @@ func (e *Edb) DeleteKind(id int64) error {
 	if err != nil {
 		log.Println("DeleteKind e.db.Exec ", id, err)
 	}
+	e.InvalidateCache("kinds")
 	return err
 }
 
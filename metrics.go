@@ -0,0 +1,415 @@
+package epgc
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Observer - receiver of query durations and errors, like Metrics
+type Observer interface {
+	ObserveQuery(method, entity, operation string, duration time.Duration, err error)
+}
+
+// Tracer - starter of spans, epgcotel.Tracer implements it on OpenTelemetry
+type Tracer interface {
+	StartSpan(name string, attrs map[string]string) Span
+}
+
+// Span - started span, End records error and finishes span
+type Span interface {
+	End(err error)
+}
+
+var (
+	queryOperationRe = regexp.MustCompile(`(?i)^\s*(SELECT|INSERT|UPDATE|DELETE|WITH|CREATE|ALTER|DROP|TRUNCATE|BEGIN|COMMIT|ROLLBACK)\b`)
+	queryInsertRe    = regexp.MustCompile(`(?i)\bINTO\s+(\w+)`)
+	queryUpdateRe    = regexp.MustCompile(`(?i)^\s*UPDATE\s+(\w+)`)
+	queryFromRe      = regexp.MustCompile(`(?i)\bFROM\s+(\w+)`)
+	queryTableRe     = regexp.MustCompile(`(?i)\b(?:TABLE|TRIGGER)\s+(?:IF\s+(?:NOT\s+)?EXISTS\s+)?(\w+)`)
+	queryTruncateRe  = regexp.MustCompile(`(?i)^\s*TRUNCATE\s+(?:TABLE\s+)?(\w+)`)
+	queryIDArgRe     = regexp.MustCompile(`(?i)\bid\s*=\s*\$(\d+)`)
+	queryLabels      sync.Map
+)
+
+type queryLabel struct {
+	entity    string
+	operation string
+	// idArg - number of argument compared with id column, 0 when query has no such argument
+	idArg int
+}
+
+// parseQuery - entity (table), operation (sql command) and argument with id of entity of query
+func parseQuery(query string) queryLabel {
+	if label, ok := queryLabels.Load(query); ok {
+		return label.(queryLabel)
+	}
+	var label queryLabel
+	if m := queryOperationRe.FindStringSubmatch(query); m != nil {
+		label.operation = strings.ToLower(m[1])
+	}
+	var m []string
+	switch label.operation {
+	case "insert":
+		m = queryInsertRe.FindStringSubmatch(query)
+	case "update":
+		m = queryUpdateRe.FindStringSubmatch(query)
+	case "create", "alter", "drop":
+		m = queryTableRe.FindStringSubmatch(query)
+	case "truncate":
+		m = queryTruncateRe.FindStringSubmatch(query)
+	case "begin", "commit", "rollback":
+	default:
+		m = queryFromRe.FindStringSubmatch(query)
+	}
+	if m != nil {
+		label.entity = strings.ToLower(m[1])
+	}
+	if m := queryIDArgRe.FindStringSubmatch(query); m != nil {
+		label.idArg, _ = strconv.Atoi(m[1])
+	}
+	queryLabels.Store(query, label)
+	return label
+}
+
+// callerMethod - name of function at depth like "GetCompany"
+func callerMethod(depth int) string {
+	pc, _, _, ok := runtime.Caller(depth + 1)
+	if !ok {
+		return ""
+	}
+	name := runtime.FuncForPC(pc).Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+	parts := strings.Split(name, ".")
+	// package.(*Edb).Method.func1 or package.function
+	for i := len(parts) - 1; i > 0; i-- {
+		if !strings.HasPrefix(parts[i], "func") {
+			return parts[i]
+		}
+	}
+	return name
+}
+
+// argID - id of entity from argument compared with id column, like $1 in "WHERE id = $1"
+func argID(args []interface{}, idArg int) string {
+	if idArg < 1 || idArg > len(args) {
+		return ""
+	}
+	switch id := args[idArg-1].(type) {
+	case int64:
+		return strconv.FormatInt(id, 10)
+	case int:
+		return strconv.Itoa(id)
+	case sql.NullInt64:
+		if id.Valid {
+			return strconv.FormatInt(id.Int64, 10)
+		}
+	}
+	return ""
+}
+
+// instrumentedDB - database with Observer and Tracer hooks on Query, QueryRow, Exec and transactions
+type instrumentedDB struct {
+	*sql.DB
+	observer Observer
+	tracer   Tracer
+}
+
+// instrumentedTx - transaction with hooks of database, queries are observed with method which began transaction
+type instrumentedTx struct {
+	*sql.Tx
+	db     *instrumentedDB
+	method string
+}
+
+// start - begin observation of query called by method at depth 2, returned function finishes it
+func (db *instrumentedDB) start(query string, args []interface{}) func(error) {
+	if db.observer == nil && db.tracer == nil {
+		return func(error) {}
+	}
+	return db.observe(callerMethod(2), query, args)
+}
+
+// observe - begin observation of query called by method, returned function finishes it
+func (db *instrumentedDB) observe(method string, query string, args []interface{}) func(error) {
+	if db.observer == nil && db.tracer == nil {
+		return func(error) {}
+	}
+	label := parseQuery(query)
+	var span Span
+	if db.tracer != nil {
+		attrs := map[string]string{
+			"db.system":    "postgresql",
+			"db.operation": label.operation,
+			"epgc.entity":  label.entity,
+		}
+		if id := argID(args, label.idArg); id != "" {
+			attrs["epgc.id"] = id
+		}
+		span = db.tracer.StartSpan("epgc."+method, attrs)
+	}
+	begin := time.Now()
+	return func(err error) {
+		if err == sql.ErrNoRows {
+			err = nil
+		}
+		if db.observer != nil {
+			db.observer.ObserveQuery(method, label.entity, label.operation, time.Since(begin), err)
+		}
+		if span != nil {
+			span.End(err)
+		}
+	}
+}
+
+func (db *instrumentedDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	done := db.start(query, args)
+	rows, err := db.DB.Query(query, args...)
+	done(err)
+	return rows, err
+}
+
+func (db *instrumentedDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	done := db.start(query, args)
+	row := db.DB.QueryRow(query, args...)
+	done(row.Err())
+	return row
+}
+
+func (db *instrumentedDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	done := db.start(query, args)
+	result, err := db.DB.Exec(query, args...)
+	done(err)
+	return result, err
+}
+
+// Begin - start transaction, queries of transaction are observed with name of method which called Begin
+func (db *instrumentedDB) Begin() (*instrumentedTx, error) {
+	method := ""
+	if db.observer != nil || db.tracer != nil {
+		method = callerMethod(1)
+	}
+	done := db.observe(method, "BEGIN", nil)
+	tx, err := db.DB.Begin()
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedTx{Tx: tx, db: db, method: method}, nil
+}
+
+func (tx *instrumentedTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	done := tx.db.observe(tx.method, query, args)
+	rows, err := tx.Tx.Query(query, args...)
+	done(err)
+	return rows, err
+}
+
+func (tx *instrumentedTx) QueryRow(query string, args ...interface{}) *sql.Row {
+	done := tx.db.observe(tx.method, query, args)
+	row := tx.Tx.QueryRow(query, args...)
+	done(row.Err())
+	return row
+}
+
+func (tx *instrumentedTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	done := tx.db.observe(tx.method, query, args)
+	result, err := tx.Tx.Exec(query, args...)
+	done(err)
+	return result, err
+}
+
+func (tx *instrumentedTx) Commit() error {
+	done := tx.db.observe(tx.method, "COMMIT", nil)
+	err := tx.Tx.Commit()
+	done(err)
+	return err
+}
+
+// Rollback - rollback transaction, rollback of finished transaction is not counted as error
+func (tx *instrumentedTx) Rollback() error {
+	done := tx.db.observe(tx.method, "ROLLBACK", nil)
+	err := tx.Tx.Rollback()
+	if err == sql.ErrTxDone {
+		done(nil)
+	} else {
+		done(err)
+	}
+	return err
+}
+
+// DefaultBuckets - buckets of query duration histogram in seconds
+var DefaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type metricKey struct {
+	method    string
+	entity    string
+	operation string
+}
+
+type metricHistogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Metrics - query duration histograms, error counters and pool stats in Prometheus text format.
+// Use as Observer in WithMetrics and serve on /metrics, or register epgcprom.NewCollector in prometheus registry.
+type Metrics struct {
+	sync.Mutex
+	buckets    []float64
+	histograms map[metricKey]*metricHistogram
+	errors     map[metricKey]uint64
+	stats      func() sql.DBStats
+}
+
+// NewMetrics - metrics with buckets, DefaultBuckets without buckets
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	return &Metrics{
+		buckets:    buckets,
+		histograms: make(map[metricKey]*metricHistogram),
+		errors:     make(map[metricKey]uint64),
+	}
+}
+
+// ObserveQuery - add duration to histogram and count error
+func (m *Metrics) ObserveQuery(method, entity, operation string, duration time.Duration, err error) {
+	key := metricKey{method: method, entity: entity, operation: operation}
+	seconds := duration.Seconds()
+	m.Lock()
+	defer m.Unlock()
+	h, ok := m.histograms[key]
+	if !ok {
+		h = &metricHistogram{counts: make([]uint64, len(m.buckets))}
+		m.histograms[key] = h
+	}
+	for i, bucket := range m.buckets {
+		if seconds <= bucket {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+	if err != nil {
+		m.errors[key]++
+	}
+}
+
+// QueryStat - duration histogram and errors of queries with same method, entity and operation
+type QueryStat struct {
+	Method    string
+	Entity    string
+	Operation string
+	// Buckets - cumulative number of queries by upper bound of duration in seconds
+	Buckets map[float64]uint64
+	Sum     float64
+	Count   uint64
+	Errors  uint64
+}
+
+func (stat QueryStat) labels() string {
+	return fmt.Sprintf(`method="%s",entity="%s",operation="%s"`, stat.Method, stat.Entity, stat.Operation)
+}
+
+// Stats - snapshot of query stats sorted by method, entity and operation, it is used by WriteTo
+// and by adapters like prometheus.Collector in epgcprom
+func (m *Metrics) Stats() []QueryStat {
+	m.Lock()
+	defer m.Unlock()
+	stats := make([]QueryStat, 0, len(m.histograms))
+	for key, h := range m.histograms {
+		stat := QueryStat{
+			Method:    key.method,
+			Entity:    key.entity,
+			Operation: key.operation,
+			Buckets:   make(map[float64]uint64, len(m.buckets)),
+			Sum:       h.sum,
+			Count:     h.count,
+			Errors:    m.errors[key],
+		}
+		for i, bucket := range m.buckets {
+			stat.Buckets[bucket] = h.counts[i]
+		}
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].labels() < stats[j].labels()
+	})
+	return stats
+}
+
+// Buckets - upper bounds of duration histogram in seconds
+func (m *Metrics) Buckets() []float64 {
+	return append([]float64(nil), m.buckets...)
+}
+
+// PoolStats - stats of connection pool, false when Metrics is not set by WithMetrics
+func (m *Metrics) PoolStats() (sql.DBStats, bool) {
+	m.Lock()
+	stats := m.stats
+	m.Unlock()
+	if stats == nil {
+		return sql.DBStats{}, false
+	}
+	return stats(), true
+}
+
+// WriteTo - write metrics in Prometheus text exposition format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	stats := m.Stats()
+	b.WriteString("# HELP epgc_query_duration_seconds Duration of epgc queries.\n")
+	b.WriteString("# TYPE epgc_query_duration_seconds histogram\n")
+	for _, stat := range stats {
+		for _, bucket := range m.buckets {
+			fmt.Fprintf(&b, "epgc_query_duration_seconds_bucket{%s,le=\"%g\"} %d\n", stat.labels(), bucket, stat.Buckets[bucket])
+		}
+		fmt.Fprintf(&b, "epgc_query_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", stat.labels(), stat.Count)
+		fmt.Fprintf(&b, "epgc_query_duration_seconds_sum{%s} %g\n", stat.labels(), stat.Sum)
+		fmt.Fprintf(&b, "epgc_query_duration_seconds_count{%s} %d\n", stat.labels(), stat.Count)
+	}
+	b.WriteString("# HELP epgc_query_errors_total Errors of epgc queries.\n")
+	b.WriteString("# TYPE epgc_query_errors_total counter\n")
+	for _, stat := range stats {
+		if stat.Errors > 0 {
+			fmt.Fprintf(&b, "epgc_query_errors_total{%s} %d\n", stat.labels(), stat.Errors)
+		}
+	}
+	if s, ok := m.PoolStats(); ok {
+		gauges := []struct {
+			name  string
+			help  string
+			kind  string
+			value float64
+		}{
+			{"epgc_pool_max_open_connections", "Maximum number of open connections.", "gauge", float64(s.MaxOpenConnections)},
+			{"epgc_pool_open_connections", "Number of open connections.", "gauge", float64(s.OpenConnections)},
+			{"epgc_pool_in_use_connections", "Number of connections in use.", "gauge", float64(s.InUse)},
+			{"epgc_pool_idle_connections", "Number of idle connections.", "gauge", float64(s.Idle)},
+			{"epgc_pool_wait_count_total", "Number of waits for connection.", "counter", float64(s.WaitCount)},
+			{"epgc_pool_wait_duration_seconds_total", "Time blocked waiting for connection.", "counter", s.WaitDuration.Seconds()},
+		}
+		for _, g := range gauges {
+			fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", g.name, g.help, g.name, g.kind, g.name, g.value)
+		}
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// ServeHTTP - serve metrics for Prometheus scraper
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = m.WriteTo(w)
+}
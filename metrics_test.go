@@ -0,0 +1,166 @@
+package epgc
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  queryLabel
+	}{
+		{`SELECT id, name FROM companies WHERE id = $1`, queryLabel{entity: "companies", operation: "select", idArg: 1}},
+		{`
+			SELECT
+				c.id,
+				c.name
+			FROM
+				contacts AS c
+			WHERE
+				c.id = $1
+		`, queryLabel{entity: "contacts", operation: "select", idArg: 1}},
+		{`INSERT INTO contacts (name, company_id) VALUES ($1, $2) RETURNING id`, queryLabel{entity: "contacts", operation: "insert"}},
+		{`UPDATE companies SET name = $2, updated_at = now() WHERE id = $1`, queryLabel{entity: "companies", operation: "update", idArg: 1}},
+		{`UPDATE phones SET contact_id = $1 WHERE contact_id = $2`, queryLabel{entity: "phones", operation: "update"}},
+		{`DELETE FROM emails WHERE company_id = $1`, queryLabel{entity: "emails", operation: "delete"}},
+		{`DELETE FROM sirens WHERE id = $2 AND company_id = $1`, queryLabel{entity: "sirens", operation: "delete", idArg: 2}},
+		{`CREATE TABLE IF NOT EXISTS ranks (id bigserial primary key)`, queryLabel{entity: "ranks", operation: "create"}},
+		{`ALTER TABLE contacts ADD COLUMN IF NOT EXISTS surname text`, queryLabel{entity: "contacts", operation: "alter"}},
+		{`TRUNCATE contacts, companies RESTART IDENTITY`, queryLabel{entity: "contacts", operation: "truncate"}},
+		{`WITH RECURSIVE tree AS (SELECT id FROM companies) SELECT id FROM tree`, queryLabel{entity: "companies", operation: "with"}},
+		{`COMMIT`, queryLabel{operation: "commit"}},
+		{`select 1`, queryLabel{operation: "select"}},
+	}
+	for _, tt := range tests {
+		if got := parseQuery(tt.query); got != tt.want {
+			t.Errorf("parseQuery(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestArgID(t *testing.T) {
+	tests := []struct {
+		args  []interface{}
+		idArg int
+		want  string
+	}{
+		{nil, 1, ""},
+		{[]interface{}{int64(7)}, 0, ""},
+		{[]interface{}{"ООО Ромашка", int64(7)}, 0, ""},
+		{[]interface{}{int64(7), "ООО Ромашка"}, 1, "7"},
+		{[]interface{}{"ООО Ромашка", int64(7)}, 2, "7"},
+		{[]interface{}{sql.NullInt64{Int64: 8, Valid: true}}, 1, "8"},
+		{[]interface{}{sql.NullInt64{}}, 1, ""},
+		{[]interface{}{9}, 1, "9"},
+		{[]interface{}{int64(7)}, 2, ""},
+	}
+	for _, tt := range tests {
+		if got := argID(tt.args, tt.idArg); got != tt.want {
+			t.Errorf("argID(%v, %d) = %q, want %q", tt.args, tt.idArg, got, tt.want)
+		}
+	}
+}
+
+func TestMetricsWriteTo(t *testing.T) {
+	m := NewMetrics(0.01, 0.1)
+	m.ObserveQuery("GetCompany", "companies", "select", 5*time.Millisecond, nil)
+	m.ObserveQuery("GetCompany", "companies", "select", 50*time.Millisecond, errors.New("failed"))
+	m.ObserveQuery("CreateContact", "contacts", "insert", time.Second, nil)
+	var b strings.Builder
+	n, err := m.WriteTo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := b.String()
+	if n != int64(len(got)) {
+		t.Errorf("WriteTo returned %d, wrote %d bytes", n, len(got))
+	}
+	want := `# HELP epgc_query_duration_seconds Duration of epgc queries.
+# TYPE epgc_query_duration_seconds histogram
+epgc_query_duration_seconds_bucket{method="CreateContact",entity="contacts",operation="insert",le="0.01"} 0
+epgc_query_duration_seconds_bucket{method="CreateContact",entity="contacts",operation="insert",le="0.1"} 0
+epgc_query_duration_seconds_bucket{method="CreateContact",entity="contacts",operation="insert",le="+Inf"} 1
+epgc_query_duration_seconds_sum{method="CreateContact",entity="contacts",operation="insert"} 1
+epgc_query_duration_seconds_count{method="CreateContact",entity="contacts",operation="insert"} 1
+epgc_query_duration_seconds_bucket{method="GetCompany",entity="companies",operation="select",le="0.01"} 1
+epgc_query_duration_seconds_bucket{method="GetCompany",entity="companies",operation="select",le="0.1"} 2
+epgc_query_duration_seconds_bucket{method="GetCompany",entity="companies",operation="select",le="+Inf"} 2
+epgc_query_duration_seconds_sum{method="GetCompany",entity="companies",operation="select"} 0.055
+epgc_query_duration_seconds_count{method="GetCompany",entity="companies",operation="select"} 2
+# HELP epgc_query_errors_total Errors of epgc queries.
+# TYPE epgc_query_errors_total counter
+epgc_query_errors_total{method="GetCompany",entity="companies",operation="select"} 1
+`
+	if got != want {
+		t.Errorf("WriteTo =\n%s\nwant\n%s", got, want)
+	}
+}
+
+type testSpan struct {
+	name  string
+	attrs map[string]string
+	err   error
+	ended bool
+}
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (t *testTracer) StartSpan(name string, attrs map[string]string) Span {
+	span := &testSpan{name: name, attrs: attrs}
+	t.spans = append(t.spans, span)
+	return span
+}
+
+func (s *testSpan) End(err error) {
+	s.err = err
+	s.ended = true
+}
+
+// mergeTest - method with transaction for TestTxObserved
+func mergeTest(e *Edb) error {
+	tx, err := e.db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE contacts SET name = $2 WHERE id = $1`, int64(5), "Иванов")
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func TestTxObserved(t *testing.T) {
+	e, _ := staleEdb(t)
+	m := NewMetrics()
+	tracer := &testTracer{}
+	e.db.observer = m
+	e.db.tracer = tracer
+	err := mergeTest(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var operations []string
+	for _, stat := range m.Stats() {
+		if stat.Method != "mergeTest" || stat.Count != 1 {
+			t.Errorf("stat = %+v, want one query of mergeTest", stat)
+		}
+		operations = append(operations, stat.Operation)
+	}
+	if strings.Join(operations, ",") != "begin,commit,update" {
+		t.Errorf("operations = %v", operations)
+	}
+	if len(tracer.spans) != 3 {
+		t.Fatalf("spans = %d, want 3", len(tracer.spans))
+	}
+	update := tracer.spans[1]
+	if update.name != "epgc.mergeTest" || update.attrs["epgc.entity"] != "contacts" || update.attrs["epgc.id"] != "5" || !update.ended {
+		t.Errorf("update span = %+v", update)
+	}
+}
@@ -0,0 +1,175 @@
+package epgc
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// PersonName - russian full name split to surname, first name and patronymic
+type PersonName struct {
+	Surname    string `json:"surname"`
+	FirstName  string `json:"first_name"`
+	Patronymic string `json:"patronymic"`
+}
+
+var patronymicSuffixes = []string{"ович", "евич", "ьич", "ична", "овна", "евна", "оглы", "кызы", "улы"}
+
+func isPatronymic(word string) bool {
+	word = strings.ToLower(word)
+	for _, suffix := range patronymicSuffixes {
+		if strings.HasSuffix(word, suffix) && utf8.RuneCountInString(word) > utf8.RuneCountInString(suffix)+1 {
+			return true
+		}
+	}
+	return false
+}
+
+// isInitials - word like "И." or "И.И."
+func isInitials(word string) bool {
+	if !strings.HasSuffix(word, ".") {
+		return false
+	}
+	for _, part := range strings.Split(strings.TrimSuffix(word, "."), ".") {
+		if utf8.RuneCountInString(part) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func splitInitials(word string) (string, string) {
+	parts := strings.Split(strings.TrimSuffix(word, "."), ".")
+	first := parts[0] + "."
+	if len(parts) > 1 {
+		return first, parts[1] + "."
+	}
+	return first, ""
+}
+
+func firstLetter(word string) string {
+	r, _ := utf8.DecodeRuneInString(word)
+	if r == utf8.RuneError {
+		return ""
+	}
+	return strings.ToUpper(string(r))
+}
+
+// ParseName - split full name like "Иванов Иван Иванович", "Иван Иванович Иванов", "Иванов И.И." or "И.И. Иванов"
+func ParseName(full string) PersonName {
+	var name PersonName
+	words := strings.Fields(full)
+	// join patronymic parts like "Ахмед оглы"
+	for i := 1; i < len(words); i++ {
+		low := strings.ToLower(words[i])
+		if low == "оглы" || low == "кызы" || low == "улы" {
+			words[i-1] = words[i-1] + " " + words[i]
+			words = append(words[:i], words[i+1:]...)
+			i--
+		}
+	}
+	switch len(words) {
+	case 0:
+	case 1:
+		name.Surname = words[0]
+	case 2:
+		switch {
+		case isInitials(words[1]):
+			name.Surname = words[0]
+			name.FirstName, name.Patronymic = splitInitials(words[1])
+		case isInitials(words[0]):
+			name.Surname = words[1]
+			name.FirstName, name.Patronymic = splitInitials(words[0])
+		case isPatronymic(words[1]):
+			name.FirstName = words[0]
+			name.Patronymic = words[1]
+		default:
+			name.Surname = words[0]
+			name.FirstName = words[1]
+		}
+	default:
+		switch {
+		case isInitials(words[0]) && isInitials(words[1]):
+			name.FirstName = words[0]
+			name.Patronymic = words[1]
+			name.Surname = strings.Join(words[2:], " ")
+		case isInitials(words[1]) && isInitials(words[2]) && len(words) == 3:
+			name.Surname = words[0]
+			name.FirstName = words[1]
+			name.Patronymic = words[2]
+		case isPatronymic(words[1]) && !isPatronymic(words[len(words)-1]):
+			name.FirstName = words[0]
+			name.Patronymic = words[1]
+			name.Surname = strings.Join(words[2:], " ")
+		default:
+			name.Surname = words[0]
+			name.FirstName = words[1]
+			name.Patronymic = strings.Join(words[2:], " ")
+		}
+	}
+	return name
+}
+
+// Full - full name like "Иванов Иван Иванович"
+func (n PersonName) Full() string {
+	if isInitials(n.FirstName) && isInitials(n.Patronymic) {
+		return strings.TrimSpace(n.Surname + " " + n.FirstName + n.Patronymic)
+	}
+	return strings.Join(strings.Fields(n.Surname+" "+n.FirstName+" "+n.Patronymic), " ")
+}
+
+func (n PersonName) initials() string {
+	var result string
+	if n.FirstName != "" {
+		result = firstLetter(n.FirstName) + "."
+		if n.Patronymic != "" {
+			result += firstLetter(n.Patronymic) + "."
+		}
+	}
+	return result
+}
+
+// Initials - surname with initials like "Иванов И.И."
+func (n PersonName) Initials() string {
+	return strings.TrimSpace(n.Surname + " " + n.initials())
+}
+
+// Short - initials before surname like "И.И. Иванов"
+func (n PersonName) Short() string {
+	return strings.TrimSpace(n.initials() + " " + n.Surname)
+}
+
+// PersonName - structured name of contact, parsed from Name when fields are empty
+func (c Contact) PersonName() PersonName {
+	if c.Surname == "" && c.FirstName == "" && c.Patronymic == "" {
+		return ParseName(c.Name)
+	}
+	return PersonName{Surname: c.Surname, FirstName: c.FirstName, Patronymic: c.Patronymic}
+}
+
+// Initials - contact name like "Иванов И.И."
+func (c Contact) Initials() string {
+	return c.PersonName().Initials()
+}
+
+// resetNameParts - clear surname, first name and patronymic left from stored name when only name was changed,
+// so setNameParts parses new name
+func (c *Contact) resetNameParts(storedName string, stored PersonName) {
+	if c.Name == storedName || c.Surname != stored.Surname || c.FirstName != stored.FirstName || c.Patronymic != stored.Patronymic {
+		return
+	}
+	c.Surname = ""
+	c.FirstName = ""
+	c.Patronymic = ""
+}
+
+// setNameParts - fill empty surname, first name and patronymic from name or name from parts
+func (c *Contact) setNameParts() {
+	if c.Surname == "" && c.FirstName == "" && c.Patronymic == "" {
+		name := ParseName(c.Name)
+		c.Surname = name.Surname
+		c.FirstName = name.FirstName
+		c.Patronymic = name.Patronymic
+	} else if strings.TrimSpace(c.Name) == "" {
+		c.Name = c.PersonName().Full()
+	}
+}
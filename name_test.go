@@ -0,0 +1,85 @@
+package epgc
+
+import "testing"
+
+func TestParseName(t *testing.T) {
+	tests := []struct {
+		full string
+		want PersonName
+	}{
+		{"", PersonName{}},
+		{"Иванов", PersonName{Surname: "Иванов"}},
+		{"Иванов Иван", PersonName{Surname: "Иванов", FirstName: "Иван"}},
+		{"Иван Иванович", PersonName{FirstName: "Иван", Patronymic: "Иванович"}},
+		{"Иванов Иван Иванович", PersonName{Surname: "Иванов", FirstName: "Иван", Patronymic: "Иванович"}},
+		{"  Иванов   Иван  Иванович ", PersonName{Surname: "Иванов", FirstName: "Иван", Patronymic: "Иванович"}},
+		{"Иван Иванович Иванов", PersonName{Surname: "Иванов", FirstName: "Иван", Patronymic: "Иванович"}},
+		{"Иванова Мария Петровна", PersonName{Surname: "Иванова", FirstName: "Мария", Patronymic: "Петровна"}},
+		{"Иванов И.И.", PersonName{Surname: "Иванов", FirstName: "И.", Patronymic: "И."}},
+		{"Иванов И.", PersonName{Surname: "Иванов", FirstName: "И."}},
+		{"И.И. Иванов", PersonName{Surname: "Иванов", FirstName: "И.", Patronymic: "И."}},
+		{"Иванов И. И.", PersonName{Surname: "Иванов", FirstName: "И.", Patronymic: "И."}},
+		{"И. И. Иванов", PersonName{Surname: "Иванов", FirstName: "И.", Patronymic: "И."}},
+		{"Алиев Ахмед Мамед оглы", PersonName{Surname: "Алиев", FirstName: "Ахмед", Patronymic: "Мамед оглы"}},
+		{"Римский-Корсаков Николай Андреевич", PersonName{Surname: "Римский-Корсаков", FirstName: "Николай", Patronymic: "Андреевич"}},
+	}
+	for _, tt := range tests {
+		if got := ParseName(tt.full); got != tt.want {
+			t.Errorf("ParseName(%q) = %+v, want %+v", tt.full, got, tt.want)
+		}
+	}
+}
+
+func TestPersonNameFormats(t *testing.T) {
+	name := PersonName{Surname: "Иванов", FirstName: "Иван", Patronymic: "Иванович"}
+	if got := name.Full(); got != "Иванов Иван Иванович" {
+		t.Errorf("Full = %q", got)
+	}
+	if got := name.Initials(); got != "Иванов И.И." {
+		t.Errorf("Initials = %q", got)
+	}
+	if got := name.Short(); got != "И.И. Иванов" {
+		t.Errorf("Short = %q", got)
+	}
+	if got := (PersonName{Surname: "Иванов", FirstName: "И.", Patronymic: "И."}).Full(); got != "Иванов И.И." {
+		t.Errorf("Full of initials = %q", got)
+	}
+}
+
+func TestResetNameParts(t *testing.T) {
+	stored := PersonName{Surname: "Иванов", FirstName: "Иван", Patronymic: "Иванович"}
+	tests := []struct {
+		name    string
+		contact Contact
+		want    PersonName
+	}{
+		{
+			"renamed",
+			Contact{Name: "Петров Пётр Петрович", Surname: "Иванов", FirstName: "Иван", Patronymic: "Иванович"},
+			PersonName{Surname: "Петров", FirstName: "Пётр", Patronymic: "Петрович"},
+		},
+		{
+			"same name",
+			Contact{Name: "Иванов Иван Иванович", Surname: "Иванов", FirstName: "Иван", Patronymic: "Иванович"},
+			stored,
+		},
+		{
+			"parts changed with name",
+			Contact{Name: "Петров-Водкин Пётр", Surname: "Петров-Водкин", FirstName: "Пётр"},
+			PersonName{Surname: "Петров-Водкин", FirstName: "Пётр"},
+		},
+		{
+			"only parts changed",
+			Contact{Name: "Иванов Иван Иванович", Surname: "Иванов", FirstName: "Иоанн", Patronymic: "Иванович"},
+			PersonName{Surname: "Иванов", FirstName: "Иоанн", Patronymic: "Иванович"},
+		},
+	}
+	for _, tt := range tests {
+		contact := tt.contact
+		contact.resetNameParts("Иванов Иван Иванович", stored)
+		contact.setNameParts()
+		if got := contact.PersonName(); got != tt.want {
+			t.Errorf("%s: name parts = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,704 @@
+package epgc
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Notification channels, empty channel is ChannelAll
+const (
+	ChannelAll   = "all"
+	ChannelPhone = "phone"
+	ChannelFax   = "fax"
+	ChannelEmail = "email"
+)
+
+// ErrNotificationMember - member of notification list or node of call tree must be either contact or company
+var ErrNotificationMember = errors.New("member must be either contact or company")
+
+// ErrNotificationChannel - unknown notification channel
+var ErrNotificationChannel = errors.New("unknown notification channel")
+
+// NotificationList - ordered group of contacts and companies alerted together
+type NotificationList struct {
+	ID        int64                `sql:"id" json:"id"`
+	Name      string               `sql:"name" json:"name"`
+	Note      string               `sql:"note, null" json:"note"`
+	Members   []NotificationMember `sql:"-" json:"members"`
+	CreatedAt string               `sql:"created_at" json:"created_at"`
+	UpdatedAt string               `sql:"updated_at" json:"updated_at"`
+}
+
+// NotificationMember - contact or company in notification list. Members with same priority are alerted together
+// starting from lowest priority, when nobody confirms in EscalateAfter minutes next priority is alerted
+type NotificationMember struct {
+	ID            int64  `sql:"id" json:"id"`
+	ListID        int64  `sql:"list_id" json:"list_id"`
+	ContactID     int64  `sql:"contact_id, null" json:"contact_id"`
+	CompanyID     int64  `sql:"company_id, null" json:"company_id"`
+	Priority      int64  `sql:"priority" json:"priority"`
+	Channel       string `sql:"channel" json:"channel"`
+	EscalateAfter int64  `sql:"escalate_after, null" json:"escalate_after"`
+	Name          string `sql:"-" json:"name"`
+}
+
+// NotificationTarget - contact or company of resolved notification list with its phones, faxes and emails
+type NotificationTarget struct {
+	ContactID     int64  `json:"contact_id"`
+	CompanyID     int64  `json:"company_id"`
+	Name          string `json:"name"`
+	Priority      int64  `json:"priority"`
+	EscalateAfter int64  `json:"escalate_after"`
+	Channel       string `json:"channel"`
+	// Level - level in call tree, NotifiedBy - name of caller, both are empty for members of list
+	Level      int64    `json:"level"`
+	NotifiedBy string   `json:"notified_by"`
+	Phones     []int64  `json:"phones"`
+	Faxes      []int64  `json:"faxes"`
+	Emails     []string `json:"emails"`
+}
+
+// NotificationResolution - targets of notification list and all their phones, faxes and emails without duplicates
+type NotificationResolution struct {
+	ListID  int64                `json:"list_id"`
+	Name    string               `json:"name"`
+	Targets []NotificationTarget `json:"targets"`
+	Phones  []int64              `json:"phones"`
+	Faxes   []int64              `json:"faxes"`
+	Emails  []string             `json:"emails"`
+}
+
+func checkChannel(channel string) (string, error) {
+	switch channel {
+	case "":
+		return ChannelAll, nil
+	case ChannelAll, ChannelPhone, ChannelFax, ChannelEmail:
+		return channel, nil
+	}
+	return channel, ErrNotificationChannel
+}
+
+func scanNotificationList(row *sql.Row) (NotificationList, error) {
+	var (
+		sID   sql.NullInt64
+		sName sql.NullString
+		sNote sql.NullString
+		list  NotificationList
+	)
+	err := row.Scan(&sID, &sName, &sNote)
+	if err != nil {
+		log.Println("scanNotificationList row.Scan ", err)
+		return list, err
+	}
+	list.ID = n2i(sID)
+	list.Name = n2s(sName)
+	list.Note = n2s(sNote)
+	return list, nil
+}
+
+func scanNotificationLists(rows *sql.Rows) ([]NotificationList, error) {
+	var lists []NotificationList
+	for rows.Next() {
+		var (
+			sID   sql.NullInt64
+			sName sql.NullString
+			sNote sql.NullString
+			list  NotificationList
+		)
+		err := rows.Scan(&sID, &sName, &sNote)
+		if err != nil {
+			log.Println("scanNotificationLists rows.Scan ", err)
+			return lists, err
+		}
+		list.ID = n2i(sID)
+		list.Name = n2s(sName)
+		list.Note = n2s(sNote)
+		lists = append(lists, list)
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Println("scanNotificationLists rows.Err ", err)
+	}
+	return lists, err
+}
+
+func scanNotificationMembers(rows *sql.Rows) ([]NotificationMember, error) {
+	var members []NotificationMember
+	for rows.Next() {
+		var (
+			sID            sql.NullInt64
+			sListID        sql.NullInt64
+			sContactID     sql.NullInt64
+			sCompanyID     sql.NullInt64
+			sPriority      sql.NullInt64
+			sChannel       sql.NullString
+			sEscalateAfter sql.NullInt64
+			sName          sql.NullString
+			member         NotificationMember
+		)
+		err := rows.Scan(&sID, &sListID, &sContactID, &sCompanyID, &sPriority, &sChannel, &sEscalateAfter, &sName)
+		if err != nil {
+			log.Println("scanNotificationMembers rows.Scan ", err)
+			return members, err
+		}
+		member.ID = n2i(sID)
+		member.ListID = n2i(sListID)
+		member.ContactID = n2i(sContactID)
+		member.CompanyID = n2i(sCompanyID)
+		member.Priority = n2i(sPriority)
+		member.Channel = n2s(sChannel)
+		member.EscalateAfter = n2i(sEscalateAfter)
+		member.Name = n2s(sName)
+		members = append(members, member)
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Println("scanNotificationMembers rows.Err ", err)
+	}
+	return members, err
+}
+
+// GetNotificationList - get one notification list with members by id
+func (e *Edb) GetNotificationList(id int64) (NotificationList, error) {
+	if id == 0 {
+		return NotificationList{}, nil
+	}
+	row := e.db.QueryRow(`
+		SELECT
+			id,
+			name,
+			note
+		FROM
+			notification_lists
+		WHERE
+			id = $1
+	`, id)
+	list, err := scanNotificationList(row)
+	if err != nil {
+		return list, err
+	}
+	list.Members, err = e.GetNotificationMembers(id)
+	return list, err
+}
+
+// GetNotificationLists - get all notification lists without members
+func (e *Edb) GetNotificationLists() ([]NotificationList, error) {
+	rows, err := e.db.Query(`
+		SELECT
+			id,
+			name,
+			note
+		FROM
+			notification_lists
+		ORDER BY
+			name ASC
+	`)
+	if err != nil {
+		log.Println("GetNotificationLists e.db.Query ", err)
+		return []NotificationList{}, err
+	}
+	return scanNotificationLists(rows)
+}
+
+// GetNotificationListSelect - get all notification lists for select
+func (e *Edb) GetNotificationListSelect() ([]SelectItem, error) {
+	lists, err := e.GetNotificationLists()
+	items := make([]SelectItem, 0, len(lists))
+	for _, list := range lists {
+		items = append(items, SelectItem{ID: list.ID, Name: list.Name})
+	}
+	return items, err
+}
+
+// GetNotificationMembers - get members of notification list in order of priority
+func (e *Edb) GetNotificationMembers(listID int64) ([]NotificationMember, error) {
+	rows, err := e.db.Query(`
+		SELECT
+			m.id,
+			m.list_id,
+			m.contact_id,
+			m.company_id,
+			m.priority,
+			m.channel,
+			m.escalate_after,
+			COALESCE(c.name, o.name)
+		FROM
+			notification_members AS m
+		LEFT JOIN
+			contacts AS c ON c.id = m.contact_id
+		LEFT JOIN
+			companies AS o ON o.id = m.company_id
+		WHERE
+			m.list_id = $1
+		ORDER BY
+			m.priority ASC,
+			m.id ASC
+	`, listID)
+	if err != nil {
+		log.Println("GetNotificationMembers e.db.Query ", err)
+		return []NotificationMember{}, err
+	}
+	return scanNotificationMembers(rows)
+}
+
+// CreateNotificationList - create new notification list with members
+func (e *Edb) CreateNotificationList(list NotificationList) (int64, error) {
+	err := checkNotificationMembers(&list)
+	if err != nil {
+		log.Println("CreateNotificationList checkNotificationMembers ", err)
+		return 0, err
+	}
+	tx, err := e.db.Begin()
+	if err != nil {
+		log.Println("CreateNotificationList e.db.Begin ", err)
+		return 0, err
+	}
+	err = tx.QueryRow(`
+		INSERT INTO
+			notification_lists (
+				name,
+				note,
+				created_at
+			)
+		VALUES (
+			$1,
+			$2,
+			now()
+		)
+		RETURNING
+			id
+	`, s2n(list.Name), s2n(list.Note)).Scan(&list.ID)
+	if err != nil {
+		log.Println("CreateNotificationList tx.QueryRow ", err)
+		_ = tx.Rollback()
+		return 0, err
+	}
+	err = saveNotificationMembers(tx, list)
+	if err != nil {
+		return 0, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Println("CreateNotificationList tx.Commit ", err)
+		return 0, err
+	}
+	return list.ID, nil
+}
+
+// UpdateNotificationList - save notification list changes, members are replaced
+func (e *Edb) UpdateNotificationList(list NotificationList) error {
+	err := checkNotificationMembers(&list)
+	if err != nil {
+		log.Println("UpdateNotificationList checkNotificationMembers ", err)
+		return err
+	}
+	tx, err := e.db.Begin()
+	if err != nil {
+		log.Println("UpdateNotificationList e.db.Begin ", err)
+		return err
+	}
+	_, err = tx.Exec(`
+		UPDATE
+			notification_lists
+		SET
+			name = $2,
+			note = $3,
+			updated_at = now()
+		WHERE
+			id = $1
+	`, i2n(list.ID), s2n(list.Name), s2n(list.Note))
+	if err != nil {
+		log.Println("UpdateNotificationList tx.Exec ", err)
+		_ = tx.Rollback()
+		return err
+	}
+	err = saveNotificationMembers(tx, list)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Println("UpdateNotificationList tx.Commit ", err)
+	}
+	return err
+}
+
+// checkNotificationMembers - every member must be contact or company with known channel, channels are
+// normalized in copy of members, so slice of caller is not changed
+func checkNotificationMembers(list *NotificationList) error {
+	members := make([]NotificationMember, len(list.Members))
+	copy(members, list.Members)
+	for i, member := range members {
+		if (member.ContactID == 0) == (member.CompanyID == 0) {
+			return ErrNotificationMember
+		}
+		channel, err := checkChannel(member.Channel)
+		if err != nil {
+			return err
+		}
+		members[i].Channel = channel
+	}
+	list.Members = members
+	return nil
+}
+
+// saveNotificationMembers - replace members of list in transaction, transaction is rolled back on error
+func saveNotificationMembers(tx *instrumentedTx, list NotificationList) error {
+	_, err := tx.Exec(`DELETE FROM notification_members WHERE list_id = $1`, list.ID)
+	if err != nil {
+		log.Println("saveNotificationMembers tx.Exec ", err)
+		_ = tx.Rollback()
+		return err
+	}
+	for _, member := range list.Members {
+		_, err = tx.Exec(`
+			INSERT INTO
+				notification_members (
+					list_id,
+					contact_id,
+					company_id,
+					priority,
+					channel,
+					escalate_after,
+					created_at
+				)
+			VALUES (
+				$1,
+				$2,
+				$3,
+				$4,
+				$5,
+				$6,
+				now()
+			)
+		`, list.ID, i2n(member.ContactID), i2n(member.CompanyID), member.Priority, member.Channel, i2n(member.EscalateAfter))
+		if err != nil {
+			log.Println("saveNotificationMembers tx.Exec ", err)
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteNotificationList - delete notification list with members and call tree
+func (e *Edb) DeleteNotificationList(id int64) error {
+	if id == 0 {
+		return nil
+	}
+	for _, query := range []string{
+		`DELETE FROM call_tree_nodes WHERE list_id = $1`,
+		`DELETE FROM notification_members WHERE list_id = $1`,
+		`DELETE FROM notification_lists WHERE id = $1`,
+	} {
+		_, err := e.db.Exec(query, id)
+		if err != nil {
+			log.Println("DeleteNotificationList e.db.Exec ", id, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteNotificationTargets - remove deleted contact or company from notification lists and call trees
+func (e *Edb) deleteNotificationTargets(owner string, id int64) error {
+	for _, query := range []string{`
+		DELETE FROM
+			notification_members
+		WHERE
+			` + owner + ` = $1
+	`, `
+		UPDATE
+			call_tree_nodes AS c
+		SET
+			parent_id = d.parent_id,
+			updated_at = now()
+		FROM
+			call_tree_nodes AS d
+		WHERE
+			c.parent_id = d.id AND d.` + owner + ` = $1
+	`, `
+		DELETE FROM
+			call_tree_nodes
+		WHERE
+			` + owner + ` = $1
+	`} {
+		_, err := e.db.Exec(query, id)
+		if err != nil {
+			log.Println("deleteNotificationTargets e.db.Exec ", owner, id, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// notificationChannels - phones, faxes and emails of contacts and companies by target key
+type notificationChannels struct {
+	phones map[string][]int64
+	faxes  map[string][]int64
+	emails map[string][]string
+}
+
+func targetKey(contactID, companyID int64) string {
+	if contactID != 0 {
+		return "contact:" + strconv.FormatInt(contactID, 10)
+	}
+	return "company:" + strconv.FormatInt(companyID, 10)
+}
+
+func (e *Edb) getNotificationChannels(contactIDs, companyIDs []int64) (notificationChannels, error) {
+	channels := notificationChannels{
+		phones: make(map[string][]int64),
+		faxes:  make(map[string][]int64),
+		emails: make(map[string][]string),
+	}
+	rows, err := e.db.Query(`
+		SELECT
+			contact_id,
+			company_id,
+			phone,
+			fax
+		FROM
+			phones
+		WHERE
+			contact_id = ANY($1) OR (contact_id IS NULL AND company_id = ANY($2))
+		ORDER BY
+			id ASC
+	`, pq.Array(contactIDs), pq.Array(companyIDs))
+	if err != nil {
+		log.Println("getNotificationChannels e.db.Query ", err)
+		return channels, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			sContactID sql.NullInt64
+			sCompanyID sql.NullInt64
+			sPhone     sql.NullInt64
+			sFax       sql.NullBool
+		)
+		err = rows.Scan(&sContactID, &sCompanyID, &sPhone, &sFax)
+		if err != nil {
+			log.Println("getNotificationChannels rows.Scan ", err)
+			return channels, err
+		}
+		key := targetKey(n2i(sContactID), n2i(sCompanyID))
+		if sFax.Bool {
+			channels.faxes[key] = append(channels.faxes[key], n2i(sPhone))
+		} else {
+			channels.phones[key] = append(channels.phones[key], n2i(sPhone))
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Println("getNotificationChannels rows.Err ", err)
+		return channels, err
+	}
+	emailRows, err := e.db.Query(`
+		SELECT
+			contact_id,
+			company_id,
+			email
+		FROM
+			emails
+		WHERE
+			contact_id = ANY($1) OR (contact_id IS NULL AND company_id = ANY($2))
+		ORDER BY
+			id ASC
+	`, pq.Array(contactIDs), pq.Array(companyIDs))
+	if err != nil {
+		log.Println("getNotificationChannels e.db.Query ", err)
+		return channels, err
+	}
+	defer emailRows.Close()
+	for emailRows.Next() {
+		var (
+			sContactID sql.NullInt64
+			sCompanyID sql.NullInt64
+			sEmail     sql.NullString
+		)
+		err = emailRows.Scan(&sContactID, &sCompanyID, &sEmail)
+		if err != nil {
+			log.Println("getNotificationChannels rows.Scan ", err)
+			return channels, err
+		}
+		key := targetKey(n2i(sContactID), n2i(sCompanyID))
+		channels.emails[key] = append(channels.emails[key], n2s(sEmail))
+	}
+	err = emailRows.Err()
+	if err != nil {
+		log.Println("getNotificationChannels rows.Err ", err)
+	}
+	return channels, err
+}
+
+// resolveTargets - drop repeated contacts and companies, fill channels of targets and drop phones, faxes
+// and emails already used by previous targets
+func resolveTargets(targets []NotificationTarget, channels notificationChannels) NotificationResolution {
+	resolution := NotificationResolution{
+		Targets: []NotificationTarget{},
+		Phones:  []int64{},
+		Faxes:   []int64{},
+		Emails:  []string{},
+	}
+	seen := make(map[string]bool)
+	phones := make(map[int64]bool)
+	faxes := make(map[int64]bool)
+	emails := make(map[string]bool)
+	for _, target := range targets {
+		key := targetKey(target.ContactID, target.CompanyID)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		target.Phones, target.Faxes, target.Emails = []int64{}, []int64{}, []string{}
+		if target.Channel == ChannelAll || target.Channel == ChannelPhone {
+			for _, phone := range channels.phones[key] {
+				if !phones[phone] {
+					phones[phone] = true
+					target.Phones = append(target.Phones, phone)
+				}
+			}
+		}
+		if target.Channel == ChannelAll || target.Channel == ChannelFax {
+			for _, fax := range channels.faxes[key] {
+				if !faxes[fax] {
+					faxes[fax] = true
+					target.Faxes = append(target.Faxes, fax)
+				}
+			}
+		}
+		if target.Channel == ChannelAll || target.Channel == ChannelEmail {
+			for _, email := range channels.emails[key] {
+				lower := strings.ToLower(email)
+				if !emails[lower] {
+					emails[lower] = true
+					target.Emails = append(target.Emails, email)
+				}
+			}
+		}
+		resolution.Phones = append(resolution.Phones, target.Phones...)
+		resolution.Faxes = append(resolution.Faxes, target.Faxes...)
+		resolution.Emails = append(resolution.Emails, target.Emails...)
+		resolution.Targets = append(resolution.Targets, target)
+	}
+	return resolution
+}
+
+// ResolveNotificationList - get contacts and companies of notification list and its call tree with phones,
+// faxes and emails at alert time. Members go first in order of priority, then call tree level by level
+// with priorities above all members, every contact, company, phone and email is included once
+func (e *Edb) ResolveNotificationList(id int64) (NotificationResolution, error) {
+	list, err := e.GetNotificationList(id)
+	if err != nil {
+		return NotificationResolution{}, err
+	}
+	tree, err := e.GetCallTree(id)
+	if err != nil {
+		return NotificationResolution{}, err
+	}
+	var (
+		targets      []NotificationTarget
+		contactIDs   []int64
+		companyIDs   []int64
+		treePriority int64
+	)
+	add := func(target NotificationTarget) {
+		if target.Channel == "" {
+			target.Channel = ChannelAll
+		}
+		targets = append(targets, target)
+		if target.ContactID != 0 {
+			contactIDs = append(contactIDs, target.ContactID)
+		} else {
+			companyIDs = append(companyIDs, target.CompanyID)
+		}
+	}
+	for _, member := range list.Members {
+		add(NotificationTarget{
+			ContactID:     member.ContactID,
+			CompanyID:     member.CompanyID,
+			Name:          member.Name,
+			Priority:      member.Priority,
+			EscalateAfter: member.EscalateAfter,
+			Channel:       member.Channel,
+		})
+		if member.Priority >= treePriority {
+			treePriority = member.Priority + 1
+		}
+	}
+	level := tree
+	for len(level) > 0 {
+		var next []CallTreeNode
+		for _, node := range level {
+			add(NotificationTarget{
+				ContactID:  node.ContactID,
+				CompanyID:  node.CompanyID,
+				Name:       node.Name,
+				Priority:   treePriority + node.Level,
+				Channel:    node.Channel,
+				Level:      node.Level,
+				NotifiedBy: node.CallerName,
+			})
+			next = append(next, node.Children...)
+		}
+		level = next
+	}
+	channels, err := e.getNotificationChannels(contactIDs, companyIDs)
+	if err != nil {
+		return NotificationResolution{}, err
+	}
+	resolution := resolveTargets(targets, channels)
+	resolution.ListID = list.ID
+	resolution.Name = list.Name
+	return resolution, nil
+}
+
+func (e *Edb) notificationCreateTable() error {
+	str := `
+		CREATE TABLE IF NOT EXISTS
+			notification_lists (
+				id bigserial primary key,
+				name text,
+				note text,
+				created_at TIMESTAMP without time zone,
+				updated_at TIMESTAMP without time zone,
+				UNIQUE (name)
+			);
+		CREATE TABLE IF NOT EXISTS
+			notification_members (
+				id bigserial primary key,
+				list_id bigint,
+				contact_id bigint,
+				company_id bigint,
+				priority bigint,
+				channel text,
+				escalate_after bigint,
+				created_at TIMESTAMP without time zone,
+				updated_at TIMESTAMP without time zone
+			);
+		CREATE INDEX IF NOT EXISTS notification_members_list_id_idx ON notification_members (list_id);
+		CREATE TABLE IF NOT EXISTS
+			call_tree_nodes (
+				id bigserial primary key,
+				list_id bigint,
+				parent_id bigint,
+				contact_id bigint,
+				company_id bigint,
+				channel text,
+				created_at TIMESTAMP without time zone,
+				updated_at TIMESTAMP without time zone
+			);
+		CREATE INDEX IF NOT EXISTS call_tree_nodes_list_id_idx ON call_tree_nodes (list_id);
+	`
+	_, err := e.db.Exec(str)
+	if err != nil {
+		log.Println("notificationCreateTable e.db.Exec ", err)
+	}
+	return err
+}
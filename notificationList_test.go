@@ -0,0 +1,82 @@
+package epgc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveTargets(t *testing.T) {
+	targets := []NotificationTarget{
+		{ContactID: 1, Name: "Иванов", Channel: ChannelAll},
+		{CompanyID: 10, Name: "ООО Ромашка", Channel: ChannelPhone},
+		{ContactID: 2, Name: "Петров", Channel: ChannelEmail},
+		{ContactID: 1, Name: "Иванов", Channel: ChannelAll, Level: 1},
+	}
+	channels := notificationChannels{
+		phones: map[string][]int64{
+			"contact:1":  {111, 222},
+			"company:10": {222, 333},
+			"contact:2":  {444},
+		},
+		faxes: map[string][]int64{
+			"contact:1":  {555},
+			"company:10": {666},
+		},
+		emails: map[string][]string{
+			"contact:1": {"ivanov@example.com"},
+			"contact:2": {"IVANOV@example.com", "petrov@example.com"},
+		},
+	}
+	got := resolveTargets(targets, channels)
+	if len(got.Targets) != 3 {
+		t.Fatalf("resolveTargets targets = %+v", got.Targets)
+	}
+	if !reflect.DeepEqual(got.Targets[1].Phones, []int64{333}) || len(got.Targets[1].Faxes) != 0 {
+		t.Errorf("company target = %+v", got.Targets[1])
+	}
+	if len(got.Targets[2].Phones) != 0 || !reflect.DeepEqual(got.Targets[2].Emails, []string{"petrov@example.com"}) {
+		t.Errorf("email target = %+v", got.Targets[2])
+	}
+	if !reflect.DeepEqual(got.Phones, []int64{111, 222, 333}) || !reflect.DeepEqual(got.Faxes, []int64{555}) {
+		t.Errorf("resolveTargets phones = %v, faxes = %v", got.Phones, got.Faxes)
+	}
+	if !reflect.DeepEqual(got.Emails, []string{"ivanov@example.com", "petrov@example.com"}) {
+		t.Errorf("resolveTargets emails = %v", got.Emails)
+	}
+}
+
+func TestBuildCallTree(t *testing.T) {
+	tree := buildCallTree([]CallTreeNode{
+		{ID: 1, Name: "Дежурный"},
+		{ID: 2, ParentID: 1, Name: "Иванов"},
+		{ID: 3, ParentID: 2, Name: "Петров"},
+		{ID: 4, ParentID: 1, Name: "Сидоров"},
+	})
+	if len(tree) != 1 || len(tree[0].Children) != 2 {
+		t.Fatalf("buildCallTree = %+v", tree)
+	}
+	node := tree[0].Children[0].Children[0]
+	if node.Name != "Петров" || node.Level != 2 || node.CallerName != "Иванов" {
+		t.Errorf("buildCallTree leaf = %+v", node)
+	}
+}
+
+func TestBuildCallTreeCycle(t *testing.T) {
+	tree := buildCallTree([]CallTreeNode{
+		{ID: 1, Name: "Дежурный"},
+		{ID: 2, ParentID: 3, Name: "Иванов"},
+		{ID: 3, ParentID: 2, Name: "Петров"},
+	})
+	if len(tree) != 2 || tree[1].ID != 2 || len(tree[1].Children) != 1 || tree[1].Children[0].CallerName != "Иванов" {
+		t.Errorf("buildCallTree with cycle = %+v", tree)
+	}
+}
+
+func TestCheckChannel(t *testing.T) {
+	if channel, err := checkChannel(""); channel != ChannelAll || err != nil {
+		t.Errorf("checkChannel empty = %q, %v", channel, err)
+	}
+	if _, err := checkChannel("pager"); err != ErrNotificationChannel {
+		t.Errorf("checkChannel unknown = %v", err)
+	}
+}
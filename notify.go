@@ -0,0 +1,185 @@
+package epgc
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Change operations
+const (
+	ChangeInsert = "INSERT"
+	ChangeUpdate = "UPDATE"
+	ChangeDelete = "DELETE"
+	// ChangeResync - connection to database was lost and restored, notifications may be missed
+	// and subscriber should reload data
+	ChangeResync = "RESYNC"
+)
+
+const changeChannel = "epgc_changes"
+
+// changeTables - tables with notify trigger, entity of ChangeEvent is name of table
+var changeTables = []string{
+	"addresses",
+	"call_tree_nodes",
+	"companies",
+	"contacts",
+	"departments",
+	"dispatch_deliveries",
+	"dispatches",
+	"educations",
+	"emails",
+	"incident_actions",
+	"incident_participants",
+	"incident_types",
+	"incidents",
+	"kinds",
+	"notification_lists",
+	"notification_members",
+	"phones",
+	"posts",
+	"practice_documents",
+	"practice_participants",
+	"practices",
+	"ranks",
+	"scopes",
+	"shift_handover_incidents",
+	"shift_handovers",
+	"shift_templates",
+	"shifts",
+	"sirens",
+	"sirentypes",
+}
+
+// ChangeEvent - insert, update or delete of row in table
+type ChangeEvent struct {
+	Entity    string `json:"entity"`
+	ID        int64  `json:"id"`
+	Operation string `json:"operation"`
+}
+
+// Subscribe - receive changes of entities (table names like "companies", all tables without entities) until ctx is done.
+// Connection is restored automatically, after reconnect ChangeEvent with ChangeResync operation is sent.
+func (e *Edb) Subscribe(ctx context.Context, entities ...string) <-chan ChangeEvent {
+	events := make(chan ChangeEvent, 32)
+	if e.dsn == "" {
+		log.Println("Subscribe: database is not opened by InitDB")
+		close(events)
+		return events
+	}
+	wanted := make(map[string]bool)
+	for _, entity := range entities {
+		wanted[entity] = true
+	}
+	listener := pq.NewListener(e.dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Println("Subscribe listener ", event, err)
+		}
+	})
+	go func() {
+		defer close(events)
+		defer listener.Close()
+		err := listener.Listen(changeChannel)
+		if err != nil {
+			log.Println("Subscribe listener.Listen ", err)
+			return
+		}
+		for {
+			var event ChangeEvent
+			select {
+			case <-ctx.Done():
+				return
+			case n := <-listener.Notify:
+				// nil notification is sent after reconnect
+				if n == nil {
+					event.Operation = ChangeResync
+				} else {
+					err = json.Unmarshal([]byte(n.Extra), &event)
+					if err != nil {
+						log.Println("Subscribe json.Unmarshal ", n.Extra, err)
+						continue
+					}
+					if event.Operation != ChangeResync && len(wanted) > 0 && !wanted[event.Entity] {
+						continue
+					}
+				}
+			case <-time.After(90 * time.Second):
+				go func() {
+					_ = listener.Ping()
+				}()
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case events <- event:
+			}
+		}
+	}()
+	return events
+}
+
+func (e *Edb) notifyCreateTriggers() error {
+	str := `
+		CREATE OR REPLACE FUNCTION epgc_notify_change() RETURNS trigger AS $$
+		DECLARE
+			rec record;
+		BEGIN
+			IF TG_OP = 'DELETE' THEN
+				rec := OLD;
+			ELSE
+				rec := NEW;
+			END IF;
+			PERFORM pg_notify('` + changeChannel + `', json_build_object('entity', TG_TABLE_NAME, 'id', rec.id, 'operation', TG_OP)::text);
+			RETURN NULL;
+		END;
+		$$ LANGUAGE plpgsql
+	`
+	_, err := e.db.Exec(str)
+	if err != nil {
+		log.Println("notifyCreateTriggers e.db.Exec ", err)
+		return err
+	}
+	// triggers are created only when missing, dropping them takes exclusive lock on every table on each Open
+	for _, table := range changeTables {
+		var exists bool
+		err = e.db.QueryRow(`
+			SELECT
+				EXISTS (
+					SELECT
+						1
+					FROM
+						pg_trigger
+					WHERE
+						tgrelid = to_regclass($1) AND tgname = $2
+				)
+		`, table, table+"_notify_change").Scan(&exists)
+		if err != nil {
+			log.Println("notifyCreateTriggers e.db.QueryRow ", table, err)
+			return err
+		}
+		if exists {
+			continue
+		}
+		// other process may create trigger after check
+		_, err = e.db.Exec(`
+			DO $$
+			BEGIN
+				CREATE TRIGGER ` + table + `_notify_change
+					AFTER INSERT OR UPDATE OR DELETE ON ` + table + `
+					FOR EACH ROW EXECUTE PROCEDURE epgc_notify_change();
+			EXCEPTION WHEN duplicate_object THEN
+				NULL;
+			END
+			$$
+		`)
+		if err != nil {
+			log.Println("notifyCreateTriggers e.db.Exec ", table, err)
+			return err
+		}
+	}
+	return nil
+}
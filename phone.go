@@ -103,7 +103,7 @@ func (e *Edb) GetPhone(id int64) (Phone, error) {
 	if id == 0 {
 		return Phone{}, nil
 	}
-	stmt, err := e.db.Prepare(`
+	stmt, err := e.prepare(`
 		SELECT
 			id,
 			company_id,
@@ -116,12 +116,12 @@ func (e *Edb) GetPhone(id int64) (Phone, error) {
 			id = $1
 	`)
 	if err != nil {
-		log.Println("GetPhone e.db.Prepare", err)
+		log.Println("GetPhone e.prepare ", err)
 		return Phone{}, err
 	}
 	row := stmt.QueryRow(id)
 	phone, err := scanPhone(row)
-	return phone, nil
+	return phone, err
 }
 
 // GetPhoneList - get all phones for list
@@ -180,10 +180,10 @@ func (e *Edb) GetContactPhones(id int64, fax bool) ([]PhoneSelect, error) {
 			phone
 		FROM
 			phones
-		ORDER BY
-			phone ASC
 		WHERE
 			contact_id = $1 AND fax = $2
+		ORDER BY
+			phone ASC
 	`, id, fax)
 	if err != nil {
 		log.Println("GetContactPhones e.db.Query ", err)
@@ -243,7 +243,7 @@ func (e *Edb) GetContactPhonesAll(id int64, fax bool) ([]PhoneSelect, error) {
 
 // CreatePhone - create new phone
 func (e *Edb) CreatePhone(phone Phone) (int64, error) {
-	stmt, err := e.db.Prepare(`
+	stmt, err := e.prepare(`
 		INSERT INTO
 			phones (
 				company_id,
@@ -261,7 +261,7 @@ func (e *Edb) CreatePhone(phone Phone) (int64, error) {
 		RETURNING id
 	`)
 	if err != nil {
-		log.Println("CreatePhone e.db.Prepare ", err)
+		log.Println("CreatePhone e.prepare ", err)
 		return 0, err
 	}
 	err = stmt.QueryRow(i2n(phone.CompanyID), i2n(phone.ContactID), i2n(phone.Phone), phone.Fax).Scan(&phone.ID)
@@ -279,8 +279,13 @@ func (e *Edb) CreateCompanyPhones(company Company, fax bool) error {
 		log.Println("CreateCompanyPhones CleanCompanyPhones ", err)
 		return err
 	}
-	for _, value := range company.Phones {
-		var id int64
+	var allPhones []Phone
+	if fax {
+		allPhones = company.Faxes
+	} else {
+		allPhones = company.Phones
+	}
+	for _, value := range allPhones {
 		phone := Phone{}
 		err = e.db.QueryRow(`
 			SELECT
@@ -289,9 +294,11 @@ func (e *Edb) CreateCompanyPhones(company Company, fax bool) error {
 				phones
 			WHERE
 				company_id = $1 and phone = $2 and fax = $3
-			RETURNING
-				id
-		`, company.ID, value.Phone, fax).Scan(&id)
+		`, company.ID, value.Phone, fax).Scan(&phone.ID)
+		if err != nil && err != sql.ErrNoRows {
+			log.Println("CreateCompanyPhones e.db.QueryRow ", err)
+			return err
+		}
 		if phone.ID == 0 {
 			value.CompanyID = company.ID
 			value.Fax = fax
@@ -327,9 +334,11 @@ func (e *Edb) CreateContactPhones(contact Contact, fax bool) error {
 				phones
 			WHERE
 				contact_id = $1 and phone = $2 and fax = $3
-			RETURNING
-				id
 		`, contact.ID, value.Phone, fax).Scan(&phone.ID)
+		if err != nil && err != sql.ErrNoRows {
+			log.Println("CreateContactPhones e.db.QueryRow ", err)
+			return err
+		}
 		if phone.ID == 0 {
 			value.ContactID = contact.ID
 			value.Fax = fax
@@ -3,6 +3,7 @@ package epgc
 import (
 	"database/sql"
 	"log"
+	"strconv"
 )
 
 // Post - struct for post
@@ -128,6 +129,9 @@ func (e *Edb) GetPost(id int64) (Post, error) {
 	if id == 0 {
 		return Post{}, nil
 	}
+	if item, ok := e.cacheGet(cacheKey("posts", id)); ok {
+		return item.(Post), nil
+	}
 	row := e.db.QueryRow(`
 		SELECT
 			id,
@@ -140,6 +144,9 @@ func (e *Edb) GetPost(id int64) (Post, error) {
 			id = $1
 	`, id)
 	post, err := scanPost(row)
+	if err == nil {
+		e.cacheSet(cacheKey("posts", id), post)
+	}
 	return post, err
 }
 
@@ -165,6 +172,9 @@ func (e *Edb) GetPostList() ([]PostList, error) {
 
 // GetPostSelect - get all post for select
 func (e *Edb) GetPostSelect(g bool) ([]SelectItem, error) {
+	if items, ok := e.cacheSelect(cacheKey("posts", "select"+strconv.FormatBool(g))); ok {
+		return items, nil
+	}
 	rows, err := e.db.Query(`
 		SELECT
 			id,
@@ -181,12 +191,15 @@ func (e *Edb) GetPostSelect(g bool) ([]SelectItem, error) {
 		return []SelectItem{}, err
 	}
 	posts, err := scanPostsSelect(rows)
+	if err == nil {
+		e.cacheSetSelect(cacheKey("posts", "select"+strconv.FormatBool(g)), posts)
+	}
 	return posts, err
 }
 
 // CreatePost - create new post
 func (e *Edb) CreatePost(post Post) (int64, error) {
-	stmt, err := e.db.Prepare(`
+	stmt, err := e.prepare(`
 		INSERT INTO
 			posts (
 				name,
@@ -203,36 +216,39 @@ func (e *Edb) CreatePost(post Post) (int64, error) {
 			id
 	`)
 	if err != nil {
-		log.Println("CreatePost e.db.Prepare ", err)
+		log.Println("CreatePost e.prepare ", err)
 		return 0, err
 	}
 	err = stmt.QueryRow(s2n(post.Name), post.GO, s2n(post.Note)).Scan(&post.ID)
 	if err != nil {
 		log.Println("CreatePost db.QueryRow ", err)
 	}
+	e.InvalidateCache("posts")
 	return post.ID, err
 }
 
 // UpdatePost - save post changes
 func (e *Edb) UpdatePost(s Post) error {
-	stmt, err := e.db.Prepare(`
+	stmt, err := e.prepare(`
 		UPDATE
 			posts
 		SET
 			name = $2,
-			note = $3,
+			go = $3,
+			note = $4,
 			updated_at = now()
 		WHERE
 			id = $1
 	`)
 	if err != nil {
-		log.Println("UpdatePost e.db.Prepare ", err)
+		log.Println("UpdatePost e.prepare ", err)
 		return err
 	}
-	_, err = stmt.Exec(i2n(s.ID), s2n(s.Name), s2n(s.Note))
+	_, err = stmt.Exec(i2n(s.ID), s2n(s.Name), s.GO, s2n(s.Note))
 	if err != nil {
 		log.Println("UpdatePost stmt.Exec ", err)
 	}
+	e.InvalidateCache("posts")
 	return err
 }
 
@@ -250,6 +266,7 @@ func (e *Edb) DeletePost(id int64) error {
 	if err != nil {
 		log.Println("DeletePost e.db.Exec ", id, err)
 	}
+	e.InvalidateCache("posts")
 	return err
 }
 
@@ -2,25 +2,54 @@ package epgc
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 
 	"github.com/lib/pq"
 )
 
-// Practice - struct for practice
+// Practice states
+const (
+	PracticePlanned   = "planned"
+	PracticeHeld      = "held"
+	PracticeCancelled = "cancelled"
+	PracticePostponed = "postponed"
+)
+
+// MaxPracticeScore - best score of practice, zero score is not evaluated practice
+const MaxPracticeScore = 5
+
+// ErrPracticeStatus - unknown status of practice
+var ErrPracticeStatus = errors.New("unknown practice status")
+
+// ErrPracticeScore - score of practice is out of range
+var ErrPracticeScore = errors.New("practice score must be from 0 to 5")
+
+// Practice - struct for practice. DateOfPractice is planned date and HeldDate is actual date of held practice,
+// Score from 1 to MaxPracticeScore with Result and Remarks is evaluation of practice
 type Practice struct {
-	ID             int64   `sql:"id" json:"id"`
-	Company        Company `sql:"-"`
-	CompanyID      int64   `sql:"company_id, null" json:"company_id"`
-	Kind           Kind    `sql:"-"`
-	KindID         int64   `sql:"kind_id, null" json:"kind_id"`
-	Topic          string  `sql:"topic, null" json:"topic"`
-	DateOfPractice string  `sql:"date_of_practice, null" json:"date_of_practice"`
-	DateStr        string  `sql:"-" json:"date_str"`
-	Note           string  `sql:"note, null" json:"note"`
-	CreatedAt      string  `sql:"created_at" json:"created_at"`
-	UpdatedAt      string  `sql:"updated_at" json:"updated_at"`
+	ID             int64              `sql:"id" json:"id"`
+	Company        Company            `sql:"-"`
+	CompanyID      int64              `sql:"company_id, null" json:"company_id"`
+	Kind           Kind               `sql:"-"`
+	KindID         int64              `sql:"kind_id, null" json:"kind_id"`
+	Topic          string             `sql:"topic, null" json:"topic"`
+	DateOfPractice string             `sql:"date_of_practice, null" json:"date_of_practice"`
+	DateStr        string             `sql:"-" json:"date_str"`
+	Status         string             `sql:"status, null" json:"status"`
+	HeldDate       string             `sql:"held_date, null" json:"held_date"`
+	Score          int64              `sql:"score, null" json:"score"`
+	Result         string             `sql:"result, null" json:"result"`
+	Remarks        string             `sql:"remarks, null" json:"remarks"`
+	Note           string             `sql:"note, null" json:"note"`
+	CompanyIDs     []int64            `sql:"-" json:"company_ids"`
+	ContactIDs     []int64            `sql:"-" json:"contact_ids"`
+	Companies      []SelectItem       `sql:"-" json:"companies"`
+	Contacts       []SelectItem       `sql:"-" json:"contacts"`
+	Documents      []PracticeDocument `sql:"-" json:"documents"`
+	CreatedAt      string             `sql:"created_at" json:"created_at"`
+	UpdatedAt      string             `sql:"updated_at" json:"updated_at"`
 }
 
 func scanPractice(row *sql.Row) (Practice, error) {
@@ -30,10 +59,15 @@ func scanPractice(row *sql.Row) (Practice, error) {
 		sKindID         sql.NullInt64
 		sTopic          sql.NullString
 		sDateOfPractice pq.NullTime
+		sStatus         sql.NullString
+		sHeldDate       pq.NullTime
+		sScore          sql.NullInt64
+		sResult         sql.NullString
+		sRemarks        sql.NullString
 		sNote           sql.NullString
 		practice        Practice
 	)
-	err := row.Scan(&sID, &sCompanyID, &sKindID, &sTopic, &sDateOfPractice, &sNote)
+	err := row.Scan(&sID, &sCompanyID, &sKindID, &sTopic, &sDateOfPractice, &sStatus, &sHeldDate, &sScore, &sResult, &sRemarks, &sNote)
 	if err != nil {
 		log.Println("scanPractice row.Scan ", err)
 		return practice, err
@@ -43,6 +77,11 @@ func scanPractice(row *sql.Row) (Practice, error) {
 	practice.KindID = n2i(sKindID)
 	practice.Topic = n2s(sTopic)
 	practice.DateOfPractice = n2sd(sDateOfPractice)
+	practice.Status = n2s(sStatus)
+	practice.HeldDate = n2sd(sHeldDate)
+	practice.Score = n2i(sScore)
+	practice.Result = n2s(sResult)
+	practice.Remarks = n2s(sRemarks)
 	practice.Note = n2s(sNote)
 	return practice, nil
 }
@@ -58,12 +97,13 @@ func scanPractices(rows *sql.Rows, opt string) ([]Practice, error) {
 			sKindName       sql.NullString
 			sTopic          sql.NullString
 			sDateOfPractice pq.NullTime
+			sStatus         sql.NullString
 			// sNote           sql.NullString
 			practice Practice
 		)
 		switch opt {
 		case "list":
-			err := rows.Scan(&sID, &sCompanyID, &sCompanyName, &sKindName, &sTopic, &sDateOfPractice)
+			err := rows.Scan(&sID, &sCompanyID, &sCompanyName, &sKindName, &sTopic, &sDateOfPractice, &sStatus)
 			if err != nil {
 				log.Println("scanPractices rows.Scan list ", err)
 				return practices, err
@@ -72,8 +112,9 @@ func scanPractices(rows *sql.Rows, opt string) ([]Practice, error) {
 			practice.Company.Name = n2s(sCompanyName)
 			practice.Kind.Name = n2s(sKindName)
 			practice.Topic = n2s(sTopic)
+			practice.Status = n2s(sStatus)
 		case "company":
-			err := rows.Scan(&sID, &sKindName, &sTopic, &sDateOfPractice)
+			err := rows.Scan(&sID, &sKindName, &sTopic, &sDateOfPractice, &sStatus)
 			if err != nil {
 				log.Println("scanPractices rows.Scan company ", err)
 				return practices, err
@@ -86,6 +127,7 @@ func scanPractices(rows *sql.Rows, opt string) ([]Practice, error) {
 			// if len(practice.Topic) > 210 {
 			// 	practice.Topic = practice.Topic[0:210]
 			// }
+			practice.Status = n2s(sStatus)
 		case "near":
 			err := rows.Scan(&sID, &sCompanyName, &sKindName, &sTopic, &sDateOfPractice)
 			if err != nil {
@@ -113,22 +155,42 @@ func (e *Edb) GetPractice(id int64) (Practice, error) {
 	if id == 0 {
 		return Practice{}, nil
 	}
-	stmt, err := e.db.Prepare(`SELECT
+	stmt, err := e.prepare(`SELECT
 		id,
 		company_id,
 		kind_id,
 		topic,
 		date_of_practice,
+		status,
+		held_date,
+		score,
+		result,
+		remarks,
 		note
 	FROM
 		practices
 	WHERE id = $1`)
 	if err != nil {
-		log.Println("GetPractice e.db.Prepare ", err)
+		log.Println("GetPractice e.prepare ", err)
 		return Practice{}, err
 	}
 	row := stmt.QueryRow(id)
 	practice, err := scanPractice(row)
+	if err != nil {
+		return practice, err
+	}
+	practice.Companies, practice.Contacts, err = e.getParticipants("practice_participants", "practice_id", id)
+	if err != nil {
+		return practice, err
+	}
+	practice.CompanyIDs, practice.ContactIDs = []int64{}, []int64{}
+	for _, item := range practice.Companies {
+		practice.CompanyIDs = append(practice.CompanyIDs, item.ID)
+	}
+	for _, item := range practice.Contacts {
+		practice.ContactIDs = append(practice.ContactIDs, item.ID)
+	}
+	practice.Documents, err = e.GetPracticeDocuments(id)
 	return practice, err
 }
 
@@ -140,7 +202,8 @@ func (e *Edb) GetPracticeList() ([]Practice, error) {
 		c.name AS company_name,
 		k.name AS kind_name,
 		p.topic,
-		p.date_of_practice
+		p.date_of_practice,
+		p.status
 	FROM
 		practices AS p
 	LEFT JOIN
@@ -162,11 +225,12 @@ func (e *Edb) GetPracticeCompany(id int64) ([]Practice, error) {
 	if id == 0 {
 		return []Practice{}, nil
 	}
-	stmt, err := e.db.Prepare(`SELECT
+	stmt, err := e.prepare(`SELECT
 		p.id,
 		k.name AS kind_name,
 		p.topic,
-		p.date_of_practice
+		p.date_of_practice,
+		p.status
 	FROM
 		practices AS p
 	LEFT JOIN
@@ -176,7 +240,7 @@ func (e *Edb) GetPracticeCompany(id int64) ([]Practice, error) {
 	ORDER BY
 		date_of_practice`)
 	if err != nil {
-		log.Println("GetPracticeCompany e.db.Prepare ", err)
+		log.Println("GetPracticeCompany e.prepare ", err)
 		return []Practice{}, err
 	}
 	rows, err := stmt.Query(id)
@@ -203,7 +267,7 @@ func (e *Edb) GetPracticeNear() ([]Practice, error) {
 	LEFT JOIN
 		kinds AS k ON k.id = p.kind_id
 	WHERE
-		p.date_of_practice > now()
+		p.date_of_practice > now() AND p.status IS DISTINCT FROM '` + PracticeCancelled + `'
 	ORDER BY
 		date_of_practice
 	LIMIT 10`)
@@ -215,15 +279,43 @@ func (e *Edb) GetPracticeNear() ([]Practice, error) {
 	return practices, err
 }
 
+// checkPractice - fill default status and actual date of held practice, check status and score
+func checkPractice(practice *Practice) error {
+	switch practice.Status {
+	case "":
+		practice.Status = PracticePlanned
+	case PracticePlanned, PracticeHeld, PracticeCancelled, PracticePostponed:
+	default:
+		return ErrPracticeStatus
+	}
+	if practice.Score < 0 || practice.Score > MaxPracticeScore {
+		return ErrPracticeScore
+	}
+	if practice.Status == PracticeHeld && practice.HeldDate == "" {
+		practice.HeldDate = practice.DateOfPractice
+	}
+	return nil
+}
+
 // CreatePractice - create new practice
 func (e *Edb) CreatePractice(practice Practice) (int64, error) {
-	stmt, err := e.db.Prepare(`
+	err := checkPractice(&practice)
+	if err != nil {
+		log.Println("CreatePractice checkPractice ", err)
+		return 0, err
+	}
+	stmt, err := e.prepare(`
 		INSERT INTO
 			practices (
 				company_id,
 				kind_id,
 				topic,
 				date_of_practice,
+				status,
+				held_date,
+				score,
+				result,
+				remarks,
 				note,
 				created_at
 			) VALUES (
@@ -232,21 +324,37 @@ func (e *Edb) CreatePractice(practice Practice) (int64, error) {
 				$3,
 				$4,
 				$5,
+				$6,
+				$7,
+				$8,
+				$9,
+				$10,
 				now()
 			)
 		RETURNING id
 	`)
 	if err != nil {
-		log.Println("CreatePractice e.db.Prepare ", err)
+		log.Println("CreatePractice e.prepare ", err)
+		return 0, err
+	}
+	err = stmt.QueryRow(i2n(practice.CompanyID), i2n(practice.KindID), s2n(practice.Topic), sd2n(practice.DateOfPractice),
+		practice.Status, sd2n(practice.HeldDate), i2n(practice.Score), s2n(practice.Result), s2n(practice.Remarks),
+		s2n(practice.Note)).Scan(&practice.ID)
+	if err != nil {
+		log.Println("CreatePractice stmt.QueryRow ", err)
 		return 0, err
 	}
-	err = stmt.QueryRow(i2n(practice.CompanyID), i2n(practice.KindID), s2n(practice.Topic), sd2n(practice.DateOfPractice), s2n(practice.Note)).Scan(&practice.ID)
-	return practice.ID, err
+	return practice.ID, e.saveParticipants("practice_participants", "practice_id", practice.ID, practice.CompanyIDs, practice.ContactIDs)
 }
 
-// UpdatePractice - save practice changes
+// UpdatePractice - save practice changes, participants are replaced when CompanyIDs or ContactIDs are not nil
 func (e *Edb) UpdatePractice(practice Practice) error {
-	stmt, err := e.db.Prepare(`
+	err := checkPractice(&practice)
+	if err != nil {
+		log.Println("UpdatePractice checkPractice ", err)
+		return err
+	}
+	stmt, err := e.prepare(`
 		UPDATE
 			practices
 		SET
@@ -254,33 +362,146 @@ func (e *Edb) UpdatePractice(practice Practice) error {
 			kind_id = $3,
 			topic = $4,
 			date_of_practice = $5,
-			note = $6,
+			status = $6,
+			held_date = $7,
+			score = $8,
+			result = $9,
+			remarks = $10,
+			note = $11,
 			updated_at = now()
 		WHERE
 			id = $1
 	`)
 	if err != nil {
-		log.Println("UpdatePractice e.db.Prepare ", err)
+		log.Println("UpdatePractice e.prepare ", err)
 		return err
 	}
-	_, err = stmt.Exec(practice.ID, i2n(practice.CompanyID), i2n(practice.KindID), s2n(practice.Topic), sd2n(practice.DateOfPractice), s2n(practice.Note))
-	return err
+	_, err = stmt.Exec(practice.ID, i2n(practice.CompanyID), i2n(practice.KindID), s2n(practice.Topic), sd2n(practice.DateOfPractice),
+		practice.Status, sd2n(practice.HeldDate), i2n(practice.Score), s2n(practice.Result), s2n(practice.Remarks), s2n(practice.Note))
+	if err != nil {
+		log.Println("UpdatePractice stmt.Exec ", err)
+		return err
+	}
+	return e.saveParticipants("practice_participants", "practice_id", practice.ID, practice.CompanyIDs, practice.ContactIDs)
 }
 
-// DeletePractice - delete practice by id
+// DeletePractice - delete practice by id with its participants and documents
 func (e *Edb) DeletePractice(id int64) error {
 	if id == 0 {
 		return nil
 	}
+	for _, query := range []string{
+		`DELETE FROM practice_participants WHERE practice_id = $1`,
+		`DELETE FROM practice_documents WHERE practice_id = $1`,
+		`DELETE FROM practices WHERE id = $1`,
+	} {
+		_, err := e.db.Exec(query, id)
+		if err != nil {
+			log.Println("DeletePractice e.db.Exec: ", id, err)
+			return fmt.Errorf("DeletePractice e.db.Exec: %s", err)
+		}
+	}
+	return nil
+}
+
+// getParticipants - companies and contacts of practice or incident, table is practice_participants
+// or incident_participants, owner is practice_id or incident_id
+func (e *Edb) getParticipants(table, owner string, id int64) ([]SelectItem, []SelectItem, error) {
+	rows, err := e.db.Query(`
+		SELECT
+			p.company_id,
+			p.contact_id,
+			COALESCE(o.name, c.name)
+		FROM
+			`+table+` AS p
+		LEFT JOIN
+			companies AS o ON o.id = p.company_id
+		LEFT JOIN
+			contacts AS c ON c.id = p.contact_id
+		WHERE
+			p.`+owner+` = $1
+		ORDER BY
+			3 ASC
+	`, id)
+	if err != nil {
+		log.Println("getParticipants e.db.Query ", table, err)
+		return nil, nil, err
+	}
+	defer rows.Close()
+	companies, contacts := []SelectItem{}, []SelectItem{}
+	for rows.Next() {
+		var (
+			sCompanyID sql.NullInt64
+			sContactID sql.NullInt64
+			sName      sql.NullString
+		)
+		err = rows.Scan(&sCompanyID, &sContactID, &sName)
+		if err != nil {
+			log.Println("getParticipants rows.Scan ", table, err)
+			return nil, nil, err
+		}
+		if sCompanyID.Valid {
+			companies = append(companies, SelectItem{ID: n2i(sCompanyID), Name: n2s(sName)})
+		} else {
+			contacts = append(contacts, SelectItem{ID: n2i(sContactID), Name: n2s(sName)})
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Println("getParticipants rows.Err ", table, err)
+	}
+	return companies, contacts, err
+}
+
+// saveParticipants - replace participants of practice or incident, without companyIDs and contactIDs
+// participants are kept
+func (e *Edb) saveParticipants(table, owner string, id int64, companyIDs, contactIDs []int64) error {
+	if companyIDs == nil && contactIDs == nil {
+		return nil
+	}
 	_, err := e.db.Exec(`
 		DELETE FROM
-			practices
+			`+table+`
 		WHERE
-			id = $1
+			`+owner+` = $1
 	`, id)
 	if err != nil {
-		log.Println("DeletePractice e.db.Exec: ", id, err)
-		return fmt.Errorf("DeletePractice e.db.Exec: %s", err)
+		log.Println("saveParticipants e.db.Exec ", table, err)
+		return err
+	}
+	_, err = e.db.Exec(`
+		INSERT INTO
+			`+table+` (
+				`+owner+`,
+				company_id,
+				contact_id
+			)
+		SELECT
+			$1,
+			id,
+			NULL
+		FROM
+			unnest($2::bigint[]) AS id
+		UNION
+		SELECT
+			$1,
+			NULL,
+			id
+		FROM
+			unnest($3::bigint[]) AS id
+	`, id, pq.Array(companyIDs), pq.Array(contactIDs))
+	if err != nil {
+		log.Println("saveParticipants e.db.Exec ", table, err)
+	}
+	return err
+}
+
+// deletePracticeParticipants - remove deleted company or contact from participants of practices,
+// owner is company_id or contact_id
+func (e *Edb) deletePracticeParticipants(owner string, id int64) error {
+	_, err := e.db.Exec(`DELETE FROM practice_participants WHERE `+owner+` = $1`, id)
+	if err != nil {
+		log.Println("deletePracticeParticipants e.db.Exec ", owner, id, err)
 	}
 	return err
 }
@@ -294,10 +515,41 @@ func (e *Edb) practiceCreateTable() error {
 				kind_id bigint,
 				topic text,
 				date_of_practice date,
+				status text,
+				held_date date,
+				score bigint,
+				result text,
+				remarks text,
 				note text,
 				created_at TIMESTAMP without time zone,
 				updated_at TIMESTAMP without time zone
-			)
+			);
+		ALTER TABLE practices ADD COLUMN IF NOT EXISTS status text;
+		ALTER TABLE practices ADD COLUMN IF NOT EXISTS held_date date;
+		ALTER TABLE practices ADD COLUMN IF NOT EXISTS score bigint;
+		ALTER TABLE practices ADD COLUMN IF NOT EXISTS result text;
+		ALTER TABLE practices ADD COLUMN IF NOT EXISTS remarks text;
+		CREATE TABLE IF NOT EXISTS
+			practice_participants (
+				id bigserial primary key,
+				practice_id bigint,
+				company_id bigint,
+				contact_id bigint
+			);
+		CREATE INDEX IF NOT EXISTS practice_participants_practice_id_idx ON practice_participants (practice_id);
+		CREATE TABLE IF NOT EXISTS
+			practice_documents (
+				id bigserial primary key,
+				practice_id bigint,
+				name text,
+				content_type text,
+				size bigint,
+				data bytea,
+				note text,
+				created_at TIMESTAMP without time zone,
+				updated_at TIMESTAMP without time zone
+			);
+		CREATE INDEX IF NOT EXISTS practice_documents_practice_id_idx ON practice_documents (practice_id);
 	`
 	_, err := e.db.Exec(str)
 	if err != nil {
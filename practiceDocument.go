@@ -0,0 +1,186 @@
+package epgc
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/lib/pq"
+)
+
+// MaxPracticeDocumentSize - max size of document attached to practice in bytes
+const MaxPracticeDocumentSize = 20 << 20
+
+// ErrPracticeDocumentSize - document is empty or larger than MaxPracticeDocumentSize
+var ErrPracticeDocumentSize = errors.New("practice document is empty or too large")
+
+// PracticeDocument - file attached to practice like plan, order or act of practice. Data is loaded
+// by GetPracticeDocument only
+type PracticeDocument struct {
+	ID          int64  `sql:"id" json:"id"`
+	PracticeID  int64  `sql:"practice_id" json:"practice_id"`
+	Name        string `sql:"name" json:"name"`
+	ContentType string `sql:"content_type" json:"content_type"`
+	Size        int64  `sql:"size" json:"size"`
+	Data        []byte `sql:"data" json:"-"`
+	Note        string `sql:"note, null" json:"note"`
+	CreatedAt   string `sql:"created_at" json:"created_at"`
+	UpdatedAt   string `sql:"updated_at" json:"updated_at"`
+}
+
+// GetPracticeDocument - get document with data by id
+func (e *Edb) GetPracticeDocument(id int64) (PracticeDocument, error) {
+	var (
+		sID          sql.NullInt64
+		sPracticeID  sql.NullInt64
+		sName        sql.NullString
+		sContentType sql.NullString
+		sSize        sql.NullInt64
+		sNote        sql.NullString
+		sCreatedAt   pq.NullTime
+		document     PracticeDocument
+	)
+	if id == 0 {
+		return document, nil
+	}
+	err := e.db.QueryRow(`
+		SELECT
+			id,
+			practice_id,
+			name,
+			content_type,
+			size,
+			data,
+			note,
+			created_at
+		FROM
+			practice_documents
+		WHERE
+			id = $1
+	`, id).Scan(&sID, &sPracticeID, &sName, &sContentType, &sSize, &document.Data, &sNote, &sCreatedAt)
+	if err != nil {
+		log.Println("GetPracticeDocument row.Scan ", err)
+		return document, err
+	}
+	document.ID = n2i(sID)
+	document.PracticeID = n2i(sPracticeID)
+	document.Name = n2s(sName)
+	document.ContentType = n2s(sContentType)
+	document.Size = n2i(sSize)
+	document.Note = n2s(sNote)
+	document.CreatedAt = n2stm(sCreatedAt)
+	return document, nil
+}
+
+// GetPracticeDocuments - get documents of practice without data
+func (e *Edb) GetPracticeDocuments(practiceID int64) ([]PracticeDocument, error) {
+	documents := []PracticeDocument{}
+	rows, err := e.db.Query(`
+		SELECT
+			id,
+			practice_id,
+			name,
+			content_type,
+			size,
+			note,
+			created_at
+		FROM
+			practice_documents
+		WHERE
+			practice_id = $1
+		ORDER BY
+			created_at ASC,
+			id ASC
+	`, practiceID)
+	if err != nil {
+		log.Println("GetPracticeDocuments e.db.Query ", err)
+		return documents, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			sID          sql.NullInt64
+			sPracticeID  sql.NullInt64
+			sName        sql.NullString
+			sContentType sql.NullString
+			sSize        sql.NullInt64
+			sNote        sql.NullString
+			sCreatedAt   pq.NullTime
+			document     PracticeDocument
+		)
+		err = rows.Scan(&sID, &sPracticeID, &sName, &sContentType, &sSize, &sNote, &sCreatedAt)
+		if err != nil {
+			log.Println("GetPracticeDocuments rows.Scan ", err)
+			return documents, err
+		}
+		document.ID = n2i(sID)
+		document.PracticeID = n2i(sPracticeID)
+		document.Name = n2s(sName)
+		document.ContentType = n2s(sContentType)
+		document.Size = n2i(sSize)
+		document.Note = n2s(sNote)
+		document.CreatedAt = n2stm(sCreatedAt)
+		documents = append(documents, document)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Println("GetPracticeDocuments rows.Err ", err)
+	}
+	return documents, err
+}
+
+// CreatePracticeDocument - attach document to practice, content type is detected by data when it is empty
+func (e *Edb) CreatePracticeDocument(document PracticeDocument) (int64, error) {
+	if len(document.Data) == 0 || len(document.Data) > MaxPracticeDocumentSize {
+		return 0, ErrPracticeDocumentSize
+	}
+	if document.ContentType == "" {
+		document.ContentType = http.DetectContentType(document.Data)
+	}
+	err := e.db.QueryRow(`
+		INSERT INTO
+			practice_documents (
+				practice_id,
+				name,
+				content_type,
+				size,
+				data,
+				note,
+				created_at
+			)
+		VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			now()
+		)
+		RETURNING
+			id
+	`, document.PracticeID, document.Name, document.ContentType, len(document.Data), document.Data, s2n(document.Note)).Scan(&document.ID)
+	if err != nil {
+		log.Println("CreatePracticeDocument e.db.QueryRow ", err)
+		return 0, err
+	}
+	return document.ID, nil
+}
+
+// DeletePracticeDocument - delete document by id
+func (e *Edb) DeletePracticeDocument(id int64) error {
+	if id == 0 {
+		return nil
+	}
+	_, err := e.db.Exec(`
+		DELETE FROM
+			practice_documents
+		WHERE
+			id = $1
+	`, id)
+	if err != nil {
+		log.Println("DeletePracticeDocument e.db.Exec ", id, err)
+	}
+	return err
+}
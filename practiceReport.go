@@ -0,0 +1,117 @@
+package epgc
+
+import (
+	"database/sql"
+	"log"
+)
+
+// PracticeReport - outcomes of practices of companies of scope in year. Year of held practice is year
+// of HeldDate, other practices are counted by planned date, practices without status are planned.
+// AverageScore is calculated by evaluated practices only
+type PracticeReport struct {
+	Year         int64   `json:"year"`
+	ScopeID      int64   `json:"scope_id"`
+	ScopeName    string  `json:"scope_name"`
+	Total        int64   `json:"total"`
+	Planned      int64   `json:"planned"`
+	Held         int64   `json:"held"`
+	Cancelled    int64   `json:"cancelled"`
+	Postponed    int64   `json:"postponed"`
+	Evaluated    int64   `json:"evaluated"`
+	AverageScore float64 `json:"average_score"`
+	Companies    int64   `json:"companies"`
+	Contacts     int64   `json:"contacts"`
+}
+
+// GetPracticeReport - get outcomes of practices per scope and year, all years for zero year.
+// Companies are organizers and participating companies, Contacts are participating contacts
+func (e *Edb) GetPracticeReport(year int64) ([]PracticeReport, error) {
+	reports := []PracticeReport{}
+	rows, err := e.db.Query(`
+		WITH p AS (
+			SELECT
+				pr.id,
+				pr.company_id,
+				COALESCE(pr.status, $2) AS status,
+				pr.score,
+				c.scope_id,
+				extract(year FROM CASE WHEN pr.status = $3 THEN COALESCE(pr.held_date, pr.date_of_practice) ELSE pr.date_of_practice END)::bigint AS year
+			FROM
+				practices AS pr
+			LEFT JOIN
+				companies AS c ON c.id = pr.company_id
+		), members AS (
+			SELECT
+				p.year,
+				p.scope_id,
+				count(DISTINCT m.company_id) AS companies,
+				count(DISTINCT m.contact_id) AS contacts
+			FROM
+				p
+			JOIN (
+				SELECT id AS practice_id, company_id, NULL::bigint AS contact_id FROM practices
+				UNION ALL
+				SELECT practice_id, company_id, contact_id FROM practice_participants
+			) AS m ON m.practice_id = p.id
+			GROUP BY
+				p.year,
+				p.scope_id
+		)
+		SELECT
+			p.year,
+			p.scope_id,
+			s.name,
+			count(*),
+			count(*) FILTER (WHERE p.status = $2),
+			count(*) FILTER (WHERE p.status = $3),
+			count(*) FILTER (WHERE p.status = $4),
+			count(*) FILTER (WHERE p.status = $5),
+			count(*) FILTER (WHERE p.score > 0),
+			avg(p.score) FILTER (WHERE p.score > 0),
+			COALESCE(max(m.companies), 0),
+			COALESCE(max(m.contacts), 0)
+		FROM
+			p
+		LEFT JOIN
+			scopes AS s ON s.id = p.scope_id
+		LEFT JOIN
+			members AS m ON m.year = p.year AND m.scope_id IS NOT DISTINCT FROM p.scope_id
+		WHERE
+			p.year IS NOT NULL AND ($1 = 0 OR p.year = $1)
+		GROUP BY
+			p.year,
+			p.scope_id,
+			s.name
+		ORDER BY
+			p.year ASC,
+			s.name ASC NULLS LAST
+	`, year, PracticePlanned, PracticeHeld, PracticeCancelled, PracticePostponed)
+	if err != nil {
+		log.Println("GetPracticeReport e.db.Query ", err)
+		return reports, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			sScopeID      sql.NullInt64
+			sScopeName    sql.NullString
+			sAverageScore sql.NullFloat64
+			report        PracticeReport
+		)
+		err = rows.Scan(&report.Year, &sScopeID, &sScopeName, &report.Total, &report.Planned, &report.Held, &report.Cancelled,
+			&report.Postponed, &report.Evaluated, &sAverageScore, &report.Companies, &report.Contacts)
+		if err != nil {
+			log.Println("GetPracticeReport rows.Scan ", err)
+			return reports, err
+		}
+		report.ScopeID = n2i(sScopeID)
+		report.ScopeName = n2s(sScopeName)
+		report.AverageScore = n2f(sAverageScore)
+		reports = append(reports, report)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Println("GetPracticeReport rows.Err ", err)
+	}
+	return reports, err
+}
@@ -0,0 +1,23 @@
+package epgc
+
+import "testing"
+
+func TestCheckPractice(t *testing.T) {
+	practice := Practice{DateOfPractice: "10.12.2025"}
+	if err := checkPractice(&practice); err != nil || practice.Status != PracticePlanned || practice.HeldDate != "" {
+		t.Errorf("checkPractice of new practice = %+v, %v", practice, err)
+	}
+	practice = Practice{DateOfPractice: "10.12.2025", Status: PracticeHeld}
+	if err := checkPractice(&practice); err != nil || practice.HeldDate != "10.12.2025" {
+		t.Errorf("checkPractice of held practice = %+v, %v", practice, err)
+	}
+	practice = Practice{DateOfPractice: "10.12.2025", Status: PracticeHeld, HeldDate: "14.01.2026"}
+	if err := checkPractice(&practice); err != nil || practice.HeldDate != "14.01.2026" {
+		t.Errorf("checkPractice of practice held later = %+v, %v", practice, err)
+	}
+	for _, practice := range []Practice{{Status: "done"}, {Score: -1}, {Score: MaxPracticeScore + 1}} {
+		if err := checkPractice(&practice); err == nil {
+			t.Errorf("checkPractice(%+v) is valid", practice)
+		}
+	}
+}
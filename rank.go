@@ -87,6 +87,9 @@ func (e *Edb) GetRank(id int64) (Rank, error) {
 	if id == 0 {
 		return Rank{}, nil
 	}
+	if item, ok := e.cacheGet(cacheKey("ranks", id)); ok {
+		return item.(Rank), nil
+	}
 	row := e.db.QueryRow(`
 		SELECT
 			id,
@@ -98,6 +101,9 @@ func (e *Edb) GetRank(id int64) (Rank, error) {
 			id = $1
 	`, id)
 	rank, err := scanRank(row)
+	if err == nil {
+		e.cacheSet(cacheKey("ranks", id), rank)
+	}
 	return rank, err
 }
 
@@ -123,6 +129,9 @@ func (e *Edb) GetRankList() ([]Rank, error) {
 
 // GetRankSelect - get all rank for select
 func (e *Edb) GetRankSelect() ([]SelectItem, error) {
+	if items, ok := e.cacheSelect(cacheKey("ranks", "select")); ok {
+		return items, nil
+	}
 	rows, err := e.db.Query(`
 		SELECT
 			id,
@@ -137,12 +146,15 @@ func (e *Edb) GetRankSelect() ([]SelectItem, error) {
 		return []SelectItem{}, err
 	}
 	ranks, err := scanRanksSelect(rows)
+	if err == nil {
+		e.cacheSetSelect(cacheKey("ranks", "select"), ranks)
+	}
 	return ranks, err
 }
 
 // CreateRank - create new rank
 func (e *Edb) CreateRank(rank Rank) (int64, error) {
-	stmt, err := e.db.Prepare(`
+	stmt, err := e.prepare(`
 		INSERT INTO
 			ranks (
 				name,
@@ -157,19 +169,20 @@ func (e *Edb) CreateRank(rank Rank) (int64, error) {
 			id
 	`)
 	if err != nil {
-		log.Println("CreateRank e.db.Prepare ", err)
+		log.Println("CreateRank e.prepare ", err)
 		return 0, err
 	}
 	err = stmt.QueryRow(s2n(rank.Name), s2n(rank.Note)).Scan(&rank.ID)
 	if err != nil {
 		log.Println("CreateRank db.QueryRow ", err)
 	}
+	e.InvalidateCache("ranks")
 	return rank.ID, err
 }
 
 // UpdateRank - save rank changes
 func (e *Edb) UpdateRank(s Rank) error {
-	stmt, err := e.db.Prepare(`
+	stmt, err := e.prepare(`
 		UPDATE
 			ranks
 		SET
@@ -180,13 +193,14 @@ func (e *Edb) UpdateRank(s Rank) error {
 			id = $1
 	`)
 	if err != nil {
-		log.Println("UpdateRank e.db.Prepare ", err)
+		log.Println("UpdateRank e.prepare ", err)
 		return err
 	}
 	_, err = stmt.Exec(i2n(s.ID), s2n(s.Name), s2n(s.Note))
 	if err != nil {
 		log.Println("UpdateRank stmt.Exec ", err)
 	}
+	e.InvalidateCache("ranks")
 	return err
 }
 
@@ -204,6 +218,7 @@ func (e *Edb) DeleteRank(id int64) error {
 	if err != nil {
 		log.Println("DeleteRank e.db.Exec ", id, err)
 	}
+	e.InvalidateCache("ranks")
 	return err
 }
 
@@ -0,0 +1,507 @@
+package epgc
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+)
+
+// Reports of annual statistics
+const (
+	ReportCompanies  = "companies"
+	ReportPractices  = "practices"
+	ReportContacts   = "contacts"
+	ReportSirens     = "sirens"
+	ReportEducations = "educations"
+)
+
+// ErrUnknownReport - report with name is not exists
+var ErrUnknownReport = errors.New("unknown report")
+
+var reportTitles = map[string]string{
+	ReportCompanies:  "Организации по сферам",
+	ReportPractices:  "Учения по видам и месяцам",
+	ReportContacts:   "Должности ГО",
+	ReportSirens:     "Сирены по этапам и типам",
+	ReportEducations: "Обучение по месяцам",
+}
+
+// ReportFilter - parameters of reports, From and To are dates in "02.01.2006" format and both are included,
+// ScopeID is scope of company, zero values are not used. Period is used by practices and educations,
+// kind by practices only
+type ReportFilter struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	ScopeID int64  `json:"scope_id"`
+	KindID  int64  `json:"kind_id"`
+}
+
+// CompanyStat - companies of scope, WithPractices counts companies with practices in period
+type CompanyStat struct {
+	ScopeID       int64  `json:"scope_id"`
+	ScopeName     string `json:"scope_name"`
+	Companies     int64  `json:"companies"`
+	WithContacts  int64  `json:"with_contacts"`
+	WithPractices int64  `json:"with_practices"`
+	WithSirens    int64  `json:"with_sirens"`
+}
+
+// PracticeStat - practices of kind in month, held practices are counted by actual date
+type PracticeStat struct {
+	KindID    int64  `json:"kind_id"`
+	KindName  string `json:"kind_name"`
+	Year      int64  `json:"year"`
+	Month     int64  `json:"month"`
+	Total     int64  `json:"total"`
+	Planned   int64  `json:"planned"`
+	Held      int64  `json:"held"`
+	Cancelled int64  `json:"cancelled"`
+	Postponed int64  `json:"postponed"`
+}
+
+// ContactGOStat - contacts of scope with GO post
+type ContactGOStat struct {
+	ScopeID    int64  `json:"scope_id"`
+	ScopeName  string `json:"scope_name"`
+	PostGOID   int64  `json:"post_go_id"`
+	PostGOName string `json:"post_go_name"`
+	Contacts   int64  `json:"contacts"`
+}
+
+// SirenStat - sirens of stage and type with number of their companies
+type SirenStat struct {
+	Stage     int64  `json:"stage"`
+	TypeID    int64  `json:"type_id"`
+	TypeName  string `json:"type_name"`
+	Sirens    int64  `json:"sirens"`
+	Companies int64  `json:"companies"`
+}
+
+// EducationStat - educations started in month with their total days
+type EducationStat struct {
+	Year       int64 `json:"year"`
+	Month      int64 `json:"month"`
+	Educations int64 `json:"educations"`
+	Days       int64 `json:"days"`
+}
+
+// GetCompanyStats - get number of companies per scope
+func (e *Edb) GetCompanyStats(filter ReportFilter) ([]CompanyStat, error) {
+	stats := []CompanyStat{}
+	rows, err := e.db.Query(`
+		SELECT
+			c.scope_id,
+			s.name,
+			count(*),
+			count(*) FILTER (WHERE EXISTS (SELECT 1 FROM contacts AS t WHERE t.company_id = c.id)),
+			count(*) FILTER (WHERE EXISTS (
+				SELECT
+					1
+				FROM
+					practices AS p
+				WHERE
+					p.company_id = c.id
+					AND ($1::date IS NULL OR p.date_of_practice >= $1)
+					AND ($2::date IS NULL OR p.date_of_practice < $2::date + 1)
+			)),
+			count(*) FILTER (WHERE EXISTS (SELECT 1 FROM sirens AS r WHERE r.company_id = c.id))
+		FROM
+			companies AS c
+		LEFT JOIN
+			scopes AS s ON s.id = c.scope_id
+		WHERE
+			$3 = 0 OR c.scope_id = $3
+		GROUP BY
+			c.scope_id,
+			s.name
+		ORDER BY
+			s.name ASC NULLS LAST
+	`, sd2n(filter.From), sd2n(filter.To), filter.ScopeID)
+	if err != nil {
+		log.Println("GetCompanyStats e.db.Query ", err)
+		return stats, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			sScopeID   sql.NullInt64
+			sScopeName sql.NullString
+			stat       CompanyStat
+		)
+		err = rows.Scan(&sScopeID, &sScopeName, &stat.Companies, &stat.WithContacts, &stat.WithPractices, &stat.WithSirens)
+		if err != nil {
+			log.Println("GetCompanyStats rows.Scan ", err)
+			return stats, err
+		}
+		stat.ScopeID = n2i(sScopeID)
+		stat.ScopeName = n2s(sScopeName)
+		stats = append(stats, stat)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Println("GetCompanyStats rows.Err ", err)
+	}
+	return stats, err
+}
+
+// GetPracticeStats - get number of practices per kind and month
+func (e *Edb) GetPracticeStats(filter ReportFilter) ([]PracticeStat, error) {
+	stats := []PracticeStat{}
+	rows, err := e.db.Query(`
+		WITH p AS (
+			SELECT
+				pr.kind_id,
+				COALESCE(pr.status, '`+PracticePlanned+`') AS status,
+				CASE WHEN pr.status = '`+PracticeHeld+`' THEN COALESCE(pr.held_date, pr.date_of_practice) ELSE pr.date_of_practice END AS practice_date
+			FROM
+				practices AS pr
+			LEFT JOIN
+				companies AS c ON c.id = pr.company_id
+			WHERE
+				($3 = 0 OR c.scope_id = $3)
+				AND ($4 = 0 OR pr.kind_id = $4)
+		)
+		SELECT
+			p.kind_id,
+			k.name,
+			extract(year FROM p.practice_date)::bigint,
+			extract(month FROM p.practice_date)::bigint,
+			count(*),
+			count(*) FILTER (WHERE p.status = '`+PracticePlanned+`'),
+			count(*) FILTER (WHERE p.status = '`+PracticeHeld+`'),
+			count(*) FILTER (WHERE p.status = '`+PracticeCancelled+`'),
+			count(*) FILTER (WHERE p.status = '`+PracticePostponed+`')
+		FROM
+			p
+		LEFT JOIN
+			kinds AS k ON k.id = p.kind_id
+		WHERE
+			p.practice_date IS NOT NULL
+			AND ($1::date IS NULL OR p.practice_date >= $1)
+			AND ($2::date IS NULL OR p.practice_date < $2::date + 1)
+		GROUP BY
+			1, 2, 3, 4
+		ORDER BY
+			k.name ASC NULLS LAST,
+			3 ASC,
+			4 ASC
+	`, sd2n(filter.From), sd2n(filter.To), filter.ScopeID, filter.KindID)
+	if err != nil {
+		log.Println("GetPracticeStats e.db.Query ", err)
+		return stats, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			sKindID   sql.NullInt64
+			sKindName sql.NullString
+			stat      PracticeStat
+		)
+		err = rows.Scan(&sKindID, &sKindName, &stat.Year, &stat.Month, &stat.Total, &stat.Planned, &stat.Held, &stat.Cancelled, &stat.Postponed)
+		if err != nil {
+			log.Println("GetPracticeStats rows.Scan ", err)
+			return stats, err
+		}
+		stat.KindID = n2i(sKindID)
+		stat.KindName = n2s(sKindName)
+		stats = append(stats, stat)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Println("GetPracticeStats rows.Err ", err)
+	}
+	return stats, err
+}
+
+// GetContactGOStats - get number of contacts with GO posts per scope and post
+func (e *Edb) GetContactGOStats(filter ReportFilter) ([]ContactGOStat, error) {
+	stats := []ContactGOStat{}
+	rows, err := e.db.Query(`
+		SELECT
+			o.scope_id,
+			s.name,
+			t.post_go_id,
+			g.name,
+			count(*)
+		FROM
+			contacts AS t
+		LEFT JOIN
+			companies AS o ON o.id = t.company_id
+		LEFT JOIN
+			scopes AS s ON s.id = o.scope_id
+		LEFT JOIN
+			posts AS g ON g.id = t.post_go_id
+		WHERE
+			t.post_go_id IS NOT NULL AND ($1 = 0 OR o.scope_id = $1)
+		GROUP BY
+			1, 2, 3, 4
+		ORDER BY
+			s.name ASC NULLS LAST,
+			g.name ASC
+	`, filter.ScopeID)
+	if err != nil {
+		log.Println("GetContactGOStats e.db.Query ", err)
+		return stats, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			sScopeID    sql.NullInt64
+			sScopeName  sql.NullString
+			sPostGOID   sql.NullInt64
+			sPostGOName sql.NullString
+			stat        ContactGOStat
+		)
+		err = rows.Scan(&sScopeID, &sScopeName, &sPostGOID, &sPostGOName, &stat.Contacts)
+		if err != nil {
+			log.Println("GetContactGOStats rows.Scan ", err)
+			return stats, err
+		}
+		stat.ScopeID = n2i(sScopeID)
+		stat.ScopeName = n2s(sScopeName)
+		stat.PostGOID = n2i(sPostGOID)
+		stat.PostGOName = n2s(sPostGOName)
+		stats = append(stats, stat)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Println("GetContactGOStats rows.Err ", err)
+	}
+	return stats, err
+}
+
+// GetSirenStats - get number of sirens per stage and type
+func (e *Edb) GetSirenStats(filter ReportFilter) ([]SirenStat, error) {
+	stats := []SirenStat{}
+	rows, err := e.db.Query(`
+		SELECT
+			r.stage,
+			r.type_id,
+			t.name,
+			count(*),
+			count(DISTINCT r.company_id)
+		FROM
+			sirens AS r
+		LEFT JOIN
+			sirentypes AS t ON t.id = r.type_id
+		LEFT JOIN
+			companies AS o ON o.id = r.company_id
+		WHERE
+			$1 = 0 OR o.scope_id = $1
+		GROUP BY
+			1, 2, 3
+		ORDER BY
+			r.stage ASC NULLS LAST,
+			t.name ASC
+	`, filter.ScopeID)
+	if err != nil {
+		log.Println("GetSirenStats e.db.Query ", err)
+		return stats, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			sStage    sql.NullInt64
+			sTypeID   sql.NullInt64
+			sTypeName sql.NullString
+			stat      SirenStat
+		)
+		err = rows.Scan(&sStage, &sTypeID, &sTypeName, &stat.Sirens, &stat.Companies)
+		if err != nil {
+			log.Println("GetSirenStats rows.Scan ", err)
+			return stats, err
+		}
+		stat.Stage = n2i(sStage)
+		stat.TypeID = n2i(sTypeID)
+		stat.TypeName = n2s(sTypeName)
+		stats = append(stats, stat)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Println("GetSirenStats rows.Err ", err)
+	}
+	return stats, err
+}
+
+// GetEducationStats - get number of educations per month of start
+func (e *Edb) GetEducationStats(filter ReportFilter) ([]EducationStat, error) {
+	stats := []EducationStat{}
+	rows, err := e.db.Query(`
+		SELECT
+			extract(year FROM start_date)::bigint,
+			extract(month FROM start_date)::bigint,
+			count(*),
+			COALESCE(sum(end_date - start_date + 1) FILTER (WHERE end_date >= start_date), 0)
+		FROM
+			educations
+		WHERE
+			start_date IS NOT NULL
+			AND ($1::date IS NULL OR start_date >= $1)
+			AND ($2::date IS NULL OR start_date < $2::date + 1)
+		GROUP BY
+			1, 2
+		ORDER BY
+			1 ASC,
+			2 ASC
+	`, sd2n(filter.From), sd2n(filter.To))
+	if err != nil {
+		log.Println("GetEducationStats e.db.Query ", err)
+		return stats, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var stat EducationStat
+		err = rows.Scan(&stat.Year, &stat.Month, &stat.Educations, &stat.Days)
+		if err != nil {
+			log.Println("GetEducationStats rows.Scan ", err)
+			return stats, err
+		}
+		stats = append(stats, stat)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Println("GetEducationStats rows.Err ", err)
+	}
+	return stats, err
+}
+
+// reportMonth - month like "03.2026"
+func reportMonth(year, month int64) string {
+	return fmt.Sprintf("%02d.%d", month, year)
+}
+
+func reportNumber(val int64) string {
+	return strconv.FormatInt(val, 10)
+}
+
+// reportName - name of entity, rows without entity are shown as not set
+func reportName(name string) string {
+	if name == "" {
+		return "Не указано"
+	}
+	return name
+}
+
+func companyStatRecords(stats []CompanyStat) [][]string {
+	records := [][]string{{"Сфера деятельности", "Организаций", "С контактами", "С учениями", "С сиренами"}}
+	for _, stat := range stats {
+		records = append(records, []string{
+			reportName(stat.ScopeName),
+			reportNumber(stat.Companies),
+			reportNumber(stat.WithContacts),
+			reportNumber(stat.WithPractices),
+			reportNumber(stat.WithSirens),
+		})
+	}
+	return records
+}
+
+func practiceStatRecords(stats []PracticeStat) [][]string {
+	records := [][]string{{"Вид учения", "Месяц", "Всего", "Запланировано", "Проведено", "Отменено", "Перенесено"}}
+	for _, stat := range stats {
+		records = append(records, []string{
+			reportName(stat.KindName),
+			reportMonth(stat.Year, stat.Month),
+			reportNumber(stat.Total),
+			reportNumber(stat.Planned),
+			reportNumber(stat.Held),
+			reportNumber(stat.Cancelled),
+			reportNumber(stat.Postponed),
+		})
+	}
+	return records
+}
+
+func contactGOStatRecords(stats []ContactGOStat) [][]string {
+	records := [][]string{{"Сфера деятельности", "Должность ГО", "Контактов"}}
+	for _, stat := range stats {
+		records = append(records, []string{
+			reportName(stat.ScopeName),
+			reportName(stat.PostGOName),
+			reportNumber(stat.Contacts),
+		})
+	}
+	return records
+}
+
+func sirenStatRecords(stats []SirenStat) [][]string {
+	records := [][]string{{"Этап", "Тип сирены", "Сирен", "Организаций"}}
+	for _, stat := range stats {
+		stage := reportName("")
+		if stat.Stage != 0 {
+			stage = reportNumber(stat.Stage)
+		}
+		records = append(records, []string{
+			stage,
+			reportName(stat.TypeName),
+			reportNumber(stat.Sirens),
+			reportNumber(stat.Companies),
+		})
+	}
+	return records
+}
+
+func educationStatRecords(stats []EducationStat) [][]string {
+	records := [][]string{{"Месяц", "Обучений", "Дней"}}
+	for _, stat := range stats {
+		records = append(records, []string{
+			reportMonth(stat.Year, stat.Month),
+			reportNumber(stat.Educations),
+			reportNumber(stat.Days),
+		})
+	}
+	return records
+}
+
+// GetReportRecords - get report by name as rows of strings with header in first row
+func (e *Edb) GetReportRecords(name string, filter ReportFilter) ([][]string, error) {
+	switch name {
+	case ReportCompanies:
+		stats, err := e.GetCompanyStats(filter)
+		return companyStatRecords(stats), err
+	case ReportPractices:
+		stats, err := e.GetPracticeStats(filter)
+		return practiceStatRecords(stats), err
+	case ReportContacts:
+		stats, err := e.GetContactGOStats(filter)
+		return contactGOStatRecords(stats), err
+	case ReportSirens:
+		stats, err := e.GetSirenStats(filter)
+		return sirenStatRecords(stats), err
+	case ReportEducations:
+		stats, err := e.GetEducationStats(filter)
+		return educationStatRecords(stats), err
+	}
+	return nil, ErrUnknownReport
+}
+
+// ExportReportCSV - write report by name as csv
+func (e *Edb) ExportReportCSV(w io.Writer, name string, filter ReportFilter) error {
+	records, err := e.GetReportRecords(name, filter)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	err = cw.WriteAll(records)
+	if err != nil {
+		log.Println("ExportReportCSV cw.WriteAll ", err)
+	}
+	return err
+}
+
+// ExportReportXLSX - write report by name as xlsx
+func (e *Edb) ExportReportXLSX(w io.Writer, name string, filter ReportFilter) error {
+	records, err := e.GetReportRecords(name, filter)
+	if err != nil {
+		return err
+	}
+	err = writeXLSX(w, reportTitles[name], records)
+	if err != nil {
+		log.Println("ExportReportXLSX writeXLSX ", err)
+	}
+	return err
+}
@@ -0,0 +1,39 @@
+package epgc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReportRecords(t *testing.T) {
+	records := practiceStatRecords([]PracticeStat{{KindName: "Тренировка", Year: 2026, Month: 3, Total: 2, Held: 1, Cancelled: 1}, {Year: 2026, Month: 11, Total: 1, Planned: 1}})
+	if len(records) != 3 || len(records[0]) != 7 {
+		t.Fatalf("practiceStatRecords = %v", records)
+	}
+	if records[1][0] != "Тренировка" || records[1][1] != "03.2026" || records[1][4] != "1" || records[2][0] != "Не указано" || records[2][1] != "11.2026" {
+		t.Errorf("practiceStatRecords = %v", records)
+	}
+	records = sirenStatRecords([]SirenStat{{Stage: 2, TypeName: "С-40", Sirens: 3, Companies: 2}, {TypeName: "С-28", Sirens: 1, Companies: 1}})
+	if records[1][0] != "2" || records[2][0] != "Не указано" || records[2][2] != "1" {
+		t.Errorf("sirenStatRecords = %v", records)
+	}
+	records = companyStatRecords(nil)
+	if len(records) != 1 || records[0][0] != "Сфера деятельности" {
+		t.Errorf("companyStatRecords(nil) = %v", records)
+	}
+}
+
+func TestReportXLSX(t *testing.T) {
+	records := educationStatRecords([]EducationStat{{Year: 2026, Month: 2, Educations: 3, Days: 25}})
+	var buf bytes.Buffer
+	if err := writeXLSX(&buf, reportTitles[ReportEducations], records); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readXLSX(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0][0] != "Месяц" || got[1][0] != "02.2026" || got[1][2] != "25" {
+		t.Errorf("readXLSX of report = %v", got)
+	}
+}
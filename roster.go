@@ -0,0 +1,856 @@
+package epgc
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DefaultShiftRest - rest in minutes required after shift without template or with template without MinRest
+const DefaultShiftRest = 12 * 60
+
+// Conflicts of roster
+const (
+	// ConflictOverlap - contact is on two shifts at the same time
+	ConflictOverlap = "overlap"
+	// ConflictRest - rest of contact between shifts is shorter than MinRest of previous shift
+	ConflictRest = "rest"
+)
+
+// ErrShiftTime - shift has no start or ends before start
+var ErrShiftTime = errors.New("shift must have start before end")
+
+// ErrShiftOverlap - contact already has shift at this time
+var ErrShiftOverlap = errors.New("contact already has shift at this time")
+
+// ShiftTemplate - template of shift, StartTime in "15:04" format, Duration and MinRest in minutes
+type ShiftTemplate struct {
+	ID        int64  `sql:"id" json:"id"`
+	Name      string `sql:"name" json:"name"`
+	StartTime string `sql:"start_time" json:"start_time"`
+	Duration  int64  `sql:"duration" json:"duration"`
+	MinRest   int64  `sql:"min_rest, null" json:"min_rest"`
+	Note      string `sql:"note, null" json:"note"`
+	CreatedAt string `sql:"created_at" json:"created_at"`
+	UpdatedAt string `sql:"updated_at" json:"updated_at"`
+}
+
+// Shift - duty of contact, times in "02.01.2006 15:04" format. Shift with template and without EndsAt
+// starts on date of StartsAt at StartTime of template and lasts Duration of template
+type Shift struct {
+	ID         int64  `sql:"id" json:"id"`
+	TemplateID int64  `sql:"template_id, null" json:"template_id"`
+	ContactID  int64  `sql:"contact_id" json:"contact_id"`
+	StartsAt   string `sql:"starts_at" json:"starts_at"`
+	EndsAt     string `sql:"ends_at" json:"ends_at"`
+	Note       string `sql:"note, null" json:"note"`
+	CreatedAt  string `sql:"created_at" json:"created_at"`
+	UpdatedAt  string `sql:"updated_at" json:"updated_at"`
+}
+
+// ShiftList - shift for roster with names of contact and template and phones of contact
+type ShiftList struct {
+	ID           int64   `json:"id"`
+	TemplateID   int64   `json:"template_id"`
+	TemplateName string  `json:"template_name"`
+	ContactID    int64   `json:"contact_id"`
+	ContactName  string  `json:"contact_name"`
+	StartsAt     string  `json:"starts_at"`
+	EndsAt       string  `json:"ends_at"`
+	Note         string  `json:"note"`
+	Phones       []int64 `json:"phones"`
+}
+
+// ShiftConflict - conflict of shift with other shift of same contact, Gap is rest between shifts in minutes
+type ShiftConflict struct {
+	Kind         string `json:"kind"`
+	ContactID    int64  `json:"contact_id"`
+	ShiftID      int64  `json:"shift_id"`
+	OtherShiftID int64  `json:"other_shift_id"`
+	Gap          int64  `json:"gap"`
+}
+
+// ShiftHandover - note of dispatcher for next shift with incidents open at handover
+type ShiftHandover struct {
+	ID          int64          `sql:"id" json:"id"`
+	ShiftID     int64          `sql:"shift_id" json:"shift_id"`
+	Note        string         `sql:"note, null" json:"note"`
+	IncidentIDs []int64        `sql:"-" json:"incident_ids"`
+	Incidents   []IncidentList `sql:"-" json:"incidents"`
+	CreatedAt   string         `sql:"created_at" json:"created_at"`
+	UpdatedAt   string         `sql:"updated_at" json:"updated_at"`
+}
+
+// shiftSpan - shift for conflict detection
+type shiftSpan struct {
+	id        int64
+	contactID int64
+	start     time.Time
+	end       time.Time
+	rest      time.Duration
+}
+
+// findShiftConflicts - overlapping shifts of same contact and too short rests after shifts
+func findShiftConflicts(spans []shiftSpan) []ShiftConflict {
+	sorted := append([]shiftSpan(nil), spans...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].contactID != sorted[j].contactID {
+			return sorted[i].contactID < sorted[j].contactID
+		}
+		return sorted[i].start.Before(sorted[j].start)
+	})
+	conflicts := []ShiftConflict{}
+	for i := 1; i < len(sorted); i++ {
+		cur := sorted[i]
+		// previous shift of contact ending last
+		var prev *shiftSpan
+		for j := i - 1; j >= 0 && sorted[j].contactID == cur.contactID; j-- {
+			if prev == nil || sorted[j].end.After(prev.end) {
+				prev = &sorted[j]
+			}
+		}
+		if prev == nil {
+			continue
+		}
+		gap := cur.start.Sub(prev.end)
+		switch {
+		case gap < 0:
+			conflicts = append(conflicts, ShiftConflict{Kind: ConflictOverlap, ContactID: cur.contactID, ShiftID: cur.id, OtherShiftID: prev.id})
+		case gap < prev.rest:
+			conflicts = append(conflicts, ShiftConflict{
+				Kind:         ConflictRest,
+				ContactID:    cur.contactID,
+				ShiftID:      cur.id,
+				OtherShiftID: prev.id,
+				Gap:          int64(gap / time.Minute),
+			})
+		}
+	}
+	return conflicts
+}
+
+func scanShiftTemplate(row *sql.Row) (ShiftTemplate, error) {
+	var (
+		sID        sql.NullInt64
+		sName      sql.NullString
+		sStartTime sql.NullString
+		sDuration  sql.NullInt64
+		sMinRest   sql.NullInt64
+		sNote      sql.NullString
+		template   ShiftTemplate
+	)
+	err := row.Scan(&sID, &sName, &sStartTime, &sDuration, &sMinRest, &sNote)
+	if err != nil {
+		log.Println("scanShiftTemplate row.Scan ", err)
+		return template, err
+	}
+	template.ID = n2i(sID)
+	template.Name = n2s(sName)
+	template.StartTime = n2s(sStartTime)
+	template.Duration = n2i(sDuration)
+	template.MinRest = n2i(sMinRest)
+	template.Note = n2s(sNote)
+	return template, nil
+}
+
+func scanShiftTemplates(rows *sql.Rows) ([]ShiftTemplate, error) {
+	var templates []ShiftTemplate
+	for rows.Next() {
+		var (
+			sID        sql.NullInt64
+			sName      sql.NullString
+			sStartTime sql.NullString
+			sDuration  sql.NullInt64
+			sMinRest   sql.NullInt64
+			sNote      sql.NullString
+			template   ShiftTemplate
+		)
+		err := rows.Scan(&sID, &sName, &sStartTime, &sDuration, &sMinRest, &sNote)
+		if err != nil {
+			log.Println("scanShiftTemplates rows.Scan ", err)
+			return templates, err
+		}
+		template.ID = n2i(sID)
+		template.Name = n2s(sName)
+		template.StartTime = n2s(sStartTime)
+		template.Duration = n2i(sDuration)
+		template.MinRest = n2i(sMinRest)
+		template.Note = n2s(sNote)
+		templates = append(templates, template)
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Println("scanShiftTemplates rows.Err ", err)
+	}
+	return templates, err
+}
+
+// GetShiftTemplate - get one shift template by id
+func (e *Edb) GetShiftTemplate(id int64) (ShiftTemplate, error) {
+	if id == 0 {
+		return ShiftTemplate{}, nil
+	}
+	row := e.db.QueryRow(`
+		SELECT
+			id,
+			name,
+			start_time,
+			duration,
+			min_rest,
+			note
+		FROM
+			shift_templates
+		WHERE
+			id = $1
+	`, id)
+	return scanShiftTemplate(row)
+}
+
+// GetShiftTemplateList - get all shift templates for list
+func (e *Edb) GetShiftTemplateList() ([]ShiftTemplate, error) {
+	rows, err := e.db.Query(`
+		SELECT
+			id,
+			name,
+			start_time,
+			duration,
+			min_rest,
+			note
+		FROM
+			shift_templates
+		ORDER BY
+			start_time ASC,
+			name ASC
+	`)
+	if err != nil {
+		log.Println("GetShiftTemplateList e.db.Query ", err)
+		return []ShiftTemplate{}, err
+	}
+	return scanShiftTemplates(rows)
+}
+
+func checkShiftTemplate(template ShiftTemplate) error {
+	_, err := time.Parse("15:04", template.StartTime)
+	if err != nil || template.Duration <= 0 {
+		return ErrShiftTime
+	}
+	return nil
+}
+
+// CreateShiftTemplate - create new shift template
+func (e *Edb) CreateShiftTemplate(template ShiftTemplate) (int64, error) {
+	err := checkShiftTemplate(template)
+	if err != nil {
+		log.Println("CreateShiftTemplate checkShiftTemplate ", err)
+		return 0, err
+	}
+	err = e.db.QueryRow(`
+		INSERT INTO
+			shift_templates (
+				name,
+				start_time,
+				duration,
+				min_rest,
+				note,
+				created_at
+			)
+		VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			now()
+		)
+		RETURNING
+			id
+	`, s2n(template.Name), template.StartTime, template.Duration, i2n(template.MinRest), s2n(template.Note)).Scan(&template.ID)
+	if err != nil {
+		log.Println("CreateShiftTemplate e.db.QueryRow ", err)
+		return 0, err
+	}
+	return template.ID, nil
+}
+
+// UpdateShiftTemplate - save shift template changes, existing shifts are not changed
+func (e *Edb) UpdateShiftTemplate(template ShiftTemplate) error {
+	err := checkShiftTemplate(template)
+	if err != nil {
+		log.Println("UpdateShiftTemplate checkShiftTemplate ", err)
+		return err
+	}
+	_, err = e.db.Exec(`
+		UPDATE
+			shift_templates
+		SET
+			name = $2,
+			start_time = $3,
+			duration = $4,
+			min_rest = $5,
+			note = $6,
+			updated_at = now()
+		WHERE
+			id = $1
+	`, i2n(template.ID), s2n(template.Name), template.StartTime, template.Duration, i2n(template.MinRest), s2n(template.Note))
+	if err != nil {
+		log.Println("UpdateShiftTemplate e.db.Exec ", err)
+	}
+	return err
+}
+
+// DeleteShiftTemplate - delete shift template by id, shifts keep their times
+func (e *Edb) DeleteShiftTemplate(id int64) error {
+	if id == 0 {
+		return nil
+	}
+	for _, query := range []string{
+		`UPDATE shifts SET template_id = NULL WHERE template_id = $1`,
+		`DELETE FROM shift_templates WHERE id = $1`,
+	} {
+		_, err := e.db.Exec(query, id)
+		if err != nil {
+			log.Println("DeleteShiftTemplate e.db.Exec ", id, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// shiftSpanOf - times of shift, times of template are used without EndsAt
+func (e *Edb) shiftSpanOf(shift Shift) (shiftSpan, error) {
+	span := shiftSpan{id: shift.ID, contactID: shift.ContactID, rest: DefaultShiftRest * time.Minute}
+	start := st2n(shift.StartsAt)
+	if !start.Valid {
+		return span, ErrShiftTime
+	}
+	span.start = start.Time
+	template, err := e.GetShiftTemplate(shift.TemplateID)
+	if err != nil {
+		return span, err
+	}
+	if template.MinRest > 0 {
+		span.rest = time.Duration(template.MinRest) * time.Minute
+	}
+	if end := st2n(shift.EndsAt); end.Valid {
+		span.end = end.Time
+	} else if template.ID != 0 {
+		at, _ := time.Parse("15:04", template.StartTime)
+		span.start = time.Date(span.start.Year(), span.start.Month(), span.start.Day(), at.Hour(), at.Minute(), 0, 0, time.UTC)
+		span.end = span.start.Add(time.Duration(template.Duration) * time.Minute)
+	}
+	if !span.end.After(span.start) {
+		return span, ErrShiftTime
+	}
+	return span, nil
+}
+
+// getShiftSpans - shifts of contact or of all contacts when contactID is zero, intersecting period
+func (e *Edb) getShiftSpans(contactID int64, from, to time.Time) ([]shiftSpan, error) {
+	rows, err := e.db.Query(`
+		SELECT
+			s.id,
+			s.contact_id,
+			s.starts_at,
+			s.ends_at,
+			COALESCE(t.min_rest, 0)
+		FROM
+			shifts AS s
+		LEFT JOIN
+			shift_templates AS t ON t.id = s.template_id
+		WHERE
+			($1 = 0 OR s.contact_id = $1) AND s.ends_at > $2::timestamp AND s.starts_at < $3::timestamp
+	`, contactID, from, to)
+	if err != nil {
+		log.Println("getShiftSpans e.db.Query ", err)
+		return nil, err
+	}
+	defer rows.Close()
+	var spans []shiftSpan
+	for rows.Next() {
+		var (
+			span    shiftSpan
+			minRest int64
+		)
+		err = rows.Scan(&span.id, &span.contactID, &span.start, &span.end, &minRest)
+		if err != nil {
+			log.Println("getShiftSpans rows.Scan ", err)
+			return nil, err
+		}
+		span.rest = DefaultShiftRest * time.Minute
+		if minRest > 0 {
+			span.rest = time.Duration(minRest) * time.Minute
+		}
+		spans = append(spans, span)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Println("getShiftSpans rows.Err ", err)
+	}
+	return spans, err
+}
+
+// CheckShift - conflicts of shift with other shifts of its contact
+func (e *Edb) CheckShift(shift Shift) ([]ShiftConflict, error) {
+	span, err := e.shiftSpanOf(shift)
+	if err != nil {
+		return []ShiftConflict{}, err
+	}
+	// new shift gets id which can not be in table
+	if span.id == 0 {
+		span.id = -1
+	}
+	window := 7 * 24 * time.Hour
+	others, err := e.getShiftSpans(shift.ContactID, span.start.Add(-window), span.end.Add(window))
+	if err != nil {
+		return []ShiftConflict{}, err
+	}
+	spans := []shiftSpan{span}
+	for _, other := range others {
+		if other.id != span.id {
+			spans = append(spans, other)
+		}
+	}
+	conflicts := []ShiftConflict{}
+	for _, conflict := range findShiftConflicts(spans) {
+		if conflict.ShiftID == span.id || conflict.OtherShiftID == span.id {
+			if conflict.ShiftID == -1 {
+				conflict.ShiftID = 0
+			}
+			if conflict.OtherShiftID == -1 {
+				conflict.OtherShiftID = 0
+			}
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	return conflicts, nil
+}
+
+// checkShiftOverlap - times of shift, ErrShiftOverlap when contact is on other shift at the same time
+func (e *Edb) checkShiftOverlap(shift Shift) (shiftSpan, error) {
+	span, err := e.shiftSpanOf(shift)
+	if err != nil {
+		return span, err
+	}
+	conflicts, err := e.CheckShift(shift)
+	if err != nil {
+		return span, err
+	}
+	for _, conflict := range conflicts {
+		if conflict.Kind == ConflictOverlap {
+			return span, ErrShiftOverlap
+		}
+	}
+	return span, nil
+}
+
+// CreateShift - create new shift, overlapping shifts of contact are rejected, short rests are allowed
+// and reported by CheckShift and GetRosterConflicts
+func (e *Edb) CreateShift(shift Shift) (int64, error) {
+	span, err := e.checkShiftOverlap(shift)
+	if err != nil {
+		log.Println("CreateShift checkShiftOverlap ", err)
+		return 0, err
+	}
+	err = e.db.QueryRow(`
+		INSERT INTO
+			shifts (
+				template_id,
+				contact_id,
+				starts_at,
+				ends_at,
+				note,
+				created_at
+			)
+		VALUES (
+			$1,
+			$2,
+			$3::timestamp,
+			$4::timestamp,
+			$5,
+			now()
+		)
+		RETURNING
+			id
+	`, i2n(shift.TemplateID), shift.ContactID, span.start, span.end, s2n(shift.Note)).Scan(&shift.ID)
+	if err != nil {
+		log.Println("CreateShift e.db.QueryRow ", err)
+		return 0, err
+	}
+	return shift.ID, nil
+}
+
+// UpdateShift - save shift changes, overlapping shifts of contact are rejected
+func (e *Edb) UpdateShift(shift Shift) error {
+	span, err := e.checkShiftOverlap(shift)
+	if err != nil {
+		log.Println("UpdateShift checkShiftOverlap ", err)
+		return err
+	}
+	_, err = e.db.Exec(`
+		UPDATE
+			shifts
+		SET
+			template_id = $2,
+			contact_id = $3,
+			starts_at = $4::timestamp,
+			ends_at = $5::timestamp,
+			note = $6,
+			updated_at = now()
+		WHERE
+			id = $1
+	`, i2n(shift.ID), i2n(shift.TemplateID), shift.ContactID, span.start, span.end, s2n(shift.Note))
+	if err != nil {
+		log.Println("UpdateShift e.db.Exec ", err)
+	}
+	return err
+}
+
+// DeleteShift - delete shift with its handover
+func (e *Edb) DeleteShift(id int64) error {
+	if id == 0 {
+		return nil
+	}
+	for _, query := range []string{
+		`DELETE FROM shift_handover_incidents WHERE handover_id IN (SELECT id FROM shift_handovers WHERE shift_id = $1)`,
+		`DELETE FROM shift_handovers WHERE shift_id = $1`,
+		`DELETE FROM shifts WHERE id = $1`,
+	} {
+		_, err := e.db.Exec(query, id)
+		if err != nil {
+			log.Println("DeleteShift e.db.Exec ", id, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteContactShifts - delete shifts of deleted contact which are not started yet
+func (e *Edb) deleteContactShifts(contactID int64) error {
+	_, err := e.db.Exec(`
+		DELETE FROM
+			shifts
+		WHERE
+			contact_id = $1 AND starts_at > localtimestamp
+	`, contactID)
+	if err != nil {
+		log.Println("deleteContactShifts e.db.Exec ", contactID, err)
+	}
+	return err
+}
+
+func scanShiftList(rows *sql.Rows) ([]ShiftList, error) {
+	shifts := []ShiftList{}
+	for rows.Next() {
+		var (
+			sID           sql.NullInt64
+			sTemplateID   sql.NullInt64
+			sTemplateName sql.NullString
+			sContactID    sql.NullInt64
+			sContactName  sql.NullString
+			sStartsAt     pq.NullTime
+			sEndsAt       pq.NullTime
+			sNote         sql.NullString
+			sPhones       pq.Int64Array
+			shift         ShiftList
+		)
+		err := rows.Scan(&sID, &sTemplateID, &sTemplateName, &sContactID, &sContactName, &sStartsAt, &sEndsAt, &sNote, &sPhones)
+		if err != nil {
+			log.Println("scanShiftList rows.Scan ", err)
+			return shifts, err
+		}
+		shift.ID = n2i(sID)
+		shift.TemplateID = n2i(sTemplateID)
+		shift.TemplateName = n2s(sTemplateName)
+		shift.ContactID = n2i(sContactID)
+		shift.ContactName = n2s(sContactName)
+		shift.StartsAt = n2stm(sStartsAt)
+		shift.EndsAt = n2stm(sEndsAt)
+		shift.Note = n2s(sNote)
+		shift.Phones = []int64(sPhones)
+		if shift.Phones == nil {
+			shift.Phones = []int64{}
+		}
+		shifts = append(shifts, shift)
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Println("scanShiftList rows.Err ", err)
+	}
+	return shifts, err
+}
+
+func (e *Edb) getShiftList(where string, args ...interface{}) ([]ShiftList, error) {
+	rows, err := e.db.Query(`
+		SELECT
+			s.id,
+			s.template_id,
+			t.name,
+			s.contact_id,
+			c.name,
+			s.starts_at,
+			s.ends_at,
+			s.note,
+			ARRAY(SELECT p.phone FROM phones AS p WHERE p.contact_id = s.contact_id AND NOT p.fax ORDER BY p.phone)
+		FROM
+			shifts AS s
+		LEFT JOIN
+			shift_templates AS t ON t.id = s.template_id
+		LEFT JOIN
+			contacts AS c ON c.id = s.contact_id
+		WHERE
+			`+where+`
+		ORDER BY
+			s.starts_at ASC,
+			c.name ASC
+	`, args...)
+	if err != nil {
+		log.Println("getShiftList e.db.Query ", err)
+		return []ShiftList{}, err
+	}
+	return scanShiftList(rows)
+}
+
+// GetRoster - get shifts intersecting period, From and To are dates in "02.01.2006" format and both are included
+func (e *Edb) GetRoster(from, to string) ([]ShiftList, error) {
+	return e.getShiftList(`s.ends_at > $1::date AND s.starts_at < $2::date + 1`, sd2n(from), sd2n(to))
+}
+
+// GetOnDuty - get shifts at time in "02.01.2006 15:04" format, now for empty time
+func (e *Edb) GetOnDuty(at string) ([]ShiftList, error) {
+	return e.getShiftList(`s.starts_at <= COALESCE($1::timestamp, localtimestamp) AND s.ends_at > COALESCE($1::timestamp, localtimestamp)`, st2n(at))
+}
+
+// GetRosterConflicts - get conflicts of shifts intersecting period, From and To are dates in "02.01.2006" format
+func (e *Edb) GetRosterConflicts(from, to string) ([]ShiftConflict, error) {
+	start, end := sd2n(from), sd2n(to)
+	if !start.Valid || !end.Valid {
+		return []ShiftConflict{}, ErrShiftTime
+	}
+	// rests before first shift of period depend on shifts before it
+	spans, err := e.getShiftSpans(0, start.Time.Add(-7*24*time.Hour), end.Time.AddDate(0, 0, 1))
+	if err != nil {
+		return []ShiftConflict{}, err
+	}
+	starts := make(map[int64]time.Time)
+	for _, span := range spans {
+		starts[span.id] = span.start
+	}
+	conflicts := []ShiftConflict{}
+	for _, conflict := range findShiftConflicts(spans) {
+		if !starts[conflict.ShiftID].Before(start.Time) {
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	return conflicts, nil
+}
+
+// SaveShiftHandover - save handover note of shift, handover without IncidentIDs gets incidents open now
+func (e *Edb) SaveShiftHandover(handover ShiftHandover) (int64, error) {
+	tx, err := e.db.Begin()
+	if err != nil {
+		log.Println("SaveShiftHandover e.db.Begin ", err)
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	err = tx.QueryRow(`
+		INSERT INTO
+			shift_handovers (
+				shift_id,
+				note,
+				created_at
+			)
+		VALUES (
+			$1,
+			$2,
+			now()
+		)
+		ON CONFLICT (shift_id) DO UPDATE SET
+			note = EXCLUDED.note,
+			updated_at = now()
+		RETURNING
+			id
+	`, handover.ShiftID, s2n(handover.Note)).Scan(&handover.ID)
+	if err != nil {
+		log.Println("SaveShiftHandover tx.QueryRow ", err)
+		return 0, err
+	}
+	_, err = tx.Exec(`DELETE FROM shift_handover_incidents WHERE handover_id = $1`, handover.ID)
+	if err != nil {
+		log.Println("SaveShiftHandover tx.Exec ", err)
+		return 0, err
+	}
+	if handover.IncidentIDs == nil {
+		_, err = tx.Exec(`
+			INSERT INTO
+				shift_handover_incidents (
+					handover_id,
+					incident_id
+				)
+			SELECT
+				$1,
+				id
+			FROM
+				incidents
+			WHERE
+				status = $2
+		`, handover.ID, IncidentOpen)
+	} else {
+		_, err = tx.Exec(`
+			INSERT INTO
+				shift_handover_incidents (
+					handover_id,
+					incident_id
+				)
+			SELECT DISTINCT
+				$1,
+				id
+			FROM
+				unnest($2::bigint[]) AS id
+		`, handover.ID, pq.Array(handover.IncidentIDs))
+	}
+	if err != nil {
+		log.Println("SaveShiftHandover tx.Exec ", err)
+		return 0, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Println("SaveShiftHandover tx.Commit ", err)
+		return 0, err
+	}
+	return handover.ID, nil
+}
+
+// getShiftHandover - handover by condition with its incidents in current state
+func (e *Edb) getShiftHandover(where string, args ...interface{}) (ShiftHandover, error) {
+	var (
+		sID        sql.NullInt64
+		sShiftID   sql.NullInt64
+		sNote      sql.NullString
+		sCreatedAt pq.NullTime
+		sUpdatedAt pq.NullTime
+		handover   ShiftHandover
+	)
+	err := e.db.QueryRow(`
+		SELECT
+			id,
+			shift_id,
+			note,
+			created_at,
+			updated_at
+		FROM
+			shift_handovers
+		WHERE
+			`+where+`
+		ORDER BY
+			created_at DESC
+		LIMIT 1
+	`, args...).Scan(&sID, &sShiftID, &sNote, &sCreatedAt, &sUpdatedAt)
+	if err != nil {
+		log.Println("getShiftHandover row.Scan ", err)
+		return handover, err
+	}
+	handover.ID = n2i(sID)
+	handover.ShiftID = n2i(sShiftID)
+	handover.Note = n2s(sNote)
+	handover.CreatedAt = n2stm(sCreatedAt)
+	handover.UpdatedAt = n2stm(sUpdatedAt)
+	rows, err := e.db.Query(`
+		SELECT
+			i.id,
+			i.received_at,
+			t.name,
+			i.severity,
+			i.status,
+			i.address,
+			i.description
+		FROM
+			shift_handover_incidents AS h
+		JOIN
+			incidents AS i ON i.id = h.incident_id
+		LEFT JOIN
+			incident_types AS t ON t.id = i.type_id
+		WHERE
+			h.handover_id = $1
+		ORDER BY
+			i.received_at ASC
+	`, handover.ID)
+	if err != nil {
+		log.Println("getShiftHandover e.db.Query ", err)
+		return handover, err
+	}
+	handover.Incidents, err = scanIncidentList(rows)
+	if handover.Incidents == nil {
+		handover.Incidents = []IncidentList{}
+	}
+	handover.IncidentIDs = []int64{}
+	for _, incident := range handover.Incidents {
+		handover.IncidentIDs = append(handover.IncidentIDs, incident.ID)
+	}
+	return handover, err
+}
+
+// GetShiftHandover - get handover of shift with its incidents
+func (e *Edb) GetShiftHandover(shiftID int64) (ShiftHandover, error) {
+	return e.getShiftHandover(`shift_id = $1`, shiftID)
+}
+
+// GetLastHandover - get latest handover, it is handover received by dispatchers on duty now
+func (e *Edb) GetLastHandover() (ShiftHandover, error) {
+	return e.getShiftHandover(`true`)
+}
+
+func (e *Edb) rosterCreateTable() error {
+	str := `
+		CREATE TABLE IF NOT EXISTS
+			shift_templates (
+				id bigserial primary key,
+				name text,
+				start_time text,
+				duration bigint,
+				min_rest bigint,
+				note text,
+				created_at TIMESTAMP without time zone,
+				updated_at TIMESTAMP without time zone,
+				UNIQUE(name)
+			);
+		CREATE TABLE IF NOT EXISTS
+			shifts (
+				id bigserial primary key,
+				template_id bigint,
+				contact_id bigint,
+				starts_at TIMESTAMP without time zone,
+				ends_at TIMESTAMP without time zone,
+				note text,
+				created_at TIMESTAMP without time zone,
+				updated_at TIMESTAMP without time zone
+			);
+		CREATE INDEX IF NOT EXISTS shifts_starts_at_idx ON shifts (starts_at, ends_at);
+		CREATE TABLE IF NOT EXISTS
+			shift_handovers (
+				id bigserial primary key,
+				shift_id bigint,
+				note text,
+				created_at TIMESTAMP without time zone,
+				updated_at TIMESTAMP without time zone,
+				UNIQUE(shift_id)
+			);
+		CREATE TABLE IF NOT EXISTS
+			shift_handover_incidents (
+				id bigserial primary key,
+				handover_id bigint,
+				incident_id bigint
+			);
+		CREATE INDEX IF NOT EXISTS shift_handover_incidents_handover_id_idx ON shift_handover_incidents (handover_id);
+	`
+	_, err := e.db.Exec(str)
+	if err != nil {
+		log.Println("rosterCreateTable e.db.Exec ", err)
+	}
+	return err
+}
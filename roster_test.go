@@ -0,0 +1,42 @@
+package epgc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFindShiftConflicts(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2026, 10, day, hour, 0, 0, 0, time.UTC)
+	}
+	rest := DefaultShiftRest * time.Minute
+	spans := []shiftSpan{
+		// contact 1: day shift, night shift after 4 hours of rest, shift inside night shift
+		{id: 1, contactID: 1, start: at(19, 8), end: at(19, 20), rest: rest},
+		{id: 2, contactID: 1, start: at(20, 0), end: at(20, 8), rest: rest},
+		{id: 3, contactID: 1, start: at(20, 2), end: at(20, 4), rest: rest},
+		// contact 2: day shifts with enough rest, then 24 hours shift with 72 hours rest
+		{id: 4, contactID: 2, start: at(19, 8), end: at(19, 20), rest: rest},
+		{id: 5, contactID: 2, start: at(20, 8), end: at(21, 8), rest: 72 * time.Hour},
+		{id: 6, contactID: 2, start: at(23, 8), end: at(23, 20), rest: rest},
+		// contact 3: other contact at the same time is not conflict
+		{id: 7, contactID: 3, start: at(20, 0), end: at(20, 8), rest: rest},
+	}
+	conflicts := findShiftConflicts(spans)
+	want := []ShiftConflict{
+		{Kind: ConflictRest, ContactID: 1, ShiftID: 2, OtherShiftID: 1, Gap: 240},
+		{Kind: ConflictOverlap, ContactID: 1, ShiftID: 3, OtherShiftID: 2},
+		{Kind: ConflictRest, ContactID: 2, ShiftID: 6, OtherShiftID: 5, Gap: 48 * 60},
+	}
+	if len(conflicts) != len(want) {
+		t.Fatalf("findShiftConflicts = %+v, want %+v", conflicts, want)
+	}
+	for i := range want {
+		if conflicts[i] != want[i] {
+			t.Errorf("findShiftConflicts[%d] = %+v, want %+v", i, conflicts[i], want[i])
+		}
+	}
+	if conflicts := findShiftConflicts(nil); len(conflicts) != 0 {
+		t.Errorf("findShiftConflicts(nil) = %+v", conflicts)
+	}
+}
@@ -87,8 +87,14 @@ func (e *Edb) GetScope(id int64) (Scope, error) {
 	if id == 0 {
 		return Scope{}, nil
 	}
+	if item, ok := e.cacheGet(cacheKey("scopes", id)); ok {
+		return item.(Scope), nil
+	}
 	row := e.db.QueryRow(`SELECT id, name, note FROM scopes WHERE id = $1`, id)
 	scope, err := scanScope(row)
+	if err == nil {
+		e.cacheSet(cacheKey("scopes", id), scope)
+	}
 	return scope, err
 }
 
@@ -105,40 +111,48 @@ func (e *Edb) GetScopeList() ([]Scope, error) {
 
 // GetScopeSelect - get all scope for select
 func (e *Edb) GetScopeSelect() ([]SelectItem, error) {
+	if items, ok := e.cacheSelect(cacheKey("scopes", "select")); ok {
+		return items, nil
+	}
 	rows, err := e.db.Query(`SELECT id, name FROM scopes ORDER BY name ASC`)
 	if err != nil {
 		log.Println("GetScopeSelect e.db.Query ", err)
 		return []SelectItem{}, err
 	}
 	scopes, err := scanScopesSelect(rows)
+	if err == nil {
+		e.cacheSetSelect(cacheKey("scopes", "select"), scopes)
+	}
 	return scopes, err
 }
 
 // CreateScope - create new scope
 func (e *Edb) CreateScope(scope Scope) (int64, error) {
-	stmt, err := e.db.Prepare(`INSERT INTO scopes(name, note, created_at) VALUES($1, $2, now()) RETURNING id`)
+	stmt, err := e.prepare(`INSERT INTO scopes(name, note, created_at) VALUES($1, $2, now()) RETURNING id`)
 	if err != nil {
-		log.Println("CreateScope e.db.Prepare ", err)
+		log.Println("CreateScope e.prepare ", err)
 		return 0, err
 	}
 	err = stmt.QueryRow(s2n(scope.Name), s2n(scope.Note)).Scan(&scope.ID)
 	if err != nil {
 		log.Println("CreateScope db.QueryRow ", err)
 	}
+	e.InvalidateCache("scopes")
 	return scope.ID, err
 }
 
 // UpdateScope - save scope changes
 func (e *Edb) UpdateScope(s Scope) error {
-	stmt, err := e.db.Prepare(`UPDATE scopes SET name=$2, note=$3, updated_at = now() WHERE id = $1`)
+	stmt, err := e.prepare(`UPDATE scopes SET name=$2, note=$3, updated_at = now() WHERE id = $1`)
 	if err != nil {
-		log.Println("UpdateScope e.db.Prepare ", err)
+		log.Println("UpdateScope e.prepare ", err)
 		return err
 	}
 	_, err = stmt.Exec(i2n(s.ID), s2n(s.Name), s2n(s.Note))
 	if err != nil {
 		log.Println("UpdateScope stmt.Exec ", err)
 	}
+	e.InvalidateCache("scopes")
 	return err
 }
 
@@ -151,6 +165,7 @@ func (e *Edb) DeleteScope(id int64) error {
 	if err != nil {
 		log.Println("DeleteScope e.db.Exec ", id, err)
 	}
+	e.InvalidateCache("scopes")
 	return err
 }
 
@@ -0,0 +1,296 @@
+package epgc
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// SeedNone - value of count in SeedOptions for no rows, zero count means default
+const SeedNone = -1
+
+// DefaultSeedYear - last year of practices by default, it is fixed so same Seed always gives same data
+const DefaultSeedYear = 2025
+
+// SeedOptions - volume of generated data, zero values are replaced by defaults, SeedNone (or any negative) count
+// creates no rows
+type SeedOptions struct {
+	Seed                 int64
+	Companies            int
+	ContactsPerCompany   int
+	PracticesPerCompany  int
+	SirensPerCompany     int
+	FirstYear, LastYear  int
+	Latitude, Longitude  float64
+	CoordinatesSpreadDeg float64
+}
+
+// SeedReport - number of created rows
+type SeedReport struct {
+	Scopes      int `json:"scopes"`
+	Departments int `json:"departments"`
+	Posts       int `json:"posts"`
+	Ranks       int `json:"ranks"`
+	Kinds       int `json:"kinds"`
+	SirenTypes  int `json:"siren_types"`
+	Companies   int `json:"companies"`
+	Contacts    int `json:"contacts"`
+	Practices   int `json:"practices"`
+	Sirens      int `json:"sirens"`
+}
+
+var (
+	seedScopes      = []string{"Промышленность", "Энергетика", "Транспорт", "Образование", "Здравоохранение", "Торговля", "Строительство", "ЖКХ", "Связь", "Сельское хозяйство"}
+	seedDepartments = []string{"Дирекция", "Бухгалтерия", "Отдел кадров", "Производственный отдел", "Служба охраны труда", "Хозяйственный отдел", "Диспетчерская служба"}
+	seedPosts       = []string{"Директор", "Главный инженер", "Главный бухгалтер", "Начальник отдела", "Специалист", "Инженер", "Диспетчер", "Заместитель директора"}
+	seedPostsGO     = []string{"Руководитель ГО", "Начальник штаба ГО", "Уполномоченный по делам ГОЧС", "Председатель КЧС", "Председатель эвакокомиссии"}
+	seedRanks       = []string{"Лейтенант", "Старший лейтенант", "Капитан", "Майор", "Подполковник", "Полковник"}
+	seedKinds       = []string{"Тренировка", "Командно-штабное учение", "Тактико-специальное учение", "Объектовая тренировка"}
+	seedSirenTypes  = []struct {
+		name   string
+		radius int64
+	}{{"С-40", 400}, {"С-28", 300}, {"П-166М", 1000}, {"МАСС", 700}}
+	seedCompanyForms = []string{"ООО", "АО", "ПАО", "МУП", "ГБУ", "ФГУП"}
+	seedCompanyNames = []string{"Ромашка", "Восход", "Энергия", "Север", "Прогресс", "Заря", "Магистраль", "Союз", "Горизонт", "Исток", "Монолит", "Импульс"}
+	seedStreets      = []string{"ул. Ленина", "ул. Советская", "ул. Мира", "пр. Победы", "ул. Гагарина", "ул. Садовая", "ул. Заводская", "ул. Школьная"}
+	seedCities       = []string{"г. Волгоград", "г. Волжский", "г. Камышин", "г. Михайловка", "р.п. Городище"}
+	seedSurnames     = []string{"Иванов", "Петров", "Сидоров", "Смирнов", "Кузнецов", "Попов", "Васильев", "Соколов", "Михайлов", "Новиков", "Фёдоров", "Морозов", "Волков", "Алексеев", "Лебедев", "Семёнов"}
+	seedMaleNames    = []string{"Александр", "Сергей", "Дмитрий", "Андрей", "Алексей", "Иван", "Михаил", "Николай", "Владимир", "Павел"}
+	seedFemaleNames  = []string{"Елена", "Ольга", "Наталья", "Татьяна", "Ирина", "Светлана", "Анна", "Мария", "Юлия", "Екатерина"}
+	seedPatronymics  = []struct {
+		male   string
+		female string
+	}{{"Александрович", "Александровна"}, {"Сергеевич", "Сергеевна"}, {"Дмитриевич", "Дмитриевна"}, {"Андреевич", "Андреевна"}, {"Алексеевич", "Алексеевна"}, {"Иванович", "Ивановна"}, {"Михайлович", "Михайловна"}, {"Николаевич", "Николаевна"}, {"Владимирович", "Владимировна"}, {"Павлович", "Павловна"}}
+	seedTopics = []string{"Эвакуация персонала при пожаре", "Действия при угрозе террористического акта", "Оповещение работников", "Ликвидация аварии на объекте", "Укрытие в защитных сооружениях"}
+)
+
+// seedCount - count or default for zero, negative count is zero
+func seedCount(count int, def int) int {
+	switch {
+	case count == 0:
+		return def
+	case count < 0:
+		return 0
+	}
+	return count
+}
+
+func (opt SeedOptions) withDefaults() SeedOptions {
+	opt.Companies = seedCount(opt.Companies, 50)
+	opt.ContactsPerCompany = seedCount(opt.ContactsPerCompany, 5)
+	opt.PracticesPerCompany = seedCount(opt.PracticesPerCompany, 3)
+	opt.SirensPerCompany = seedCount(opt.SirensPerCompany, 1)
+	if opt.LastYear == 0 {
+		opt.LastYear = DefaultSeedYear
+	}
+	if opt.FirstYear == 0 || opt.FirstYear > opt.LastYear {
+		opt.FirstYear = opt.LastYear - 5
+	}
+	if opt.Latitude == 0 && opt.Longitude == 0 {
+		opt.Latitude, opt.Longitude = 48.708, 44.513
+	}
+	if opt.CoordinatesSpreadDeg == 0 {
+		opt.CoordinatesSpreadDeg = 0.3
+	}
+	return opt
+}
+
+type seeder struct {
+	e      *Edb
+	opt    SeedOptions
+	rnd    *rand.Rand
+	report SeedReport
+	used   map[string]bool
+}
+
+func (s *seeder) pick(list []string) string {
+	return list[s.rnd.Intn(len(list))]
+}
+
+// unique - value not used before, number is added to repeated values
+func (s *seeder) unique(kind, value string) string {
+	result := value
+	for i := 2; s.used[kind+result]; i++ {
+		result = value + " " + strconv.Itoa(i)
+	}
+	s.used[kind+result] = true
+	return result
+}
+
+func (s *seeder) date(first, last int) string {
+	from := time.Date(first, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(last, time.December, 31, 0, 0, 0, 0, time.UTC)
+	days := int(to.Sub(from).Hours() / 24)
+	return from.AddDate(0, 0, s.rnd.Intn(days+1)).Format("02.01.2006")
+}
+
+func (s *seeder) phone() int64 {
+	return 8442000000 + s.rnd.Int63n(1000000)
+}
+
+func (s *seeder) address() string {
+	return fmt.Sprintf("%s, %s, д. %d", s.pick(seedCities), s.pick(seedStreets), 1+s.rnd.Intn(150))
+}
+
+func (s *seeder) person() string {
+	surname := s.pick(seedSurnames)
+	patronymic := seedPatronymics[s.rnd.Intn(len(seedPatronymics))]
+	if s.rnd.Intn(2) == 0 {
+		return surname + " " + s.pick(seedMaleNames) + " " + patronymic.male
+	}
+	return surname + "а " + s.pick(seedFemaleNames) + " " + patronymic.female
+}
+
+func (s *seeder) dictionary(names []string, create func(string) (int64, error)) ([]int64, error) {
+	var ids []int64
+	for _, name := range names {
+		id, err := create(name)
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (s *seeder) pickID(ids []int64) int64 {
+	return ids[s.rnd.Intn(len(ids))]
+}
+
+// Seed - fill empty database with realistic data, same Seed and volume give same data
+func (e *Edb) Seed(opt SeedOptions) (SeedReport, error) {
+	s := &seeder{e: e, opt: opt.withDefaults(), rnd: rand.New(rand.NewSource(opt.Seed)), used: make(map[string]bool)}
+	err := s.run()
+	if err != nil {
+		log.Println("Seed ", err)
+	}
+	return s.report, err
+}
+
+func (s *seeder) run() error {
+	e := s.e
+	scopes, err := s.dictionary(seedScopes, func(name string) (int64, error) {
+		return e.CreateScope(Scope{Name: name})
+	})
+	s.report.Scopes = len(scopes)
+	if err != nil {
+		return err
+	}
+	departments, err := s.dictionary(seedDepartments, func(name string) (int64, error) {
+		return e.CreateDepartment(Department{Name: name})
+	})
+	s.report.Departments = len(departments)
+	if err != nil {
+		return err
+	}
+	posts, err := s.dictionary(seedPosts, func(name string) (int64, error) {
+		return e.CreatePost(Post{Name: name})
+	})
+	s.report.Posts = len(posts)
+	if err != nil {
+		return err
+	}
+	postsGO, err := s.dictionary(seedPostsGO, func(name string) (int64, error) {
+		return e.CreatePost(Post{Name: name, GO: true})
+	})
+	s.report.Posts += len(postsGO)
+	if err != nil {
+		return err
+	}
+	ranks, err := s.dictionary(seedRanks, func(name string) (int64, error) {
+		return e.CreateRank(Rank{Name: name})
+	})
+	s.report.Ranks = len(ranks)
+	if err != nil {
+		return err
+	}
+	kinds, err := s.dictionary(seedKinds, func(name string) (int64, error) {
+		return e.CreateKind(Kind{Name: name})
+	})
+	s.report.Kinds = len(kinds)
+	if err != nil {
+		return err
+	}
+	var sirenTypes []int64
+	for _, sirenType := range seedSirenTypes {
+		id, err := e.CreateSirenType(SirenType{Name: sirenType.name, Radius: sirenType.radius})
+		if err != nil {
+			return err
+		}
+		sirenTypes = append(sirenTypes, id)
+	}
+	s.report.SirenTypes = len(sirenTypes)
+	var sirenNum int64
+	for i := 0; i < s.opt.Companies; i++ {
+		name := s.unique("company", s.pick(seedCompanyForms)+" «"+s.pick(seedCompanyNames)+"»")
+		domain := "company" + strconv.Itoa(i+1) + ".ru"
+		company := Company{
+			Name:    name,
+			Address: s.address(),
+			ScopeID: s.pickID(scopes),
+			Emails:  []Email{{Email: "info@" + domain}},
+			Phones:  []Phone{{Phone: s.phone()}},
+		}
+		if s.rnd.Intn(3) == 0 {
+			company.Faxes = []Phone{{Phone: s.phone()}}
+		}
+		company.ID, err = e.CreateCompany(company)
+		if err != nil {
+			return err
+		}
+		s.report.Companies++
+		for j := 0; j < s.opt.ContactsPerCompany; j++ {
+			contact := Contact{
+				Name:         s.unique("contact", s.person()),
+				CompanyID:    company.ID,
+				DepartmentID: s.pickID(departments),
+				PostID:       s.pickID(posts),
+				Birthday:     s.date(1955, 2000),
+				Emails:       []Email{{Email: "user" + strconv.Itoa(j+1) + "@" + domain}},
+				Phones:       []Phone{{Phone: s.phone()}},
+			}
+			// first contact of company is responsible for civil defence
+			if j == 0 {
+				contact.PostGOID = s.pickID(postsGO)
+				contact.RankID = s.pickID(ranks)
+			}
+			contact.ID, err = e.CreateContact(contact)
+			if err != nil {
+				return err
+			}
+			s.report.Contacts++
+		}
+		for j := 0; j < s.opt.PracticesPerCompany; j++ {
+			_, err = e.CreatePractice(Practice{
+				CompanyID:      company.ID,
+				KindID:         s.pickID(kinds),
+				Topic:          s.pick(seedTopics),
+				DateOfPractice: s.date(s.opt.FirstYear, s.opt.LastYear),
+			})
+			if err != nil {
+				return err
+			}
+			s.report.Practices++
+		}
+		for j := 0; j < s.opt.SirensPerCompany; j++ {
+			sirenNum++
+			_, err = e.CreateSiren(Siren{
+				NumID:     sirenNum,
+				TypeID:    s.pickID(sirenTypes),
+				CompanyID: company.ID,
+				Address:   company.Address,
+				Latitude:  strconv.FormatFloat(s.opt.Latitude+(s.rnd.Float64()*2-1)*s.opt.CoordinatesSpreadDeg, 'f', 6, 64),
+				Longitude: strconv.FormatFloat(s.opt.Longitude+(s.rnd.Float64()*2-1)*s.opt.CoordinatesSpreadDeg, 'f', 6, 64),
+				Stage:     int64(1 + s.rnd.Intn(3)),
+				Own:       name,
+			})
+			if err != nil {
+				return err
+			}
+			s.report.Sirens++
+		}
+	}
+	return nil
+}
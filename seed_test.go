@@ -0,0 +1,59 @@
+package epgc
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSeedOptionsDefaults(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  SeedOptions
+		want SeedOptions
+	}{
+		{
+			"defaults",
+			SeedOptions{},
+			SeedOptions{Companies: 50, ContactsPerCompany: 5, PracticesPerCompany: 3, SirensPerCompany: 1, FirstYear: DefaultSeedYear - 5, LastYear: DefaultSeedYear},
+		},
+		{
+			"no rows",
+			SeedOptions{Companies: 2, ContactsPerCompany: SeedNone, PracticesPerCompany: SeedNone, SirensPerCompany: -5},
+			SeedOptions{Companies: 2, FirstYear: DefaultSeedYear - 5, LastYear: DefaultSeedYear},
+		},
+		{
+			"years",
+			SeedOptions{Companies: 1, ContactsPerCompany: 1, PracticesPerCompany: 1, SirensPerCompany: 1, FirstYear: 2030, LastYear: 2020},
+			SeedOptions{Companies: 1, ContactsPerCompany: 1, PracticesPerCompany: 1, SirensPerCompany: 1, FirstYear: 2015, LastYear: 2020},
+		},
+	}
+	for _, tt := range tests {
+		got := tt.opt.withDefaults()
+		tt.want.Latitude, tt.want.Longitude, tt.want.CoordinatesSpreadDeg = 48.708, 44.513, 0.3
+		if got != tt.want {
+			t.Errorf("%s: withDefaults = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSeederSameSeed(t *testing.T) {
+	values := func(seed int64) []string {
+		s := &seeder{opt: SeedOptions{Seed: seed}.withDefaults(), rnd: rand.New(rand.NewSource(seed)), used: make(map[string]bool)}
+		var result []string
+		for i := 0; i < 20; i++ {
+			result = append(result, s.person(), s.address(), s.date(s.opt.FirstYear, s.opt.LastYear))
+		}
+		return result
+	}
+	first, second, other := values(3), values(3), values(4)
+	same := true
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("value %d = %q and %q with same seed", i, first[i], second[i])
+		}
+		same = same && first[i] == other[i]
+	}
+	if same {
+		t.Error("different seeds give same values")
+	}
+}
@@ -3,29 +3,31 @@ package epgc
 import (
 	"database/sql"
 	"log"
+	"strconv"
 )
 
 // Siren - struct for siren
 type Siren struct {
-	ID        int64     `sql:"id" json:"id"`
-	NumID     int64     `sql:"num_id, null" json:"num_id"`
-	NumPass   string    `sql:"num_pass, null" json:"num_pass"`
-	TypeID    int64     `sql:"type_id" json:"type_id"`
-	Type      SirenType `sql:"-"`
-	Address   string    `sql:"address, null" json:"address"`
-	Radio     string    `sql:"radio, null" json:"radio"`
-	Desk      string    `sql:"desk, null" json:"desk"`
-	ContactID int64     `sql:"contact_id, null" json:"contact_id"`
-	Contact   Contact   `sql:"-"`
-	CompanyID int64     `sql:"company_id, null" json:"company_id"`
-	Company   Company   `sql:"-"`
-	Latitude  string    `sql:"latitude, null" json:"latitude"`
-	Longitude string    `sql:"longitude, null" json:"longitude"`
-	Stage     int64     `sql:"stage, null" json:"stage"`
-	Own       string    `sql:"own, null" json:"own"`
-	Note      string    `sql:"note, null" json:"note"`
-	CreatedAt string    `sql:"created_at" json:"created_at"`
-	UpdatedAt string    `sql:"updated_at" json:"updated_at"`
+	ID           int64     `sql:"id" json:"id"`
+	NumID        int64     `sql:"num_id, null" json:"num_id"`
+	NumPass      string    `sql:"num_pass, null" json:"num_pass"`
+	TypeID       int64     `sql:"type_id" json:"type_id"`
+	Type         SirenType `sql:"-"`
+	Address      string    `sql:"address, null" json:"address"`
+	Radio        string    `sql:"radio, null" json:"radio"`
+	Desk         string    `sql:"desk, null" json:"desk"`
+	ContactID    int64     `sql:"contact_id, null" json:"contact_id"`
+	Contact      Contact   `sql:"-"`
+	CompanyID    int64     `sql:"company_id, null" json:"company_id"`
+	Company      Company   `sql:"-"`
+	Latitude     string    `sql:"latitude, null" json:"latitude"`
+	Longitude    string    `sql:"longitude, null" json:"longitude"`
+	Stage        int64     `sql:"stage, null" json:"stage"`
+	Own          string    `sql:"own, null" json:"own"`
+	Note         string    `sql:"note, null" json:"note"`
+	AddressParts Address   `sql:"-"`
+	CreatedAt    string    `sql:"created_at" json:"created_at"`
+	UpdatedAt    string    `sql:"updated_at" json:"updated_at"`
 }
 
 func scanSiren(row *sql.Row) (Siren, error) {
@@ -58,7 +60,7 @@ func scanSiren(row *sql.Row) (Siren, error) {
 	siren.Address = n2s(sAddress)
 	siren.Radio = n2s(sRadio)
 	siren.Desk = n2s(sDesk)
-	siren.CompanyID = n2i(sContactID)
+	siren.ContactID = n2i(sContactID)
 	siren.CompanyID = n2i(sCompanyID)
 	siren.Latitude = n2s(sLatitude)
 	siren.Longitude = n2s(sLongitude)
@@ -100,13 +102,14 @@ func scanSirensList(rows *sql.Rows) ([]Siren, error) {
 		siren.Address = n2s(sAddress)
 		siren.Radio = n2s(sRadio)
 		siren.Desk = n2s(sDesk)
-		siren.CompanyID = n2i(sContactID)
+		siren.ContactID = n2i(sContactID)
 		siren.CompanyID = n2i(sCompanyID)
 		siren.Latitude = n2s(sLatitude)
 		siren.Longitude = n2s(sLongitude)
 		siren.Stage = n2i(sStage)
 		siren.Own = n2s(sOwn)
 		siren.Note = n2s(sNote)
+		sirens = append(sirens, siren)
 	}
 	err := rows.Err()
 	if err != nil {
@@ -142,9 +145,25 @@ func (e *Edb) GetSiren(id int64) (Siren, error) {
 			id = $1
 	`, id)
 	siren, err := scanSiren(row)
+	if err != nil {
+		return siren, err
+	}
+	siren.AddressParts, err = e.GetSirenAddress(id)
 	return siren, err
 }
 
+// saveSirenAddress - save structured address of siren with coordinates of siren, coordinates of parts are used
+// only when siren has none
+func (e *Edb) saveSirenAddress(siren Siren) error {
+	address := siren.AddressParts
+	latitude, _ := strconv.ParseFloat(siren.Latitude, 64)
+	longitude, _ := strconv.ParseFloat(siren.Longitude, 64)
+	if latitude != 0 || longitude != 0 {
+		address.Latitude, address.Longitude = latitude, longitude
+	}
+	return e.saveAddress("siren_id", siren.ID, address, siren.Address)
+}
+
 // GetSirenList - get all siren for list
 func (e *Edb) GetSirenList() ([]Siren, error) {
 	rows, err := e.db.Query(`
@@ -166,7 +185,7 @@ func (e *Edb) GetSirenList() ([]Siren, error) {
 		FROM
 			sirens
 		ORDER BY
-			name ASC`)
+			num_id ASC`)
 	if err != nil {
 		log.Println("GetSirenList e.db.Query ", err)
 		return []Siren{}, err
@@ -177,7 +196,7 @@ func (e *Edb) GetSirenList() ([]Siren, error) {
 
 // CreateSiren - create new siren
 func (e *Edb) CreateSiren(siren Siren) (int64, error) {
-	stmt, err := e.db.Prepare(`
+	stmt, err := e.prepare(`
 		INSERT INTO
 			sirens (
 				num_id,
@@ -192,7 +211,7 @@ func (e *Edb) CreateSiren(siren Siren) (int64, error) {
 				longitude,
 				stage,
 				own,
-				note
+				note,
 				created_at
 			) VALUES (
 				$1,
@@ -214,7 +233,7 @@ func (e *Edb) CreateSiren(siren Siren) (int64, error) {
 			id
 	`)
 	if err != nil {
-		log.Println("CreateSiren e.db.Prepare ", err)
+		log.Println("CreateSiren e.prepare ", err)
 		return 0, err
 	}
 	err = stmt.QueryRow(
@@ -224,7 +243,7 @@ func (e *Edb) CreateSiren(siren Siren) (int64, error) {
 		s2n(siren.Address),
 		s2n(siren.Radio),
 		s2n(siren.Desk),
-		i2n(siren.CompanyID),
+		i2n(siren.ContactID),
 		i2n(siren.CompanyID),
 		s2n(siren.Latitude),
 		s2n(siren.Longitude),
@@ -233,13 +252,15 @@ func (e *Edb) CreateSiren(siren Siren) (int64, error) {
 		s2n(siren.Note)).Scan(&siren.ID)
 	if err != nil {
 		log.Println("CreateSiren db.QueryRow ", err)
+		return siren.ID, err
 	}
+	_ = e.saveSirenAddress(siren)
 	return siren.ID, err
 }
 
 // UpdateSiren - save siren changes
 func (e *Edb) UpdateSiren(siren Siren) error {
-	stmt, err := e.db.Prepare(`
+	stmt, err := e.prepare(`
 		UPDATE
 			sirens
 		SET
@@ -261,7 +282,7 @@ func (e *Edb) UpdateSiren(siren Siren) error {
 			id = $1
 	`)
 	if err != nil {
-		log.Println("UpdateSiren e.db.Prepare ", err)
+		log.Println("UpdateSiren e.prepare ", err)
 		return err
 	}
 	_, err = stmt.Exec(
@@ -272,7 +293,7 @@ func (e *Edb) UpdateSiren(siren Siren) error {
 		s2n(siren.Address),
 		s2n(siren.Radio),
 		s2n(siren.Desk),
-		i2n(siren.CompanyID),
+		i2n(siren.ContactID),
 		i2n(siren.CompanyID),
 		s2n(siren.Latitude),
 		s2n(siren.Longitude),
@@ -281,7 +302,9 @@ func (e *Edb) UpdateSiren(siren Siren) error {
 		s2n(siren.Note))
 	if err != nil {
 		log.Println("UpdateSiren stmt.Exec ", err)
+		return err
 	}
+	_ = e.saveSirenAddress(siren)
 	return err
 }
 
@@ -290,6 +313,7 @@ func (e *Edb) DeleteSiren(id int64) error {
 	if id == 0 {
 		return nil
 	}
+	_ = e.deleteAddress("siren_id", id)
 	_, err := e.db.Exec(`
 		DELETE FROM
 			sirens
@@ -319,6 +343,7 @@ func (e *Edb) sirenCreateTable() error {
 				longitude  text,
 				stage      bigint,
 				own        text,
+				note       text,
 				created_at TIMESTAMP without time zone,
 				updated_at TIMESTAMP without time zone,
 				UNIQUE(num_id, num_pass, type_id)
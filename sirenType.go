@@ -92,6 +92,9 @@ func (e *Edb) GetSirenType(id int64) (SirenType, error) {
 	if id == 0 {
 		return SirenType{}, nil
 	}
+	if item, ok := e.cacheGet(cacheKey("sirentypes", id)); ok {
+		return item.(SirenType), nil
+	}
 	row := e.db.QueryRow(`
 		SELECT
 			id,
@@ -104,6 +107,9 @@ func (e *Edb) GetSirenType(id int64) (SirenType, error) {
 			id = $1
 	`, id)
 	sirenType, err := scanSirenType(row)
+	if err == nil {
+		e.cacheSet(cacheKey("sirentypes", id), sirenType)
+	}
 	return sirenType, err
 }
 
@@ -130,6 +136,9 @@ func (e *Edb) GetSirenTypeList() ([]SirenType, error) {
 
 // GetSirenTypeSelect - get all sirenType for select
 func (e *Edb) GetSirenTypeSelect() ([]SelectItem, error) {
+	if items, ok := e.cacheSelect(cacheKey("sirentypes", "select")); ok {
+		return items, nil
+	}
 	rows, err := e.db.Query(`
 		SELECT
 			id,
@@ -143,12 +152,15 @@ func (e *Edb) GetSirenTypeSelect() ([]SelectItem, error) {
 		return []SelectItem{}, err
 	}
 	sirenTypes, err := scanSirenTypesSelect(rows)
+	if err == nil {
+		e.cacheSetSelect(cacheKey("sirentypes", "select"), sirenTypes)
+	}
 	return sirenTypes, err
 }
 
 // CreateSirenType - create new sirenType
 func (e *Edb) CreateSirenType(sirenType SirenType) (int64, error) {
-	stmt, err := e.db.Prepare(`
+	stmt, err := e.prepare(`
 		INSERT INTO
 			sirenTypes (
 				name,
@@ -165,20 +177,21 @@ func (e *Edb) CreateSirenType(sirenType SirenType) (int64, error) {
 			id
 	`)
 	if err != nil {
-		log.Println("CreateSirenType e.db.Prepare ", err)
+		log.Println("CreateSirenType e.prepare ", err)
 		return 0, err
 	}
-	err = stmt.QueryRow(s2n(sirenType.Name), s2n(sirenType.Note)).Scan(&sirenType.ID)
+	err = stmt.QueryRow(s2n(sirenType.Name), i2n(sirenType.Radius), s2n(sirenType.Note)).Scan(&sirenType.ID)
 	if err != nil {
 		log.Println("CreateSirenType db.QueryRow ", err)
 		return 0, err
 	}
+	e.InvalidateCache("sirentypes")
 	return sirenType.ID, nil
 }
 
 // UpdateSirenType - save sirenType changes
 func (e *Edb) UpdateSirenType(s SirenType) error {
-	stmt, err := e.db.Prepare(`
+	stmt, err := e.prepare(`
 		UPDATE
 			sirenTypes
 		SET
@@ -189,13 +202,14 @@ func (e *Edb) UpdateSirenType(s SirenType) error {
 		WHERE
 			id = $1`)
 	if err != nil {
-		log.Println("UpdateSirenType e.db.Prepare ", err)
+		log.Println("UpdateSirenType e.prepare ", err)
 		return err
 	}
-	_, err = stmt.Exec(i2n(s.ID), s2n(s.Name), s2n(s.Note))
+	_, err = stmt.Exec(i2n(s.ID), s2n(s.Name), i2n(s.Radius), s2n(s.Note))
 	if err != nil {
 		log.Println("UpdateSirenType stmt.Exec ", err)
 	}
+	e.InvalidateCache("sirentypes")
 	return err
 }
 
@@ -213,6 +227,7 @@ func (e *Edb) DeleteSirenType(id int64) error {
 	if err != nil {
 		log.Println("DeleteSirenType e.db.Exec ", id, err)
 	}
+	e.InvalidateCache("sirentypes")
 	return err
 }
 
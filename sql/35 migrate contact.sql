@@ -0,0 +1,5 @@
+ALTER TABLE contacts ADD COLUMN surname text;
+ALTER TABLE contacts ADD COLUMN first_name text;
+ALTER TABLE contacts ADD COLUMN patronymic text;
+CREATE INDEX contacts_surname_idx ON contacts (surname, first_name, patronymic);
+-- existing names are split by Edb.SplitContactNames()
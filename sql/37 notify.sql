@@ -0,0 +1,145 @@
+CREATE OR REPLACE FUNCTION epgc_notify_change() RETURNS trigger AS $$
+DECLARE
+    rec record;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        rec := OLD;
+    ELSE
+        rec := NEW;
+    END IF;
+    PERFORM pg_notify('epgc_changes', json_build_object('entity', TG_TABLE_NAME, 'id', rec.id, 'operation', TG_OP)::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- existing triggers are kept, dropping them takes exclusive lock on every table. Triggers of tables added later
+-- are created by Edb.Open()
+DO $$
+BEGIN
+    CREATE TRIGGER companies_notify_change
+        AFTER INSERT OR UPDATE OR DELETE ON companies
+        FOR EACH ROW EXECUTE PROCEDURE epgc_notify_change();
+EXCEPTION WHEN duplicate_object THEN
+    NULL;
+END
+$$;
+
+DO $$
+BEGIN
+    CREATE TRIGGER contacts_notify_change
+        AFTER INSERT OR UPDATE OR DELETE ON contacts
+        FOR EACH ROW EXECUTE PROCEDURE epgc_notify_change();
+EXCEPTION WHEN duplicate_object THEN
+    NULL;
+END
+$$;
+
+DO $$
+BEGIN
+    CREATE TRIGGER departments_notify_change
+        AFTER INSERT OR UPDATE OR DELETE ON departments
+        FOR EACH ROW EXECUTE PROCEDURE epgc_notify_change();
+EXCEPTION WHEN duplicate_object THEN
+    NULL;
+END
+$$;
+
+DO $$
+BEGIN
+    CREATE TRIGGER educations_notify_change
+        AFTER INSERT OR UPDATE OR DELETE ON educations
+        FOR EACH ROW EXECUTE PROCEDURE epgc_notify_change();
+EXCEPTION WHEN duplicate_object THEN
+    NULL;
+END
+$$;
+
+DO $$
+BEGIN
+    CREATE TRIGGER emails_notify_change
+        AFTER INSERT OR UPDATE OR DELETE ON emails
+        FOR EACH ROW EXECUTE PROCEDURE epgc_notify_change();
+EXCEPTION WHEN duplicate_object THEN
+    NULL;
+END
+$$;
+
+DO $$
+BEGIN
+    CREATE TRIGGER kinds_notify_change
+        AFTER INSERT OR UPDATE OR DELETE ON kinds
+        FOR EACH ROW EXECUTE PROCEDURE epgc_notify_change();
+EXCEPTION WHEN duplicate_object THEN
+    NULL;
+END
+$$;
+
+DO $$
+BEGIN
+    CREATE TRIGGER phones_notify_change
+        AFTER INSERT OR UPDATE OR DELETE ON phones
+        FOR EACH ROW EXECUTE PROCEDURE epgc_notify_change();
+EXCEPTION WHEN duplicate_object THEN
+    NULL;
+END
+$$;
+
+DO $$
+BEGIN
+    CREATE TRIGGER posts_notify_change
+        AFTER INSERT OR UPDATE OR DELETE ON posts
+        FOR EACH ROW EXECUTE PROCEDURE epgc_notify_change();
+EXCEPTION WHEN duplicate_object THEN
+    NULL;
+END
+$$;
+
+DO $$
+BEGIN
+    CREATE TRIGGER practices_notify_change
+        AFTER INSERT OR UPDATE OR DELETE ON practices
+        FOR EACH ROW EXECUTE PROCEDURE epgc_notify_change();
+EXCEPTION WHEN duplicate_object THEN
+    NULL;
+END
+$$;
+
+DO $$
+BEGIN
+    CREATE TRIGGER ranks_notify_change
+        AFTER INSERT OR UPDATE OR DELETE ON ranks
+        FOR EACH ROW EXECUTE PROCEDURE epgc_notify_change();
+EXCEPTION WHEN duplicate_object THEN
+    NULL;
+END
+$$;
+
+DO $$
+BEGIN
+    CREATE TRIGGER scopes_notify_change
+        AFTER INSERT OR UPDATE OR DELETE ON scopes
+        FOR EACH ROW EXECUTE PROCEDURE epgc_notify_change();
+EXCEPTION WHEN duplicate_object THEN
+    NULL;
+END
+$$;
+
+DO $$
+BEGIN
+    CREATE TRIGGER sirens_notify_change
+        AFTER INSERT OR UPDATE OR DELETE ON sirens
+        FOR EACH ROW EXECUTE PROCEDURE epgc_notify_change();
+EXCEPTION WHEN duplicate_object THEN
+    NULL;
+END
+$$;
+
+DO $$
+BEGIN
+    CREATE TRIGGER sirentypes_notify_change
+        AFTER INSERT OR UPDATE OR DELETE ON sirentypes
+        FOR EACH ROW EXECUTE PROCEDURE epgc_notify_change();
+EXCEPTION WHEN duplicate_object THEN
+    NULL;
+END
+$$;
@@ -0,0 +1,2 @@
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS parent_id BIGINT;
+CREATE INDEX IF NOT EXISTS companies_parent_id_idx ON companies (parent_id);
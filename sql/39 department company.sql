@@ -0,0 +1,5 @@
+ALTER TABLE departments ADD COLUMN IF NOT EXISTS company_id bigint;
+ALTER TABLE departments ADD COLUMN IF NOT EXISTS parent_id bigint;
+ALTER TABLE departments DROP CONSTRAINT IF EXISTS departments_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS departments_name_idx ON departments (COALESCE(company_id, 0), COALESCE(parent_id, 0), name);
+CREATE INDEX IF NOT EXISTS departments_parent_id_idx ON departments (parent_id);
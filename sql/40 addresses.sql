@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS addresses (
+    id bigserial primary key,
+    company_id bigint,
+    siren_id bigint,
+    postal_code text,
+    region text,
+    district text,
+    settlement text,
+    street text,
+    house text,
+    building text,
+    other text,
+    latitude double precision,
+    longitude double precision,
+    created_at TIMESTAMP without time zone,
+    updated_at TIMESTAMP without time zone
+);
+CREATE INDEX IF NOT EXISTS addresses_company_id_idx ON addresses (company_id);
+CREATE INDEX IF NOT EXISTS addresses_siren_id_idx ON addresses (siren_id);
+CREATE INDEX IF NOT EXISTS addresses_district_idx ON addresses (district);
+
+-- structured addresses of existing companies and sirens are parsed by Edb.ParseAllAddresses()
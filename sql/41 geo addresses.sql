@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS geo_addresses (
+    id bigserial primary key,
+    source text,
+    postal_code text,
+    region text,
+    district text,
+    settlement text,
+    street text,
+    house text,
+    building text,
+    latitude double precision,
+    longitude double precision,
+    district_key text,
+    settlement_key text,
+    street_key text,
+    house_key text,
+    building_key text
+);
+CREATE INDEX IF NOT EXISTS geo_addresses_key_idx ON geo_addresses (settlement_key, street_key, house_key);
+-- registry is loaded by Edb.ImportGeoRegistry(), addresses are resolved by Edb.GeocodeAll()
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS notification_lists (
+    id bigserial primary key,
+    name text,
+    note text,
+    created_at TIMESTAMP without time zone,
+    updated_at TIMESTAMP without time zone,
+    UNIQUE (name)
+);
+CREATE TABLE IF NOT EXISTS notification_members (
+    id bigserial primary key,
+    list_id bigint,
+    contact_id bigint,
+    company_id bigint,
+    priority bigint,
+    channel text,
+    escalate_after bigint,
+    created_at TIMESTAMP without time zone,
+    updated_at TIMESTAMP without time zone
+);
+CREATE INDEX IF NOT EXISTS notification_members_list_id_idx ON notification_members (list_id);
+CREATE TABLE IF NOT EXISTS call_tree_nodes (
+    id bigserial primary key,
+    list_id bigint,
+    parent_id bigint,
+    contact_id bigint,
+    company_id bigint,
+    channel text,
+    created_at TIMESTAMP without time zone,
+    updated_at TIMESTAMP without time zone
+);
+CREATE INDEX IF NOT EXISTS call_tree_nodes_list_id_idx ON call_tree_nodes (list_id);
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS dispatches (
+    id bigserial primary key,
+    list_id bigint,
+    subject text,
+    body text,
+    status text,
+    finished_at TIMESTAMP without time zone,
+    created_at TIMESTAMP without time zone,
+    updated_at TIMESTAMP without time zone
+);
+CREATE TABLE IF NOT EXISTS dispatch_deliveries (
+    id bigserial primary key,
+    dispatch_id bigint,
+    contact_id bigint,
+    company_id bigint,
+    name text,
+    channel text,
+    address text,
+    status text,
+    attempts bigint,
+    last_error text,
+    provider_id text,
+    ack_token text,
+    next_attempt_at TIMESTAMP without time zone,
+    sent_at TIMESTAMP without time zone,
+    acknowledged_at TIMESTAMP without time zone,
+    created_at TIMESTAMP without time zone,
+    updated_at TIMESTAMP without time zone,
+    UNIQUE (ack_token)
+);
+CREATE INDEX IF NOT EXISTS dispatch_deliveries_dispatch_id_idx ON dispatch_deliveries (dispatch_id, status);
@@ -0,0 +1,43 @@
+CREATE TABLE IF NOT EXISTS incident_types (
+    id bigserial primary key,
+    name text,
+    note text,
+    created_at TIMESTAMP without time zone,
+    updated_at TIMESTAMP without time zone,
+    UNIQUE(name)
+);
+CREATE TABLE IF NOT EXISTS incidents (
+    id bigserial primary key,
+    received_at TIMESTAMP without time zone,
+    source text,
+    type_id bigint,
+    severity bigint,
+    status text,
+    address text,
+    latitude double precision,
+    longitude double precision,
+    description text,
+    result text,
+    closed_at TIMESTAMP without time zone,
+    note text,
+    created_at TIMESTAMP without time zone,
+    updated_at TIMESTAMP without time zone
+);
+CREATE INDEX IF NOT EXISTS incidents_received_at_idx ON incidents (received_at);
+CREATE TABLE IF NOT EXISTS incident_participants (
+    id bigserial primary key,
+    incident_id bigint,
+    company_id bigint,
+    contact_id bigint
+);
+CREATE INDEX IF NOT EXISTS incident_participants_incident_id_idx ON incident_participants (incident_id);
+CREATE TABLE IF NOT EXISTS incident_actions (
+    id bigserial primary key,
+    incident_id bigint,
+    action_at TIMESTAMP without time zone,
+    contact_id bigint,
+    description text,
+    created_at TIMESTAMP without time zone,
+    updated_at TIMESTAMP without time zone
+);
+CREATE INDEX IF NOT EXISTS incident_actions_incident_id_idx ON incident_actions (incident_id);
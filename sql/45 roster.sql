@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS shift_templates (
+    id bigserial primary key,
+    name text,
+    start_time text,
+    duration bigint,
+    min_rest bigint,
+    note text,
+    created_at TIMESTAMP without time zone,
+    updated_at TIMESTAMP without time zone,
+    UNIQUE(name)
+);
+CREATE TABLE IF NOT EXISTS shifts (
+    id bigserial primary key,
+    template_id bigint,
+    contact_id bigint,
+    starts_at TIMESTAMP without time zone,
+    ends_at TIMESTAMP without time zone,
+    note text,
+    created_at TIMESTAMP without time zone,
+    updated_at TIMESTAMP without time zone
+);
+CREATE INDEX IF NOT EXISTS shifts_starts_at_idx ON shifts (starts_at, ends_at);
+CREATE TABLE IF NOT EXISTS shift_handovers (
+    id bigserial primary key,
+    shift_id bigint,
+    note text,
+    created_at TIMESTAMP without time zone,
+    updated_at TIMESTAMP without time zone,
+    UNIQUE(shift_id)
+);
+CREATE TABLE IF NOT EXISTS shift_handover_incidents (
+    id bigserial primary key,
+    handover_id bigint,
+    incident_id bigint
+);
+CREATE INDEX IF NOT EXISTS shift_handover_incidents_handover_id_idx ON shift_handover_incidents (handover_id);
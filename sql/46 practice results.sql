@@ -0,0 +1,24 @@
+ALTER TABLE practices ADD COLUMN IF NOT EXISTS status text;
+ALTER TABLE practices ADD COLUMN IF NOT EXISTS held_date date;
+ALTER TABLE practices ADD COLUMN IF NOT EXISTS score bigint;
+ALTER TABLE practices ADD COLUMN IF NOT EXISTS result text;
+ALTER TABLE practices ADD COLUMN IF NOT EXISTS remarks text;
+CREATE TABLE IF NOT EXISTS practice_participants (
+    id bigserial primary key,
+    practice_id bigint,
+    company_id bigint,
+    contact_id bigint
+);
+CREATE INDEX IF NOT EXISTS practice_participants_practice_id_idx ON practice_participants (practice_id);
+CREATE TABLE IF NOT EXISTS practice_documents (
+    id bigserial primary key,
+    practice_id bigint,
+    name text,
+    content_type text,
+    size bigint,
+    data bytea,
+    note text,
+    created_at TIMESTAMP without time zone,
+    updated_at TIMESTAMP without time zone
+);
+CREATE INDEX IF NOT EXISTS practice_documents_practice_id_idx ON practice_documents (practice_id);
@@ -0,0 +1,5 @@
+ALTER TABLE addresses ADD COLUMN IF NOT EXISTS source text;
+
+-- text of address the parts were saved from, parts are parsed again when text is edited
+UPDATE addresses AS a SET source = c.address FROM companies AS c WHERE a.source IS NULL AND a.company_id = c.id;
+UPDATE addresses AS a SET source = s.address FROM sirens AS s WHERE a.source IS NULL AND a.siren_id = s.id;
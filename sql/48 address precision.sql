@@ -0,0 +1,3 @@
+ALTER TABLE addresses ADD COLUMN IF NOT EXISTS precision text;
+
+-- precision of coordinates found by Edb.GeocodeAll(): house, street or settlement
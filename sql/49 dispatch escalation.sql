@@ -0,0 +1,6 @@
+ALTER TABLE dispatch_deliveries ADD COLUMN IF NOT EXISTS priority bigint;
+ALTER TABLE dispatch_deliveries ADD COLUMN IF NOT EXISTS escalate_after bigint;
+ALTER TABLE dispatch_deliveries ADD COLUMN IF NOT EXISTS escalate_at TIMESTAMP without time zone;
+
+-- priority and escalate_after are copied from notification_members, escalate_at is set on first attempt
+-- and delivery with higher priority waits for it
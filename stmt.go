@@ -0,0 +1,193 @@
+package epgc
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/lib/pq"
+)
+
+// stmtCache - prepared statements of Edb keyed by query
+type stmtCache struct {
+	sync.Mutex
+	stmts map[string]*cachedStmt
+}
+
+// cachedStmt - prepared statement with number of calls using it, stale statement is removed from cache at once
+// and closed when last call using it is finished
+type cachedStmt struct {
+	stmt  *sql.Stmt
+	refs  int
+	stale bool
+}
+
+// preparedStmt - statement prepared once per Edb. database/sql prepares it again on every new connection
+// of pool, so statement survives reconnect. Exec, Query and QueryRow also prepare statement again when server
+// lost it on same connection, like after DISCARD ALL in pgbouncer or change of table.
+type preparedStmt struct {
+	e     *Edb
+	query string
+}
+
+// isStaleStmt - errors of invalid prepared statement: invalid_sql_statement_name and feature_not_supported
+// with "cached plan must not change result type"
+func isStaleStmt(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	if !ok {
+		return false
+	}
+	return pqErr.Code == "26000" || pqErr.Code == "0A000" && strings.Contains(pqErr.Message, "cached plan must not change result type")
+}
+
+// cached - statement of query from cache, statement is prepared when it is missing, must be called with lock
+func (e *Edb) cached(query string) (*cachedStmt, error) {
+	cs, ok := e.stmts.stmts[query]
+	if ok {
+		return cs, nil
+	}
+	stmt, err := e.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	if e.stmts.stmts == nil {
+		e.stmts.stmts = make(map[string]*cachedStmt)
+	}
+	cs = &cachedStmt{stmt: stmt}
+	e.stmts.stmts[query] = cs
+	return cs, nil
+}
+
+// prepare - get prepared statement for query, statement is prepared on first call
+func (e *Edb) prepare(query string) (*preparedStmt, error) {
+	e.stmts.Lock()
+	defer e.stmts.Unlock()
+	_, err := e.cached(query)
+	if err != nil {
+		return nil, err
+	}
+	return &preparedStmt{e: e, query: query}, nil
+}
+
+// acquire - current statement of query, it is not closed until release
+func (s *preparedStmt) acquire() (*cachedStmt, error) {
+	s.e.stmts.Lock()
+	defer s.e.stmts.Unlock()
+	cs, err := s.e.cached(s.query)
+	if err != nil {
+		return nil, err
+	}
+	cs.refs++
+	return cs, nil
+}
+
+// release - finish use of statement, stale statement is closed by last user
+func (s *preparedStmt) release(cs *cachedStmt) {
+	s.e.stmts.Lock()
+	defer s.e.stmts.Unlock()
+	cs.refs--
+	if cs.stale && cs.refs == 0 {
+		_ = cs.stmt.Close()
+	}
+}
+
+// reprepare - replace stale statement in cache with new one, stale statement is released and closed
+// after calls still using it
+func (s *preparedStmt) reprepare(stale *cachedStmt) (*cachedStmt, error) {
+	s.e.stmts.Lock()
+	if cs, ok := s.e.stmts.stmts[s.query]; ok && cs == stale {
+		delete(s.e.stmts.stmts, s.query)
+		stale.stale = true
+	}
+	s.e.stmts.Unlock()
+	fresh, err := s.acquire()
+	if err != nil {
+		log.Println("preparedStmt reprepare ", err)
+		return nil, err
+	}
+	s.release(stale)
+	return fresh, nil
+}
+
+// call - run f with statement, prepare statement again and run f once more when it is stale
+func (s *preparedStmt) call(f func(*sql.Stmt) error) error {
+	cs, err := s.acquire()
+	if err != nil {
+		return err
+	}
+	err = f(cs.stmt)
+	if isStaleStmt(err) {
+		fresh, rerr := s.reprepare(cs)
+		if rerr == nil {
+			cs = fresh
+			err = f(cs.stmt)
+		}
+	}
+	s.release(cs)
+	return err
+}
+
+// Exec - execute statement, prepare it again once when it is stale
+func (s *preparedStmt) Exec(args ...interface{}) (sql.Result, error) {
+	done := s.e.db.start(s.query, args)
+	var result sql.Result
+	err := s.call(func(stmt *sql.Stmt) error {
+		var err error
+		result, err = stmt.Exec(args...)
+		return err
+	})
+	done(err)
+	return result, err
+}
+
+// Query - run query, prepare it again once when it is stale
+func (s *preparedStmt) Query(args ...interface{}) (*sql.Rows, error) {
+	done := s.e.db.start(s.query, args)
+	var rows *sql.Rows
+	err := s.call(func(stmt *sql.Stmt) error {
+		var err error
+		rows, err = stmt.Query(args...)
+		return err
+	})
+	done(err)
+	return rows, err
+}
+
+// QueryRow - run query for one row, prepare it again once when it is stale
+func (s *preparedStmt) QueryRow(args ...interface{}) *sql.Row {
+	done := s.e.db.start(s.query, args)
+	var row *sql.Row
+	err := s.call(func(stmt *sql.Stmt) error {
+		row = stmt.QueryRow(args...)
+		return row.Err()
+	})
+	if row == nil {
+		// statement can not be prepared, error of query is returned by Scan
+		row = s.e.db.DB.QueryRow(s.query, args...)
+		err = row.Err()
+	}
+	done(err)
+	return row
+}
+
+// Close - close all prepared statements and database
+func (e *Edb) Close() error {
+	e.stmts.Lock()
+	for query, cs := range e.stmts.stmts {
+		err := cs.stmt.Close()
+		if err != nil {
+			log.Println("Close stmt.Close ", err)
+		}
+		delete(e.stmts.stmts, query)
+	}
+	e.stmts.Unlock()
+	if e.db == nil {
+		return nil
+	}
+	err := e.db.Close()
+	if err != nil {
+		log.Println("Close e.db.Close ", err)
+	}
+	return err
+}
@@ -0,0 +1,370 @@
+package epgc
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+// staleDriver - driver which fails first calls of statements with stale plan error
+type staleDriver struct {
+	sync.Mutex
+	fails    int
+	prepares int
+	closes   int
+}
+
+type staleConn struct {
+	d *staleDriver
+}
+
+type staleStmt struct {
+	d *staleDriver
+}
+
+type staleRows struct {
+	done bool
+}
+
+var testStaleDriver = &staleDriver{}
+
+func init() {
+	sql.Register("epgcstale", testStaleDriver)
+}
+
+func (d *staleDriver) Open(string) (driver.Conn, error) {
+	return staleConn{d: d}, nil
+}
+
+func (d *staleDriver) fail() error {
+	d.Lock()
+	defer d.Unlock()
+	if d.fails == 0 {
+		return nil
+	}
+	d.fails--
+	return &pq.Error{Code: "0A000", Message: "cached plan must not change result type"}
+}
+
+func (c staleConn) Prepare(string) (driver.Stmt, error) {
+	c.d.Lock()
+	c.d.prepares++
+	c.d.Unlock()
+	return staleStmt{d: c.d}, nil
+}
+
+func (c staleConn) Close() error {
+	return nil
+}
+
+func (c staleConn) Begin() (driver.Tx, error) {
+	return staleTx{}, nil
+}
+
+type staleTx struct{}
+
+func (staleTx) Commit() error {
+	return nil
+}
+
+func (staleTx) Rollback() error {
+	return nil
+}
+
+func (s staleStmt) Close() error {
+	s.d.Lock()
+	s.d.closes++
+	s.d.Unlock()
+	return nil
+}
+
+func (s staleStmt) NumInput() int {
+	return -1
+}
+
+func (s staleStmt) Exec([]driver.Value) (driver.Result, error) {
+	if err := s.d.fail(); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s staleStmt) Query([]driver.Value) (driver.Rows, error) {
+	if err := s.d.fail(); err != nil {
+		return nil, err
+	}
+	return &staleRows{}, nil
+}
+
+func (r *staleRows) Columns() []string {
+	return []string{"id"}
+}
+
+func (r *staleRows) Close() error {
+	return nil
+}
+
+func (r *staleRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+func staleEdb(t *testing.T) (*Edb, *staleDriver) {
+	db, err := sql.Open("epgcstale", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	d := testStaleDriver
+	d.Lock()
+	d.fails, d.prepares, d.closes = 0, 0, 0
+	d.Unlock()
+	e := &Edb{db: &instrumentedDB{DB: db}}
+	t.Cleanup(func() {
+		_ = e.Close()
+	})
+	return e, d
+}
+
+func TestIsStaleStmt(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("cached plan must not change result type"), false},
+		{&pq.Error{Code: "26000", Message: "prepared statement \"1\" does not exist"}, true},
+		{&pq.Error{Code: "0A000", Message: "cached plan must not change result type"}, true},
+		{&pq.Error{Code: "0A000", Message: "cannot use window function in check constraint"}, false},
+		{&pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint"}, false},
+	}
+	for _, tt := range tests {
+		if got := isStaleStmt(tt.err); got != tt.want {
+			t.Errorf("isStaleStmt(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestPreparedStmtStale(t *testing.T) {
+	e, d := staleEdb(t)
+	stmt, err := e.prepare(`SELECT id FROM contacts`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := []struct {
+		name string
+		call func() error
+	}{
+		{"Exec", func() error {
+			_, err := stmt.Exec()
+			return err
+		}},
+		{"Query", func() error {
+			rows, err := stmt.Query()
+			if err != nil {
+				return err
+			}
+			return rows.Close()
+		}},
+		{"QueryRow", func() error {
+			var id int64
+			return stmt.QueryRow().Scan(&id)
+		}},
+	}
+	for i, c := range calls {
+		d.Lock()
+		d.fails = 1
+		d.Unlock()
+		if err := c.call(); err != nil {
+			t.Errorf("%s with stale statement: %v", c.name, err)
+		}
+		d.Lock()
+		if d.prepares != i+2 || d.closes != i+1 {
+			t.Errorf("%s: prepares = %d, closes = %d, want %d and %d", c.name, d.prepares, d.closes, i+2, i+1)
+		}
+		d.Unlock()
+	}
+}
+
+func TestPreparedStmtCloseAfterUse(t *testing.T) {
+	e, d := staleEdb(t)
+	stmt, err := e.prepare(`SELECT id FROM contacts`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// first call still uses statement when second call finds it stale
+	first, err := stmt.acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := stmt.acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh, err := stmt.reprepare(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fresh == first || e.stmts.stmts[stmt.query] != fresh {
+		t.Fatal("stale statement is not replaced in cache")
+	}
+	d.Lock()
+	closes := d.closes
+	d.Unlock()
+	if closes != 0 {
+		t.Errorf("stale statement closed while in use, closes = %d", closes)
+	}
+	_, err = first.stmt.Exec()
+	if err != nil {
+		t.Errorf("Exec of stale statement in use: %v", err)
+	}
+	stmt.release(first)
+	stmt.release(fresh)
+	d.Lock()
+	closes = d.closes
+	d.Unlock()
+	if closes != 1 {
+		t.Errorf("closes = %d after last use of stale statement, want 1", closes)
+	}
+}
+
+// benchEdb - Edb on database from EPGC_TEST_DSN, benchmark is skipped without it
+func benchEdb(b *testing.B) *Edb {
+	dsn := os.Getenv("EPGC_TEST_DSN")
+	if dsn == "" {
+		b.Skip("EPGC_TEST_DSN is not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	e := &Edb{db: &instrumentedDB{DB: db}, dsn: dsn}
+	err = e.createAllTables()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		_ = e.Close()
+	})
+	return e
+}
+
+func benchContact(b *testing.B, e *Edb) int64 {
+	id, err := e.CreateContact(Contact{Name: "Бенчмарков Тест " + strconv.Itoa(b.N)})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		_ = e.DeleteContact(id)
+	})
+	return id
+}
+
+const benchContactQuery = `
+	SELECT
+		id,
+		name,
+		company_id,
+		department_id,
+		post_id,
+		post_go_id,
+		rank_id,
+		birthday,
+		note
+	FROM
+		contacts
+	WHERE
+		id = $1
+`
+
+// BenchmarkPrepareEachCall - old behaviour: prepare and close statement on every call
+func BenchmarkPrepareEachCall(b *testing.B) {
+	e := benchEdb(b)
+	id := benchContact(b, e)
+	var name sql.NullString
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stmt, err := e.db.Prepare(benchContactQuery)
+		if err != nil {
+			b.Fatal(err)
+		}
+		err = stmt.QueryRow(id).Scan(new(sql.NullInt64), &name, new(sql.NullInt64), new(sql.NullInt64), new(sql.NullInt64), new(sql.NullInt64), new(sql.NullInt64), new(sql.NullString), new(sql.NullString))
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = stmt.Close()
+	}
+}
+
+// BenchmarkPreparedReuse - statement prepared once per Edb
+func BenchmarkPreparedReuse(b *testing.B) {
+	e := benchEdb(b)
+	id := benchContact(b, e)
+	var name sql.NullString
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stmt, err := e.prepare(benchContactQuery)
+		if err != nil {
+			b.Fatal(err)
+		}
+		err = stmt.QueryRow(id).Scan(new(sql.NullInt64), &name, new(sql.NullInt64), new(sql.NullInt64), new(sql.NullInt64), new(sql.NullInt64), new(sql.NullInt64), new(sql.NullString), new(sql.NullString))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetContact(b *testing.B) {
+	e := benchEdb(b)
+	id := benchContact(b, e)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := e.GetContact(id)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetCompany(b *testing.B) {
+	e := benchEdb(b)
+	id, err := e.CreateCompany(Company{Name: "Бенчмарк " + strconv.Itoa(b.N)})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		_ = e.DeleteCompany(id)
+	})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := e.GetCompany(id)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCreateContact(b *testing.B) {
+	e := benchEdb(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		id, err := e.CreateContact(Contact{Name: "Бенчмарков Создан " + strconv.Itoa(i)})
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StopTimer()
+		_ = e.DeleteContact(id)
+		b.StartTimer()
+	}
+}
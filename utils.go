@@ -75,6 +75,9 @@ func n2s(val sql.NullString) string {
 }
 
 func n2as(val sql.NullString) []string {
+	if val.String == "" {
+		return []string{}
+	}
 	return strings.Split(val.String, ",")
 }
 
@@ -106,6 +109,14 @@ func n2sd(val pq.NullTime) string {
 	return str
 }
 
+func n2st(val pq.NullTime) string {
+	var str string
+	if val.Valid {
+		str = val.Time.Format("02.01.2006 15:04:05")
+	}
+	return str
+}
+
 func n2emails(emails sql.NullString) []Email {
 	var (
 		e  string
@@ -235,7 +246,7 @@ func setStrMonth(d string) string {
 	}
 	str := t.Format("02.01.2006")
 	spl := strings.Split(str, ".")
-	month := map[string]string{"01": "января", "02": "февраля", "03": "марта", "04": "апреля", "05": "мая", "06": "июня", "07": "июля", "08": "августа", "09": "сентября", "10": "октября", "11": "ноября", "12": "декабря "}
+	month := map[string]string{"01": "января", "02": "февраля", "03": "марта", "04": "апреля", "05": "мая", "06": "июня", "07": "июля", "08": "августа", "09": "сентября", "10": "октября", "11": "ноября", "12": "декабря"}
 	result = spl[0] + " " + month[spl[1]] + " " + spl[2] + " года"
 	return result
 }
@@ -0,0 +1,127 @@
+package epgc
+
+import (
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var vCardEscaper = strings.NewReplacer(`\`, `\\`, `,`, `\,`, `;`, `\;`, "\r\n", `\n`, "\n", `\n`)
+
+func vCardEscape(val string) string {
+	return vCardEscaper.Replace(val)
+}
+
+// vCardFold - fold content line to 75 octets without breaking utf-8 sequences
+func vCardFold(line string) string {
+	var (
+		b     strings.Builder
+		count int
+	)
+	for _, r := range line {
+		size := utf8.RuneLen(r)
+		if count+size > 75 {
+			b.WriteString("\r\n ")
+			count = 1
+		}
+		b.WriteRune(r)
+		count += size
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// vCardName - structured name value: family;given;additional;prefix;suffix
+func vCardName(name PersonName) string {
+	return vCardEscape(name.Surname) + ";" + vCardEscape(name.FirstName) + ";" + vCardEscape(name.Patronymic) + ";;"
+}
+
+// VCard - contact as vCard 4.0, Company, Department and Post must be filled to be included
+func (c Contact) VCard() string {
+	var b strings.Builder
+	b.WriteString("BEGIN:VCARD\r\n")
+	b.WriteString("VERSION:4.0\r\n")
+	b.WriteString(vCardFold("UID:urn:epgc:contact:" + strconv.FormatInt(c.ID, 10)))
+	b.WriteString(vCardFold("FN:" + vCardEscape(c.Name)))
+	b.WriteString(vCardFold("N:" + vCardName(c.PersonName())))
+	if c.Company.Name != "" {
+		org := vCardEscape(c.Company.Name)
+		if c.Department.Name != "" {
+			org += ";" + vCardEscape(c.Department.Name)
+		}
+		b.WriteString(vCardFold("ORG:" + org))
+	}
+	if c.Post.Name != "" {
+		b.WriteString(vCardFold("TITLE:" + vCardEscape(c.Post.Name)))
+	}
+	if c.PostGO.Name != "" {
+		b.WriteString(vCardFold("ROLE:" + vCardEscape(c.PostGO.Name)))
+	}
+	for _, phone := range c.Phones {
+		b.WriteString(vCardFold("TEL;VALUE=text;TYPE=work,voice:" + strconv.FormatInt(phone.Phone, 10)))
+	}
+	for _, fax := range c.Faxes {
+		b.WriteString(vCardFold("TEL;VALUE=text;TYPE=work,fax:" + strconv.FormatInt(fax.Phone, 10)))
+	}
+	for _, email := range c.Emails {
+		b.WriteString(vCardFold("EMAIL;TYPE=work:" + vCardEscape(email.Email)))
+	}
+	if t, err := time.Parse("02.01.2006", c.Birthday); err == nil {
+		b.WriteString("BDAY:" + t.Format("20060102") + "\r\n")
+	}
+	if c.Note != "" {
+		b.WriteString(vCardFold("NOTE:" + vCardEscape(c.Note)))
+	}
+	b.WriteString("END:VCARD\r\n")
+	return b.String()
+}
+
+// GetContactVCard - get one contact by id as vCard
+func (e *Edb) GetContactVCard(id int64) (string, error) {
+	contacts, err := e.getContactsFull(`c.id = $1`, id)
+	if err != nil || len(contacts) == 0 {
+		return "", err
+	}
+	return contacts[0].VCard(), nil
+}
+
+func writeVCards(w io.Writer, contacts []Contact) error {
+	for _, contact := range contacts {
+		_, err := io.WriteString(w, contact.VCard())
+		if err != nil {
+			log.Println("writeVCards io.WriteString ", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportCompanyVCF - write all contacts of company as vcf
+func (e *Edb) ExportCompanyVCF(w io.Writer, id int64) error {
+	contacts, err := e.getContactsFull(`c.company_id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return writeVCards(w, contacts)
+}
+
+// ExportScopeVCF - write all contacts of companies in scope as vcf
+func (e *Edb) ExportScopeVCF(w io.Writer, id int64) error {
+	contacts, err := e.getContactsFull(`co.scope_id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return writeVCards(w, contacts)
+}
+
+// ExportVCF - write all contacts as vcf
+func (e *Edb) ExportVCF(w io.Writer) error {
+	contacts, err := e.getContactsFull(`true`)
+	if err != nil {
+		return err
+	}
+	return writeVCards(w, contacts)
+}
@@ -0,0 +1,258 @@
+package epgc
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+)
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxSharedStrings struct {
+	Items []struct {
+		T string `xml:"t"`
+		R []struct {
+			T string `xml:"t"`
+		} `xml:"r"`
+	} `xml:"si"`
+}
+
+type xlsxSheetData struct {
+	Rows []struct {
+		Ref   int `xml:"r,attr"`
+		Cells []struct {
+			Ref    string `xml:"r,attr"`
+			Type   string `xml:"t,attr"`
+			Value  string `xml:"v"`
+			Inline struct {
+				T string `xml:"t"`
+			} `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func xlsxReadXML(files map[string]*zip.File, name string, v interface{}) error {
+	f, ok := files[name]
+	if !ok {
+		return errors.New("xlsx: missing " + name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(rc).Decode(v)
+}
+
+// xlsxColumn - convert cell reference like "AB12" to zero based column index
+func xlsxColumn(ref string) int {
+	col := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A') + 1
+	}
+	return col - 1
+}
+
+// xlsxNumber - drop float notation from integer values, so phones like 7.9001234567E10 stay readable
+func xlsxNumber(val string) string {
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil || f != float64(int64(f)) {
+		return val
+	}
+	return strconv.FormatInt(int64(f), 10)
+}
+
+// readXLSX - read all rows of first worksheet as strings, rows skipped in sheet are returned empty,
+// so index of record is number of row in sheet minus one
+func readXLSX(r io.ReaderAt, size int64) ([][]string, error) {
+	var (
+		workbook xlsxWorkbook
+		rels     xlsxRelationships
+		shared   xlsxSharedStrings
+		sheet    xlsxSheetData
+		strs     []string
+		records  [][]string
+	)
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		log.Println("readXLSX zip.NewReader ", err)
+		return records, err
+	}
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	sheetName := "xl/worksheets/sheet1.xml"
+	if xlsxReadXML(files, "xl/workbook.xml", &workbook) == nil && len(workbook.Sheets) > 0 &&
+		xlsxReadXML(files, "xl/_rels/workbook.xml.rels", &rels) == nil {
+		for _, rel := range rels.Relationships {
+			if rel.ID == workbook.Sheets[0].RID {
+				if strings.HasPrefix(rel.Target, "/") {
+					sheetName = strings.TrimPrefix(rel.Target, "/")
+				} else {
+					sheetName = path.Join("xl", rel.Target)
+				}
+			}
+		}
+	}
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		err = xlsxReadXML(files, "xl/sharedStrings.xml", &shared)
+		if err != nil {
+			log.Println("readXLSX sharedStrings ", err)
+			return records, err
+		}
+		for _, si := range shared.Items {
+			str := si.T
+			for _, run := range si.R {
+				str += run.T
+			}
+			strs = append(strs, str)
+		}
+	}
+	err = xlsxReadXML(files, sheetName, &sheet)
+	if err != nil {
+		log.Println("readXLSX sheet ", err)
+		return records, err
+	}
+	for _, row := range sheet.Rows {
+		for row.Ref > len(records)+1 {
+			records = append(records, []string{})
+		}
+		var record []string
+		for i, cell := range row.Cells {
+			col := i
+			if cell.Ref != "" {
+				col = xlsxColumn(cell.Ref)
+			}
+			for len(record) <= col {
+				record = append(record, "")
+			}
+			switch cell.Type {
+			case "s":
+				idx, err := strconv.Atoi(cell.Value)
+				if err == nil && idx >= 0 && idx < len(strs) {
+					record[col] = strs[idx]
+				}
+			case "inlineStr":
+				record[col] = cell.Inline.T
+			case "str", "b", "e":
+				record[col] = cell.Value
+			default:
+				record[col] = xlsxNumber(cell.Value)
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// xlsxColumnName - convert zero based column index to letters like "AB"
+func xlsxColumnName(col int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name
+}
+
+func xmlEscape(val string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(val))
+	return buf.String()
+}
+
+// maxXLSXSheetName - max length of sheet name in excel
+const maxXLSXSheetName = 31
+
+// xlsxSheetName - name of sheet without characters forbidden by excel and cut to 31 characters
+func xlsxSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.Trim(strings.TrimSpace(name), "'")
+	if runes := []rune(name); len(runes) > maxXLSXSheetName {
+		name = strings.TrimSpace(string(runes[:maxXLSXSheetName]))
+	}
+	if name == "" {
+		return "Sheet1"
+	}
+	return name
+}
+
+// writeXLSX - write rows as strings to single sheet xlsx
+func writeXLSX(w io.Writer, sheetName string, records [][]string) error {
+	var sheet bytes.Buffer
+	sheet.WriteString(xml.Header)
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for i, record := range records {
+		sheet.WriteString(`<row r="` + strconv.Itoa(i+1) + `">`)
+		for j, val := range record {
+			if val == "" {
+				continue
+			}
+			sheet.WriteString(`<c r="` + xlsxColumnName(j) + strconv.Itoa(i+1) + `" t="inlineStr"><is><t xml:space="preserve">`)
+			sheet.WriteString(xmlEscape(val))
+			sheet.WriteString(`</t></is></c>`)
+		}
+		sheet.WriteString(`</row>`)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+	files := []struct {
+		name string
+		body string
+	}{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="` + xmlEscape(xlsxSheetName(sheetName)) + `" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`},
+		{"xl/worksheets/sheet1.xml", sheet.String()},
+	}
+	zw := zip.NewWriter(w)
+	for _, file := range files {
+		f, err := zw.Create(file.name)
+		if err != nil {
+			log.Println("writeXLSX zw.Create ", err)
+			return err
+		}
+		_, err = io.WriteString(f, file.body)
+		if err != nil {
+			log.Println("writeXLSX io.WriteString ", err)
+			return err
+		}
+	}
+	return zw.Close()
+}